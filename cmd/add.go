@@ -16,7 +16,12 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/kjnsn/tim/lib"
 	"github.com/kjnsn/tim/lib/message"
@@ -32,77 +37,322 @@ var addCmd = &cobra.Command{
 Plugins are github URLs of the format <username>/<repo>.
 
 So "add user123/my-cool-plugin" installs github.com/user123/my-cool-plugin.
+Plugins found with "tim search" can be added by just their repository
+name, such as "add my-cool-plugin".
+
+Plugins hosted elsewhere can be added with a full https or ssh clone URL,
+for example "add https://gitlab.com/user123/my-cool-plugin.git".
+
+A plugin in a subdirectory of a repository is added by its path after
+"//", such as "add user123/tmux-plugins//plugins/theme". Its releases
+are the tags prefixed with the path, such as "plugins/theme/v1.2.0".
 
 The repository will be scanned for releases and tags,
 and the latest installed by default.
 
+Pass "--path" to add a plugin you are developing from a local directory,
+such as "add --path ~/src/my-plugin". It is linked into the plugin
+directory and loaded from there, is never upgraded, and is named
+local/<directory> unless a name is given.
+
+Before a new plugin is added, including when syncing the configuration
+file, the scripts it runs when tmux loads it are listed, and nothing is
+added unless they are approved. Pass "--yes" to skip this, which also
+happens when there is no terminal to ask on.
+
 If no plugin names are given, then plugins are installed according to the
-configuration file ~/.config/tim/tim.json`,
+configuration file ~/.config/tim/tim.json. Pass "--retry-failed" to only
+install the plugins that failed the last time.`,
 	Args: cobra.MaximumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if addPath != "" {
+			name := ""
+			if len(args) > 0 {
+				name = args[0]
+			}
+			return addLocalPlugin(cmd.Context(), name)
+		}
 		if len(args) > 0 {
-			addPlugin(strings.ToLower(strings.TrimSpace(args[0])))
-		} else {
-			syncPlugins()
+			if addRetryFailed {
+				return fmt.Errorf("--retry-failed retries syncing the config file, and can't be given a plugin")
+			}
+			return addPlugin(cmd.Context(), args[0])
 		}
+		return syncPlugins(cmd.Context())
 	},
 }
 
 var (
-	versionSpec string
+	versionSpec    string
+	addJobs        int
+	addRetryFailed bool
+	addPath        string
+	addYes         bool
 )
 
 func init() {
 	rootCmd.AddCommand(addCmd)
 	addCmd.Flags().StringVar(&versionSpec, "version", "",
 		"Version to use. Only semver 2.0 compliant strings and branch names are supported.")
+	addCmd.Flags().IntVarP(&addJobs, "jobs", "j", defaultJobs,
+		"Number of plugins to install concurrently when syncing, one per CPU by default.")
+	addCmd.Flags().BoolVar(&addRetryFailed, "retry-failed", false,
+		"Only install the plugins that failed to install when the config file was last synced.")
+	addCmd.Flags().StringVar(&addPath, "path", "",
+		"Load the plugin from this local directory, rather than cloning it.")
+	addCmd.Flags().BoolVarP(&addYes, "yes", "y", false,
+		"Add new plugins without reviewing the scripts they run.")
+	addCmd.MarkFlagDirname("path")
+	addCmd.MarkFlagsMutuallyExclusive("path", "version")
+	addCmd.MarkFlagsMutuallyExclusive("path", "retry-failed")
+	addCmd.RegisterFlagCompletionFunc("version", completeVersionSpec)
 }
 
-func syncPlugins() {
-	lockFile, err := lib.GetLockfile(cfgFile)
+func syncPlugins(ctx context.Context) error {
+	lockFile, err := lib.GetLockfile(ctx, cfgFile)
 	if err != nil {
-		message.Error(err.Error())
+		return err
 	}
 	defer lockFile.Close()
 
-	for pluginName, spec := range lockFile.PluginSpecs {
-		plugin := lib.Plugin{
-			Name: pluginName,
+	plugins := lockFile.Plugins()
+	for i := range plugins {
+		// Install resolves the version from the spec.
+		plugins[i].Version = nil
+	}
+
+	status := lib.NewSyncStatus(lockFile.Path())
+	if addRetryFailed {
+		status, err = lib.GetSyncStatus()
+		if err != nil {
+			return err
+		}
+		if status == nil || status.Config != lockFile.Path() {
+			return fmt.Errorf("%s has not been synced, run \"tim add\" first", lockFile.Path())
+		}
+		plugins = retryablePlugins(status, plugins)
+		if len(plugins) == 0 {
+			message.Info("No plugins failed to install when %s was last synced", lockFile.Path())
+			return nil
+		}
+	}
+
+	var lockSync sync.Mutex
+	forEachPlugin(addJobs, plugins, func(plugin *lib.Plugin) error {
+		fail := func(err error) error {
+			warnFailed(plugin.Name, "install", err)
+			lockSync.Lock()
+			defer lockSync.Unlock()
+			status.RecordFailure(plugin.Name, err)
+			return err
+		}
+		spec := lockFile.PluginSpecs[plugin.Name]
+		isNew := isNewPlugin(plugin)
+		if err := plugin.Install(ctx, spec.Version); err != nil {
+			return fail(err)
+		}
+		if isNew {
+			// Reviewed as "add <plugin>" does, as nobody may have
+			// looked at the plugins added to the config file.
+			review, err := plugin.ReviewInstalled()
+			if err != nil {
+				return fail(err)
+			}
+			if !confirmScripts(plugin.Name, review, addYes) {
+				if err := plugin.Uninstall(); err != nil {
+					return fail(err)
+				}
+				message.Info("Plugin %s not added", plugin.Name)
+				return nil
+			}
+			runLifecycleHook(ctx, plugin, lib.LifecyclePostInstall)
+		}
+
+		lockSync.Lock()
+		defer lockSync.Unlock()
+		var err error
+		if spec.Version == "" {
+			// Pin the version that was resolved, as "add <plugin>" does.
+			err = lockFile.SetPlugin(ctx, plugin)
+		} else {
+			err = lockFile.Record(ctx, plugin)
+		}
+		if err != nil {
+			status.RecordFailure(plugin.Name, err)
+			return err
+		}
+		status.RecordDone(plugin.Name)
+		message.Info("Plugin %s successfully installed at version %s", plugin.Name, plugin.Version)
+		return nil
+	})
+
+	if err := lockFile.Save(); err != nil {
+		return err
+	}
+	if err := status.Save(); err != nil {
+		message.Warning("Unable to save which plugins failed to install: %s", err)
+	}
+
+	// Retrying reports on the whole sync, not just the retried plugins.
+	failed := status.Failed()
+	if len(failed) > 0 {
+		return &fieldsError{
+			fields: message.Fields{Data: failed},
+			err: lib.WithErrorCode(lib.CodePluginsFailed,
+				fmt.Errorf("%d of %d plugins failed to install: %s\nRun \"tim add --retry-failed\" to retry them.",
+					len(failed), len(status.Plugins), strings.Join(failed, ", "))),
+		}
+	}
+	if addRetryFailed {
+		message.Info("All %d plugins in %s are installed", len(status.Plugins), lockFile.Path())
+	}
+	return nil
+}
+
+// Returns the plugins that failed to install in the last sync, and are
+// still in the config file. Plugins removed from the config file since
+// are forgotten.
+func retryablePlugins(status *lib.SyncStatus, plugins []lib.Plugin) []lib.Plugin {
+	inConfig := make(map[string]bool)
+	for _, plugin := range plugins {
+		inConfig[plugin.Name] = true
+	}
+	for name := range status.Plugins {
+		if !inConfig[name] {
+			delete(status.Plugins, name)
 		}
+	}
 
-		if err := plugin.Install(spec); err != nil {
-			message.Error(err.Error())
+	failed := status.Failed()
+	retry := []lib.Plugin{}
+	for _, plugin := range plugins {
+		if slices.Contains(failed, plugin.Name) {
+			retry = append(retry, plugin)
 		}
-		message.Info("Plugin %s successfully installed at version %s", pluginName, plugin.Version)
 	}
+	return retry
 }
 
-func addPlugin(pluginName string) {
-	lockFile, err := lib.GetLockfile(cfgFile)
+func addPlugin(ctx context.Context, pluginArg string) error {
+	lockFile, err := lib.GetLockfile(ctx, cfgFile)
 	if err != nil {
-		message.Error(err.Error())
+		return err
 	}
 	defer lockFile.Close()
 
+	pluginName, remote, err := lib.ParsePluginArg(lib.ResolvePluginArg(lockFile.ResolveAlias(pluginArg)))
+	if err != nil {
+		return err
+	}
+
 	// If a version has not been explicitly specified,
 	// try and find the plugin in the lockfile,
 	// and if it exists use that version spec.
 	if versionSpec == "" {
-		versionSpec = lockFile.PluginSpecs[pluginName]
+		versionSpec = lockFile.PluginSpecs[pluginName].Version
+	}
+	if remote == "" {
+		remote = lockFile.PluginSpecs[pluginName].Remote
 	}
 
 	plugin := lib.Plugin{
-		Name: pluginName,
+		Name:   pluginName,
+		Remote: remote,
 	}
 
-	if err := plugin.Install(versionSpec); err != nil {
-		message.Error(err.Error())
+	warnIncompatible(pluginName)
+
+	// Plugins already installed have had their scripts reviewed.
+	isNew := isNewPlugin(&plugin)
+	if err := plugin.Install(ctx, versionSpec); err != nil {
+		return err
+	}
+	if isNew {
+		review, err := plugin.ReviewInstalled()
+		if err != nil {
+			return err
+		}
+		if !confirmScripts(pluginName, review, addYes) {
+			if err := plugin.Uninstall(); err != nil {
+				return err
+			}
+			message.Info("Plugin %s not added", pluginName)
+			return nil
+		}
+		runLifecycleHook(ctx, &plugin, lib.LifecyclePostInstall)
 	}
 
-	lockFile.PluginSpecs[plugin.Name] = plugin.Version.GitRef()
+	if err := lockFile.SetPlugin(ctx, &plugin); err != nil {
+		return err
+	}
 	if err := lockFile.Save(); err != nil {
-		message.Error(err.Error())
+		return err
 	}
 
 	message.Info("Plugin %s successfully installed at version %s", pluginName, plugin.Version)
+	if checkOverlaps(lockFile, pluginName) > 0 {
+		message.Info("Run \"tim doctor\" to check all plugins for conflicts, or set \"allow_overlap\": true in %s to stop these warnings", lockFile.Path())
+	}
+	return nil
+}
+
+// Adds a plugin loaded from the directory given with --path, named
+// pluginArg, or after the directory if that is empty.
+func addLocalPlugin(ctx context.Context, pluginArg string) error {
+	localPath, err := filepath.Abs(addPath)
+	if err != nil {
+		return err
+	}
+	pluginName := "local/" + filepath.Base(localPath)
+	if pluginArg != "" {
+		if pluginName, _, err = lib.ParsePluginArg(pluginArg); err != nil {
+			return err
+		}
+	}
+
+	lockFile, err := lib.GetLockfile(ctx, cfgFile)
+	if err != nil {
+		return err
+	}
+	defer lockFile.Close()
+
+	plugin := lib.Plugin{
+		Name: pluginName,
+		Path: localPath,
+	}
+	isNew := isNewPlugin(&plugin)
+	if err := plugin.Install(ctx, ""); err != nil {
+		return err
+	}
+	if isNew {
+		runLifecycleHook(ctx, &plugin, lib.LifecyclePostInstall)
+	}
+	if err := lockFile.SetPlugin(ctx, &plugin); err != nil {
+		return err
+	}
+	if err := lockFile.Save(); err != nil {
+		return err
+	}
+
+	message.Info("Plugin %s added from %s", pluginName, localPath)
+	return nil
+}
+
+// Warns if the plugin is known to require a newer version of tmux
+// than the one installed.
+func warnIncompatible(pluginName string) {
+	tmuxVersion, err := lib.GetTmuxVersion()
+	if err != nil {
+		message.Debug("Unable to determine tmux version: %s", err)
+		return
+	}
+
+	problem, err := lib.CheckPluginCompat(pluginName, tmuxVersion)
+	if err != nil {
+		message.Debug("Unable to check compatibility of %s: %s", pluginName, err)
+		return
+	}
+	if problem != "" {
+		message.Warning(problem)
+	}
 }
@@ -0,0 +1,123 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+	"github.com/spf13/cobra"
+)
+
+var cleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Removes plugins that are no longer in the config file",
+	Long: `Finds plugin directories in ~/.local/share/tim/plugins, or in
+"plugin_dir" if it is set, that are not in the config file, for example
+after removing a plugin from tim.json by hand, and deletes them.
+
+Plugins holding the sessions saved by tmux-resurrect, because
+@resurrect-dir is inside them, are never deleted.
+
+Pass "--dry-run" to only list them, or "--yes" to delete them without
+asking for confirmation.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cleanCommand(cmd.Context())
+	},
+}
+
+var (
+	cDryRunFlag bool
+	cYesFlag    bool
+)
+
+func init() {
+	rootCmd.AddCommand(cleanCmd)
+	cleanCmd.Flags().BoolVar(&cDryRunFlag, "dry-run", false, "List orphaned plugins without deleting them.")
+	cleanCmd.Flags().BoolVarP(&cYesFlag, "yes", "y", false, "Delete orphaned plugins without asking.")
+}
+
+func cleanCommand(ctx context.Context) error {
+	lockFile, err := lib.GetLockfile(ctx, cfgFile)
+	if err != nil {
+		return err
+	}
+	defer lockFile.Close()
+
+	orphaned, err := lockFile.OrphanedPlugins()
+	if err != nil {
+		return err
+	}
+	orphaned, err = keepSessions(lockFile, orphaned)
+	if err != nil {
+		return err
+	}
+	if len(orphaned) == 0 {
+		message.Info("No orphaned plugins found")
+		return nil
+	}
+
+	for _, name := range orphaned {
+		plugin := lib.Plugin{Name: name}
+		pluginDir, err := plugin.Dir()
+		if err != nil {
+			return err
+		}
+		message.Fields{Plugin: name}.Info("Orphaned plugin %s at %s", name, pluginDir)
+	}
+
+	if cDryRunFlag {
+		return nil
+	}
+	if !cYesFlag && !message.Confirm("Delete %d orphaned plugins?", len(orphaned)) {
+		message.Info("Nothing deleted, pass --yes to delete without asking")
+		return nil
+	}
+
+	for _, name := range orphaned {
+		plugin := lib.Plugin{Name: name}
+		if err := uninstallPlugin(ctx, &plugin); err != nil {
+			return err
+		}
+		message.Fields{Plugin: name}.Info("Deleted plugin %s", name)
+	}
+	return nil
+}
+
+// Returns the orphaned plugins, without any holding saved tmux sessions.
+func keepSessions(lockFile *lib.Lockfile, orphaned []string) ([]string, error) {
+	sessionDir, err := lockFile.SessionDir()
+	if err != nil {
+		return nil, err
+	}
+
+	kept := make([]string, 0, len(orphaned))
+	for _, name := range orphaned {
+		plugin := lib.Plugin{Name: name}
+		holds, err := plugin.HoldsSessions(sessionDir)
+		if err != nil {
+			return nil, err
+		}
+		if holds {
+			message.Warning("Plugin %s holds the tmux sessions saved in %s, not deleting it", name, sessionDir)
+			continue
+		}
+		kept = append(kept, name)
+	}
+	return kept, nil
+}
@@ -0,0 +1,412 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/gittest"
+	"github.com/kjnsn/tim/lib/message"
+)
+
+// Sets up a config directory with the plugin user/fixture, whose remote
+// is a repository tagged v1.0.0 and v1.1.0. Returns the config file and
+// the remote.
+func setupFixture(t *testing.T) (string, *gittest.Repo) {
+	t.Helper()
+	remote := gittest.New(t)
+	for _, tag := range []string{"v1.0.0", "v1.1.0"} {
+		remote.Commit(tag, map[string]string{"fixture.tmux": "# " + tag + "\n"})
+		remote.Tag(tag)
+	}
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "config"))
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, "state"))
+	t.Setenv("XDG_DATA_HOME", filepath.Join(home, "data"))
+	t.Setenv(updateCheckEnv, "false")
+
+	configFile := filepath.Join(home, "config", "tim", "tim.json")
+	if err := os.MkdirAll(filepath.Dir(configFile), 0750); err != nil {
+		t.Fatal(err)
+	}
+	config := `{"schema_version": 4, "plugins": {"user/fixture": {"version": "v1.0.0", "remote": "` + remote.Dir + `"}}}`
+	if err := os.WriteFile(configFile, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return configFile, remote
+}
+
+// Runs tim with args, failing the test unless it exits with want.
+// Returns what it wrote.
+func runTim(t *testing.T, want int, args ...string) string {
+	t.Helper()
+	var out, errOut strings.Builder
+	code := Run(context.Background(), lib.BuildInfo{}, append([]string{"--no-pager"}, args...), &out, &errOut)
+	if code != want {
+		t.Fatalf("tim %s exited with %d; want %d\n%s%s", strings.Join(args, " "), code, want, out.String(), errOut.String())
+	}
+	return out.String()
+}
+
+// Returns the version of user/fixture in the config file, or an empty
+// string if it is not there.
+func configVersion(t *testing.T, configFile string) string {
+	t.Helper()
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config struct {
+		Plugins map[string]struct {
+			Version string `json:"version"`
+		} `json:"plugins"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+	return config.Plugins["user/fixture"].Version
+}
+
+func TestAddUpgradeRemove(t *testing.T) {
+	configFile, _ := setupFixture(t)
+	pluginDir := filepath.Join(os.Getenv("XDG_DATA_HOME"), "tim", "plugins", "user", "fixture")
+
+	out := runTim(t, 0, "add")
+	if !strings.Contains(out, "user/fixture successfully installed at version v1.0.0") {
+		t.Errorf("tim add output = %q; want user/fixture installed at v1.0.0", out)
+	}
+	if script, err := os.ReadFile(filepath.Join(pluginDir, "fixture.tmux")); err != nil || string(script) != "# v1.0.0\n" {
+		t.Errorf("installed fixture.tmux = %q, %v; want v1.0.0", script, err)
+	}
+
+	runTim(t, 0, "upgrade")
+	if got := configVersion(t, configFile); got != "v1.1.0" {
+		t.Errorf("version after upgrade = %q; want v1.1.0", got)
+	}
+	if script, err := os.ReadFile(filepath.Join(pluginDir, "fixture.tmux")); err != nil || string(script) != "# v1.1.0\n" {
+		t.Errorf("upgraded fixture.tmux = %q, %v; want v1.1.0", script, err)
+	}
+
+	runTim(t, 0, "remove", "user/fixture")
+	if got := configVersion(t, configFile); got != "" {
+		t.Errorf("version after remove = %q; want the plugin removed", got)
+	}
+	if _, err := os.Stat(pluginDir); !os.IsNotExist(err) {
+		t.Errorf("plugin directory after remove: %v; want it deleted", err)
+	}
+}
+
+func TestUpgradeLocalChanges(t *testing.T) {
+	setupFixture(t)
+	pluginDir := filepath.Join(os.Getenv("XDG_DATA_HOME"), "tim", "plugins", "user", "fixture")
+	runTim(t, 0, "add")
+	if err := os.WriteFile(filepath.Join(pluginDir, "fixture.tmux"), []byte("# changed\n"), 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	// Untracked files are kept, so don't stop an upgrade.
+	if err := os.WriteFile(filepath.Join(pluginDir, "untracked.conf"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	out := runTim(t, 1, "--json", "upgrade", "user/fixture")
+	if !strings.Contains(out, "local changes") || !strings.Contains(out, `"code":"`+string(lib.CodeLocalChanges)+`"`) {
+		t.Errorf("tim upgrade output = %q; want it to refuse to overwrite local changes", out)
+	}
+	runTim(t, 0, "upgrade", "--force", "user/fixture")
+	if script, err := os.ReadFile(filepath.Join(pluginDir, "fixture.tmux")); err != nil || string(script) != "# v1.1.0\n" {
+		t.Errorf("fixture.tmux after --force = %q, %v; want v1.1.0", script, err)
+	}
+}
+
+func TestImportKeepsExtraOnFailure(t *testing.T) {
+	configFile, _ := setupFixture(t)
+	pluginDir := filepath.Join(os.Getenv("XDG_DATA_HOME"), "tim", "plugins", "user", "fixture")
+	runTim(t, 0, "add")
+
+	// user/fixture is not in the snapshot, but it must not be removed while
+	// the plugins in the snapshot fail to install.
+	snapshotFile := filepath.Join(t.TempDir(), "snapshot.json")
+	snapshot := `{"schema_version": 4, "plugins": {"user/broken": {"ref": "v1.0.0", "commit": "0123456789abcdef0123456789abcdef01234567", "remote": "` +
+		filepath.Join(t.TempDir(), "missing") + `"}}}`
+	if err := os.WriteFile(snapshotFile, []byte(snapshot), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	runTim(t, 1, "import", snapshotFile)
+	if got := configVersion(t, configFile); got != "v1.0.0" {
+		t.Errorf("version after failed import = %q; want v1.0.0", got)
+	}
+	if _, err := os.Stat(pluginDir); err != nil {
+		t.Errorf("plugin directory after failed import: %v; want it kept", err)
+	}
+}
+
+func TestLockSetChecksVersion(t *testing.T) {
+	configFile, _ := setupFixture(t)
+	runTim(t, 1, "lock", "set", "user/fixture", "v1.1")
+	runTim(t, 1, "lock", "set", "user/fixture", "v1..1")
+	if got := configVersion(t, configFile); got != "v1.0.0" {
+		t.Errorf("version after invalid lock set = %q; want v1.0.0", got)
+	}
+
+	runTim(t, 0, "lock", "set", "user/fixture", "v1.1.0")
+	if got := configVersion(t, configFile); got != "v1.1.0" {
+		t.Errorf("version after lock set = %q; want v1.1.0", got)
+	}
+}
+
+func TestHookKeepsTmuxConfigMode(t *testing.T) {
+	setupFixture(t)
+	t.Setenv("TMUX_TMPDIR", t.TempDir())
+	tmuxConfigPath := filepath.Join(os.Getenv("HOME"), ".tmux.conf")
+	if err := os.WriteFile(tmuxConfigPath, []byte("set -g mouse on\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runTim(t, 0, "hook", "install", "client-attached", "upgrade")
+	for _, file := range []string{tmuxConfigPath, tmuxConfigPath + ".bak"} {
+		if info, err := os.Stat(file); err != nil || info.Mode().Perm() != 0644 {
+			t.Errorf("mode of %s = %v, %v; want 0644", file, info.Mode().Perm(), err)
+		}
+	}
+}
+
+func TestRemoveKeepConfig(t *testing.T) {
+	configFile, _ := setupFixture(t)
+	t.Setenv("TMUX_TMPDIR", t.TempDir())
+	runTim(t, 0, "add")
+
+	runTim(t, 0, "remove", "--keep-config", "user/fixture")
+	if got := configVersion(t, configFile); got != "v1.0.0" {
+		t.Errorf("version after remove --keep-config = %q; want v1.0.0", got)
+	}
+	if out := runTim(t, 0, "list"); !strings.Contains(out, "not installed") {
+		t.Errorf("tim list output = %q; want user/fixture not installed", out)
+	}
+	// The plugin is skipped until it is installed again, not a failure.
+	if out := runTim(t, 0, "load"); !strings.Contains(out, "user/fixture is not installed") {
+		t.Errorf("tim load output = %q; want user/fixture skipped", out)
+	}
+}
+
+func TestSelfUpdateRefusesDevBuild(t *testing.T) {
+	setupFixture(t)
+	out := runTim(t, 1, "--json", "self-update")
+	if !strings.Contains(out, "is not a release") {
+		t.Errorf("tim self-update output = %q; want it refused", out)
+	}
+}
+
+func TestFlagsReset(t *testing.T) {
+	setupFixture(t)
+	runTim(t, 0, "--json", "add")
+	// --json is not passed again, so messages are plain text.
+	if out := runTim(t, 0, "list"); strings.HasPrefix(out, "{") {
+		t.Errorf("tim list output = %q; want plain text", out)
+	}
+}
+
+func TestPluginNotFoundJSON(t *testing.T) {
+	setupFixture(t)
+	out := runTim(t, 1, "--json", "remove", "user/missing")
+	if !strings.Contains(out, `"code":"E_PLUGIN_NOT_FOUND"`) {
+		t.Errorf("tim --json remove output = %q; want E_PLUGIN_NOT_FOUND", out)
+	}
+}
+
+func TestAliases(t *testing.T) {
+	configFile, _ := setupFixture(t)
+	config, err := os.ReadFile(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config = []byte(strings.Replace(string(config), `"plugins"`, `"aliases": {"fix": "user/fixture"}, "plugins"`, 1))
+	if err := os.WriteFile(configFile, config, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	runTim(t, 0, "add", "fix")
+	if out := runTim(t, 0, "list"); !strings.Contains(out, "user/fixture (fix)") {
+		t.Errorf("tim list output = %q; want the alias shown", out)
+	}
+	runTim(t, 0, "upgrade", "FIX")
+	if got := configVersion(t, configFile); got != "v1.1.0" {
+		t.Errorf("version after upgrade = %q; want v1.1.0", got)
+	}
+	runTim(t, 0, "remove", "fix")
+	if got := configVersion(t, configFile); got != "" {
+		t.Errorf("version after remove = %q; want the plugin removed", got)
+	}
+}
+
+func TestCheckCache(t *testing.T) {
+	_, remote := setupFixture(t)
+	runTim(t, 0, "add")
+	runTim(t, 10, "upgrade", "--check")
+	if out := runTim(t, 0, "list"); !strings.Contains(out, "v1.1.0 available") {
+		t.Errorf("tim list output = %q; want v1.1.0 available", out)
+	}
+
+	remote.Commit("v1.2.0", map[string]string{"fixture.tmux": "# v1.2.0\n"})
+	remote.Tag("v1.2.0")
+	// Checked moments ago, so the last check is used.
+	runTim(t, 10, "upgrade", "--check")
+	if out := runTim(t, 0, "list"); !strings.Contains(out, "v1.1.0 available") {
+		t.Errorf("tim list output after a cached check = %q; want v1.1.0 available", out)
+	}
+	runTim(t, 10, "upgrade", "--check", "--refresh")
+	if out := runTim(t, 0, "list"); !strings.Contains(out, "v1.2.0 available") {
+		t.Errorf("tim list output after --refresh = %q; want v1.2.0 available", out)
+	}
+}
+
+func TestCheckFailed(t *testing.T) {
+	_, remote := setupFixture(t)
+	runTim(t, 0, "add")
+	if err := os.RemoveAll(remote.Dir); err != nil {
+		t.Fatal(err)
+	}
+
+	out := runTim(t, message.ExitCheckFailed, "upgrade", "--check", "--refresh")
+	if !strings.Contains(out, "1 could not be checked") {
+		t.Errorf("tim upgrade --check output = %q; want 1 could not be checked", out)
+	}
+}
+
+// Runs tim watch until it has checked once.
+func runWatch(t *testing.T) {
+	t.Helper()
+	// The watch checks once, then waits until the context is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var out strings.Builder
+	if code := Run(ctx, lib.BuildInfo{}, []string{"watch"}, &out, &out); code != 0 {
+		t.Fatalf("tim watch exited with %d\n%s", code, out.String())
+	}
+}
+
+func TestWatch(t *testing.T) {
+	setupFixture(t)
+	runTim(t, 0, "add")
+	runWatch(t)
+	if out := runTim(t, 0, "list"); !strings.Contains(out, "v1.1.0 available") {
+		t.Errorf("tim list output after watching = %q; want v1.1.0 available", out)
+	}
+}
+
+func TestUpgradePolicy(t *testing.T) {
+	tests := []struct {
+		policy       string
+		wantUpgrade  string
+		wantUpgraded string
+	}{
+		{policy: "auto", wantUpgrade: "v1.1.0", wantUpgraded: "v1.1.0"},
+		{policy: "notify", wantUpgrade: "v1.0.0", wantUpgraded: "v1.1.0"},
+		{policy: "pinned", wantUpgrade: "v1.0.0", wantUpgraded: "v1.0.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			configFile, _ := setupFixture(t)
+			config, err := os.ReadFile(configFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			config = []byte(strings.Replace(string(config), `"version": "v1.0.0"`, `"version": "v1.0.0", "upgrade": "`+tt.policy+`"`, 1))
+			if err := os.WriteFile(configFile, config, 0600); err != nil {
+				t.Fatal(err)
+			}
+			runTim(t, 0, "add")
+
+			runTim(t, 0, "upgrade")
+			if got := configVersion(t, configFile); got != tt.wantUpgrade {
+				t.Errorf("version after tim upgrade = %q; want %q", got, tt.wantUpgrade)
+			}
+			runTim(t, 0, "upgrade", "user/fixture")
+			if got := configVersion(t, configFile); got != tt.wantUpgraded {
+				t.Errorf("version after tim upgrade user/fixture = %q; want %q", got, tt.wantUpgraded)
+			}
+		})
+	}
+}
+
+func TestUpgradePinnedReportsBlocked(t *testing.T) {
+	configFile, _ := setupFixture(t)
+	config, err := os.ReadFile(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config = []byte(strings.Replace(string(config), `"version": "v1.0.0"`, `"version": "v1.0.0", "upgrade": "pinned"`, 1))
+	if err := os.WriteFile(configFile, config, 0600); err != nil {
+		t.Fatal(err)
+	}
+	runTim(t, 0, "add")
+
+	for _, args := range [][]string{{"upgrade"}, {"upgrade", "user/fixture"}} {
+		out := runTim(t, 0, args...)
+		if want := `v1.1.0 is available but blocked by its "pinned" upgrade policy`; !strings.Contains(out, want) {
+			t.Errorf("tim %s printed %q; want it to contain %q", strings.Join(args, " "), out, want)
+		}
+	}
+	if out := runTim(t, 0, "info", "--check-remote"); !strings.Contains(out, "v1.1.0 blocked") {
+		t.Errorf("tim info --check-remote printed %q; want v1.1.0 blocked", out)
+	}
+}
+
+func TestWatchUpgradesAuto(t *testing.T) {
+	configFile, _ := setupFixture(t)
+	config, err := os.ReadFile(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config = []byte(strings.Replace(string(config), `"version": "v1.0.0"`, `"version": "v1.0.0", "upgrade": "auto"`, 1))
+	if err := os.WriteFile(configFile, config, 0600); err != nil {
+		t.Fatal(err)
+	}
+	runTim(t, 0, "add")
+	runWatch(t)
+	if got := configVersion(t, configFile); got != "v1.1.0" {
+		t.Errorf("version after watching = %q; want v1.1.0", got)
+	}
+}
+
+func TestCommandArgIndex(t *testing.T) {
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{[]string{"up"}, 0},
+		{[]string{"--config", "tim.json", "up"}, 2},
+		{[]string{"--config=tim.json", "up"}, 1},
+		{[]string{"--plugin-dir", "/tmp/plugins", "up"}, 2},
+		{[]string{"--timeout", "1m", "-v", "up"}, 3},
+		{[]string{"--json", "up"}, 1},
+		{[]string{"--", "up"}, -1},
+	}
+	for _, tt := range tests {
+		if got := commandArgIndex(tt.args); got != tt.want {
+			t.Errorf("commandArgIndex(%q) = %d; want %d", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+	"github.com/spf13/cobra"
+)
+
+// Returns true if cmd is generating shell completions, in which case
+// nothing but the completions may be written to stdout.
+func isCompletionRequest(cmd *cobra.Command) bool {
+	return cmd.Name() == cobra.ShellCompRequestCmd || cmd.Name() == cobra.ShellCompNoDescRequestCmd
+}
+
+// Completes the names of plugins in the config file, for commands
+// taking a single plugin.
+func completePluginName(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completePluginNames(cmd, args, toComplete)
+}
+
+// Completes the names of plugins in the config file, skipping any
+// already given, for commands taking several plugins.
+func completePluginNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	lockFile, err := lib.ReadLockfile(cfgFile)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	defer lockFile.Close()
+
+	names := make([]string, 0)
+	for name, spec := range lockFile.PluginSpecs {
+		if strings.HasPrefix(name, toComplete) && !slices.Contains(args, name) {
+			names = append(names, name+"\t"+spec.Version)
+		}
+	}
+	slices.Sort(names)
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// Completes the --version flag with the tags of the plugin given as the
+// first argument, from the completion cache.
+func completeVersionSpec(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	cache, err := lib.GetCompletionCache()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	versions := make([]string, 0)
+	for _, tag := range cache.Plugins[pluginNameArg(args[0])].Tags {
+		if strings.HasPrefix(tag, toComplete) {
+			versions = append(versions, tag)
+		}
+	}
+	return versions, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+}
+
+// Refreshes the completion cache if it is out of date, so completions
+// can be served without running git.
+func refreshCompletionCache(ctx context.Context) {
+	lockFile, err := lib.ReadLockfile(cfgFile)
+	if err != nil {
+		message.Debug("Unable to refresh the completion cache: %s", err)
+		return
+	}
+	defer lockFile.Close()
+
+	cache, err := lib.GetCompletionCache()
+	if err == nil && !cache.Stale(lockFile) {
+		return
+	}
+	if err := lib.RefreshCompletionCache(ctx, lockFile); err != nil {
+		message.Debug("Unable to refresh the completion cache: %s", err)
+	}
+}
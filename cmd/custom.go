@@ -0,0 +1,190 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const (
+	builtinGroup = "builtin"
+	customGroup  = "custom"
+)
+
+// Why custom commands in the config file were ignored, which are
+// reported by "tim doctor" rather than by every command.
+var customCommandProblems []string
+
+// The custom commands added to rootCmd.
+var customCommands []*cobra.Command
+
+// Adds the custom commands in the config file to rootCmd, and returns
+// args with the custom command being run, if any, replaced by the tim
+// arguments it runs.
+func addCustomCommands(args []string) []string {
+	// Those added by an earlier Run are replaced.
+	rootCmd.RemoveCommand(customCommands...)
+	customCommands = nil
+	customCommandProblems = nil
+
+	commands, err := lib.ReadCustomCommands(configFlagArg(args))
+	if err != nil || len(commands) == 0 {
+		// Problems with the config file are reported by the command run.
+		return args
+	}
+
+	// Grouping the custom commands moves the others under "Additional
+	// Commands", unless they are grouped too.
+	rootCmd.AddGroup(&cobra.Group{ID: builtinGroup, Title: "Available Commands:"},
+		&cobra.Group{ID: customGroup, Title: "Custom Commands, from the config file:"})
+	for _, command := range rootCmd.Commands() {
+		command.GroupID = builtinGroup
+	}
+	rootCmd.SetHelpCommandGroupID(builtinGroup)
+	rootCmd.SetCompletionCommandGroupID(builtinGroup)
+
+	for _, name := range slices.Sorted(maps.Keys(commands)) {
+		command := commands[name]
+		if err := command.Validate(name); err != nil {
+			customCommandProblems = append(customCommandProblems, err.Error())
+			delete(commands, name)
+			continue
+		}
+		if found, _, err := rootCmd.Find([]string{name}); err == nil && found != rootCmd {
+			customCommandProblems = append(customCommandProblems,
+				fmt.Sprintf("command %s is ignored, as tim already has a command named %s", name, name))
+			delete(commands, name)
+			continue
+		}
+		custom := customCommand(name, command)
+		customCommands = append(customCommands, custom)
+		rootCmd.AddCommand(custom)
+	}
+
+	i := commandArgIndex(args)
+	if i == -1 {
+		return args
+	}
+	// Complete the arguments of the command it runs.
+	if args[i] == cobra.ShellCompRequestCmd && i+1 < len(args) {
+		i++
+	}
+	command, ok := commands[args[i]]
+	if !ok || len(command.Args) == 0 {
+		return args
+	}
+	return slices.Concat(args[:i], command.Args, args[i+1:])
+}
+
+// Returns the cobra command for a custom command. Commands running tim
+// are replaced by their arguments before cobra sees them, so are only
+// run here for their help.
+func customCommand(name string, command lib.CustomCommand) *cobra.Command {
+	short := command.Description
+	if short == "" {
+		short = fmt.Sprintf("Runs %q", command.String())
+	}
+	return &cobra.Command{
+		Use:                name + " [args...]",
+		Short:              short,
+		Long:               fmt.Sprintf("Runs %q, as set in the config file. Any arguments are passed on.", command.String()),
+		GroupID:            customGroup,
+		DisableFlagParsing: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 && (args[0] == "--help" || args[0] == "-h") {
+				return cmd.Help()
+			}
+			if command.Shell == "" {
+				return fmt.Errorf("unable to run custom command %s, which runs %q, give it before any arguments",
+					name, command.String())
+			}
+			return runShellCommand(cmd.Context(), name, command.Shell, args)
+		},
+	}
+}
+
+// Runs a shell command with "sh -c", exiting with its exit status.
+func runShellCommand(ctx context.Context, name, shell string, args []string) error {
+	cmd := exec.CommandContext(ctx, "sh", append([]string{"-c", shell, name}, args...)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		return &exitError{code: exit.ExitCode()}
+	}
+	return err
+}
+
+// Returns the --config flag in args, before cobra has parsed it.
+func configFlagArg(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if value, ok := strings.CutPrefix(arg, "--config="); ok {
+			return value
+		}
+		if arg == "--config" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// Returns the index of the command name in args, skipping global flags,
+// or -1 if there is none.
+func commandArgIndex(args []string) int {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return -1
+		}
+		if !strings.HasPrefix(arg, "-") {
+			return i
+		}
+		if takesValue(arg) {
+			i++
+		}
+	}
+	return -1
+}
+
+// Returns whether arg is a global flag followed by its value as a
+// separate argument, such as "--config".
+func takesValue(arg string) bool {
+	var flag *pflag.Flag
+	if name, ok := strings.CutPrefix(arg, "--"); ok {
+		flag = rootCmd.PersistentFlags().Lookup(name)
+	} else if len(arg) == 2 {
+		flag = rootCmd.PersistentFlags().ShorthandLookup(arg[1:])
+	}
+	// Flags that need no value, such as booleans, have a default for
+	// when it is left out.
+	return flag != nil && flag.NoOptDefVal == ""
+}
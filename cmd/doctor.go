@@ -0,0 +1,203 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Checks the environment for common problems",
+	Long: `Checks that tmux is installed, and that every plugin in the config
+file is installed and compatible with the installed version of tmux.
+
+With tmux-resurrect or tmux-continuum, also checks that the directory
+sessions are saved in exists and is writable.
+
+Plugins known to do the same job, such as two status line themes, are
+reported as conflicting, unless "allow_overlap" is set in the config file.
+
+Custom commands in the config file that tim ignores are reported too.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return doctorCommand(cmd.Context())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
+
+func doctorCommand(ctx context.Context) error {
+	lockFile, err := lib.ReadLockfile(cfgFile)
+	if err != nil {
+		return err
+	}
+	defer lockFile.Close()
+
+	problems := 0
+
+	if err := lockFile.CheckClean(); err != nil {
+		message.Warning(err.Error())
+		problems++
+	}
+
+	tmuxVersion, err := lib.GetTmuxVersion()
+	if err != nil {
+		failureFields("", err).Warning("Unable to find tmux: %s", err)
+		problems++
+	} else {
+		message.Info("tmux version %s", tmuxVersion)
+	}
+
+	for _, plugin := range lockFile.Plugins() {
+		if err := plugin.CheckInstalled(); err != nil {
+			if errors.Is(err, lib.ErrPluginNotInstalled) {
+				message.Warning("Plugin %s is not installed. Run \"tim add\" to install it.", plugin.Name)
+				problems++
+			} else {
+				return err
+			}
+		} else {
+			found, err := checkPluginFiles(ctx, plugin)
+			if err != nil {
+				return err
+			}
+			problems += found
+		}
+
+		if tmuxVersion == "" {
+			continue
+		}
+		problem, err := lib.CheckPluginCompat(plugin.Name, tmuxVersion)
+		if err != nil {
+			message.Debug("Unable to check compatibility of %s: %s", plugin.Name, err)
+		} else if problem != "" {
+			message.Warning(problem)
+			problems++
+		}
+	}
+
+	problems += checkOverlaps(lockFile, "")
+
+	for _, problem := range customCommandProblems {
+		message.Warning("Custom command in the config file: %s", problem)
+		problems++
+	}
+
+	if lockFile.HasSessionPlugin() {
+		problems += checkSessionDir(lockFile)
+	}
+
+	if problems == 0 {
+		message.Info("No problems found")
+	} else {
+		message.Info("%d problems found", problems)
+	}
+	return nil
+}
+
+// Warns about plugins in the config file doing the same job, or only those
+// overlapping with pluginName if it is set. Returns the number of warnings.
+func checkOverlaps(lockFile *lib.Lockfile, pluginName string) int {
+	if lockFile.AllowOverlap {
+		return 0
+	}
+	overlaps, err := lib.CategoryOverlaps(slices.Collect(maps.Keys(lockFile.PluginSpecs)))
+	if err != nil {
+		message.Debug("Unable to check for plugins doing the same job: %s", err)
+		return 0
+	}
+
+	warnings := 0
+	for _, overlap := range overlaps {
+		if pluginName != "" && !slices.Contains(overlap.Plugins, pluginName) {
+			continue
+		}
+		all := "both"
+		if len(overlap.Plugins) > 2 {
+			all = "all"
+		}
+		message.Fields{Plugin: pluginName, Data: overlap}.Warning("%s are %s %s, %s",
+			strings.Join(overlap.Plugins, " and "), all, overlap.Description, overlap.Conflict)
+		warnings++
+	}
+	return warnings
+}
+
+// Checks that tmux sessions can be saved, returning the number of problems.
+func checkSessionDir(lockFile *lib.Lockfile) int {
+	sessionDir, err := lockFile.SessionDir()
+	if err != nil {
+		message.Warning("Unable to find where tmux sessions are saved: %s", err)
+		return 1
+	}
+	if err := lib.CheckSessionDir(sessionDir); err != nil {
+		message.Warning("tmux sessions are saved in %s, but %s", sessionDir, err)
+		return 1
+	}
+	message.Info("tmux sessions are saved in %s", sessionDir)
+	return 0
+}
+
+// Checks the files of an installed plugin, returning the number of problems.
+func checkPluginFiles(ctx context.Context, plugin lib.Plugin) (int, error) {
+	problems := 0
+
+	entrypoints, err := plugin.Entrypoints()
+	if err != nil {
+		return 0, err
+	}
+	if len(entrypoints) == 0 {
+		message.Warning("Plugin %s has no scripts to run, so loading it does nothing", plugin.Name)
+		problems++
+	}
+
+	pluginDir, err := plugin.RepoDir()
+	if err != nil {
+		return 0, err
+	}
+	if lib.UsesLFS(pluginDir) {
+		if _, err := exec.LookPath("git-lfs"); err != nil {
+			message.Warning("Plugin %s stores files with git LFS, but git-lfs is not installed", plugin.Name)
+			problems++
+		}
+	}
+	// Local changes are expected in plugins being developed.
+	if plugin.IsLocal() {
+		return problems, nil
+	}
+	dirty, err := lib.IsDirty(ctx, pluginDir)
+	if err != nil {
+		message.Warning("Unable to check plugin %s for local changes: %s", plugin.Name, err)
+		problems++
+	} else if dirty {
+		message.Warning("Plugin %s has local changes, which upgrading may overwrite", plugin.Name)
+		problems++
+	}
+
+	return problems, nil
+}
@@ -0,0 +1,89 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+	"github.com/spf13/cobra"
+)
+
+var enableCmd = &cobra.Command{
+	Use:   "enable <plugin...>",
+	Short: "Loads disabled plugins again",
+	Long: `Enables plugins disabled with "tim disable", so that "tim load" loads
+them again.`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completePluginNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setDisabledCommand(cmd.Context(), args, false)
+	},
+}
+
+var disableCmd = &cobra.Command{
+	Use:   "disable <plugin...>",
+	Short: "Stops loading plugins without removing them",
+	Long: `Disables plugins, so that "tim load" skips them, without uninstalling
+them or changing their versions. "disabled" is set for each in the config
+file. Run "tim enable" to load them again.
+
+Disabled plugins are still installed, synced and upgraded as usual.`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completePluginNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setDisabledCommand(cmd.Context(), args, true)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(enableCmd)
+	rootCmd.AddCommand(disableCmd)
+}
+
+func setDisabledCommand(ctx context.Context, args []string, disabled bool) error {
+	lockFile, err := lib.GetLockfile(ctx, cfgFile)
+	if err != nil {
+		return err
+	}
+	defer lockFile.Close()
+
+	state := "enabled"
+	if disabled {
+		state = "disabled"
+	}
+
+	changed := false
+	for _, arg := range args {
+		pluginName := pluginNameArg(arg)
+		set, err := lockFile.SetDisabled(pluginName, disabled)
+		if err != nil {
+			return err
+		}
+		if !set {
+			message.Fields{Plugin: pluginName}.Info("Plugin %s is already %s", pluginName, state)
+			continue
+		}
+		changed = true
+		message.Fields{Plugin: pluginName}.Info("Plugin %s %s", pluginName, state)
+	}
+
+	if !changed {
+		return nil
+	}
+	return lockFile.Save()
+}
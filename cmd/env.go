@@ -0,0 +1,281 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+	"github.com/spf13/cobra"
+)
+
+var envCmd = &cobra.Command{
+	Use:   "env [plugins...]",
+	Short: "Shows the environment plugin scripts are run in",
+	Long: `Shows the working directory, shell, PATH and environment variables that
+plugin scripts are given by "tim load", along with the variables tim and
+the config file set for each plugin, and the interpreter each script is
+run with.
+This helps find out why a plugin works when run from a shell, but not
+when loaded by tim.
+
+When tmux is running, the environment is the one the tmux server gives
+"tim load" in the tmux config. Pass "--current" to show tim's own
+environment instead, as "tim load" run from a shell would see.
+
+Env values in the config file starting with "cmd:" are shown as the
+command, pass "--resolve" to run them and show their output. Take care,
+as they are often secrets.
+
+With no plugins specified, every plugin in the config file is shown.`,
+	ValidArgsFunction: completePluginNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return envCommand(cmd.Context(), args)
+	},
+}
+
+var (
+	enCurrentFlag bool
+	enResolveFlag bool
+)
+
+func init() {
+	rootCmd.AddCommand(envCmd)
+	envCmd.Flags().BoolVar(&enCurrentFlag, "current", false,
+		"Show tim's own environment, rather than the one tmux runs tim in.")
+	envCmd.Flags().BoolVar(&enResolveFlag, "resolve", false,
+		"Run the commands in env values starting with \"cmd:\", and show their output.")
+}
+
+// The environment shared by the scripts of every plugin.
+type execEnv struct {
+	// Either "tmux" or "tim", for tim's own environment.
+	Source  string       `json:"source"`
+	Dir     string       `json:"dir"`
+	Shell   string       `json:"shell"`
+	Path    []string     `json:"path"`
+	Environ []lib.EnvVar `json:"environ"`
+}
+
+// The scripts of a plugin, the variables tim sets for them, and the env
+// they are given in the config file.
+type pluginEnv struct {
+	Name      string         `json:"name"`
+	Installed bool           `json:"installed"`
+	Scripts   []pluginScript `json:"scripts"`
+	Tim       []lib.EnvVar   `json:"tim"`
+	Env       []lib.EnvVar   `json:"env"`
+}
+
+type pluginScript struct {
+	Path        string `json:"path"`
+	Interpreter string `json:"interpreter,omitempty"`
+	Executable  bool   `json:"executable"`
+}
+
+func envCommand(ctx context.Context, pluginNames []string) error {
+	lockFile, err := lib.ReadLockfile(cfgFile)
+	if err != nil {
+		return err
+	}
+	defer lockFile.Close()
+
+	for _, name := range pluginNames {
+		if _, ok := lockFile.PluginSpecs[name]; !ok {
+			return lib.PluginNotFound(name)
+		}
+	}
+
+	shared, err := getExecEnv()
+	if err != nil {
+		return err
+	}
+	printExecEnv(shared)
+
+	for _, plugin := range lockFile.Plugins() {
+		if len(pluginNames) > 0 && !slices.Contains(pluginNames, plugin.Name) {
+			continue
+		}
+		env, err := getPluginEnv(ctx, plugin)
+		if err != nil {
+			return err
+		}
+		printPluginEnv(env)
+	}
+	return nil
+}
+
+// Returns the environment "tim load" is run in, by the tmux server if it
+// is running, otherwise tim's own.
+func getExecEnv() (execEnv, error) {
+	env := execEnv{Source: "tim"}
+	var environ []string
+	if !enCurrentFlag && lib.TmuxServerRunning() {
+		tmuxEnviron, dir, err := lib.TmuxShellEnviron()
+		if err != nil {
+			message.Warning("Unable to get the environment of the tmux server, showing tim's own: %s", err)
+		} else {
+			env.Source = "tmux"
+			env.Dir = dir
+			environ = tmuxEnviron
+		}
+	}
+	if env.Source == "tim" {
+		dir, err := os.Getwd()
+		if err != nil {
+			return env, err
+		}
+		env.Dir = dir
+		environ = os.Environ()
+	}
+
+	env.Environ = lib.ParseEnviron(environ)
+	for _, envVar := range env.Environ {
+		switch envVar.Name {
+		case "PATH":
+			env.Path = filepath.SplitList(envVar.Value)
+		case "SHELL":
+			env.Shell = envVar.Value
+		}
+	}
+	return env, nil
+}
+
+// Returns the scripts of a plugin and its env from the config file.
+func getPluginEnv(ctx context.Context, plugin lib.Plugin) (pluginEnv, error) {
+	env := pluginEnv{Name: plugin.Name, Scripts: make([]pluginScript, 0)}
+	timEnv, err := plugin.TimEnv()
+	if err != nil {
+		return env, err
+	}
+	env.Tim = timEnv
+	configEnv, err := plugin.ConfigEnv(ctx, enResolveFlag)
+	if err != nil {
+		return env, err
+	}
+	env.Env = configEnv
+
+	entrypoints, err := plugin.Entrypoints()
+	if errors.Is(err, fs.ErrNotExist) {
+		return env, nil
+	} else if err != nil {
+		return env, err
+	}
+	env.Installed = true
+	for _, entrypoint := range entrypoints {
+		script, err := newPluginScript(entrypoint)
+		if err != nil {
+			return env, err
+		}
+		env.Scripts = append(env.Scripts, script)
+	}
+	return env, nil
+}
+
+func printExecEnv(env execEnv) {
+	if message.JSONEnabled {
+		message.Fields{Data: env}.Info("Environment")
+		return
+	}
+
+	var b strings.Builder
+	if env.Source == "tmux" {
+		b.WriteString("Environment given to \"tim load\" by the tmux server\n")
+	} else {
+		b.WriteString("Environment given to \"tim load\" run from this shell\n")
+	}
+	fmt.Fprintf(&b, "Working directory: %s\n", env.Dir)
+	if env.Shell == "" {
+		b.WriteString("Shell: SHELL is not set\n")
+	} else {
+		fmt.Fprintf(&b, "Shell: %s\n", env.Shell)
+	}
+	b.WriteString("PATH:\n")
+	for _, dir := range env.Path {
+		fmt.Fprintf(&b, "  %s\n", dir)
+	}
+	b.WriteString("Variables:\n")
+	for _, envVar := range env.Environ {
+		fmt.Fprintf(&b, "  %s=%s\n", envVar.Name, envVar.Value)
+	}
+	message.Info("%s", strings.TrimSuffix(b.String(), "\n"))
+}
+
+func printPluginEnv(env pluginEnv) {
+	if message.JSONEnabled {
+		message.Fields{Plugin: env.Name, Data: env}.Info("Plugin %s", env.Name)
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\nPlugin %s\n", env.Name)
+	if !env.Installed {
+		b.WriteString("Not installed\n")
+	} else if len(env.Scripts) == 0 {
+		b.WriteString("No scripts\n")
+	} else {
+		b.WriteString("Scripts:\n")
+		for _, script := range env.Scripts {
+			fmt.Fprintf(&b, "  %s, %s\n", script.Path, describeScript(script))
+		}
+	}
+	b.WriteString("Variables set by tim:\n")
+	for _, envVar := range env.Tim {
+		fmt.Fprintf(&b, "  %s=%s\n", envVar.Name, envVar.Value)
+	}
+	if len(env.Env) > 0 {
+		b.WriteString("Variables from the config file:\n")
+		for _, envVar := range env.Env {
+			note := ""
+			if envVar.Unresolved {
+				note = " (pass --resolve to run it)"
+			}
+			fmt.Fprintf(&b, "  %s=%s%s\n", envVar.Name, envVar.Value, note)
+		}
+	}
+	message.Info("%s", strings.TrimSuffix(b.String(), "\n"))
+}
+
+// Returns how the script at path is run.
+func newPluginScript(path string) (pluginScript, error) {
+	script := pluginScript{Path: path}
+	if stat, err := os.Stat(path); err == nil {
+		script.Executable = stat.Mode()&0111 != 0
+	}
+	var err error
+	script.Interpreter, err = lib.ScriptInterpreter(path)
+	return script, err
+}
+
+// Describes how a script is run, or why it fails to run.
+func describeScript(script pluginScript) string {
+	switch {
+	case !script.Executable:
+		return "not executable, so it fails to run"
+	case script.Interpreter == "":
+		return "has no #! line, so it fails to run"
+	default:
+		return "run with " + script.Interpreter
+	}
+}
@@ -0,0 +1,128 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+	"github.com/spf13/cobra"
+)
+
+var freezeCmd = &cobra.Command{
+	Use:   "freeze [file]",
+	Short: "Writes a snapshot of the installed plugins",
+	Long: `Writes a snapshot pinning every installed plugin to the exact commit
+checked out and the full URL it was cloned from, along with its
+environment and options. The snapshot needs nothing else, so
+"tim import" can reproduce exactly the same plugins on another machine,
+for example from your dotfiles.
+
+Without a file, the snapshot is printed instead. Plugins loaded from a
+local directory with "tim add --path" are left out.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		snapshotPath := ""
+		if len(args) > 0 {
+			snapshotPath = args[0]
+		}
+		return freezeCommand(cmd.Context(), snapshotPath)
+	},
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Installs exactly the plugins in a snapshot",
+	Long: `Installs every plugin in a snapshot written by "tim freeze" at exactly
+its commit, and records them in the config file and tim.lock. Plugins
+that are not in the snapshot are removed, other than those loaded from a
+local directory.
+
+Pass "--keep-extra" to keep plugins that are not in the snapshot.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return importCommand(cmd.Context(), args[0])
+	},
+}
+
+var (
+	imKeepExtraFlag bool
+	imJobs          int
+)
+
+func init() {
+	rootCmd.AddCommand(freezeCmd)
+	rootCmd.AddCommand(importCmd)
+	importCmd.Flags().BoolVar(&imKeepExtraFlag, "keep-extra", false,
+		"Keep plugins that are not in the snapshot.")
+	importCmd.Flags().IntVarP(&imJobs, "jobs", "j", defaultJobs,
+		"Number of plugins to install concurrently, one per CPU by default.")
+}
+
+func freezeCommand(ctx context.Context, snapshotPath string) error {
+	lockFile, err := lib.ReadLockfile(cfgFile)
+	if err != nil {
+		return err
+	}
+	defer lockFile.Close()
+
+	snapshot, err := lockFile.Freeze(ctx)
+	if err != nil {
+		return err
+	}
+
+	encoded, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	encoded = append(encoded, '\n')
+
+	if snapshotPath == "" {
+		_, err := stdout.Write(encoded)
+		return err
+	}
+	if err := os.WriteFile(snapshotPath, encoded, 0644); err != nil {
+		return err
+	}
+	message.Info("Wrote a snapshot of %d plugins to %s", len(snapshot.Plugins), snapshotPath)
+	return nil
+}
+
+func importCommand(ctx context.Context, snapshotPath string) error {
+	snapshot, err := lib.ReadSnapshot(snapshotPath)
+	if err != nil {
+		return err
+	}
+
+	lockFile, err := lib.GetLockfile(ctx, cfgFile)
+	if err != nil {
+		return err
+	}
+	defer lockFile.Close()
+
+	var extra []string
+	if !imKeepExtraFlag {
+		extra = snapshot.Extra(lockFile)
+	}
+	if err := installPins(ctx, lockFile, snapshot.Pins(), extra, imJobs); err != nil {
+		return err
+	}
+	message.Info("Imported %d plugins from %s", len(snapshot.Plugins), snapshotPath)
+	return nil
+}
@@ -0,0 +1,203 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+	"github.com/spf13/cobra"
+)
+
+var hookCmd = &cobra.Command{
+	Use:   "hook",
+	Short: "Runs tim when tmux events happen",
+	Long: `Registers tmux hooks that run tim when something happens in tmux, such
+as checking for upgrades whenever a client attaches:
+
+  tim hook install client-attached upgrade --check
+
+Hooks are written to the block managed by tim in the tmux config file,
+and registered in the running tmux server straight away.`,
+}
+
+var hookInstallCmd = &cobra.Command{
+	Use:   "install <event> <command...>",
+	Short: "Runs a tim command whenever a tmux event happens",
+	Long: `Adds a hook to the tmux config file running tim with the given command
+and arguments whenever the tmux event happens. Events are the names of
+tmux hooks, such as "client-attached" or "session-created", see the
+HOOKS section of "man tmux".
+
+Everything after the event is passed to tim, including flags. A backup of
+the tmux config file is written first.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return hookInstallCommand(args[0], args[1:])
+	},
+}
+
+var hookListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the hooks installed by tim",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return hookListCommand()
+	},
+}
+
+var hookRemoveCmd = &cobra.Command{
+	Use:     "remove <event> <command...>",
+	Aliases: []string{"rm"},
+	Short:   "Removes a hook installed by tim",
+	Long: `Removes a hook from the tmux config file and the running tmux server.
+The event and command are given as they were installed, and as shown by
+"tim hook list".`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return hookRemoveCommand(args[0], args[1:])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(hookCmd)
+	hookCmd.AddCommand(hookInstallCmd)
+	hookCmd.AddCommand(hookListCmd)
+	hookCmd.AddCommand(hookRemoveCmd)
+	// Flags after the event belong to the command run by the hook.
+	hookInstallCmd.Flags().SetInterspersed(false)
+	hookRemoveCmd.Flags().SetInterspersed(false)
+}
+
+// Returns true if cmd is "tim hook" or one of its subcommands.
+func isHookCommand(cmd *cobra.Command) bool {
+	return cmd == hookCmd || cmd.Parent() == hookCmd
+}
+
+func hookInstallCommand(event string, args []string) error {
+	if found, _, err := rootCmd.Find(args); err != nil || found == rootCmd {
+		return fmt.Errorf("unknown tim command %q", args[0])
+	}
+	hook, err := lib.NewHook(event, args)
+	if err != nil {
+		return err
+	}
+
+	tmuxConfigPath, original, err := readTmuxConfig()
+	if err != nil {
+		return err
+	}
+	for _, existing := range lib.TmuxConfigHooks(original) {
+		if hook.Conflicts(existing) {
+			return fmt.Errorf("the hook would replace the one running \"tim %s\" on %s, remove that first", existing.Action, existing.Event)
+		}
+	}
+	executable := timExecutable()
+	tmuxConfig, changed := lib.InsertHook(original, hook, executable)
+	if !changed {
+		message.Info("%s already runs \"tim %s\" on %s", tmuxConfigPath, hook.Action, hook.Event)
+		return nil
+	}
+	if err := writeTmuxConfig(tmuxConfigPath, original, tmuxConfig); err != nil {
+		return err
+	}
+
+	if lib.TmuxServerRunning() {
+		if err := hook.Set(executable); err != nil {
+			message.Warning("Unable to add the hook to the running tmux server, it is added when tmux restarts: %s", err)
+		}
+	}
+	message.Fields{Data: hook}.Info("tmux runs \"tim %s\" on %s", hook.Action, hook.Event)
+	return nil
+}
+
+func hookListCommand() error {
+	_, tmuxConfig, err := readTmuxConfig()
+	if err != nil {
+		return err
+	}
+	hooks := lib.TmuxConfigHooks(tmuxConfig)
+	if len(hooks) == 0 {
+		message.Info("No hooks installed")
+		return nil
+	}
+	for _, hook := range hooks {
+		message.Fields{Data: hook}.Info("%s: tim %s", hook.Event, hook.Action)
+	}
+	return nil
+}
+
+func hookRemoveCommand(event string, args []string) error {
+	hook := lib.Hook{Event: event, Action: strings.Join(args, " ")}
+
+	tmuxConfigPath, original, err := readTmuxConfig()
+	if err != nil {
+		return err
+	}
+	tmuxConfig, changed := lib.RemoveHook(original, hook)
+	if !changed {
+		return fmt.Errorf("no hook runs \"tim %s\" on %s, see \"tim hook list\"", hook.Action, hook.Event)
+	}
+	if err := writeTmuxConfig(tmuxConfigPath, original, tmuxConfig); err != nil {
+		return err
+	}
+
+	if lib.TmuxServerRunning() {
+		if err := hook.Unset(); err != nil {
+			message.Warning("Unable to remove the hook from the running tmux server, it is removed when tmux restarts: %s", err)
+		}
+	}
+	message.Fields{Data: hook}.Info("Removed the hook running \"tim %s\" on %s", hook.Action, hook.Event)
+	return nil
+}
+
+// Returns the path and contents of the tmux config file.
+func readTmuxConfig() (string, string, error) {
+	tmuxConfigPath, err := lib.GetTmuxConfigPath()
+	if errors.Is(err, lib.ErrNoTmuxConfig) {
+		return "", "", fmt.Errorf("%w, run \"tim init\" to create one", err)
+	}
+	if err != nil {
+		return "", "", err
+	}
+	tmuxConfig, err := os.ReadFile(tmuxConfigPath)
+	if err != nil {
+		return "", "", err
+	}
+	return tmuxConfigPath, string(tmuxConfig), nil
+}
+
+// Writes the tmux config file, keeping a backup of the original. Both
+// keep the mode of the tmux config file.
+func writeTmuxConfig(tmuxConfigPath, original, tmuxConfig string) error {
+	info, err := os.Stat(tmuxConfigPath)
+	if err != nil {
+		return err
+	}
+	backupPath := tmuxConfigPath + ".bak"
+	if err := os.WriteFile(backupPath, []byte(original), info.Mode().Perm()); err != nil {
+		return err
+	}
+	// The backup may already exist with another mode.
+	if err := os.Chmod(backupPath, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return lib.ReplaceFile(tmuxConfigPath, []byte(tmuxConfig), info.Mode().Perm())
+}
@@ -16,10 +16,13 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/kjnsn/tim/lib"
 	"github.com/kjnsn/tim/lib/message"
@@ -31,81 +34,421 @@ var infoCmd = &cobra.Command{
 	Use:   "info [plugin]",
 	Short: "Displays information about installed plugins and tim itself",
 	Long: `Displays information about the given installed plugin,
-or without an argument shows information about all plugins.`,
-	Args: cobra.MaximumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+or without an argument shows information about all plugins.
+
+Each plugin's commit, whether it has local changes, and the result of
+the last check for a new version, by "upgrade" or "--check-remote", are
+shown too.
+
+Plugins in the read-only directories listed in "plugin_roots" in the
+config file are shown as unmanaged, since tim never changes them.
+
+Pass "--paths" to print just where tim keeps its files, one per line
+after its name, for scripts to find them. Nothing is checked, so it is
+quick enough to run from a shell prompt or plugin script.`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completePluginName,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if iPathsFlag {
+			if len(args) > 0 {
+				return fmt.Errorf("--paths can't be given a plugin")
+			}
+			return printPaths()
+		}
 		pluginName := ""
 		if len(args) > 0 {
-			pluginName = strings.ToLower(strings.TrimSpace(args[0]))
+			pluginName = pluginNameArg(args[0])
 		}
-		infoCommand(pluginName)
+		return infoCommand(cmd.Context(), pluginName)
 	},
 }
 
+var (
+	iCheckRemoteFlag bool
+	iPathsFlag       bool
+)
+
 func init() {
 	rootCmd.AddCommand(infoCmd)
+	infoCmd.Flags().BoolVar(&iCheckRemoteFlag, "check-remote", false,
+		"Check each plugin's remote for a new version.")
+	infoCmd.Flags().BoolVar(&iPathsFlag, "paths", false,
+		"Print just the paths of tim's directories and files.")
 }
 
-func infoCommand(pluginName string) {
-	lockFile, err := lib.GetLockfile(cfgFile)
+func infoCommand(ctx context.Context, pluginName string) error {
+	lockFile, err := lib.ReadLockfile(cfgFile)
 	if err != nil {
-		message.Error(err.Error())
+		return err
 	}
 	defer lockFile.Close()
 
+	loadState, err := lib.GetLoadState()
+	if err != nil {
+		return err
+	}
+	checks, err := lib.GetCheckCache()
+	if err != nil {
+		return err
+	}
+	history, err := lib.GetHistory()
+	if err != nil {
+		return err
+	}
+
+	plugins := slices.Concat(lockFile.Plugins(), lockFile.UnmanagedPlugins())
 	if pluginName != "" {
-		i := slices.IndexFunc(lockFile.Plugins(), func(plugin lib.Plugin) bool {
+		pluginName = resolvePluginName(lockFile, pluginName)
+		i := slices.IndexFunc(plugins, func(plugin lib.Plugin) bool {
 			return plugin.Name == pluginName
 		})
 		if i == -1 {
 			message.Warning("Plugin %s not installed", pluginName)
-		} else {
-			printPluginInfo(lockFile.Plugins()[i])
+			return nil
+		}
+		plugin := plugins[i]
+		updates := checkRemotes(ctx, iCheckRemoteFlag, []lib.Plugin{plugin})
+		return printPluginInfo(ctx, lockFile, loadState, checks, history, plugin, updates)
+	}
+
+	message.StartPager()
+	defer message.StopPager()
+
+	// Print some generic information about tim.
+	message.Fields{Version: buildInfo.Version}.Info("Tim Version: %s", buildInfo.Version)
+	message.Fields{Data: map[string]string{"lockfile": lockFile.Path()}}.Info("Lockfile: %s", lockFile.Path())
+
+	updates := checkRemotes(ctx, iCheckRemoteFlag, plugins)
+	for _, plugin := range plugins {
+		if err := printPluginInfo(ctx, lockFile, loadState, checks, history, plugin, updates); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Prints the paths tim uses, one per line after its name.
+func printPaths() error {
+	paths, err := lib.GetPaths(cfgFile)
+	if err != nil {
+		return err
+	}
+
+	if message.JSONEnabled {
+		message.Fields{Data: paths}.Info("Paths")
+		return nil
+	}
+	for _, path := range []struct{ name, path string }{
+		{"tim_dir", paths.TimDir},
+		{"plugins_dir", paths.PluginsDir},
+		{"config", paths.Config},
+		{"lock", paths.Lock},
+		{"tmux_conf", paths.TmuxConfig},
+		{"state_dir", paths.StateDir},
+	} {
+		if path.path != "" {
+			message.Info("%s\t%s", path.name, path.path)
 		}
+	}
+	return nil
+}
+
+// Information about a plugin, as shown by info and list.
+type pluginInfo struct {
+	Name      string   `json:"name"`
+	Aliases   []string `json:"aliases,omitempty"`
+	URL       string   `json:"url"`
+	Version   string   `json:"version"`
+	Installed bool     `json:"installed"`
+	Disabled  bool     `json:"disabled,omitempty"`
+	Unmanaged bool     `json:"unmanaged,omitempty"`
+	Dir       string   `json:"dir"`
+	UpdatedAt string   `json:"updated_at,omitempty"`
+
+	// Whether a new version is available, from checking remotes or the
+	// last check of the installed version.
+	Update string `json:"update,omitempty"`
+
+	// The commit checked out, and whether it has local changes.
+	Commit string `json:"commit,omitempty"`
+	Dirty  bool   `json:"dirty,omitempty"`
+
+	// The latest version upstream when the plugin was last checked, when
+	// that was, and why the check after it failed, if it did.
+	Latest      string `json:"latest,omitempty"`
+	CheckedAt   string `json:"checked_at,omitempty"`
+	CheckFailed string `json:"check_failed,omitempty"`
+
+	// How often new versions of the plugin are released, described, and
+	// how often "tim upgrade --check --due" checks it.
+	Cadence       *lib.ReleaseCadence `json:"cadence,omitempty"`
+	Releases      string              `json:"releases,omitempty"`
+	CheckInterval string              `json:"check_interval,omitempty"`
+
+	// How long the plugin took to load the last time it loaded.
+	LoadTime string `json:"load_time,omitempty"`
+}
 
+// Adds the commit checked out and whether it has local changes, which
+// are expected in plugins loaded from a local directory.
+func addGitInfo(ctx context.Context, info *pluginInfo, plugin lib.Plugin) {
+	commit, err := plugin.Commit(ctx)
+	if err != nil {
+		message.Debug("Unable to find the commit of %s: %s", plugin.Name, err)
+		return
+	}
+	info.Commit = commit
+	if plugin.IsLocal() {
+		return
+	}
+	repoDir, err := plugin.RepoDir()
+	if err != nil {
 		return
 	}
+	dirty, err := lib.IsDirty(ctx, repoDir)
+	if err != nil {
+		message.Debug("Unable to check %s for local changes: %s", plugin.Name, err)
+	}
+	info.Dirty = dirty
+}
 
-	// Print some generic information about tim.
-	message.Info("Tim Version: %s", TimVersion)
-	message.Info("Lockfile: %s", lockFile.Path())
+// Adds the result of the last check of the plugin for a new version.
+func addCachedCheck(info *pluginInfo, plugin lib.Plugin, check *lib.CachedCheck) {
+	info.CheckFailed = check.Error
+	if check.CheckedAt.IsZero() {
+		return
+	}
+	info.Latest = check.Latest
+	info.CheckedAt = message.FormatTime(check.CheckedAt)
+	if upgrade, ok := check.UpgradeFor(plugin.Version); ok {
+		info.Update = "up-to-date"
+		if upgrade != "" {
+			info.Update = upgrade + " available"
+		}
+	}
+}
 
-	for _, plugin := range lockFile.Plugins() {
-		printPluginInfo(plugin)
+// Adds how often new versions of the plugin are released.
+func addCadence(ctx context.Context, info *pluginInfo, plugin lib.Plugin, revisions []lib.Revision) {
+	cadence, err := plugin.ReleaseCadence(ctx, revisions)
+	if err != nil {
+		message.Debug("Unable to find how often %s is released: %s", plugin.Name, err)
+		return
 	}
+	if cadence.LastRelease.IsZero() {
+		return
+	}
+	info.Cadence = &cadence
+	info.Releases = describeCadence(cadence)
+	info.CheckInterval = describeDays(cadence.CheckInterval())
 }
 
-func printPluginInfo(plugin lib.Plugin) {
+// Describes a release cadence, such as "~monthly, the last 5 months ago".
+func describeCadence(cadence lib.ReleaseCadence) string {
+	last := "the last " + message.RelativeTime(cadence.LastRelease)
+	if cadence.Interval == 0 {
+		return last
+	}
+
+	days := cadence.Interval.Hours() / 24
+	var often string
+	switch {
+	case days < 2:
+		often = "daily"
+	case days < 10:
+		often = "weekly"
+	case days < 21:
+		often = "every 2 weeks"
+	case days < 45:
+		often = "monthly"
+	case days < 330:
+		often = fmt.Sprintf("every %.0f months", days/30)
+	default:
+		often = "yearly"
+	}
+	return fmt.Sprintf("~%s, %s", often, last)
+}
+
+// Describes a duration of whole days, such as "7 days".
+func describeDays(d time.Duration) string {
+	days := int(d.Hours() / 24)
+	if days == 1 {
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", days)
+}
+
+// Checks the remotes of the installed plugins concurrently, if enabled.
+// Returns a summary of each result by plugin name.
+func checkRemotes(ctx context.Context, enabled bool, plugins []lib.Plugin) map[string]string {
+	updates := make(map[string]string)
+	if !enabled {
+		return updates
+	}
+
+	installed := make([]lib.Plugin, 0, len(plugins))
+	for _, plugin := range plugins {
+		if plugin.Version != nil && !plugin.IsLocal() && plugin.CheckInstalled() == nil {
+			installed = append(installed, plugin)
+		}
+	}
+
+	var updatesLock sync.Mutex
+	forEachPlugin(defaultJobs, installed, func(plugin *lib.Plugin) error {
+		result, err := checkPlugin(ctx, plugin)
+		if err != nil {
+			return err
+		}
+
+		updatesLock.Lock()
+		defer updatesLock.Unlock()
+		updates[plugin.Name] = updateSummary(result)
+		return nil
+	})
+	return updates
+}
+
+// Summarises a check result in a few words, for a table column.
+func updateSummary(result lib.CheckResult) string {
+	switch result.Outcome {
+	case lib.OutcomeUpgradeAvailable:
+		return fmt.Sprintf("%s available", result.Upgrade)
+	case lib.OutcomeUpToDate:
+		return "up-to-date"
+	case lib.OutcomeAhead:
+		return "ahead"
+	case lib.OutcomeConstrained:
+		return fmt.Sprintf("%s blocked", result.Latest)
+	case lib.OutcomeUnknown:
+		return "unknown"
+	default:
+		return fmt.Sprintf("check failed (%s)", result.ErrorClass)
+	}
+}
+
+func getPluginInfo(lockFile *lib.Lockfile, plugin lib.Plugin) (pluginInfo, error) {
 	pluginDir, err := plugin.Dir()
 	if err != nil {
-		message.Error(err.Error())
+		return pluginInfo{}, err
+	}
+
+	info := pluginInfo{
+		Name:      plugin.Name,
+		Aliases:   lockFile.AliasesOf(plugin.Name),
+		Disabled:  plugin.Disabled,
+		Unmanaged: plugin.Unmanaged,
+		Dir:       pluginDir,
+	}
+	// Unmanaged plugins are named after their directory, not a repository.
+	if !plugin.Unmanaged {
+		info.URL = plugin.WebURL()
+	}
+	if plugin.Version != nil {
+		info.Version = plugin.Version.String()
+	}
+	if updatedAt := lockFile.Locked[plugin.Name].UpdatedAt; updatedAt != nil {
+		info.UpdatedAt = message.FormatTime(*updatedAt)
+	}
+
+	err = plugin.CheckInstalled()
+	if err == nil {
+		info.Installed = true
+	} else if !errors.Is(err, lib.ErrPluginNotInstalled) {
+		return pluginInfo{}, err
+	}
+	return info, nil
+}
+
+func printPluginInfo(ctx context.Context, lockFile *lib.Lockfile, loadState *lib.LoadState, checks *lib.CheckCache, history *lib.History, plugin lib.Plugin, updates map[string]string) error {
+	info, err := getPluginInfo(lockFile, plugin)
+	if err != nil {
+		return err
+	}
+	if info.Installed && !plugin.Unmanaged {
+		addGitInfo(ctx, &info, plugin)
+		addCadence(ctx, &info, plugin, history.Plugins[plugin.Name])
+	}
+	if check, ok := checks.Plugins[plugin.Name]; ok {
+		addCachedCheck(&info, plugin, check)
+	}
+	if update, ok := updates[plugin.Name]; ok {
+		info.Update = update
+	}
+	if state, ok := loadState.Plugins[plugin.Name]; ok && state.LastLoadDuration > 0 {
+		info.LoadTime = formatLoadDuration(state.LastLoadDuration)
+	}
+
+	if message.JSONEnabled {
+		message.Fields{Plugin: info.Name, Version: info.Version, Data: info}.Info("Plugin %s", info.Name)
+		return nil
 	}
 
 	str := ""
 
-	name := message.Hyperlink("https://github.com/"+plugin.Name, plugin.Name)
+	name := message.Hyperlink(info.URL, plugin.Name)
 	str += fmt.Sprintf("\nName: %s\n", name)
+	if len(info.Aliases) > 0 {
+		str += fmt.Sprintf("Aliases: %s\n", strings.Join(info.Aliases, ", "))
+	}
 	if plugin.Version != nil {
 		switch version := plugin.Version.(type) {
 		case *lib.SemanticVersion:
-			ver := message.Hyperlink("https://github.com/"+plugin.Name+"/releases/tag/"+version.GitRef(), version.String())
+			ver := message.Hyperlink(plugin.TagURL(version.GitRef()), version.String())
 			str += fmt.Sprintf("Version: %s\n", ver)
 		case *lib.GitVersion:
-			ver := message.Hyperlink("https://github.com/"+plugin.Name+"/tree/"+version.GitRef(), version.String())
+			ver := message.Hyperlink(plugin.TreeURL(version.GitRef()), version.String())
 			str += fmt.Sprintf("Version: %s\n", ver)
+		case lib.LocalVersion:
+			str += fmt.Sprintf("Version: local, from %s\n", plugin.Path)
 		}
 	}
-	fmt.Println(str)
-
-	err = plugin.CheckInstalled()
-	if err != nil {
-		if errors.Is(err, lib.ErrPluginNotInstalled) {
-			message.Warning("Plugin %s is present in the config file but not installed.\n"+
-				"  Run \"tim add\" to install it.", plugin.Name)
-		} else {
-			message.Error(err.Error())
+	if plugin.Unmanaged {
+		str += fmt.Sprintf("Version: unmanaged, from the plugin root %s\n", plugin.Root)
+	}
+	if info.Installed {
+		str += fmt.Sprintf("Installed to: %s\n", info.Dir)
+	}
+	if info.Disabled {
+		str += fmt.Sprintf("Disabled: not loaded until \"tim enable %s\"\n", plugin.Name)
+	}
+	if info.Commit != "" {
+		changes := ""
+		if info.Dirty {
+			changes = ", with local changes"
+		}
+		str += fmt.Sprintf("Commit: %.10s%s\n", info.Commit, changes)
+	}
+	if info.UpdatedAt != "" {
+		str += fmt.Sprintf("Updated: %s\n", info.UpdatedAt)
+	}
+	if info.Update != "" {
+		str += fmt.Sprintf("Remote: %s\n", info.Update)
+	}
+	if info.CheckedAt != "" {
+		latest := ""
+		if info.Latest != "" {
+			latest = fmt.Sprintf(", latest %s", info.Latest)
 		}
-	} else {
-		str += fmt.Sprintf("Installed to: %s", pluginDir)
+		str += fmt.Sprintf("Last checked: %s%s\n", info.CheckedAt, latest)
+	}
+	if info.CheckFailed != "" {
+		str += fmt.Sprintf("Last check failed: %s\n", info.CheckFailed)
+	}
+	if info.Releases != "" {
+		label := "Releases"
+		if info.Cadence.FromHistory {
+			label = "Upgraded"
+		}
+		str += fmt.Sprintf("%s: %s, checked every %s with --due\n", label, info.Releases, info.CheckInterval)
+	}
+	if info.LoadTime != "" {
+		str += fmt.Sprintf("Last load time: %s\n", info.LoadTime)
+	}
+	fmt.Fprintln(message.Output, str)
+
+	if !info.Installed {
+		message.Warning("Plugin %s is present in the config file but not installed.\n"+
+			"  Run \"tim add\" to install it.", plugin.Name)
 	}
+	return nil
 }
@@ -0,0 +1,142 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+	"github.com/spf13/cobra"
+)
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Sets up tmux to load plugins with tim",
+	Long: `Checks that the installed tmux is supported, then adds a line running
+"tim load" to the end of the tmux config file, so that plugins are loaded
+when tmux starts. Nothing is changed if the tmux config already runs
+"tim load", so it is safe to run init more than once.
+
+If the tim being run is not the one in PATH, for example ~/go/bin/tim
+when ~/go/bin is not in PATH, the line runs it by its full path.
+
+A backup of the tmux config file is written first. If there is no tmux
+config file, ~/.tmux.conf is created.
+
+Pass "--dry-run" to print the changes without making them.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return initCommand()
+	},
+}
+
+var (
+	inDryRunFlag bool
+)
+
+func init() {
+	rootCmd.AddCommand(initCmd)
+	initCmd.Flags().BoolVar(&inDryRunFlag, "dry-run", false,
+		"Print the changes to the tmux config without making them.")
+}
+
+func initCommand() error {
+	tmuxVersion, err := lib.GetTmuxVersion()
+	if err != nil {
+		return fmt.Errorf("Unable to find tmux, is it installed? %w", err)
+	}
+	if err := lib.CheckTmuxVersion(tmuxVersion); err != nil {
+		return err
+	}
+	message.Debug("Found tmux %s", tmuxVersion)
+
+	tmuxConfigPath, err := lib.GetTmuxConfigPath()
+	exists := true
+	if errors.Is(err, lib.ErrNoTmuxConfig) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		tmuxConfigPath = path.Join(home, ".tmux.conf")
+		exists = false
+	} else if err != nil {
+		return err
+	}
+
+	original := []byte{}
+	if exists {
+		original, err = os.ReadFile(tmuxConfigPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	loadLine := lib.TimLoadLine(timExecutable())
+	tmuxConfig, changed := lib.AddTimLoadLine(string(original), loadLine)
+	if !changed {
+		message.Info("%s already loads plugins with tim", tmuxConfigPath)
+		return nil
+	}
+
+	if inDryRunFlag {
+		message.Info("Would add the following line to %s:\n  %s", tmuxConfigPath, loadLine)
+		return nil
+	}
+
+	if exists {
+		err = writeTmuxConfig(tmuxConfigPath, string(original), tmuxConfig)
+	} else {
+		err = lib.ReplaceFile(tmuxConfigPath, []byte(tmuxConfig), 0600)
+	}
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		message.Info("Added the line %s to %s, a backup is at %s.bak", loadLine, tmuxConfigPath, tmuxConfigPath)
+	} else {
+		message.Info("Created %s with the line %s", tmuxConfigPath, loadLine)
+	}
+	return nil
+}
+
+// Returns the full path of the running tim, or an empty string if it is
+// the tim found in PATH, so the tmux config can run it either way.
+func timExecutable() string {
+	executable, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	found, err := exec.LookPath("tim")
+	if err != nil {
+		return executable
+	}
+
+	executableInfo, err := os.Stat(executable)
+	if err != nil {
+		return ""
+	}
+	foundInfo, err := os.Stat(found)
+	if err != nil || !os.SameFile(executableInfo, foundInfo) {
+		return executable
+	}
+	return ""
+}
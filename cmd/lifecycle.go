@@ -0,0 +1,43 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/kjnsn/tim/lib"
+)
+
+// Returns true if the plugin is not installed yet, so its post-install
+// hook should run once it is.
+func isNewPlugin(plugin *lib.Plugin) bool {
+	return errors.Is(plugin.CheckInstalled(), lib.ErrPluginNotInstalled)
+}
+
+// Runs the plugin's lifecycle hook for event, warning if it fails, as the
+// plugin is installed or removed either way.
+func runLifecycleHook(ctx context.Context, plugin *lib.Plugin, event string) {
+	if err := plugin.RunLifecycleHook(ctx, event); err != nil {
+		failureFields(plugin.Name, err).Warning("Plugin %s: %s", plugin.Name, err)
+	}
+}
+
+// Removes the plugin, after running its pre-uninstall hook.
+func uninstallPlugin(ctx context.Context, plugin *lib.Plugin) error {
+	runLifecycleHook(ctx, plugin, lib.LifecyclePreUninstall)
+	return plugin.Uninstall()
+}
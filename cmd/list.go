@@ -0,0 +1,126 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+	"github.com/spf13/cobra"
+)
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists plugins",
+	Long: `Lists every plugin in the config file, one per line, with its version,
+and whether a new version was available when it was last checked by
+"tim upgrade --check".
+
+Pass "--check-remote" to check the plugins for new versions now,
+concurrently.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return listCommand(cmd.Context())
+	},
+}
+
+var (
+	lCheckRemoteFlag bool
+)
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+	listCmd.Flags().BoolVar(&lCheckRemoteFlag, "check-remote", false,
+		"Check each plugin's remote for a new version.")
+}
+
+func listCommand(ctx context.Context) error {
+	lockFile, err := lib.ReadLockfile(cfgFile)
+	if err != nil {
+		return err
+	}
+	defer lockFile.Close()
+
+	checks, err := lib.GetCheckCache()
+	if err != nil {
+		return err
+	}
+
+	plugins := lockFile.Plugins()
+	slices.SortFunc(plugins, func(a, b lib.Plugin) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	updates := checkRemotes(ctx, lCheckRemoteFlag, plugins)
+
+	if message.JSONEnabled {
+		for _, plugin := range plugins {
+			info, err := getListInfo(lockFile, checks, plugin, updates)
+			if err != nil {
+				return err
+			}
+			message.Fields{Plugin: info.Name, Version: info.Version, Data: info}.Info("Plugin %s", info.Name)
+		}
+		return nil
+	}
+
+	message.StartPager()
+	defer message.StopPager()
+
+	table := tabwriter.NewWriter(message.Output, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "PLUGIN\tVERSION\tSTATUS\tUPDATE")
+	for _, plugin := range plugins {
+		info, err := getListInfo(lockFile, checks, plugin, updates)
+		if err != nil {
+			return err
+		}
+		status := "installed"
+		if !info.Installed {
+			status = "not installed"
+		}
+		if info.Disabled {
+			status += ", disabled"
+		}
+		name := info.Name
+		if len(info.Aliases) > 0 {
+			name += " (" + strings.Join(info.Aliases, ", ") + ")"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", name, info.Version, status, info.Update)
+	}
+	table.Flush()
+	return nil
+}
+
+// Returns the info listed for a plugin, with whether a new version is
+// available from checking its remote, or failing that the last check.
+func getListInfo(lockFile *lib.Lockfile, checks *lib.CheckCache, plugin lib.Plugin, updates map[string]string) (pluginInfo, error) {
+	info, err := getPluginInfo(lockFile, plugin)
+	if err != nil {
+		return pluginInfo{}, err
+	}
+	if check, ok := checks.Plugins[plugin.Name]; ok {
+		addCachedCheck(&info, plugin, check)
+	}
+	if update, ok := updates[plugin.Name]; ok {
+		info.Update = update
+	}
+	return info, nil
+}
@@ -16,7 +16,15 @@ limitations under the License.
 package cmd
 
 import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
 	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/kjnsn/tim/lib"
 	"github.com/kjnsn/tim/lib/message"
@@ -30,32 +38,293 @@ var loadCmd = &cobra.Command{
 
 If no arguments are given, all plugins are loaded.
 
-Otherwise the plugins specified are loaded.`,
-	Args: cobra.ArbitraryArgs,
-	Run: func(cmd *cobra.Command, args []string) {
-		loadCommand(args)
+Otherwise the plugins specified are loaded.
+
+After loading, the tmux user options @tim_plugins_dir and @tim_plugins
+are set to the plugin directory and the space separated list of loaded
+plugins respectively.
+
+A plugin failing to load does not stop the others from loading, but tim
+exits with an error once every plugin has been tried. Pass "--fail-fast"
+to stop at the first failure instead.
+
+A plugin that fails to load several times in a row is quarantined, and
+skipped until it is released with "tim quarantine release".
+
+Pass "--session" or "--window" to load plugins into a single tmux session
+or window, rather than globally. Their options are set in the session or
+window, and their scripts run with TMUX_PANE set to its active pane, so
+the tmux commands they run without a target apply to it.
+
+Plugins in the read-only directories listed in "plugin_roots" in the
+config file, such as /usr/share/tmux-plugins, are loaded too. A plugin
+with the same name in the config file is loaded instead.
+
+Plugins disabled with "tim disable" are skipped, even when specified.
+
+Pass "--verify" to refuse to load plugins that have been changed since
+they were installed, as checked by "tim verify".
+
+Pass "--profile" to print how long each plugin took to load, slowest
+first. The time each plugin last took to load is shown by "tim info".
+
+Pass "--reload" to also source the tmux config file in the running tmux
+server afterwards, so changes to it apply without restarting tmux.
+
+If a plugin breaks tmux, pass "--safe" or set TIM_SAFE_MODE=1 when
+starting tmux, for example "TIM_SAFE_MODE=1 tmux". Nothing is loaded,
+and the plugins that would have been loaded are listed instead.`,
+	Args:              cobra.ArbitraryArgs,
+	ValidArgsFunction: completePluginNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return loadCommand(cmd.Context(), args)
 	},
 }
 
+// Setting this environment variable to a true value, such as "1", is
+// equivalent to passing --safe.
+const safeModeEnv = "TIM_SAFE_MODE"
+
+// Set in the tmux server while "tim load --reload" sources the tmux
+// config, so the "tim load" it runs does not load plugins twice.
+const reloadingOption = "@tim-reloading"
+
+// Explains how to recover from a plugin that breaks tmux.
+const safeModeHint = "  Start tmux with " + safeModeEnv + "=1 to skip loading plugins."
+
+var (
+	lQuarantineAfter int
+	lSafeFlag        bool
+	lFailFastFlag    bool
+	lReloadFlag      bool
+	lProfileFlag     bool
+	lVerifyFlag      bool
+	lSessionFlag     string
+	lWindowFlag      string
+)
+
 func init() {
 	rootCmd.AddCommand(loadCmd)
+	loadCmd.Flags().IntVar(&lQuarantineAfter, "quarantine-after", lib.DefaultQuarantineThreshold,
+		"Skip plugins that fail to load this many times in a row, 0 to never skip.")
+	loadCmd.Flags().BoolVar(&lSafeFlag, "safe", false,
+		"Load nothing, only list the plugins that would be loaded.")
+	loadCmd.Flags().BoolVar(&lFailFastFlag, "fail-fast", false,
+		"Stop loading plugins at the first failure.")
+	loadCmd.Flags().BoolVar(&lReloadFlag, "reload", false,
+		"Source the tmux config in the running tmux server after loading.")
+	loadCmd.Flags().BoolVar(&lProfileFlag, "profile", false,
+		"Print how long each plugin took to load, slowest first.")
+	loadCmd.Flags().BoolVar(&lVerifyFlag, "verify", false,
+		"Refuse to load plugins that fail \"tim verify\".")
+	loadCmd.Flags().StringVar(&lSessionFlag, "session", "",
+		"Load plugins into this tmux session, rather than globally.")
+	loadCmd.Flags().StringVar(&lWindowFlag, "window", "",
+		"Load plugins into this tmux window, such as \"work:2\", rather than globally.")
 }
 
-func loadCommand(pluginNames []string) {
-	lockFile, err := lib.GetLockfile(cfgFile)
+// Returns true if loading is disabled, with --safe or TIM_SAFE_MODE.
+func safeModeEnabled() bool {
+	if lSafeFlag {
+		return true
+	}
+	enabled, err := strconv.ParseBool(os.Getenv(safeModeEnv))
+	return err == nil && enabled
+}
+
+func loadCommand(ctx context.Context, pluginNames []string) error {
+	if lib.GetTmuxOption(reloadingOption) != "" {
+		message.Debug("Plugins were already loaded by \"tim load --reload\"")
+		return nil
+	}
+
+	lockFile, err := lib.ReadLockfile(cfgFile)
 	if err != nil {
-		message.Error(err.Error())
+		return err
 	}
 	defer lockFile.Close()
+	for i, name := range pluginNames {
+		pluginNames[i] = resolvePluginName(lockFile, name)
+	}
+
+	loadState, err := lib.GetLoadState()
+	if err != nil {
+		return err
+	}
+
+	safeMode := safeModeEnabled()
+	if safeMode {
+		message.Info("Safe mode is enabled, not loading any plugins")
+	}
 
-	for _, plugin := range lockFile.Plugins() {
+	target := lib.TmuxTarget{Session: lSessionFlag, Window: lWindowFlag}
+	if !target.IsGlobal() && !safeMode {
+		if _, err := target.Pane(); err != nil {
+			return err
+		}
+	}
+
+	loaded := make([]string, 0)
+	failed := make([]string, 0)
+	for _, plugin := range slices.Concat(lockFile.Plugins(), lockFile.UnmanagedPlugins()) {
 		if len(pluginNames) > 0 && !slices.Contains(pluginNames, plugin.Name) {
 			continue
 		}
+		if plugin.Disabled {
+			message.Fields{Plugin: plugin.Name}.Info("Plugin %s is disabled, skipping", plugin.Name)
+			continue
+		}
+		// Such as after "tim remove --keep-config", until "tim add".
+		if err := plugin.CheckInstalled(); errors.Is(err, lib.ErrPluginNotInstalled) {
+			message.Fields{Plugin: plugin.Name}.Info("Plugin %s is not installed, skipping. Run \"tim add\" to install it", plugin.Name)
+			continue
+		}
+		if loadState.IsQuarantined(plugin.Name) {
+			message.Warning("Plugin %s is quarantined after failing to load %d times in a row, skipping.\n"+
+				"  Run \"tim quarantine release %s\" once it is fixed.",
+				plugin.Name, loadState.Plugins[plugin.Name].ConsecutiveFailures, plugin.Name)
+			continue
+		}
+		if safeMode {
+			message.Fields{Plugin: plugin.Name}.Info("Would load plugin %s", plugin.Name)
+			continue
+		}
 
-		if err := plugin.Load(); err != nil {
-			message.Error(err.Error())
+		started := time.Now()
+		if err := loadPlugin(ctx, lockFile, &plugin, target); err != nil {
+			// Quarantine is for broken plugins, not forbidden ones.
+			forbidden := errors.Is(err, lib.ErrPolicyViolation) || errors.Is(err, lib.ErrVerifyFailed)
+			if !forbidden && loadState.RecordFailure(plugin.Name, err, lQuarantineAfter) {
+				message.Warning("Plugin %s has failed to load %d times in a row and is now quarantined",
+					plugin.Name, lQuarantineAfter)
+			}
+			if lFailFastFlag {
+				if err := loadState.Save(); err != nil {
+					message.Warning("Unable to save load state: %s", err)
+				}
+				return fmt.Errorf("Plugin %s failed to load: %s\n%s", plugin.Name, err, safeModeHint)
+			}
+			warnFailed(plugin.Name, "load", err)
+			failed = append(failed, plugin.Name)
+			continue
 		}
+		loadState.RecordSuccess(plugin.Name, time.Since(started))
 		message.Info("loaded plugin %s", plugin.Name)
+		loaded = append(loaded, plugin.Name)
+	}
+
+	if lProfileFlag {
+		printLoadProfile(loadState, loaded)
+	}
+
+	if err := loadState.Save(); err != nil {
+		message.Warning("Unable to save load state: %s", err)
+	}
+
+	if err := exportTmuxOptions(loaded, target); err != nil {
+		return err
+	}
+
+	if lReloadFlag && !safeMode {
+		if err := reloadTmux(true, fmt.Sprintf("tim loaded %d plugins", len(loaded))); err != nil {
+			return err
+		}
+	}
+
+	if len(failed) > 0 {
+		slices.Sort(failed)
+		return &fieldsError{
+			fields: message.Fields{Data: failed},
+			err: lib.WithErrorCode(lib.CodePluginsFailed, fmt.Errorf("%d of %d plugins failed to load: %s\n%s",
+				len(failed), len(failed)+len(loaded), strings.Join(failed, ", "), safeModeHint)),
+		}
+	}
+	return nil
+}
+
+// Loads the plugin into target, first verifying it with --verify.
+func loadPlugin(ctx context.Context, lockFile *lib.Lockfile, plugin *lib.Plugin, target lib.TmuxTarget) error {
+	// Unmanaged plugins have no recorded state to verify against.
+	if lVerifyFlag && !plugin.Unmanaged {
+		if err := lockFile.Verify(ctx, plugin); err != nil {
+			return err
+		}
+	}
+	return plugin.LoadInto(ctx, target)
+}
+
+// Prints how long each of the loaded plugins took to load, slowest first.
+func printLoadProfile(loadState *lib.LoadState, loaded []string) {
+	byDuration := slices.Clone(loaded)
+	slices.SortStableFunc(byDuration, func(a, b string) int {
+		return cmp.Compare(loadState.Plugins[b].LastLoadDuration, loadState.Plugins[a].LastLoadDuration)
+	})
+
+	var total time.Duration
+	for _, name := range byDuration {
+		duration := loadState.Plugins[name].LastLoadDuration
+		total += duration
+		message.Fields{Plugin: name, Data: map[string]int64{"duration_ms": duration.Milliseconds()}}.Info(
+			"%8s  %s", formatLoadDuration(duration), name)
+	}
+	message.Fields{Data: map[string]int64{"duration_ms": total.Milliseconds()}}.Info(
+		"%8s  total for %d plugins", formatLoadDuration(total), len(loaded))
+}
+
+// Formats a load duration to the millisecond, which is precise enough to
+// compare plugins.
+func formatLoadDuration(duration time.Duration) string {
+	return duration.Round(time.Millisecond).String()
+}
+
+// Exports the plugin directory and loaded plugins as tmux user options,
+// so that other tools can find them without invoking tim. The loaded
+// plugins are set in target.
+func exportTmuxOptions(loaded []string, target lib.TmuxTarget) error {
+	pluginsDir, err := lib.GetPluginsDir()
+	if err != nil {
+		return err
+	}
+
+	slices.Sort(loaded)
+	batch := lib.TmuxBatch{}
+	batch.SetOption("@tim_plugins_dir", pluginsDir)
+	batch.SetOptionIn(target, "@tim_plugins", strings.Join(loaded, " "))
+	if err := batch.Run(); err != nil {
+		message.Warning("Unable to set tmux options: %s", err)
+	}
+	return nil
+}
+
+// Sources the tmux config file in the running tmux server, then shows
+// notice in tmux if tim is running inside it. If skipLoad is true, the
+// "tim load" run by the tmux config does nothing, as plugins have just
+// been loaded.
+func reloadTmux(skipLoad bool, notice string) error {
+	if !lib.TmuxServerRunning() {
+		message.Info("No tmux server is running, nothing to reload")
+		return nil
+	}
+	tmuxConfigPath, err := lib.GetTmuxConfigPath()
+	if err != nil {
+		return err
+	}
+
+	if skipLoad {
+		if err := lib.SetTmuxOption(reloadingOption, "1"); err != nil {
+			return err
+		}
+		defer lib.UnsetTmuxOption(reloadingOption)
+	}
+	if err := lib.SourceTmuxConfig(tmuxConfigPath); err != nil {
+		return fmt.Errorf("unable to source %s: %w", tmuxConfigPath, err)
+	}
+
+	if os.Getenv("TMUX") != "" {
+		if err := lib.DisplayTmuxMessage(notice); err != nil {
+			message.Warning("Unable to display a message in tmux: %s", err)
+		}
 	}
+	message.Info("Reloaded %s", tmuxConfigPath)
+	return nil
 }
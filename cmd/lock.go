@@ -0,0 +1,115 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+	"github.com/spf13/cobra"
+)
+
+var lockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Edits the config file without installing anything",
+	Long: `Edits the plugins in the config file without installing, upgrading or
+removing any plugins, for scripts that set up the desired plugins first
+and then install them all with a single "tim sync".
+
+Each edit is written to the config file in one step, so it is never left
+half written.`,
+}
+
+var lockSetCmd = &cobra.Command{
+	Use:   "set <plugin> <version>",
+	Short: "Sets the version of a plugin in the config file",
+	Long: `Adds a plugin to the config file, or changes its version, without
+installing it. The version is a semver 2.0 compliant version or a branch
+name, as passed to "tim add --version". It is checked against the
+plugin's tags and branches when they can be listed.
+
+The plugin may be a clone URL, which is recorded as its remote. Run
+"tim sync" afterwards to install it.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return lockSetCommand(cmd.Context(), args[0], args[1])
+	},
+}
+
+var lockRmCmd = &cobra.Command{
+	Use:     "rm <plugin>",
+	Aliases: []string{"remove"},
+	Short:   "Removes a plugin from the config file",
+	Long: `Removes a plugin from the config file, and tim.lock, without deleting
+its files. Run "tim clean" afterwards to delete them.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completePluginName,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return lockRmCommand(cmd.Context(), pluginNameArg(args[0]))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lockCmd)
+	lockCmd.AddCommand(lockSetCmd)
+	lockCmd.AddCommand(lockRmCmd)
+}
+
+func lockSetCommand(ctx context.Context, pluginArg, version string) error {
+	pluginName, remote, err := lib.ParsePluginArg(pluginArg)
+	if err != nil {
+		return err
+	}
+
+	lockFile, err := lib.GetLockfile(ctx, cfgFile)
+	if err != nil {
+		return err
+	}
+	defer lockFile.Close()
+
+	lockFile.SetSpec(pluginName, version, remote)
+	if plugin := lockFile.GetPlugin(pluginName); !plugin.IsLocal() {
+		if err := plugin.CheckVersionSpec(ctx, version); err != nil {
+			return err
+		}
+	}
+	if err := lockFile.Save(); err != nil {
+		return err
+	}
+
+	message.Fields{Plugin: pluginName, Version: version}.Info("Set plugin %s to version %s", pluginName, version)
+	return nil
+}
+
+func lockRmCommand(ctx context.Context, pluginName string) error {
+	lockFile, err := lib.GetLockfile(ctx, cfgFile)
+	if err != nil {
+		return err
+	}
+	defer lockFile.Close()
+
+	if _, ok := lockFile.PluginSpecs[pluginName]; !ok {
+		return lib.PluginNotFound(pluginName)
+	}
+	lockFile.Remove(pluginName)
+	if err := lockFile.Save(); err != nil {
+		return err
+	}
+
+	message.Fields{Plugin: pluginName}.Info("Removed plugin %s from %s", pluginName, lockFile.Path())
+	return nil
+}
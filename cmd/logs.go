@@ -0,0 +1,88 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+	"github.com/spf13/cobra"
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Shows the git commands tim has run",
+	Long: `Shows the most recent git commands run by tim, how long each took, its
+exit status, and what it printed if it failed, to help find out why a
+plugin failed to install or upgrade.
+
+The log is kept in tim.log in the state directory, usually
+~/.local/state/tim, see "tim info --paths". It is rotated when it reaches
+1MB, keeping the 3 logs before it.
+
+Pass "--follow" to keep printing commands as they are run, such as by
+"tim load" when tmux starts.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return logsCommand(cmd.Context())
+	},
+}
+
+var (
+	lgLines      int
+	lgFollowFlag bool
+)
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+	logsCmd.Flags().IntVarP(&lgLines, "lines", "n", 20, "Number of commands to show, 0 for all of them.")
+	logsCmd.Flags().BoolVarP(&lgFollowFlag, "follow", "f", false, "Keep showing commands as they are run.")
+}
+
+func logsCommand(ctx context.Context) error {
+	entries, err := lib.ReadLog(lgLines)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 && !lgFollowFlag {
+		message.Info("No git commands have been logged yet")
+		return nil
+	}
+	for _, entry := range entries {
+		printLogEntry(entry)
+	}
+	if !lgFollowFlag {
+		return nil
+	}
+	return lib.FollowLog(ctx, printLogEntry)
+}
+
+func printLogEntry(entry lib.LogEntry) {
+	line := fmt.Sprintf("%s git %s", message.Timestamp(entry.Time), strings.Join(entry.Args, " "))
+	if entry.Dir != "" {
+		line += fmt.Sprintf(" (in %s)", entry.Dir)
+	}
+	duration := time.Duration(entry.DurationMs) * time.Millisecond
+	line += fmt.Sprintf(" took %s, exit status %d", duration, entry.ExitCode)
+	for _, stderr := range entry.Stderr {
+		line += "\n    " + stderr
+	}
+	message.Fields{Data: entry}.Info("%s", line)
+}
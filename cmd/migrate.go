@@ -0,0 +1,140 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+	"github.com/spf13/cobra"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrates plugins from TPM",
+	Long: `Finds plugins declared for TPM with "set -g @plugin '...'" in the tmux
+config file, adds them to tim's config file and installs them.
+
+Pass "--replace-tpm" to also comment out the line running TPM in the
+tmux config file, and load plugins with tim instead. A backup of the
+tmux config file is written first.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return migrateCommand(cmd.Context())
+	},
+}
+
+var (
+	mReplaceTPMFlag bool
+)
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+	migrateCmd.Flags().BoolVar(&mReplaceTPMFlag, "replace-tpm", false,
+		"Comment out the line running TPM in the tmux config, and run tim instead.")
+}
+
+func migrateCommand(ctx context.Context) error {
+	tmuxConfigPath, err := lib.GetTmuxConfigPath()
+	if err != nil {
+		return err
+	}
+	tmuxConfig, err := os.ReadFile(tmuxConfigPath)
+	if err != nil {
+		return err
+	}
+
+	lockFile, err := lib.GetLockfile(ctx, cfgFile)
+	if err != nil {
+		return err
+	}
+	defer lockFile.Close()
+
+	plugins := make([]lib.Plugin, 0)
+	versionSpecs := make(map[string]string)
+	for _, tpmPlugin := range lib.ParseTPMPlugins(string(tmuxConfig)) {
+		name, remote, err := lib.ParsePluginArg(tpmPlugin.Arg)
+		if err != nil {
+			message.Warning("Skipping plugin %s: %s", tpmPlugin.Arg, err)
+			continue
+		}
+		if name == lib.TPMPluginName {
+			continue
+		}
+		if _, ok := lockFile.PluginSpecs[name]; ok {
+			message.Info("Plugin %s is already managed by tim", name)
+			continue
+		}
+
+		plugins = append(plugins, lib.Plugin{Name: name, Remote: remote})
+		versionSpecs[name] = tpmPlugin.Branch
+	}
+	message.Info("Found %d plugins to migrate in %s", len(plugins), tmuxConfigPath)
+
+	var specsLock sync.Mutex
+	failures := forEachPlugin(defaultJobs, plugins, func(plugin *lib.Plugin) error {
+		isNew := isNewPlugin(plugin)
+		if err := plugin.Install(ctx, versionSpecs[plugin.Name]); err != nil {
+			warnFailed(plugin.Name, "install", err)
+			return err
+		}
+		if isNew {
+			runLifecycleHook(ctx, plugin, lib.LifecyclePostInstall)
+		}
+
+		specsLock.Lock()
+		defer specsLock.Unlock()
+		if err := lockFile.SetPlugin(ctx, plugin); err != nil {
+			return err
+		}
+		message.Info("Plugin %s successfully installed at version %s", plugin.Name, plugin.Version)
+		return nil
+	})
+
+	if err := lockFile.Save(); err != nil {
+		return err
+	}
+
+	if mReplaceTPMFlag {
+		if err := replaceTPM(tmuxConfigPath, string(tmuxConfig)); err != nil {
+			return err
+		}
+	}
+
+	if len(failures) > 0 {
+		return lib.WithErrorCode(lib.CodePluginsFailed, fmt.Errorf("%d of %d plugins failed to install", len(failures), len(plugins)))
+	}
+	return nil
+}
+
+// Comments out the line running TPM in the tmux config.
+func replaceTPM(tmuxConfigPath, tmuxConfig string) error {
+	replaced, changed := lib.ReplaceTPMRunLine(tmuxConfig)
+	if !changed {
+		message.Warning("No line running TPM found in %s", tmuxConfigPath)
+		return nil
+	}
+
+	if err := writeTmuxConfig(tmuxConfigPath, tmuxConfig, replaced); err != nil {
+		return err
+	}
+	message.Info("Replaced TPM with tim in %s, a backup is at %s.bak", tmuxConfigPath, tmuxConfigPath)
+	return nil
+}
@@ -0,0 +1,77 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+	"github.com/spf13/cobra"
+)
+
+var migrationsCmd = &cobra.Command{
+	Use:   "migrations",
+	Short: "Upgrades tim's state to the current format",
+	Long: `Upgrades the config file and tim's directories to the format used by
+this version of tim.
+
+Migrations run automatically before every other command, so this is only
+needed to preview them with "--dry-run". A backup of the config file is
+written alongside it before any changes are made.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		migrated, err := runMigrations(cmd.Context(), mDryRunFlag)
+		if err != nil {
+			return err
+		}
+		if !migrated {
+			message.Info("Nothing to migrate, tim's state is at schema version %d", lib.CurrentSchemaVersion)
+		}
+		return nil
+	},
+}
+
+var (
+	mDryRunFlag bool
+)
+
+func init() {
+	rootCmd.AddCommand(migrationsCmd)
+	migrationsCmd.Flags().BoolVar(&mDryRunFlag, "dry-run", false, "Print pending migrations without applying them.")
+}
+
+// Runs pending migrations, returning true if there were any.
+func runMigrations(ctx context.Context, dryRun bool) (bool, error) {
+	applied, err := lib.Migrate(ctx, cfgFile, dryRun)
+	if errors.Is(err, lib.ErrNewerSchema) {
+		// Commands that don't save can still run, saving is refused
+		// unless --allow-dirty-config is passed.
+		message.Warning(err.Error())
+	} else if err != nil {
+		return false, err
+	}
+
+	for _, migration := range applied {
+		if dryRun {
+			message.Info("Pending migration to schema version %d: %s", migration.Version, migration.Description)
+		} else {
+			message.Info("Migrated to schema version %d: %s", migration.Version, migration.Description)
+		}
+	}
+	return len(applied) > 0, nil
+}
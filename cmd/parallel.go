@@ -0,0 +1,62 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/kjnsn/tim/lib"
+)
+
+// The most plugins operated on concurrently by default. Most of the work
+// is waiting on the network, so more would mostly load the git host.
+const maxDefaultJobs = 16
+
+// The default number of plugins operated on concurrently, one per CPU.
+var defaultJobs = min(runtime.NumCPU(), maxDefaultJobs)
+
+// Runs fn for every plugin, with at most jobs running concurrently.
+// Returns the errors returned by fn, keyed by plugin name.
+func forEachPlugin(jobs int, plugins []lib.Plugin, fn func(plugin *lib.Plugin) error) map[string]error {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	var wg sync.WaitGroup
+	var errorsLock sync.Mutex
+	errors := make(map[string]error)
+	semaphore := make(chan struct{}, jobs)
+
+	for _, plugin := range plugins {
+		wg.Add(1)
+		go func(plugin lib.Plugin) {
+			defer wg.Done()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			if err := fn(&plugin); err != nil {
+				errorsLock.Lock()
+				errors[plugin.Name] = err
+				errorsLock.Unlock()
+			}
+		}(plugin)
+	}
+
+	wg.Wait()
+	return errors
+}
@@ -0,0 +1,93 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+)
+
+// Installs the pinned plugins at exactly their commits, recording each
+// in the config file and tim.lock once it is installed. Plugins loaded
+// from a local directory are left alone. Only once every plugin is
+// installed are the extra plugins uninstalled and removed.
+func installPins(ctx context.Context, lockFile *lib.Lockfile, pins map[string]lib.Pin, extra []string, jobs int) error {
+	plugins := make([]lib.Plugin, 0, len(pins))
+	for _, name := range slices.Sorted(maps.Keys(pins)) {
+		// A plugin being developed locally stays local.
+		if lockFile.PluginSpecs[name].Path != "" {
+			continue
+		}
+		plugins = append(plugins, lockFile.PinnedPlugin(name, pins[name]))
+	}
+
+	var lockFileLock sync.Mutex
+	failures := forEachPlugin(jobs, plugins, func(plugin *lib.Plugin) error {
+		pin := pins[plugin.Name]
+		isNew := isNewPlugin(plugin)
+		if err := plugin.InstallLocked(ctx, lib.LockedPlugin{Ref: pin.Ref, Commit: pin.Commit, Remote: pin.Remote}); err != nil {
+			warnFailed(plugin.Name, "install", err)
+			return err
+		}
+		if isNew {
+			runLifecycleHook(ctx, plugin, lib.LifecyclePostInstall)
+		}
+
+		lockFileLock.Lock()
+		lockFile.ApplyPin(plugin.Name, pin)
+		lockFileLock.Unlock()
+		message.Info("Plugin %s installed at %s (%.10s)", plugin.Name, pin.Ref, pin.Commit)
+		return nil
+	})
+
+	var err error
+	if len(failures) > 0 {
+		if len(extra) > 0 {
+			message.Warning("Not removing %d other plugins, as some plugins failed to install", len(extra))
+		}
+		err = lib.WithErrorCode(lib.CodePluginsFailed, fmt.Errorf("%d of %d plugins failed to install", len(failures), len(plugins)))
+	} else {
+		err = removePlugins(ctx, lockFile, extra)
+	}
+
+	if saveErr := lockFile.Save(); saveErr != nil {
+		return saveErr
+	}
+	return err
+}
+
+// Uninstalls the named plugins and removes them from the config file,
+// stopping at the first that fails to uninstall.
+func removePlugins(ctx context.Context, lockFile *lib.Lockfile, names []string) error {
+	for _, name := range names {
+		plugin := lockFile.GetPlugin(name)
+		if plugin == nil {
+			continue
+		}
+		if err := uninstallPlugin(ctx, plugin); err != nil {
+			return err
+		}
+		lockFile.Remove(name)
+		message.Info("Plugin %s removed", name)
+	}
+	return nil
+}
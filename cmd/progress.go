@@ -0,0 +1,171 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+	"github.com/mattn/go-isatty"
+	"golang.org/x/term"
+)
+
+// Width of the bar itself, not including the plugin name.
+const progressBarWidth = 20
+
+// Draws the progress of plugins being installed on a single line of the
+// terminal, which is cleared whenever a message is printed.
+type progressBar struct {
+	mu   sync.Mutex
+	out  io.Writer
+	line string
+}
+
+// Numbers plugins in the order work on them starts, shown as "[3/12]"
+// before their progress once the number of plugins is known.
+type pluginCounter struct {
+	mu      sync.Mutex
+	total   int
+	numbers map[string]int
+}
+
+var progressCounter = &pluginCounter{numbers: make(map[string]int)}
+
+// Sets the number of plugins progress is shown for.
+func countPlugins(total int) {
+	progressCounter.mu.Lock()
+	defer progressCounter.mu.Unlock()
+	progressCounter.total = total
+	progressCounter.numbers = make(map[string]int)
+}
+
+// Returns the prefix shown before the progress of the plugin, such as
+// "[3/12] ", or nothing if the number of plugins is not known.
+func (c *pluginCounter) prefix(plugin string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.total == 0 || plugin == "" {
+		return ""
+	}
+	number, ok := c.numbers[plugin]
+	if !ok {
+		number = len(c.numbers) + 1
+		c.numbers[plugin] = number
+	}
+	return fmt.Sprintf("[%d/%d] ", number, c.total)
+}
+
+// Returns ctx reporting progress to a progress bar on stderr, if stderr
+// is a terminal and output is not JSON. With plain output, each phase is
+// printed on its own line instead.
+func withProgressBar(ctx context.Context) context.Context {
+	if message.JSONEnabled {
+		return ctx
+	}
+	if message.PlainEnabled {
+		progress := &plainProgress{out: stderr, phases: make(map[string]lib.Phase)}
+		return lib.WithProgress(ctx, progress.update)
+	}
+	if stderr != io.Writer(os.Stderr) || !isatty.IsTerminal(os.Stderr.Fd()) {
+		return ctx
+	}
+	bar := &progressBar{out: os.Stderr}
+	message.Output = &progressOutput{bar: bar, out: message.Output}
+	return lib.WithProgress(ctx, bar.update)
+}
+
+func (b *progressBar) update(event lib.ProgressEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if event.Phase == lib.PhaseDone {
+		b.draw("")
+		return
+	}
+	b.draw(formatProgress(event))
+}
+
+// Replaces the line shown. Must be called with mu held.
+func (b *progressBar) draw(line string) {
+	if width, _, err := term.GetSize(int(os.Stderr.Fd())); err == nil && width > 0 && utf8.RuneCountInString(line) >= width {
+		line = string([]rune(line)[:width-1])
+	}
+	if line == b.line {
+		return
+	}
+	fmt.Fprint(b.out, "\r\x1b[K"+line)
+	b.line = line
+}
+
+func formatProgress(event lib.ProgressEvent) string {
+	prefix := progressCounter.prefix(event.Plugin)
+	if event.Phase == lib.PhaseCheck {
+		return fmt.Sprintf("%schecking %s…", prefix, event.Plugin)
+	}
+	if event.Percent < 0 {
+		return fmt.Sprintf("%s%s: %s", prefix, event.Plugin, event.Message)
+	}
+	done := event.Percent * progressBarWidth / 100
+	bar := strings.Repeat("=", done) + strings.Repeat(" ", progressBarWidth-done)
+	line := fmt.Sprintf("%s%s: %s [%s] %3d%%", prefix, event.Plugin, event.Phase, bar, event.Percent)
+	if event.Message != "" {
+		line += " " + event.Message
+	}
+	return line
+}
+
+// Writes messages, clearing the progress bar first and drawing it again
+// afterwards so the two don't end up on the same line.
+type progressOutput struct {
+	bar *progressBar
+	out io.Writer
+}
+
+func (o *progressOutput) Write(p []byte) (int, error) {
+	o.bar.mu.Lock()
+	defer o.bar.mu.Unlock()
+	line := o.bar.line
+	o.bar.draw("")
+	n, err := o.out.Write(p)
+	o.bar.draw(line)
+	return n, err
+}
+
+// Prints a line as each plugin starts a phase, for terminals that can't
+// redraw a progress bar.
+type plainProgress struct {
+	mu     sync.Mutex
+	out    io.Writer
+	phases map[string]lib.Phase
+}
+
+func (p *plainProgress) update(event lib.ProgressEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.phases[event.Plugin] == event.Phase {
+		return
+	}
+	p.phases[event.Plugin] = event.Phase
+	if event.Phase != lib.PhaseDone {
+		fmt.Fprintf(p.out, "%s%s: %s\n", progressCounter.prefix(event.Plugin), event.Plugin, event.Phase)
+	}
+}
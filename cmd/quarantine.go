@@ -0,0 +1,111 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+	"github.com/spf13/cobra"
+)
+
+var quarantineCmd = &cobra.Command{
+	Use:   "quarantine",
+	Short: "Lists plugins quarantined after repeatedly failing to load",
+	Long: `Lists plugins that are skipped by "tim load" because they failed to
+load too many times in a row.
+
+Use "tim quarantine release" to load them again.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return quarantineListCommand()
+	},
+}
+
+var quarantineReleaseCmd = &cobra.Command{
+	Use:   "release [plugin...]",
+	Short: "Releases plugins from quarantine",
+	Long: `Releases the given plugins from quarantine, so that "tim load" runs
+them again. Without arguments, all plugins are released.`,
+	Args:              cobra.ArbitraryArgs,
+	ValidArgsFunction: completePluginNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pluginNames := make([]string, len(args))
+		for i, arg := range args {
+			pluginNames[i] = pluginNameArg(arg)
+		}
+		return quarantineReleaseCommand(pluginNames)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(quarantineCmd)
+	quarantineCmd.AddCommand(quarantineReleaseCmd)
+}
+
+func quarantineListCommand() error {
+	loadState, err := lib.GetLoadState()
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for name, state := range loadState.Plugins {
+		if !state.Quarantined {
+			continue
+		}
+		found = true
+		lastFailure := "an unknown time"
+		if state.LastFailure != nil {
+			lastFailure = message.FormatTime(*state.LastFailure)
+		}
+		message.Fields{Plugin: name, Data: state}.Info("%s: failed %d times, last %s with exit code %d: %s",
+			name, state.ConsecutiveFailures, lastFailure, state.LastExitCode, state.LastError)
+	}
+
+	if !found {
+		message.Info("No plugins are quarantined")
+	}
+	return nil
+}
+
+func quarantineReleaseCommand(pluginNames []string) error {
+	loadState, err := lib.GetLoadState()
+	if err != nil {
+		return err
+	}
+
+	if len(pluginNames) == 0 {
+		for name, state := range loadState.Plugins {
+			if state.Quarantined {
+				pluginNames = append(pluginNames, name)
+			}
+		}
+	}
+
+	for _, name := range pluginNames {
+		if !loadState.IsQuarantined(name) {
+			message.Warning("Plugin %s is not quarantined", name)
+			continue
+		}
+		loadState.Release(name)
+		message.Info("Released plugin %s from quarantine", name)
+	}
+
+	if err := loadState.Save(); err != nil {
+		return err
+	}
+	return nil
+}
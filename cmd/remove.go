@@ -16,7 +16,12 @@ limitations under the License.
 package cmd
 
 import (
-	"strings"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
 
 	"github.com/kjnsn/tim/lib"
 	"github.com/kjnsn/tim/lib/message"
@@ -26,38 +31,170 @@ import (
 var removeCmd = &cobra.Command{
 	Use:   "remove [plugin]",
 	Short: "Removes a plugin",
-	Long:  `Uninstalls a plugin`,
-	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		removeCommand(strings.ToLower(strings.TrimSpace(args[0])))
+	Long: `Uninstalls a plugin and removes it from the config file.
+
+Pass "--keep-config" to only delete the plugin's files, keeping it in the
+config file so the next "tim add" installs it again. Until then it is
+shown as not installed, and "tim load" skips it. Pass "--purge-config" to
+also remove snippets inserted by "tim snippets --insert" from the tmux
+config file.
+
+Removing tmux-resurrect or tmux-continuum keeps the sessions they saved.
+Pass "--export-sessions" to also archive them, and with "--purge-config"
+you are asked whether to delete them once no plugin uses them.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completePluginName,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return removeCommand(cmd.Context(), pluginNameArg(args[0]))
 	},
 }
 
+var (
+	rKeepConfigFlag     bool
+	rPurgeConfigFlag    bool
+	rExportSessionsFlag string
+)
+
 func init() {
 	rootCmd.AddCommand(removeCmd)
+	removeCmd.Flags().BoolVar(&rKeepConfigFlag, "keep-config", false,
+		"Only uninstall the plugin's files, keeping it in the config file.")
+	removeCmd.Flags().BoolVar(&rPurgeConfigFlag, "purge-config", false,
+		"Also remove the plugin's snippets from the tmux config.")
+	removeCmd.Flags().StringVar(&rExportSessionsFlag, "export-sessions", "",
+		"Archive the tmux sessions saved by tmux-resurrect to this .tar.gz file.")
 }
 
-func removeCommand(pluginName string) {
-	lockFile, err := lib.GetLockfile(cfgFile)
+func removeCommand(ctx context.Context, pluginName string) error {
+	lockFile, err := lib.GetLockfile(ctx, cfgFile)
 	if err != nil {
-		message.Error(err.Error())
+		return err
 	}
 	defer lockFile.Close()
 
+	pluginName = resolvePluginName(lockFile, pluginName)
 	plugin := lockFile.GetPlugin(pluginName)
 	if plugin == nil {
-		message.Error("plugin %s not found", pluginName)
+		return lockFile.PluginNotFound(pluginName)
+	}
+
+	sessionDir := ""
+	if lib.IsSessionPlugin(pluginName) {
+		sessionDir, err = preserveSessions(lockFile, plugin)
+		if err != nil {
+			return err
+		}
 	}
 
-	if err := plugin.Uninstall(); err != nil {
-		message.Error(err.Error())
+	if err := uninstallPlugin(ctx, plugin); err != nil {
+		return err
 	}
 
-	delete(lockFile.PluginSpecs, pluginName)
+	if rKeepConfigFlag {
+		lockFile.RemoveLocked(pluginName)
+	} else {
+		lockFile.Remove(pluginName)
+	}
 
 	if err := lockFile.Save(); err != nil {
-		message.Error(err.Error())
+		return err
+	}
+
+	if rPurgeConfigFlag {
+		if err := purgeSnippets(pluginName); err != nil {
+			return err
+		}
+	}
+	if sessionDir != "" && !lockFile.HasSessionPlugin() {
+		if err := purgeSessions(sessionDir); err != nil {
+			return err
+		}
+	}
+
+	if rKeepConfigFlag {
+		message.Info("Successfully uninstalled plugin %s, keeping it in the config file", pluginName)
+	} else {
+		message.Info("Successfully uninstalled plugin %s", pluginName)
+	}
+	return nil
+}
+
+// Removes the plugin's snippets from the tmux config.
+func purgeSnippets(pluginName string) error {
+	tmuxConfigPath, err := lib.GetTmuxConfigPath()
+	if err != nil {
+		return err
+	}
+	original, err := os.ReadFile(tmuxConfigPath)
+	if err != nil {
+		return err
+	}
+
+	purged, changed := lib.RemoveSnippets(string(original), pluginName)
+	if !changed {
+		message.Info("No snippets for plugin %s found in %s", pluginName, tmuxConfigPath)
+		return nil
+	}
+
+	if err := writeTmuxConfig(tmuxConfigPath, string(original), purged); err != nil {
+		return err
+	}
+	message.Info("Removed snippets for plugin %s from %s, a backup is at %s.bak", pluginName, tmuxConfigPath, tmuxConfigPath)
+	return nil
+}
+
+// Exports the sessions saved by a session plugin being removed, if asked
+// to, and moves them out of the plugin's directory so they are not
+// deleted with it. Returns where the sessions are now.
+func preserveSessions(lockFile *lib.Lockfile, plugin *lib.Plugin) (string, error) {
+	sessionDir, err := lockFile.SessionDir()
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(sessionDir); errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+
+	if rExportSessionsFlag != "" {
+		if err := lib.ExportSessions(sessionDir, rExportSessionsFlag); err != nil {
+			return "", fmt.Errorf("unable to export the saved tmux sessions: %w", err)
+		}
+		message.Info("Exported the tmux sessions saved in %s to %s", sessionDir, rExportSessionsFlag)
 	}
 
-	message.Info("Successfully uninstalled plugin %s", pluginName)
+	holds, err := plugin.HoldsSessions(sessionDir)
+	if err != nil || !holds {
+		return sessionDir, err
+	}
+	moved, err := lib.DefaultSessionDir()
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(moved); err == nil {
+		return "", fmt.Errorf("the tmux sessions saved in %s would be deleted with plugin %s, "+
+			"and can't be moved to %s as it already exists", sessionDir, plugin.Name, moved)
+	}
+	if err := os.MkdirAll(path.Dir(moved), 0750); err != nil {
+		return "", err
+	}
+	if err := os.Rename(sessionDir, moved); err != nil {
+		return "", err
+	}
+	message.Warning("Moved the tmux sessions saved in %s to %s, so they are not deleted with plugin %s",
+		sessionDir, moved, plugin.Name)
+	return moved, nil
+}
+
+// Asks whether to delete saved tmux sessions that no plugin uses any
+// more, when purging. They are kept otherwise.
+func purgeSessions(sessionDir string) error {
+	if rPurgeConfigFlag && message.Confirm("Also delete the tmux sessions saved in %s?", sessionDir) {
+		if err := os.RemoveAll(sessionDir); err != nil {
+			return err
+		}
+		message.Info("Deleted the tmux sessions saved in %s", sessionDir)
+		return nil
+	}
+	message.Info("Kept the tmux sessions saved in %s", sessionDir)
+	return nil
 }
@@ -0,0 +1,56 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"sync"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+)
+
+// Plugins are upgraded concurrently, so reviews take turns.
+var reviewLock sync.Mutex
+
+// Lists the scripts a plugin will run when loaded and asks whether to run
+// them, offering to show how an upgrade changes them. Returns true without
+// asking if yes is set or nobody can be asked.
+func confirmScripts(pluginName string, review *lib.ScriptReview, yes bool) bool {
+	if yes || !message.Interactive() {
+		return true
+	}
+	reviewLock.Lock()
+	defer reviewLock.Unlock()
+
+	if len(review.Scripts) == 0 {
+		message.Info("Plugin %s has no scripts to run", pluginName)
+	} else {
+		message.Info("Plugin %s runs these scripts when loaded:", pluginName)
+		for _, script := range review.Scripts {
+			message.Info("  %s", script)
+		}
+	}
+	if len(review.Hooks) > 0 {
+		message.Info("And these when it is installed or removed:")
+		for _, hook := range review.Hooks {
+			message.Info("  %s", hook)
+		}
+	}
+	if review.Diff != "" && message.Confirm("Show the changes to its scripts?") {
+		message.Info("%s", review.Diff)
+	}
+	return message.Confirm("Run the scripts of %s when tmux loads plugins?", pluginName)
+}
@@ -0,0 +1,129 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+	"github.com/spf13/cobra"
+)
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback [plugin]",
+	Short: "Restores the version of a plugin before it was upgraded",
+	Long: `Checks out the version a plugin had before it was last upgraded, and
+restores it in the config file and tim.lock.
+
+Without a plugin, every plugin changed by the most recent upgrade is
+rolled back. Running rollback again goes further back.
+
+Previous versions are kept for each plugin, 5 by default. Set
+"history_depth" in the config file to keep more or fewer, or 0 to keep
+none. Pass "--list" to show the versions kept.`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completePluginName,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pluginName := ""
+		if len(args) > 0 {
+			pluginName = pluginNameArg(args[0])
+		}
+		return rollbackCommand(cmd.Context(), pluginName)
+	},
+}
+
+var (
+	rbListFlag bool
+)
+
+func init() {
+	rootCmd.AddCommand(rollbackCmd)
+	rollbackCmd.Flags().BoolVar(&rbListFlag, "list", false, "List the previous versions kept for each plugin.")
+}
+
+func rollbackCommand(ctx context.Context, pluginName string) error {
+	history, err := lib.GetHistory()
+	if err != nil {
+		return err
+	}
+	if rbListFlag {
+		listHistory(history, pluginName)
+		return nil
+	}
+
+	lockFile, err := lib.GetLockfile(ctx, cfgFile)
+	if err != nil {
+		return err
+	}
+	defer lockFile.Close()
+
+	names := []string{pluginName}
+	if pluginName == "" {
+		names = history.LastReplaced()
+		slices.Sort(names)
+	}
+	if len(names) == 0 {
+		message.Info("Nothing to roll back")
+		return nil
+	}
+
+	failures := 0
+	for _, name := range names {
+		revision, err := lockFile.Rollback(ctx, history, name)
+		if err != nil {
+			warnFailed(name, "roll back", err)
+			failures++
+			continue
+		}
+		message.Fields{Plugin: name, Version: revision.Ref}.Info(
+			"Plugin %s rolled back to %s (%.10s)", name, revision.Ref, revision.Commit)
+	}
+
+	if err := lockFile.Save(); err != nil {
+		return err
+	}
+	if err := history.Save(); err != nil {
+		return err
+	}
+	if failures > 0 {
+		return lib.WithErrorCode(lib.CodePluginsFailed, fmt.Errorf("%d of %d plugins failed to roll back", failures, len(names)))
+	}
+	return nil
+}
+
+// Prints the previous versions kept for the plugin, or every plugin.
+func listHistory(history *lib.History, pluginName string) {
+	names := slices.Sorted(maps.Keys(history.Plugins))
+	if pluginName != "" {
+		names = []string{pluginName}
+	}
+
+	found := false
+	for _, name := range names {
+		for _, revision := range history.Plugins[name] {
+			found = true
+			message.Fields{Plugin: name, Version: revision.Ref, Data: revision}.Info(
+				"%s %s (%.10s), replaced %s", name, revision.Ref, revision.Commit, message.FormatTime(revision.ReplacedAt))
+		}
+	}
+	if !found {
+		message.Info("No previous versions kept")
+	}
+}
@@ -16,10 +16,20 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/kjnsn/tim/lib"
 	"github.com/kjnsn/tim/lib/message"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -30,26 +40,254 @@ var rootCmd = &cobra.Command{
 
 Tim manages plugins for tmux and optionaly ensures that the tmux
 configuration is setup with opinionated defaults.`,
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+	// Errors are printed by Execute.
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if rootVersionFlag {
+			return versionCommand(cmd.Context())
+		}
+		return cmd.Help()
+	},
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Arguments have been validated by now, so errors from here on
+		// are not helped by printing the usage.
+		cmd.SilenceUsage = true
 		message.DebugEnabled = enableVerbose
+		lib.AllowDirtyConfig = allowDirtyConfig
+		message.PagerDisabled = disablePager
+		message.JSONEnabled = enableJSON
+		message.UseUTC = useUTC
+		message.StrictEnabled = enableStrict
+		lib.WaitForLock = waitForLock
+		if err := lib.SetPluginDirOverride(pluginDirOverride); err != nil {
+			return fmt.Errorf("invalid --plugin-dir: %w", err)
+		}
+		if operationTimeout > 0 {
+			var ctx context.Context
+			ctx, cancelTimeout = context.WithTimeout(cmd.Context(), operationTimeout)
+			cmd.SetContext(ctx)
+		}
+		// The report printed by "upgrade --notes" has stdout to itself.
+		if cmd == upgradeCmd && uNotes == "-" {
+			message.Output = stderr
+		}
+		// Loading runs when tmux starts, with nowhere to draw progress.
+		if cmd != loadCmd && !isCompletionRequest(cmd) {
+			cmd.SetContext(withProgressBar(cmd.Context()))
+		}
+
+		// The migrations command reports pending migrations itself, and
+		// completions must not print anything else.
+		if cmd != migrationsCmd && !isCompletionRequest(cmd) {
+			if _, err := runMigrations(cmd.Context(), false); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		// Printing paths must be quick, and print nothing else.
+		if cmd == infoCmd && iPathsFlag {
+			return
+		}
+		// Loading must be quick, and hooks don't change any plugins.
+		if cmd != loadCmd && !isHookCommand(cmd) && !isCompletionRequest(cmd) {
+			refreshCompletionCache(cmd.Context())
+		}
+		// Loading, hooks and watch are run from tmux or in the background,
+		// where a notice would get in the way, and version and self-update
+		// check for releases themselves.
+		quiet := cmd == loadCmd || isHookCommand(cmd) || cmd == watchCmd || isCompletionRequest(cmd)
+		if !quiet && cmd != versionCmd && cmd != selfUpdateCmd && !rootVersionFlag {
+			notifyTimUpdate(cmd.Context())
+		}
 	},
 }
 
 var cfgFile string
+var pluginDirOverride string
 var enableVerbose bool
-var TimVersion string
+var rootVersionFlag bool
+var allowDirtyConfig bool
+var disablePager bool
+var enableJSON bool
+var useUTC bool
+var operationTimeout time.Duration
+var cancelTimeout context.CancelFunc = func() {}
+var enableStrict bool
+var waitForLock bool
+var buildInfo lib.BuildInfo
+
+// Where output for other programs is written, such as JSON and reports.
+// Messages are written to message.Output.
+var stdout io.Writer = os.Stdout
+
+// Where output only for people is written, such as progress.
+var stderr io.Writer = os.Stderr
+
+// An error with structured fields, shown in JSON mode when it is printed.
+type fieldsError struct {
+	fields message.Fields
+	err    error
+}
+
+func (e *fieldsError) Error() string {
+	return e.err.Error()
+}
+
+func (e *fieldsError) Unwrap() error {
+	return e.err
+}
+
+// Returns the fields of a warning that a plugin failed, with the code
+// of its error.
+func failureFields(plugin string, err error) message.Fields {
+	return message.Fields{Plugin: plugin, Code: string(lib.ErrorCodeOf(err))}
+}
+
+// How to fix the common causes of plugins failing.
+var remedies = []struct {
+	err    error
+	remedy string
+}{
+	{lib.ErrGitAuth, "check the credentials for its remote, which can be set in \"network\" in the config file"},
+	{lib.ErrRemoteNotFound, "check its name, or set its \"remote\" in the config file if it has moved"},
+	{lib.ErrNetwork, "check the network connection, or set a proxy in \"network\" in the config file"},
+	{lib.ErrRefNotFound, "run \"tim upgrade\" to move it to a version that still exists"},
+}
+
+// Warns that plugin failed to do action, with how to fix the cause of err
+// if it is a common one.
+func warnFailed(plugin, action string, err error) {
+	for _, known := range remedies {
+		if errors.Is(err, known.err) {
+			failureFields(plugin, err).Warning("Plugin %s failed to %s: %s. To fix this, %s",
+				plugin, action, err, known.remedy)
+			return
+		}
+	}
+	failureFields(plugin, err).Warning("Plugin %s failed to %s: %s", plugin, action, err)
+}
+
+// Exits with code without printing anything, for commands whose result
+// is their exit status.
+type exitError struct {
+	code int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
-func Execute(ver string) {
-	TimVersion = ver
-	err := rootCmd.Execute()
+//
+// This is the only place tim exits with a non-zero status.
+func Execute(info lib.BuildInfo) {
+	// Cancel outstanding git commands on ctrl-c, or when --timeout passes.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := Run(ctx, info, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	if code != 0 {
+		os.Exit(code)
+	}
+}
+
+// Runs tim with args, writing to out and errOut rather than stdout and
+// stderr, and returns its exit status. Flags start from their defaults,
+// and every command runs with ctx, each time, so Run can be called more
+// than once, such as by tests.
+//
+// Commands return their errors rather than exiting, so that deferred
+// cleanup such as closing the config file always runs.
+func Run(ctx context.Context, info lib.BuildInfo, args []string, out, errOut io.Writer) int {
+	buildInfo = info
+	lib.TimVersion = info.Version
+	stdout, stderr = out, errOut
+	lib.Stdout, lib.Stderr = out, errOut
+	message.Output = out
+	message.ResetWarningCount()
+	countPlugins(0)
+	rootCmd.SetOut(out)
+	rootCmd.SetErr(errOut)
+	resetCommands(ctx, rootCmd)
+
+	rootCmd.SetArgs(addCustomCommands(args))
+	err := rootCmd.ExecuteContext(ctx)
+	cancelTimeout()
+	cancelTimeout = func() {}
 	if err != nil {
-		os.Exit(1)
+		message.StopPager()
+		var exit *exitError
+		if errors.As(err, &exit) {
+			return exit.code
+		}
+		fields := message.Fields{}
+		var withFields *fieldsError
+		if errors.As(err, &withFields) {
+			fields = withFields.fields
+		}
+		fields.Code = string(lib.ErrorCodeOf(err))
+		fields.Error("%s", err)
+		return 1
+	}
+
+	if message.StrictEnabled && message.WarningCount() > 0 {
+		message.StopPager()
+		fmt.Fprintf(errOut, "%d warnings in strict mode\n", message.WarningCount())
+		return message.ExitStrictWarnings
+	}
+	return 0
+}
+
+// Sets the flags of cmd and its subcommands back to their defaults, and
+// their context to ctx, which cobra otherwise keeps from the first run.
+func resetCommands(ctx context.Context, cmd *cobra.Command) {
+	reset := func(flag *pflag.Flag) {
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			slice.Replace(nil)
+		} else {
+			flag.Value.Set(flag.DefValue)
+		}
+		flag.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+	cmd.SetContext(ctx)
+	for _, sub := range cmd.Commands() {
+		resetCommands(ctx, sub)
 	}
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is ~/.config/tim/tim.json)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file, in JSON, TOML or yaml (default is ~/.config/tim/tim.json)")
+	rootCmd.PersistentFlags().StringVar(&pluginDirOverride, "plugin-dir", "",
+		"directory to install plugins in, instead of plugin_dir in the config file")
+	rootCmd.Flags().BoolVar(&rootVersionFlag, "version", false, "print the version of tim, as \"tim version\" does")
 	rootCmd.PersistentFlags().BoolVarP(&enableVerbose, "verbose", "v", false, "print verbose information")
+	rootCmd.PersistentFlags().BoolVar(&allowDirtyConfig, "allow-dirty-config", false,
+		"save the config file even if it has unknown keys or a newer schema version, discarding them")
+	rootCmd.PersistentFlags().BoolVar(&disablePager, "no-pager", false, "do not pipe long output into a pager")
+	rootCmd.PersistentFlags().BoolVar(&enableJSON, "json", false, "print output as JSON, one object per line")
+	rootCmd.PersistentFlags().BoolVar(&useUTC, "utc", false, "show times in UTC rather than the local timezone")
+	rootCmd.PersistentFlags().BoolVar(&enableStrict, "strict", false,
+		"treat warnings as errors, exiting with status 2 if there are any")
+	rootCmd.PersistentFlags().BoolVar(&waitForLock, "wait", false,
+		"wait for other tim processes to finish, rather than failing")
+	rootCmd.PersistentFlags().DurationVar(&operationTimeout, "timeout", 0,
+		"the longest the whole command may take, such as 30s, 0 for no limit")
+}
+
+// Resolves a plugin argument, which may be a clone URL, to a plugin name.
+func pluginNameArg(arg string) string {
+	if name, _, err := lib.ParsePluginArg(arg); err == nil {
+		return name
+	}
+	return strings.ToLower(strings.TrimSpace(arg))
+}
+
+// Resolves a plugin argument, which may be an alias in the config file,
+// to a plugin name.
+func resolvePluginName(lockFile *lib.Lockfile, arg string) string {
+	return pluginNameArg(lockFile.ResolveAlias(arg))
 }
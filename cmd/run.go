@@ -0,0 +1,155 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+	"github.com/spf13/cobra"
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run <plugin> [script]",
+	Short: "Runs one of a plugin's scripts",
+	Long: `Runs one of the scripts "tim load" runs for a plugin, with the same
+environment and after setting the plugin's options, printing its output as
+it runs. Without a script, lists the plugin's scripts.
+
+The script is given by its path in the plugin's directory, or just its name.
+tim exits with the script's exit status.
+
+Pass "--trace" to print each command a shell script runs, and "--verbose"
+for what tim does before running it.`,
+	Args:              cobra.RangeArgs(1, 2),
+	ValidArgsFunction: completeRunArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		script := ""
+		if len(args) > 1 {
+			script = args[1]
+		}
+		return runCommand(cmd.Context(), pluginNameArg(args[0]), script)
+	},
+}
+
+var (
+	ruTraceFlag bool
+)
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+	runCmd.Flags().BoolVar(&ruTraceFlag, "trace", false,
+		"Print each command the script runs, for shell scripts.")
+}
+
+func runCommand(ctx context.Context, pluginName, script string) error {
+	lockFile, err := lib.ReadLockfile(cfgFile)
+	if err != nil {
+		return err
+	}
+	defer lockFile.Close()
+
+	plugin := lockFile.GetPlugin(pluginName)
+	if plugin == nil {
+		return lib.PluginNotFound(pluginName)
+	}
+	if err := plugin.CheckInstalled(); err != nil {
+		return err
+	}
+
+	if script == "" {
+		return listScripts(plugin)
+	}
+	err = plugin.RunScript(ctx, script, ruTraceFlag)
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		message.Debug("%s exited with status %d", script, exit.ExitCode())
+		return &exitError{code: exit.ExitCode()}
+	}
+	return err
+}
+
+// Prints the scripts run when loading the plugin, by their paths in the
+// plugin's directory.
+func listScripts(plugin *lib.Plugin) error {
+	pluginDir, err := plugin.Dir()
+	if err != nil {
+		return err
+	}
+	entrypoints, err := plugin.Entrypoints()
+	if err != nil {
+		return err
+	}
+	if len(entrypoints) == 0 {
+		message.Info("Plugin %s has no scripts to run", plugin.Name)
+		return nil
+	}
+
+	message.Info("Scripts of %s:", plugin.Name)
+	for _, entrypoint := range entrypoints {
+		script, err := newPluginScript(entrypoint)
+		if err != nil {
+			return err
+		}
+		if relative, err := filepath.Rel(pluginDir, entrypoint); err == nil {
+			script.Path = relative
+		}
+		message.Fields{Plugin: plugin.Name, Data: script}.Info("  %s, %s", script.Path, describeScript(script))
+	}
+	return nil
+}
+
+// Completes the plugin, then the names of its scripts.
+func completeRunArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return completePluginNames(cmd, args, toComplete)
+	}
+	if len(args) > 1 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	lockFile, err := lib.ReadLockfile(cfgFile)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	defer lockFile.Close()
+	plugin := lockFile.GetPlugin(pluginNameArg(args[0]))
+	if plugin == nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	pluginDir, err := plugin.Dir()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	entrypoints, err := plugin.Entrypoints()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	scripts := make([]string, 0)
+	for _, entrypoint := range entrypoints {
+		relative, err := filepath.Rel(pluginDir, entrypoint)
+		if err == nil && strings.HasPrefix(relative, toComplete) {
+			scripts = append(scripts, relative)
+		}
+	}
+	return scripts, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,89 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+	"github.com/spf13/cobra"
+)
+
+var searchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Searches for plugins",
+	Long: `Searches github for repositories with the "tmux-plugin" topic, showing
+the most starred first. Results are cached for a day.
+
+Any plugin found can be added by name, for example "tim add tmux-resurrect"
+after searching for "resurrect", as well as with <username>/<repo>.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return searchCommand(cmd.Context(), strings.Join(args, " "))
+	},
+}
+
+var (
+	seLimit int
+)
+
+// The longest description shown in the table of results.
+const maxDescriptionLength = 60
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+	searchCmd.Flags().IntVarP(&seLimit, "limit", "n", 20, "Maximum number of plugins to show.")
+}
+
+func searchCommand(ctx context.Context, query string) error {
+	results, err := lib.SearchPlugins(ctx, query)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		message.Info("No plugins found matching %q", query)
+		return nil
+	}
+	if seLimit > 0 && len(results) > seLimit {
+		results = results[:seLimit]
+	}
+
+	if message.JSONEnabled {
+		for _, result := range results {
+			message.Fields{Plugin: result.Name, Data: result}.Info("Plugin %s", result.Name)
+		}
+		return nil
+	}
+
+	message.StartPager()
+	defer message.StopPager()
+
+	table := tabwriter.NewWriter(message.Output, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "PLUGIN\tSTARS\tDESCRIPTION")
+	for _, result := range results {
+		description := result.Description
+		if runes := []rune(description); len(runes) > maxDescriptionLength {
+			description = strings.TrimSpace(string(runes[:maxDescriptionLength-3])) + "..."
+		}
+		fmt.Fprintf(table, "%s\t%d\t%s\n", message.Hyperlink(result.URL, result.Name), result.Stars, description)
+	}
+	table.Flush()
+	return nil
+}
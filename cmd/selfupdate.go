@@ -0,0 +1,95 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+	"github.com/spf13/cobra"
+	"golang.org/x/mod/semver"
+)
+
+var selfUpdateCmd = &cobra.Command{
+	Use:   "self-update",
+	Short: "Updates tim to the latest release",
+	Long: `Checks github for a newer release of tim, and replaces the running
+tim binary with it. The download is verified against the checksums
+published with the release.
+
+Pass "--check" to only report whether a newer release is available.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return selfUpdateCommand(cmd.Context())
+	},
+}
+
+var (
+	suCheckFlag bool
+)
+
+func init() {
+	rootCmd.AddCommand(selfUpdateCmd)
+	selfUpdateCmd.Flags().BoolVar(&suCheckFlag, "check", false,
+		"Only check if a newer release of tim is available.")
+}
+
+func selfUpdateCommand(ctx context.Context) error {
+	// Builds from source are not replaced by a release behind their back.
+	if !semver.IsValid(buildInfo.Version) {
+		return fmt.Errorf("tim %s is not a release, so it cannot be updated to one. Install a release of tim first", orUnknown(buildInfo.Version))
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	executable, err = filepath.EvalSymlinks(executable)
+	if err != nil {
+		return err
+	}
+
+	release, err := lib.GetLatestTimRelease(ctx)
+	if err != nil {
+		return err
+	}
+
+	fields := message.Fields{Version: release.TagName}
+	if semver.Compare(release.TagName, buildInfo.Version) != 1 {
+		fields.Info("tim %s is up-to-date", orUnknown(buildInfo.Version))
+		return nil
+	}
+	if suCheckFlag {
+		fields.Info("A newer version of tim is available: %s -> %s", orUnknown(buildInfo.Version), release.TagName)
+		return nil
+	}
+
+	message.Info("Downloading tim %s for %s/%s", release.TagName, runtime.GOOS, runtime.GOARCH)
+	binary, err := release.DownloadBinary(ctx, runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return err
+	}
+
+	if err := lib.ReplaceExecutable(executable, binary); err != nil {
+		return fmt.Errorf("Unable to replace %s: %w", executable, err)
+	}
+	fields.Info("Updated tim from %s to %s", orUnknown(buildInfo.Version), release.TagName)
+	return nil
+}
@@ -0,0 +1,147 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+	"github.com/spf13/cobra"
+)
+
+var snippetsCmd = &cobra.Command{
+	Use:   "snippets [plugin]",
+	Short: "Shows example tmux config shipped by a plugin",
+	Long: `Shows the example tmux config snippets shipped by the given plugin.
+Snippets are listed in the plugin's tim-plugin.json manifest, or found
+by looking for files such as "example.conf" or "tmux.conf.example".
+
+Pass "--insert" to copy the snippets into a block managed by tim in the
+tmux config file. Snippets setting an option to a different value than
+the tmux config are not inserted unless "--force" is given. A backup of
+the tmux config file is written first.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completePluginName,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return snippetsCommand(pluginNameArg(args[0]))
+	},
+}
+
+var (
+	sInsertFlag bool
+	sFileFlag   string
+	sForceFlag  bool
+)
+
+func init() {
+	rootCmd.AddCommand(snippetsCmd)
+	snippetsCmd.Flags().BoolVar(&sInsertFlag, "insert", false,
+		"Insert the snippets into the tmux config.")
+	snippetsCmd.Flags().StringVar(&sFileFlag, "file", "",
+		"Only use the snippet at the given path in the plugin.")
+	snippetsCmd.Flags().BoolVar(&sForceFlag, "force", false,
+		"Insert snippets even if they conflict with the tmux config.")
+}
+
+func snippetsCommand(pluginName string) error {
+	lockFile, err := lib.ReadLockfile(cfgFile)
+	if err != nil {
+		return err
+	}
+	defer lockFile.Close()
+
+	plugin := lockFile.GetPlugin(pluginName)
+	if plugin == nil {
+		return lib.PluginNotFound(pluginName)
+	}
+	if err := plugin.CheckInstalled(); err != nil {
+		return fmt.Errorf("Plugin %s: %w", pluginName, err)
+	}
+
+	snippets, err := plugin.Snippets()
+	if err != nil {
+		return err
+	}
+	if sFileFlag != "" {
+		selected := make([]lib.Snippet, 0, 1)
+		for _, snippet := range snippets {
+			if snippet.Path == sFileFlag {
+				selected = append(selected, snippet)
+			}
+		}
+		snippets = selected
+	}
+	if len(snippets) == 0 {
+		message.Warning("No snippets found for plugin %s", pluginName)
+		return nil
+	}
+
+	if sInsertFlag {
+		return insertSnippets(plugin, snippets)
+	}
+
+	message.StartPager()
+	defer message.StopPager()
+	for _, snippet := range snippets {
+		message.Fields{Plugin: plugin.Name, Data: snippet}.Info("# %s\n%s", snippet.Path, snippet.Content)
+	}
+	return nil
+}
+
+// Inserts the snippets into the tmux config, skipping those that conflict.
+func insertSnippets(plugin *lib.Plugin, snippets []lib.Snippet) error {
+	tmuxConfigPath, err := lib.GetTmuxConfigPath()
+	if err != nil {
+		return err
+	}
+	original, err := os.ReadFile(tmuxConfigPath)
+	if err != nil {
+		return err
+	}
+
+	tmuxConfig := string(original)
+	inserted := 0
+	for _, snippet := range snippets {
+		conflicts := lib.SnippetConflicts(tmuxConfig, snippet)
+		for _, conflict := range conflicts {
+			message.Fields{Plugin: plugin.Name, Data: conflict}.Warning("Snippet %s sets %s to %q, but it is %q in %s",
+				snippet.Path, conflict.Option, conflict.Snippet, conflict.Existing, tmuxConfigPath)
+		}
+		if len(conflicts) > 0 && !sForceFlag {
+			message.Info("Skipping snippet %s, pass --force to insert it anyway", snippet.Path)
+			continue
+		}
+
+		var changed bool
+		tmuxConfig, changed = lib.InsertSnippet(tmuxConfig, plugin.Name, snippet)
+		if !changed {
+			message.Info("Snippet %s is already in %s", snippet.Path, tmuxConfigPath)
+			continue
+		}
+		inserted++
+	}
+
+	if inserted == 0 {
+		return nil
+	}
+	if err := writeTmuxConfig(tmuxConfigPath, string(original), tmuxConfig); err != nil {
+		return err
+	}
+	message.Info("Inserted %d snippets into %s, a backup is at %s.bak", inserted, tmuxConfigPath, tmuxConfigPath)
+	return nil
+}
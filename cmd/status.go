@@ -0,0 +1,113 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+	"github.com/spf13/cobra"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status [plugin...]",
+	Short: "Shows plugins that have been changed by hand",
+	Long: `Shows whether every plugin, or just those given, has been changed since
+it was installed, so you know what "tim upgrade" might overwrite.
+
+Modified and untracked files are listed, as are plugins with a different
+commit checked out than the one recorded in tim.lock, and plugins on a
+branch with commits that are not upstream.
+
+Plugins loaded from a local directory with "tim add --path" are not
+shown, since they are expected to change.`,
+	Args:              cobra.ArbitraryArgs,
+	ValidArgsFunction: completePluginNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return statusCommand(cmd.Context(), args)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+}
+
+func statusCommand(ctx context.Context, pluginNames []string) error {
+	lockFile, err := lib.ReadLockfile(cfgFile)
+	if err != nil {
+		return err
+	}
+	defer lockFile.Close()
+
+	for _, name := range pluginNames {
+		if _, ok := lockFile.PluginSpecs[name]; !ok {
+			return lockFile.PluginNotFound(name)
+		}
+	}
+
+	plugins := lockFile.Plugins()
+	slices.SortFunc(plugins, func(a, b lib.Plugin) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	changed := 0
+	for _, plugin := range plugins {
+		if len(pluginNames) > 0 && !slices.Contains(pluginNames, plugin.Name) {
+			continue
+		}
+		if plugin.IsLocal() {
+			message.Debug("Plugin %s is loaded from %s, not showing its status", plugin.Name, plugin.Path)
+			continue
+		}
+		if err := plugin.CheckInstalled(); errors.Is(err, lib.ErrPluginNotInstalled) {
+			message.Fields{Plugin: plugin.Name}.Info("%s: not installed", plugin.Name)
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		status, err := plugin.Status(ctx, lockFile.Locked[plugin.Name])
+		if err != nil {
+			failureFields(plugin.Name, err).Warning("Unable to find the status of plugin %s: %s", plugin.Name, err)
+			continue
+		}
+		problems := status.Problems()
+		fields := message.Fields{Plugin: plugin.Name, Data: status}
+		if len(problems) == 0 {
+			fields.Info("%s: clean", plugin.Name)
+			continue
+		}
+		changed++
+		fields.Info("%s: %s", plugin.Name, strings.Join(problems, ", "))
+		if message.JSONEnabled {
+			continue
+		}
+		for _, file := range status.Modified {
+			message.Info("  M %s", file)
+		}
+		for _, file := range status.Untracked {
+			message.Info("  ? %s", file)
+		}
+	}
+
+	if changed > 0 {
+		message.Info("%d plugins have changes that upgrading might overwrite", changed)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+	"github.com/spf13/cobra"
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Installs exactly the versions in tim.lock",
+	Long: `Installs every plugin at exactly the commit recorded in tim.lock.
+
+tim.lock is generated next to the config file by "add" and "upgrade", and
+records the commit each plugin resolved to. Commit it alongside the config
+file to reproduce the same plugins on another machine with "sync".
+
+Plugins in the config file that are missing from tim.lock, such as those
+added with "tim lock set", are installed at the version in the config
+file and recorded in tim.lock.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return syncCommand(cmd.Context())
+	},
+}
+
+var (
+	sJobs int
+)
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+	syncCmd.Flags().IntVarP(&sJobs, "jobs", "j", defaultJobs, "Number of plugins to install concurrently, one per CPU by default.")
+}
+
+func syncCommand(ctx context.Context) error {
+	lockFile, err := lib.GetLockfile(ctx, cfgFile)
+	if err != nil {
+		return err
+	}
+	defer lockFile.Close()
+
+	plugins := lockFile.Plugins()
+	for i, plugin := range plugins {
+		if locked, ok := lockFile.Locked[plugin.Name]; ok && locked.Remote != "" {
+			plugins[i].Remote = locked.Remote
+		}
+	}
+
+	var lockSync sync.Mutex
+	installed := 0
+	failures := forEachPlugin(sJobs, plugins, func(plugin *lib.Plugin) error {
+		locked, ok := lockFile.Locked[plugin.Name]
+		if !ok {
+			if err := installUnlocked(ctx, lockFile, &lockSync, plugin); err != nil {
+				return err
+			}
+			lockSync.Lock()
+			defer lockSync.Unlock()
+			installed++
+			return nil
+		}
+		isNew := isNewPlugin(plugin)
+		if err := plugin.InstallLocked(ctx, locked); err != nil {
+			warnFailed(plugin.Name, "sync", err)
+			return err
+		}
+		if isNew {
+			runLifecycleHook(ctx, plugin, lib.LifecyclePostInstall)
+		}
+		message.Info("Plugin %s synced to %s (%.10s)", plugin.Name, locked.Ref, locked.Commit)
+		return nil
+	})
+
+	if installed > 0 {
+		if err := lockFile.Save(); err != nil {
+			return err
+		}
+	}
+	if len(failures) > 0 {
+		return lib.WithErrorCode(lib.CodePluginsFailed, fmt.Errorf("%d of %d plugins failed to sync", len(failures), len(plugins)))
+	}
+	return nil
+}
+
+// Installs a plugin missing from tim.lock at the version in the config
+// file, and records it.
+func installUnlocked(ctx context.Context, lockFile *lib.Lockfile, lockSync *sync.Mutex, plugin *lib.Plugin) error {
+	spec := lockFile.PluginSpecs[plugin.Name]
+	// Install resolves the version from the spec.
+	plugin.Version = nil
+	isNew := isNewPlugin(plugin)
+	if err := plugin.Install(ctx, spec.Version); err != nil {
+		warnFailed(plugin.Name, "install", err)
+		return err
+	}
+	if isNew {
+		runLifecycleHook(ctx, plugin, lib.LifecyclePostInstall)
+	}
+
+	lockSync.Lock()
+	defer lockSync.Unlock()
+	var err error
+	if spec.Version == "" {
+		// Pin the version that was resolved, as "tim add" does.
+		err = lockFile.SetPlugin(ctx, plugin)
+	} else {
+		err = lockFile.Record(ctx, plugin)
+	}
+	if err != nil {
+		return err
+	}
+	message.Info("Plugin %s installed at %s and added to %s", plugin.Name, plugin.Version, lockFile.LockPath())
+	return nil
+}
@@ -0,0 +1,184 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+	"github.com/spf13/cobra"
+)
+
+var teamCmd = &cobra.Command{
+	Use:   "team",
+	Short: "Shares pinned plugins across a team",
+	Long: `Shares the exact plugins, commits and tmux options used on one machine
+with a team, so everyone works in the same tmux environment.
+
+Use "tim team export" to write a team manifest, and "tim team apply" on
+each machine to install exactly what it describes.`,
+}
+
+var teamExportCmd = &cobra.Command{
+	Use:   "export [file]",
+	Short: "Writes a team manifest of the installed plugins",
+	Long: `Writes a team manifest of every plugin in the config file, pinned to the
+commit recorded in tim.lock, along with their tmux options and the oldest
+tmux version they are known to work with. Environment variables are not
+exported, as they often hold machine specific paths or secrets.
+
+Without a file, the manifest is printed instead. Pass "--min-tmux" to
+require a newer tmux than tim works out from the plugins.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manifestPath := ""
+		if len(args) > 0 {
+			manifestPath = args[0]
+		}
+		return teamExportCommand(manifestPath)
+	},
+}
+
+var teamApplyCmd = &cobra.Command{
+	Use:   "apply <file>",
+	Short: "Installs exactly the plugins in a team manifest",
+	Long: `Reports how the config file differs from the team manifest, then
+installs every plugin in the manifest at exactly its commit, and removes
+plugins the team does not use.
+
+The installed tmux must be at least the version the manifest requires.
+
+Pass "--check" to only report the differences, exiting with an error if
+there are any, for example in CI or a login script. Pass "--keep-extra"
+to keep plugins that are not in the manifest.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return teamApplyCommand(cmd.Context(), args[0])
+	},
+}
+
+var (
+	tmMinTmuxFlag   string
+	tmCheckFlag     bool
+	tmKeepExtraFlag bool
+	tmJobs          int
+)
+
+func init() {
+	rootCmd.AddCommand(teamCmd)
+	teamCmd.AddCommand(teamExportCmd)
+	teamCmd.AddCommand(teamApplyCmd)
+	teamExportCmd.Flags().StringVar(&tmMinTmuxFlag, "min-tmux", "",
+		"The oldest tmux version the team may use, such as 3.3a.")
+	teamApplyCmd.Flags().BoolVar(&tmCheckFlag, "check", false,
+		"Only report differences from the manifest, failing if there are any.")
+	teamApplyCmd.Flags().BoolVar(&tmKeepExtraFlag, "keep-extra", false,
+		"Keep plugins that are not in the manifest.")
+	teamApplyCmd.Flags().IntVarP(&tmJobs, "jobs", "j", defaultJobs,
+		"Number of plugins to install concurrently, one per CPU by default.")
+}
+
+func teamExportCommand(manifestPath string) error {
+	lockFile, err := lib.ReadLockfile(cfgFile)
+	if err != nil {
+		return err
+	}
+	defer lockFile.Close()
+
+	manifest, err := lockFile.TeamManifest()
+	if err != nil {
+		return err
+	}
+	if tmMinTmuxFlag != "" {
+		if _, err := lib.CompareTmuxVersions(tmMinTmuxFlag, lib.MinTmuxVersion); err != nil {
+			return err
+		}
+		manifest.MinTmux = tmMinTmuxFlag
+	}
+
+	encoded, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	encoded = append(encoded, '\n')
+
+	if manifestPath == "" {
+		_, err := stdout.Write(encoded)
+		return err
+	}
+	if err := os.WriteFile(manifestPath, encoded, 0644); err != nil {
+		return err
+	}
+	message.Info("Wrote a team manifest of %d plugins to %s", len(manifest.Plugins), manifestPath)
+	return nil
+}
+
+func teamApplyCommand(ctx context.Context, manifestPath string) error {
+	manifest, err := lib.ReadTeamManifest(manifestPath)
+	if err != nil {
+		return err
+	}
+
+	if manifest.MinTmux != "" {
+		tmuxVersion, err := lib.GetTmuxVersion()
+		if err != nil {
+			message.Warning("Unable to determine tmux version: %s", err)
+		} else {
+			cmp, err := lib.CompareTmuxVersions(tmuxVersion, manifest.MinTmux)
+			if err != nil {
+				return err
+			}
+			if cmp < 0 {
+				return fmt.Errorf("the team manifest requires tmux %s or newer, but tmux %s is installed",
+					manifest.MinTmux, tmuxVersion)
+			}
+		}
+	}
+
+	lockFile, err := lib.GetLockfile(ctx, cfgFile)
+	if err != nil {
+		return err
+	}
+	defer lockFile.Close()
+
+	drift := manifest.Drift(lockFile)
+	for _, difference := range drift {
+		message.Fields{Plugin: difference.Plugin, Data: difference}.Info("Drift: %s", difference)
+	}
+	if len(drift) == 0 {
+		message.Info("No drift from the team manifest")
+	}
+	if tmCheckFlag {
+		if len(drift) > 0 {
+			return fmt.Errorf("%d differences from the team manifest %s", len(drift), manifestPath)
+		}
+		return nil
+	}
+
+	var extra []string
+	if !tmKeepExtraFlag {
+		for _, difference := range drift {
+			if difference.Kind == lib.DriftExtra {
+				extra = append(extra, difference.Plugin)
+			}
+		}
+	}
+	return installPins(ctx, lockFile, manifest.Pins(), extra, tmJobs)
+}
@@ -0,0 +1,118 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+	"github.com/spf13/cobra"
+)
+
+var tryCmd = &cobra.Command{
+	Use:   "try [plugin]",
+	Short: "Tries out a plugin without adding it",
+	Long: `Installs a plugin to a temporary directory, in memory where possible,
+and loads it into the running tmux server. The config file is not changed.
+
+Tried plugins are deleted by "tim try --end", or when the tmux server
+exits. Anything the plugin changed in tmux lasts until tmux is restarted.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if tEndFlag {
+			return endTry()
+		}
+		if len(args) == 0 {
+			return fmt.Errorf("A plugin to try is required, or pass --end to finish trying plugins")
+		}
+		return tryPlugin(cmd.Context(), args[0])
+	},
+}
+
+var (
+	tEndFlag bool
+)
+
+func init() {
+	rootCmd.AddCommand(tryCmd)
+	tryCmd.Flags().BoolVar(&tEndFlag, "end", false, "Delete all plugins being tried out.")
+	tryCmd.Flags().StringVar(&versionSpec, "version", "",
+		"Version to use. Only semver 2.0 compliant strings and branch names are supported.")
+	tryCmd.RegisterFlagCompletionFunc("version", completeVersionSpec)
+}
+
+func tryPlugin(ctx context.Context, pluginArg string) error {
+	pluginName, remote, err := lib.ParsePluginArg(lib.ResolvePluginArg(pluginArg))
+	if err != nil {
+		return err
+	}
+
+	tryDir, err := lib.GetTryDir()
+	if err != nil {
+		return err
+	}
+
+	plugin := lib.Plugin{
+		Name:   pluginName,
+		Remote: remote,
+		Root:   tryDir,
+	}
+	isNew := isNewPlugin(&plugin)
+	if err := plugin.Install(ctx, versionSpec); err != nil {
+		return err
+	}
+	if isNew {
+		runLifecycleHook(ctx, &plugin, lib.LifecyclePostInstall)
+	}
+	if err := plugin.Load(ctx); err != nil {
+		return fmt.Errorf("Plugin %s failed to load: %w", pluginName, err)
+	}
+
+	cleanupOnServerExit(tryDir)
+
+	message.Info("Trying plugin %s at version %s. Run \"tim add %s\" to keep it, or \"tim try --end\" to finish.",
+		pluginName, plugin.Version, pluginArg)
+	return nil
+}
+
+// Starts a background process that deletes dir once the tmux server exits.
+func cleanupOnServerExit(dir string) {
+	watcher := exec.Command("sh", "-c",
+		`while tmux has-session 2>/dev/null; do sleep 30; done; rm -rf "$0"`, dir)
+	watcher.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := watcher.Start(); err != nil {
+		message.Warning("Unable to clean up when tmux exits, run \"tim try --end\" instead: %s", err)
+		return
+	}
+	watcher.Process.Release()
+}
+
+func endTry() error {
+	tryDir, err := lib.GetTryDir()
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(tryDir); err != nil {
+		return err
+	}
+	message.Info("Deleted all plugins being tried out")
+	return nil
+}
@@ -16,8 +16,15 @@ limitations under the License.
 package cmd
 
 import (
-	"strings"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/kjnsn/tim/lib"
 	"github.com/kjnsn/tim/lib/message"
@@ -31,123 +38,638 @@ var upgradeCmd = &cobra.Command{
 
 To upgrade all plugins run "upgrade".
 
+Set "upgrade" on a plugin in the config file to "notify" to only report
+its new versions, upgrading it only when it is named, or "pinned" to
+never upgrade it, only reporting its new versions as blocked. "auto"
+upgrades it with the rest, and with "tim watch" too.
+
 To check if any updates are available without modifying any versions,
-pass the "--check" flag.
+pass the "--check" flag. This prints a single summary line, and exits with
+status 10 if there are updates, or 0 if every plugin is up-to-date. Pass
+"--json" as well for the details of each plugin, or "--verbose" to list
+them.
+
+Plugins checked in the last 6 hours are not checked again, the result of
+the last check is used instead. Set "check_ttl" in the config file to
+change how long, such as "1h", or "0" to always check. Pass "--refresh"
+to check every plugin regardless.
+
+To choose which plugins to upgrade from a list of those with updates
+available, pass the "--interactive" flag.
+
+Pass "--due" with "--check" to only check plugins that are due a check,
+given how often new versions of each are released, using the result of
+the last check for the others. Plugins released monthly are checked about
+weekly, for example. This keeps checks run often, such as by a tmux hook,
+quick.
+
+Plugins are checked and upgraded concurrently, one per CPU by default,
+up to 16 at once. Pass "--jobs" to change how many.
+
+To apply upgrades to a running tmux server, pass the "--reload" flag to
+source the tmux config file afterwards, which runs "tim load" again.
+
+To never leave plugins half upgraded, pass the "--rollback-on-failure"
+flag. If any plugin fails to upgrade, or saving or reloading fails, every
+plugin upgraded by the run is put back to the version it had before.
+
+When an upgrade changes the scripts a plugin runs, they are listed, along
+with the changes if asked, and the plugin is only upgraded if they are
+approved. Pass "--yes" to skip this, which also happens when there is no
+terminal to ask on.
+
+Plugins with local changes, such as files edited by hand, are not
+upgraded, as upgrading would overwrite the changes. New files are kept,
+so don't count. "tim status" shows them. Pass "--stash" to stash the
+changes with git before upgrading, or "--force" to discard them.
+
+Pass "--notes" to print a markdown report of the upgrade, listing each
+plugin upgraded with links to its changes and the start of the notes of
+each release, or "--notes=<file>" to write it to a file. Release notes are
+fetched from github for plugins hosted there, otherwise the commits made
+since the previous version are listed.
 	
 Either a single plugin can be specified, or all plugins
 will be affected.`,
-	Args: cobra.MaximumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completePluginName,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		pluginName := ""
 		if len(args) > 0 {
-			pluginName = strings.ToLower(strings.TrimSpace(args[0]))
+			pluginName = pluginNameArg(args[0])
 		}
-		upgradeCommand(strings.ToLower(strings.TrimSpace(pluginName)))
+		return upgradeCommand(cmd.Context(), pluginName)
 	},
 }
 
 var (
-	uCheckFlag bool
+	uCheckFlag       bool
+	uInteractiveFlag bool
+	uReloadFlag      bool
+	uRollbackFlag    bool
+	uYesFlag         bool
+	uDueFlag         bool
+	uRefreshFlag     bool
+	uNotes           string
+	uForceFlag       bool
+	uStashFlag       bool
+	uJobs            int
 )
 
 func init() {
 	rootCmd.AddCommand(upgradeCmd)
 	upgradeCmd.Flags().BoolVar(&uCheckFlag, "check", false, "Check if any upgrades are available without upgrading anything.")
+	upgradeCmd.Flags().BoolVarP(&uInteractiveFlag, "interactive", "i", false, "Choose which plugins to upgrade.")
+	upgradeCmd.Flags().BoolVar(&uReloadFlag, "reload", false,
+		"Source the tmux config in the running tmux server after upgrading.")
+	upgradeCmd.Flags().BoolVar(&uRollbackFlag, "rollback-on-failure", false,
+		"If anything fails, put back every plugin upgraded by this run.")
+	upgradeCmd.Flags().BoolVarP(&uYesFlag, "yes", "y", false,
+		"Upgrade without reviewing changes to the scripts plugins run.")
+	upgradeCmd.Flags().BoolVar(&uDueFlag, "due", false,
+		"With --check, only check plugins due a check given how often they are released.")
+	upgradeCmd.Flags().BoolVar(&uRefreshFlag, "refresh", false,
+		"With --check, check every plugin rather than using the results of recent checks.")
+	upgradeCmd.Flags().StringVar(&uNotes, "notes", "",
+		"Write a markdown report of the upgrade to a file, or stdout if none is given.")
+	upgradeCmd.Flags().Lookup("notes").NoOptDefVal = "-"
+	upgradeCmd.Flags().BoolVar(&uForceFlag, "force", false,
+		"Upgrade plugins with local changes, discarding the changes.")
+	upgradeCmd.Flags().BoolVar(&uStashFlag, "stash", false,
+		"Upgrade plugins with local changes, stashing the changes with git first.")
+	upgradeCmd.Flags().IntVarP(&uJobs, "jobs", "j", defaultJobs,
+		"Number of plugins to check and upgrade concurrently, one per CPU by default.")
+	upgradeCmd.MarkFlagsMutuallyExclusive("check", "interactive")
+	upgradeCmd.MarkFlagsMutuallyExclusive("check", "rollback-on-failure")
+	upgradeCmd.MarkFlagsMutuallyExclusive("check", "reload")
+	upgradeCmd.MarkFlagsMutuallyExclusive("check", "notes")
+	upgradeCmd.MarkFlagsMutuallyExclusive("force", "stash")
+	upgradeCmd.MarkFlagsMutuallyExclusive("due", "refresh")
 }
 
-func upgradeCommand(pluginName string) {
-	lockFile, err := lib.GetLockfile(cfgFile)
+func upgradeCommand(ctx context.Context, pluginName string) error {
+	if uDueFlag && !uCheckFlag {
+		return fmt.Errorf("--due can only be passed with --check")
+	}
+	if uRefreshFlag && !uCheckFlag {
+		return fmt.Errorf("--refresh can only be passed with --check")
+	}
+	lib.LocalChangesMode = lib.RefuseLocalChanges
+	if uForceFlag {
+		lib.LocalChangesMode = lib.DiscardLocalChanges
+	} else if uStashFlag {
+		lib.LocalChangesMode = lib.StashLocalChanges
+	}
+	openLockfile := func() (*lib.Lockfile, error) { return lib.GetLockfile(ctx, cfgFile) }
+	if uCheckFlag {
+		// Checking changes nothing, so can run alongside other tim processes.
+		openLockfile = func() (*lib.Lockfile, error) { return lib.ReadLockfile(cfgFile) }
+	}
+	lockFile, err := openLockfile()
 	if err != nil {
-		message.Error(err.Error())
+		return err
 	}
 	defer lockFile.Close()
-
 	if pluginName != "" {
+		pluginName = resolvePluginName(lockFile, pluginName)
+	}
+
+	var countLock sync.Mutex
+	checked, upgradable, failed := 0, 0, 0
+	countPlugin := func(hasUpgrade bool, err error) {
+		countLock.Lock()
+		defer countLock.Unlock()
+		checked++
+		if err != nil {
+			failed++
+		} else if hasUpgrade {
+			upgradable++
+		}
+	}
+
+	skipChecked, err := recentChecks(ctx, lockFile.CheckCacheTTL())
+	if err != nil {
+		return err
+	}
+
+	run := newUpgradeRun(ctx, lockFile)
+	// Why the named plugin failed to upgrade, returned once the config
+	// file is saved.
+	var namedErr error
+	if uInteractiveFlag {
+		if err := upgradeInteractive(ctx, lockFile, run, pluginName); err != nil {
+			return err
+		}
+	} else if pluginName != "" {
 		plugin := lockFile.GetPlugin(pluginName)
 		if plugin == nil {
-			message.Error("Plugin %s not found", pluginName)
+			return lockFile.PluginNotFound(pluginName)
 		}
-		upgradePlugin(plugin)
-		if !uCheckFlag {
-			lockFile.PluginSpecs[plugin.Name] = plugin.Version.GitRef()
+		if upgrade, ok := skipChecked(plugin); ok {
+			countPlugin(upgrade, nil)
+			return checkSummary(checked, upgradable, failed)
 		}
-	} else {
-		var wg sync.WaitGroup
-		lockSync := new(sync.Mutex)
-
-		for _, plugin := range lockFile.Plugins() {
-			wg.Add(1)
-			go func(plugin lib.Plugin) {
-				defer wg.Done()
-
-				upgradePlugin(&plugin)
-				if !uCheckFlag {
-					lockSync.Lock()
-					lockFile.PluginSpecs[plugin.Name] = plugin.Version.GitRef()
-					lockSync.Unlock()
+		// Plugins only notified of upgrades are upgraded when named.
+		hasUpgrade, err := upgradePlugin(ctx, plugin, !uCheckFlag)
+		countPlugin(hasUpgrade, err)
+		if !uCheckFlag {
+			run.record(plugin.Name, hasUpgrade, err)
+			if err != nil {
+				// Already explained by upgradePlugin.
+				namedErr = lib.WithErrorCode(lib.ErrorCodeOf(err), fmt.Errorf("plugin %s was not upgraded", plugin.Name))
+			}
+			if err := lockFile.SetPlugin(ctx, plugin); err != nil {
+				run.record(plugin.Name, false, err)
+				if !uRollbackFlag {
+					return err
 				}
-			}(plugin)
+			}
 		}
+	} else {
+		var lockSync sync.Mutex
+		plugins := lockFile.Plugins()
+		countPlugins(len(plugins))
+		forEachPlugin(uJobs, plugins, func(plugin *lib.Plugin) error {
+			if upgrade, ok := skipChecked(plugin); ok {
+				countPlugin(upgrade, nil)
+				return nil
+			}
+			apply := !uCheckFlag && plugin.UpgradePolicy != lib.UpgradeNotify
+			hasUpgrade, err := upgradePlugin(ctx, plugin, apply)
+			countPlugin(hasUpgrade, err)
+			if !apply {
+				return err
+			}
+			run.record(plugin.Name, hasUpgrade, err)
+			lockSync.Lock()
+			defer lockSync.Unlock()
+			if err := lockFile.SetPlugin(ctx, plugin); err != nil {
+				message.Warning("Unable to record the version of %s: %s", plugin.Name, err)
+				run.record(plugin.Name, false, err)
+			}
+			return err
+		})
+	}
 
-		wg.Wait()
+	if uCheckFlag {
+		return checkSummary(checked, upgradable, failed)
+	}
+	if uRollbackFlag && run.failures > 0 {
+		return run.rollback(ctx, lockFile,
+			lib.WithErrorCode(lib.CodePluginsFailed, fmt.Errorf("%d plugins failed to upgrade", run.failures)))
+	}
+	if err := lockFile.Save(); err != nil {
+		if uRollbackFlag {
+			// The config file is unchanged, so only the plugins need putting back.
+			return run.rollback(ctx, nil, err)
+		}
+		return err
+	}
+	if namedErr != nil {
+		return namedErr
+	}
+	if uNotes != "" {
+		if err := writeUpgradeNotes(ctx, lockFile, run); err != nil {
+			return err
+		}
 	}
 
-	if !uCheckFlag {
-		if err := lockFile.Save(); err != nil {
-			message.Error(err.Error())
+	if uReloadFlag {
+		err := reloadTmux(false, "tim upgraded plugins")
+		if err != nil && uRollbackFlag {
+			return run.rollback(ctx, lockFile, fmt.Errorf("unable to reload tmux: %w", err))
 		}
+		return err
 	}
+	if lib.TmuxServerRunning() {
+		message.Info("Pass --reload to apply upgrades to the running tmux server")
+	}
+	return nil
 }
 
-func upgradePlugin(plugin *lib.Plugin) error {
-	newVersion, err := newVersion(plugin)
+// Upgrades the plugin, or with --check only reports whether it has an
+// upgrade available.
+func upgradePlugin(ctx context.Context, plugin *lib.Plugin, apply bool) (bool, error) {
+	if plugin.IsLocal() {
+		message.Fields{Plugin: plugin.Name}.Debug("Plugin %s is loaded from %s, not upgrading", plugin.Name, plugin.Path)
+		return false, nil
+	}
+	result, err := checkPlugin(ctx, plugin)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	if newVersion == nil {
-		message.Info("Plugin %s up-to-date", plugin.Name)
-		return nil
+	oldVersion := plugin.Version.String()
+	fields := message.Fields{
+		Plugin:  plugin.Name,
+		Version: oldVersion,
+		Data:    checkResultData(result),
+	}
+	// A check only prints its summary, unless the details are asked for.
+	report := fields.Info
+	if uCheckFlag && !message.JSONEnabled {
+		report = fields.Debug
 	}
 
+	if !result.HasUpgrade() {
+		if result.Outcome == lib.OutcomeError {
+			fields.Code = string(lib.ErrorCodeOf(result.Err))
+			fields.Warning("Plugin %s: %s", plugin.Name, result.Reason())
+			return false, result.Err
+		}
+		report("Plugin %s %s", plugin.Name, result.Reason())
+		return false, nil
+	}
+	newVersion := result.Upgrade
+
+	report("Plugin %s has upgrade available: %s -> %s", plugin.Name, oldVersion, newVersion)
+
+	if !apply {
+		if !uCheckFlag {
+			fields.Info("Plugin %s is only notified of upgrades, run \"tim upgrade %s\" to upgrade it",
+				plugin.Name, plugin.Name)
+		}
+		return true, nil
+	}
+
+	if err := applyUpgrade(ctx, plugin, newVersion); err != nil {
+		warnFailed(plugin.Name, "upgrade", err)
+		return true, err
+	}
+	return true, nil
+}
+
+// Checks out newVersion of the plugin, once any changes to its scripts
+// are approved.
+func applyUpgrade(ctx context.Context, plugin *lib.Plugin, newVersion lib.Version) error {
 	oldVersion := plugin.Version.String()
-	message.Info("Plugin %s has upgrade available: %s -> %s", plugin.Name, oldVersion, newVersion)
+	if !uYesFlag && message.Interactive() {
+		review, err := plugin.ReviewUpgrade(ctx, newVersion)
+		if err != nil {
+			return err
+		}
+		if review.Diff != "" && !confirmScripts(plugin.Name, review, false) {
+			message.Fields{Plugin: plugin.Name, Version: oldVersion}.Info(
+				"Plugin %s not upgraded, staying at %s", plugin.Name, oldVersion)
+			return nil
+		}
+	}
 
-	if uCheckFlag {
+	if err := plugin.Upgrade(ctx, newVersion); errors.Is(err, lib.ErrDirtyWorktree) {
+		return fmt.Errorf("%w. Pass --stash to keep them, or --force to discard them", err)
+	} else if err != nil {
+		return err
+	}
+
+	plugin.Version = newVersion
+	message.Fields{Plugin: plugin.Name, Version: newVersion.String()}.Info(
+		"Plugin %s upgraded from %s to %s", plugin.Name, oldVersion, newVersion)
+
+	return nil
+}
+
+// Checks every plugin, or just pluginName, for upgrades and asks which
+// of those with an upgrade available to apply.
+func upgradeInteractive(ctx context.Context, lockFile *lib.Lockfile, run *upgradeRun, pluginName string) error {
+	plugins := lockFile.Plugins()
+	if pluginName != "" {
+		plugin := lockFile.GetPlugin(pluginName)
+		if plugin == nil {
+			return lockFile.PluginNotFound(pluginName)
+		}
+		plugins = []lib.Plugin{*plugin}
+	}
+
+	var resultsLock sync.Mutex
+	upgrades := make(map[string]lib.Version)
+	countPlugins(len(plugins))
+	forEachPlugin(uJobs, plugins, func(plugin *lib.Plugin) error {
+		result, err := checkPlugin(ctx, plugin)
+		if err != nil {
+			return err
+		}
+		if result.Outcome == lib.OutcomeError {
+			failureFields(plugin.Name, result.Err).Warning("Plugin %s: %s", plugin.Name, result.Reason())
+			return result.Err
+		}
+		if result.Outcome == lib.OutcomeConstrained {
+			message.Fields{Plugin: plugin.Name, Data: checkResultData(result)}.Info(
+				"Plugin %s %s", plugin.Name, result.Reason())
+		}
+		if result.HasUpgrade() {
+			resultsLock.Lock()
+			defer resultsLock.Unlock()
+			upgrades[plugin.Name] = result.Upgrade
+		}
+		return nil
+	})
+
+	candidates := make([]lib.Plugin, 0, len(upgrades))
+	options := make([]string, 0, len(upgrades))
+	for _, plugin := range plugins {
+		if newVersion, ok := upgrades[plugin.Name]; ok {
+			candidates = append(candidates, plugin)
+			options = append(options, fmt.Sprintf("%s  %s -> %s", plugin.Name, plugin.Version, newVersion))
+		}
+	}
+	if len(candidates) == 0 {
+		message.Info("All plugins are up-to-date")
 		return nil
 	}
 
-	pluginDir, err := plugin.Dir()
+	chosen, err := message.Select("Select the plugins to upgrade:", options)
+	if errors.Is(err, message.ErrCancelled) {
+		message.Info("No plugins upgraded")
+		return nil
+	}
 	if err != nil {
 		return err
 	}
-	err = newVersion.Upgrade(pluginDir)
+
+	for _, i := range chosen {
+		plugin := &candidates[i]
+		err := applyUpgrade(ctx, plugin, upgrades[plugin.Name])
+		run.record(plugin.Name, true, err)
+		if err != nil {
+			warnFailed(plugin.Name, "upgrade", err)
+			continue
+		}
+		if err := lockFile.SetPlugin(ctx, plugin); err != nil {
+			message.Warning("Unable to record the version of %s: %s", plugin.Name, err)
+			run.record(plugin.Name, false, err)
+		}
+	}
+	return nil
+}
+
+// The plugins changed by an upgrade and the versions they had before, so
+// that with --rollback-on-failure they can all be put back if any part of
+// the upgrade fails.
+type upgradeRun struct {
+	lock     sync.Mutex
+	specs    map[string]lib.PluginSpec
+	before   map[string]lib.LockedPlugin
+	changed  []string
+	failed   []string
+	failures int
+}
+
+// Records the version of every plugin before upgrading.
+func newUpgradeRun(ctx context.Context, lockFile *lib.Lockfile) *upgradeRun {
+	run := &upgradeRun{
+		specs:  maps.Clone(lockFile.PluginSpecs),
+		before: maps.Clone(lockFile.Locked),
+	}
+	if !uRollbackFlag && uNotes == "" {
+		return run
+	}
+	// Plugins missing from tim.lock are put back to their current commit,
+	// and their notes start from it.
+	for _, plugin := range lockFile.Plugins() {
+		if _, ok := run.before[plugin.Name]; ok || plugin.IsLocal() || plugin.CheckInstalled() != nil {
+			continue
+		}
+		commit, err := plugin.Commit(ctx)
+		if err != nil {
+			message.Debug("Unable to find the commit of %s to roll back to: %s", plugin.Name, err)
+			continue
+		}
+		run.before[plugin.Name] = lib.LockedPlugin{
+			Ref:    plugin.Spec().Version,
+			Commit: commit,
+			Remote: plugin.Remote,
+		}
+	}
+	return run
+}
+
+// Records the outcome of upgrading a plugin. Plugins that failed part way
+// through upgrading are changed as well.
+func (r *upgradeRun) record(name string, changed bool, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if changed && !slices.Contains(r.changed, name) {
+		r.changed = append(r.changed, name)
+	}
 	if err != nil {
-		return err
+		r.failures++
+		if !slices.Contains(r.failed, name) {
+			r.failed = append(r.failed, name)
+		}
 	}
+}
 
-	plugin.Version = newVersion
-	message.Info("Plugin %s upgraded from %s to %s", plugin.Name, oldVersion, newVersion)
+// Puts every changed plugin back to its version before the upgrade, and
+// saves the config file unless lockFile is nil. Returns cause, explaining
+// the rollback.
+func (r *upgradeRun) rollback(ctx context.Context, lockFile *lib.Lockfile, cause error) error {
+	message.Warning("Rolling back the %d plugins upgraded: %s", len(r.changed), cause)
+	failed := 0
+	for _, name := range r.changed {
+		locked, ok := r.before[name]
+		if !ok {
+			message.Warning("Unable to roll back plugin %s, its previous version is unknown", name)
+			failed++
+			continue
+		}
+		plugin := lib.Plugin{Name: name, Remote: locked.Remote}
+		if err := plugin.InstallLocked(ctx, locked); err != nil {
+			warnFailed(name, "roll back", err)
+			failed++
+			continue
+		}
+		if lockFile != nil {
+			lockFile.Revert(name, r.specs[name], locked)
+		}
+		message.Fields{Plugin: name, Version: locked.Ref}.Info("Plugin %s rolled back to %s", name, locked.Ref)
+	}
+
+	if lockFile != nil {
+		if err := lockFile.Save(); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d plugins could not be rolled back after the upgrade failed: %w", failed, cause)
+	}
+	return fmt.Errorf("upgrade rolled back: %w", cause)
+}
+
+// Writes the report asked for by --notes, of the plugins the run moved to
+// a new commit.
+func writeUpgradeNotes(ctx context.Context, lockFile *lib.Lockfile, run *upgradeRun) error {
+	upgraded := make([]lib.UpgradeNotes, 0)
+	for _, name := range slices.Sorted(slices.Values(run.changed)) {
+		to, ok := lockFile.Locked[name]
+		if !ok || slices.Contains(run.failed, name) {
+			continue
+		}
+		from, ok := run.before[name]
+		if !ok {
+			from = lib.LockedPlugin{Ref: run.specs[name].Version}
+		}
+		if from.Commit == to.Commit {
+			continue
+		}
+		plugin := lockFile.GetPlugin(name)
+		if plugin == nil {
+			continue
+		}
+		upgraded = append(upgraded, plugin.UpgradeNotes(ctx, from, to))
+	}
+	report := lib.UpgradeReport(upgraded, slices.Sorted(slices.Values(run.failed)), time.Now())
 
+	if uNotes == "-" {
+		_, err := io.WriteString(stdout, report)
+		return err
+	}
+	if err := os.WriteFile(uNotes, []byte(report), 0644); err != nil {
+		return err
+	}
+	message.Info("Wrote notes on the %d plugins upgraded to %s", len(upgraded), uNotes)
 	return nil
 }
 
-// Returns the version to upgrade to. Will be non-empty
-// if an upgrade should occur.
-func newVersion(plugin *lib.Plugin) (lib.Version, error) {
-	pluginDir, err := plugin.Dir()
-	if err != nil {
-		return nil, err
+// Prints how many of the checked plugins have upgrades, returning an
+// error that exits with ExitUpdatesAvailable if any do, or with
+// ExitCheckFailed if any could not be checked.
+func checkSummary(checked, upgradable, failed int) error {
+	fields := message.Fields{Data: map[string]any{
+		"checked":    checked,
+		"upgradable": upgradable,
+		"failed":     failed,
+	}}
+	if failed > 0 {
+		fields.Info("%d of %d plugins have updates, %d could not be checked", upgradable, checked, failed)
+		return &exitError{code: message.ExitCheckFailed}
+	}
+	if upgradable == 0 {
+		fields.Info("All %d plugins are up-to-date", checked)
+		return nil
+	}
+	fields.Info("%d of %d plugins have updates", upgradable, checked)
+	return &exitError{code: message.ExitUpdatesAvailable}
+}
+
+// The machine readable form of a check result.
+func checkResultData(result lib.CheckResult) map[string]any {
+	data := map[string]any{
+		"upgrade_available": result.HasUpgrade(),
+		"reason":            result.Reason(),
+	}
+	if result.Latest != nil {
+		data["latest"] = result.Latest.String()
+	}
+	if result.Err != nil {
+		data["error_class"] = result.ErrorClass.String()
+		data["error_code"] = lib.ErrorCodeOf(result.Err)
+	}
+	return data
+}
+
+// Checks the plugin's remote for a new version.
+func checkPlugin(ctx context.Context, plugin *lib.Plugin) (lib.CheckResult, error) {
+	if _, err := plugin.Dir(); err != nil {
+		return lib.CheckResult{}, err
 	}
 
 	message.Debug("Checking plugin %s for a new version", plugin.Name)
 
-	if err := plugin.Version.Check(pluginDir); err != nil {
-		return nil, err
+	result := plugin.CheckForUpgrade(ctx)
+	if plugin.UpgradePolicy == lib.UpgradePinned {
+		// Pinned plugins are still checked, so it is clear what they
+		// are missing.
+		result = result.Constrain(`its "pinned" upgrade policy, change its "upgrade" in the config file to upgrade it`)
 	}
+	return result, nil
+}
 
-	if ok, newVersion := plugin.Version.HasUpgrade(); ok {
-		return newVersion, nil
+// With --check, returns a function reporting whether a plugin was checked
+// within ttl, or with --due recently enough given its release cadence, to
+// skip checking it, and if so whether that check found an upgrade.
+// Otherwise, or with --refresh, no plugin is skipped.
+func recentChecks(ctx context.Context, ttl time.Duration) (func(plugin *lib.Plugin) (bool, bool), error) {
+	if !uCheckFlag || uRefreshFlag || (!uDueFlag && ttl == 0) {
+		return func(*lib.Plugin) (bool, bool) { return false, false }, nil
 	}
-	return nil, nil
+	checks, err := lib.GetCheckCache()
+	if err != nil {
+		return nil, err
+	}
+	history, err := lib.GetHistory()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(plugin *lib.Plugin) (bool, bool) {
+		check, ok := checks.Plugins[plugin.Name]
+		if !ok || check.Error != "" {
+			return false, false
+		}
+		upgrade, ok := check.UpgradeFor(plugin.Version)
+		if !ok {
+			return false, false
+		}
+		if time.Since(check.CheckedAt) >= ttl {
+			if !uDueFlag {
+				return false, false
+			}
+			cadence, err := plugin.ReleaseCadence(ctx, history.Plugins[plugin.Name])
+			if err != nil {
+				message.Debug("Unable to find how often %s is released: %s", plugin.Name, err)
+				return false, false
+			}
+			if !cadence.CheckedRecently(check.CheckedAt) {
+				return false, false
+			}
+		}
+		// As with checks, only printed if the details are asked for.
+		fields := message.Fields{Plugin: plugin.Name, Version: check.Version, Data: check}
+		report := fields.Debug
+		if message.JSONEnabled {
+			report = fields.Info
+		}
+		report("Plugin %s was checked %s, and is not due a check yet", plugin.Name, message.RelativeTime(check.CheckedAt))
+		return upgrade != "", true
+	}, nil
 }
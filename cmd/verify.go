@@ -0,0 +1,86 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [plugin...]",
+	Short: "Checks plugins have not been changed since they were installed",
+	Long: `Checks that every plugin, or just those given, has exactly the commit
+recorded in tim.lock checked out, and that none of its files have been
+changed, added or deleted since. This catches plugins that have been
+tampered with or edited by hand. To restore a plugin that fails, delete
+its directory and run "tim sync".
+
+Plugins loaded from a local directory with "tim add --path" are not
+checked. Pass "--verify" to "tim load" to refuse to load plugins that fail
+verification.`,
+	Args:              cobra.ArbitraryArgs,
+	ValidArgsFunction: completePluginNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return verifyCommand(cmd.Context(), args)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+}
+
+func verifyCommand(ctx context.Context, pluginNames []string) error {
+	lockFile, err := lib.ReadLockfile(cfgFile)
+	if err != nil {
+		return err
+	}
+	defer lockFile.Close()
+
+	for _, name := range pluginNames {
+		if _, ok := lockFile.PluginSpecs[name]; !ok {
+			return lib.PluginNotFound(name)
+		}
+	}
+
+	checked, failed := 0, 0
+	for _, plugin := range lockFile.Plugins() {
+		if len(pluginNames) > 0 && !slices.Contains(pluginNames, plugin.Name) {
+			continue
+		}
+		if plugin.IsLocal() {
+			message.Debug("Plugin %s is loaded from %s, not verifying it", plugin.Name, plugin.Path)
+			continue
+		}
+		checked++
+		if err := lockFile.Verify(ctx, &plugin); err != nil {
+			failureFields(plugin.Name, err).Warning("Plugin %s failed verification: %s", plugin.Name, err)
+			failed++
+			continue
+		}
+		message.Fields{Plugin: plugin.Name}.Info("Plugin %s verified at %.10s", plugin.Name, lockFile.Locked[plugin.Name].Commit)
+	}
+
+	if failed > 0 {
+		return lib.WithErrorCode(lib.CodeVerifyFailed, fmt.Errorf("%d of %d plugins failed verification", failed, checked))
+	}
+	return nil
+}
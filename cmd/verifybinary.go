@@ -0,0 +1,79 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+	"github.com/spf13/cobra"
+	"golang.org/x/mod/semver"
+)
+
+var verifyBinaryCmd = &cobra.Command{
+	Use:   "verify-binary",
+	Short: "Checks that tim is the binary published in its release",
+	Long: `Downloads the tim binary published in the github release of the running
+version, checked against the checksums published with the release, and
+checks that the running tim is exactly the same.
+
+tim built from source, such as with "go install", never matches. Build it
+as the release does, described in the README, to reproduce the release.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return verifyBinaryCommand(cmd.Context())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyBinaryCmd)
+}
+
+func verifyBinaryCommand(ctx context.Context) error {
+	if !semver.IsValid(buildInfo.Version) {
+		return fmt.Errorf("tim %s is not a release, so there is nothing to verify it against", orUnknown(buildInfo.Version))
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	binary, err := os.ReadFile(executable)
+	if err != nil {
+		return err
+	}
+
+	release, err := lib.GetTimRelease(ctx, buildInfo.Version)
+	if err != nil {
+		return err
+	}
+	message.Info("Downloading tim %s for %s/%s to compare", release.TagName, runtime.GOOS, runtime.GOARCH)
+	err = release.VerifyBinary(ctx, runtime.GOOS, runtime.GOARCH, binary)
+	if errors.Is(err, lib.ErrBinaryMismatch) {
+		return fmt.Errorf("%s is not the tim published in release %s: %w. "+
+			"Run \"tim self-update\" or reinstall tim from the release", executable, release.TagName, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	message.Fields{Version: release.TagName}.Info("%s is exactly the tim published in release %s", executable, release.TagName)
+	return nil
+}
@@ -0,0 +1,174 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+	"github.com/spf13/cobra"
+	"golang.org/x/mod/semver"
+)
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Displays the version of tim",
+	Long: `Displays the version of tim, along with the commit and date it was
+built from, the go version used to build it, the platform, and the
+SHA-256 of the binary, which "tim verify-binary" checks against the
+release. "tim --version" prints the same.
+
+The latest release found by the last check for one is shown too. When
+update checks are enabled with "update_check" in the config file, or
+TIM_UPDATE_CHECK, tim checks again if the last check is a week old.
+Otherwise nothing is sent. Pass "--check" to check now.
+
+Pass "--json" for the same details as a JSON object, for packaging scripts.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return versionCommand(cmd.Context())
+	},
+}
+
+var (
+	vCheckFlag bool
+)
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+	versionCmd.Flags().BoolVar(&vCheckFlag, "check", false, "Check if a newer release of tim is available.")
+}
+
+func versionCommand(ctx context.Context) error {
+	if err := buildInfo.HashExecutable(); err != nil {
+		message.Debug("Unable to hash the tim binary: %s", err)
+	}
+	check, err := versionUpdateCheck(ctx)
+	if err != nil {
+		return err
+	}
+	updateAvailable := semver.IsValid(check.Latest) && semver.Compare(check.Latest, buildInfo.Version) == 1
+
+	if message.JSONEnabled {
+		output := struct {
+			lib.BuildInfo
+			Latest          string     `json:"latest,omitempty"`
+			CheckedAt       *time.Time `json:"checked_at,omitempty"`
+			UpdateAvailable bool       `json:"update_available"`
+		}{BuildInfo: buildInfo, Latest: check.Latest, UpdateAvailable: updateAvailable}
+		if !check.CheckedAt.IsZero() {
+			output.CheckedAt = &check.CheckedAt
+		}
+
+		encoded, err := json.MarshalIndent(output, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, string(encoded))
+		return nil
+	}
+
+	message.Info("Version:    %s", orUnknown(buildInfo.Version))
+	commit := orUnknown(buildInfo.Commit)
+	if buildInfo.Modified {
+		commit += " (modified)"
+	}
+	message.Info("Commit:     %s", commit)
+	message.Info("Built:      %s", orUnknown(buildInfo.Date))
+	message.Info("Go version: %s", buildInfo.GoVersion)
+	message.Info("Platform:   %s", buildInfo.Platform)
+	message.Info("SHA-256:    %s", orUnknown(buildInfo.Sha256))
+
+	switch {
+	case check.CheckedAt.IsZero():
+		message.Info("Latest:     unknown, pass --check to check")
+	case check.Latest == "":
+		message.Info("Latest:     unknown, the last check failed %s", message.FormatTime(check.CheckedAt))
+	default:
+		message.Info("Latest:     %s, checked %s", check.Latest, message.FormatTime(check.CheckedAt))
+	}
+	if updateAvailable {
+		message.Info("A newer version of tim is available: %s. Run \"tim self-update\" to upgrade.", check.Latest)
+	} else if check.Latest != "" {
+		message.Info("tim is up-to-date")
+	}
+	return nil
+}
+
+// Returns the latest release of tim found by the last check for one. With
+// --check, checks now, and when update checks are enabled, checks if the
+// last check is out of date. Otherwise nothing is sent.
+func versionUpdateCheck(ctx context.Context) (lib.UpdateCheck, error) {
+	if vCheckFlag {
+		return lib.CheckForTimUpdate(ctx)
+	}
+	if updateChecksEnabled() {
+		if _, err := lib.CachedLatestTimRelease(ctx); err != nil {
+			message.Debug("Unable to check for a new release of tim: %s", err)
+		}
+	}
+	return lib.LastUpdateCheck()
+}
+
+// Setting this environment variable to a true or false value overrides
+// update_check in the config file.
+const updateCheckEnv = "TIM_UPDATE_CHECK"
+
+// Prints a notice if a newer release of tim is available, when update
+// checks are enabled. Failures are only shown in verbose mode.
+func notifyTimUpdate(ctx context.Context) {
+	if !updateChecksEnabled() || !semver.IsValid(buildInfo.Version) {
+		return
+	}
+
+	latest, err := lib.CachedLatestTimRelease(ctx)
+	if err != nil {
+		message.Debug("Unable to check for a new release of tim: %s", err)
+		return
+	}
+	if semver.Compare(latest, buildInfo.Version) == 1 {
+		message.Fields{Version: latest}.Info("\nA new tim release is available: %s -> %s\n"+
+			"  Run \"tim self-update\" to upgrade.", buildInfo.Version, latest)
+	}
+}
+
+// Returns whether tim may check for new releases of itself, from
+// TIM_UPDATE_CHECK or update_check in the config file.
+func updateChecksEnabled() bool {
+	enabled, err := strconv.ParseBool(os.Getenv(updateCheckEnv))
+	if err == nil {
+		return enabled
+	}
+	lockFile, err := lib.ReadLockfile(cfgFile)
+	if err != nil {
+		return false
+	}
+	defer lockFile.Close()
+	return lockFile.UpdateCheck
+}
+
+func orUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return value
+}
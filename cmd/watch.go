@@ -0,0 +1,248 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kjnsn/tim/lib"
+	"github.com/kjnsn/tim/lib/message"
+	"github.com/spf13/cobra"
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Checks plugins for new versions in the background",
+	Long: `Checks every plugin for a new version, then again every interval, until
+the tmux server exits. The results are recorded as "tim upgrade --check"
+records them, so "tim list" and "tim info" show them straight away.
+
+Start it from the tmux config file, after "tim load":
+
+  run-shell -b "tim watch --notify"
+
+Only one watch runs at a time, so sourcing the tmux config again does
+not start another. Plugins are only checked, unless "upgrade" is set to
+"auto" for them in the config file, in which case they are upgraded too.
+Plugins with "upgrade" set to "pinned" are not checked.
+
+Plugins are checked as often as "check_ttl" in the config file, every 6
+hours by default. Pass "--interval" to check more or less often.
+
+Pass "--notify" to show a message in tmux when new versions are found.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return watchCommand(cmd.Context())
+	},
+}
+
+var (
+	wInterval   time.Duration
+	wNotifyFlag bool
+)
+
+// How often the watch checks that the tmux server is still running.
+const watchPollInterval = 30 * time.Second
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+	watchCmd.Flags().DurationVar(&wInterval, "interval", 0,
+		"How often to check, such as 1h. Defaults to check_ttl in the config file.")
+	watchCmd.Flags().BoolVar(&wNotifyFlag, "notify", false,
+		"Show a message in tmux when new versions are found.")
+}
+
+func watchCommand(ctx context.Context) error {
+	if wInterval != 0 && wInterval < time.Minute {
+		return fmt.Errorf("--interval must be at least 1m")
+	}
+	release, err := lib.AcquireWatchLock()
+	if errors.Is(err, lib.ErrWatching) {
+		message.Debug("Not watching: %s", err)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer release()
+
+	// Upgrades already notified, so each is only shown once.
+	notified := make(map[string]string)
+	for {
+		interval, err := watchCheck(ctx, notified)
+		if err != nil {
+			message.Warning("Unable to check plugins for new versions: %s", err)
+		}
+		if !waitWhileTmuxRuns(ctx, interval) {
+			return nil
+		}
+	}
+}
+
+// Checks every plugin not checked within the interval, notifying of new
+// upgrades if asked. Returns the interval until the next check.
+func watchCheck(ctx context.Context, notified map[string]string) (time.Duration, error) {
+	interval := cmp.Or(wInterval, lib.DefaultCheckTTL)
+	lockFile, err := lib.ReadLockfile(cfgFile)
+	if err != nil {
+		return interval, err
+	}
+	defer lockFile.Close()
+	// A check_ttl of 0 means always check, not continuously.
+	interval = cmp.Or(wInterval, lockFile.CheckCacheTTL(), lib.DefaultCheckTTL)
+
+	checks, err := lib.GetCheckCache()
+	if err != nil {
+		return interval, err
+	}
+
+	var upgradesLock sync.Mutex
+	upgrades := make(map[string]string)
+	auto := []string{}
+	forEachPlugin(defaultJobs, lockFile.Plugins(), func(plugin *lib.Plugin) error {
+		if plugin.IsLocal() || plugin.UpgradePolicy == lib.UpgradePinned {
+			return nil
+		}
+		upgrade, recent := "", false
+		if check, ok := checks.Plugins[plugin.Name]; ok {
+			upgrade, recent = check.RecentUpgradeFor(plugin.Version, interval)
+		}
+		if !recent {
+			result, err := checkPlugin(ctx, plugin)
+			if err != nil {
+				return err
+			}
+			if result.Outcome == lib.OutcomeError {
+				message.Debug("Plugin %s: %s", plugin.Name, result.Reason())
+				return result.Err
+			}
+			if result.HasUpgrade() {
+				upgrade = result.Upgrade.String()
+			}
+		}
+		if upgrade != "" {
+			upgradesLock.Lock()
+			defer upgradesLock.Unlock()
+			upgrades[plugin.Name] = upgrade
+			if plugin.UpgradePolicy == lib.UpgradeAuto {
+				auto = append(auto, plugin.Name)
+			}
+		}
+		return nil
+	})
+
+	upgraded := watchUpgrade(ctx, auto)
+	for _, name := range upgraded {
+		delete(upgrades, name)
+	}
+	if wNotifyFlag {
+		notifyUpgrades(upgrades, notified)
+		if len(upgraded) > 0 {
+			slices.Sort(upgraded)
+			text := fmt.Sprintf("tim: upgraded %s, run \"tim load --reload\" to use the new versions",
+				strings.Join(upgraded, ", "))
+			if err := lib.DisplayTmuxMessage(text); err != nil {
+				message.Debug("Unable to display a message in tmux: %s", err)
+			}
+		}
+	}
+	return interval, nil
+}
+
+// Upgrades the plugins named, which are set to upgrade automatically.
+// Returns those upgraded.
+func watchUpgrade(ctx context.Context, names []string) []string {
+	if len(names) == 0 {
+		return nil
+	}
+	lockFile, err := lib.GetLockfile(ctx, cfgFile)
+	if err != nil {
+		message.Debug("Not upgrading plugins this time: %s", err)
+		return nil
+	}
+	defer lockFile.Close()
+
+	upgraded := []string{}
+	for _, name := range names {
+		plugin := lockFile.GetPlugin(name)
+		if plugin == nil {
+			continue
+		}
+		oldVersion := plugin.Version.String()
+		if _, err := upgradePlugin(ctx, plugin, true); err != nil || plugin.Version.String() == oldVersion {
+			continue
+		}
+		if err := lockFile.SetPlugin(ctx, plugin); err != nil {
+			message.Warning("Unable to record the version of %s: %s", plugin.Name, err)
+			continue
+		}
+		upgraded = append(upgraded, name)
+	}
+	if len(upgraded) > 0 {
+		if err := lockFile.Save(); err != nil {
+			message.Warning("Unable to save the upgraded versions: %s", err)
+			return nil
+		}
+	}
+	return upgraded
+}
+
+// Shows a message in tmux listing the upgrades not already notified.
+func notifyUpgrades(upgrades, notified map[string]string) {
+	names := []string{}
+	for name, upgrade := range upgrades {
+		if notified[name] != upgrade {
+			names = append(names, name)
+			notified[name] = upgrade
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+	slices.Sort(names)
+
+	text := fmt.Sprintf("tim: %s has a new version, run \"tim upgrade\"", names[0])
+	if len(names) > 1 {
+		text = fmt.Sprintf("tim: %d plugins have new versions, run \"tim upgrade\"", len(names))
+	}
+	if err := lib.DisplayTmuxMessage(text); err != nil {
+		message.Debug("Unable to display a message in tmux: %s", err)
+	}
+}
+
+// Waits for d, returning false early if the tmux server exits or ctx is
+// done.
+func waitWhileTmuxRuns(ctx context.Context, d time.Duration) bool {
+	deadline := time.Now().Add(d)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(min(time.Until(deadline), watchPollInterval)):
+		}
+		if !lib.TmuxServerAlive() {
+			message.Debug("The tmux server has exited, no longer watching")
+			return false
+		}
+	}
+	return true
+}
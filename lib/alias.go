@@ -0,0 +1,59 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Returns the plugin an alias in the config file stands for, or arg
+// unchanged if it is not an alias. Aliases are matched ignoring case.
+func (lf *Lockfile) ResolveAlias(arg string) string {
+	arg = strings.TrimSpace(arg)
+	for alias, target := range lf.Aliases {
+		if strings.EqualFold(alias, arg) {
+			return target
+		}
+	}
+	return arg
+}
+
+// Returns the aliases standing for the plugin named name, sorted.
+func (lf *Lockfile) AliasesOf(name string) []string {
+	aliases := []string{}
+	for alias, target := range lf.Aliases {
+		if targetName, _, err := ParsePluginArg(target); err == nil && targetName == name {
+			aliases = append(aliases, alias)
+		}
+	}
+	slices.Sort(aliases)
+	return aliases
+}
+
+// Checks that every alias is a plain word standing for a valid plugin.
+func (lf *Lockfile) checkAliases() error {
+	for alias, target := range lf.Aliases {
+		if alias == "" || strings.ContainsAny(alias, "/: \t") {
+			return fmt.Errorf("alias %q: must be a single word without \"/\" or \":\"", alias)
+		}
+		if _, _, err := ParsePluginArg(target); err != nil {
+			return fmt.Errorf("alias %s: %w", alias, err)
+		}
+	}
+	return nil
+}
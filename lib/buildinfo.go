@@ -0,0 +1,158 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// The github repository tim is released from.
+const timRepository = "kjnsn/tim"
+
+// Metadata about how the running tim binary was built.
+type BuildInfo struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+
+	// Whether the binary was built with uncommitted changes to Commit.
+	Modified bool `json:"modified,omitempty"`
+
+	Date      string `json:"date"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+
+	// The hex encoded SHA-256 of the binary, only set by HashExecutable.
+	Sha256 string `json:"sha256,omitempty"`
+}
+
+// Creates the build info from values injected with ldflags. Any that are
+// empty are filled in from the information embedded by the go toolchain.
+func NewBuildInfo(version, commit, date string) BuildInfo {
+	info := BuildInfo{
+		Version:   version,
+		Commit:    commit,
+		Date:      date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	embedded, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	if info.Version == "" && embedded.Main.Version != "(devel)" {
+		info.Version = embedded.Main.Version
+	}
+	for _, setting := range embedded.Settings {
+		switch {
+		case setting.Key == "vcs.revision" && info.Commit == "":
+			info.Commit = setting.Value
+		case setting.Key == "vcs.time" && info.Date == "":
+			info.Date = setting.Value
+		case setting.Key == "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+
+	return info
+}
+
+// Sets Sha256 to the hash of the running binary. This reads the whole
+// binary, so is only done when it is needed.
+func (b *BuildInfo) HashExecutable() error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	contents, err := os.ReadFile(executable)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(contents)
+	b.Sha256 = hex.EncodeToString(sum[:])
+	return nil
+}
+
+// Fetches the tag of the latest tim release from github.
+func LatestTimRelease() (string, error) {
+	release, err := GetLatestTimRelease(context.Background())
+	if err != nil {
+		return "", err
+	}
+	return release.TagName, nil
+}
+
+// A release of tim published on github.
+type TimRelease struct {
+	TagName string         `json:"tag_name"`
+	Assets  []ReleaseAsset `json:"assets"`
+}
+
+// A file attached to a release.
+type ReleaseAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// Fetches the latest tim release from github.
+func GetLatestTimRelease(ctx context.Context) (*TimRelease, error) {
+	return getTimRelease(ctx, "latest")
+}
+
+// Fetches the tim release with the given tag from github.
+func GetTimRelease(ctx context.Context, tag string) (*TimRelease, error) {
+	return getTimRelease(ctx, "tags/"+url.PathEscape(tag))
+}
+
+func getTimRelease(ctx context.Context, which string) (*TimRelease, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		"https://api.github.com/repos/"+timRepository+"/releases/"+which, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch the %s tim release: %s", path.Base(which), resp.Status)
+	}
+
+	release := &TimRelease{}
+	if err := json.NewDecoder(resp.Body).Decode(release); err != nil {
+		return nil, err
+	}
+	if release.TagName == "" {
+		return nil, fmt.Errorf("the %s tim release has no tag", path.Base(which))
+	}
+	return release, nil
+}
@@ -0,0 +1,128 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"golang.org/x/mod/semver"
+)
+
+// The number of recent releases the cadence of a plugin is worked out from.
+const cadenceReleases = 10
+
+// The least and most time between checks of a plugin for new versions,
+// see CheckInterval.
+const (
+	MinCheckInterval = 24 * time.Hour
+	MaxCheckInterval = 30 * 24 * time.Hour
+)
+
+// How often new versions of a plugin are released.
+type ReleaseCadence struct {
+	// The median time between recent releases, zero if there were too
+	// few to tell.
+	Interval time.Duration `json:"interval"`
+
+	// When the latest release was made, zero if unknown.
+	LastRelease time.Time `json:"last_release"`
+
+	// Whether the cadence is from the times the plugin was upgraded,
+	// as it has too few tagged releases.
+	FromHistory bool `json:"from_history,omitempty"`
+}
+
+// Works out how often the plugin is released, from the dates of its
+// semver tags, or failing that from revisions, its upgrade history.
+func (p *Plugin) ReleaseCadence(ctx context.Context, revisions []Revision) (ReleaseCadence, error) {
+	if p.IsLocal() {
+		return ReleaseCadence{}, nil
+	}
+	dates, err := p.releaseDates(ctx)
+	if err != nil {
+		return ReleaseCadence{}, err
+	}
+	if len(dates) >= 2 {
+		return cadenceOf(dates), nil
+	}
+
+	dates = make([]time.Time, 0, len(revisions))
+	for _, revision := range revisions {
+		dates = append(dates, revision.ReplacedAt)
+	}
+	cadence := cadenceOf(dates)
+	cadence.FromHistory = true
+	return cadence, nil
+}
+
+// Returns the dates of the plugin's releases, the semver tags fetched,
+// skipping pre-releases.
+func (p *Plugin) releaseDates(ctx context.Context) ([]time.Time, error) {
+	pluginDir, err := p.RepoDir()
+	if err != nil {
+		return nil, err
+	}
+	tagDates, err := Git.TagDates(ctx, pluginDir)
+	if err != nil {
+		return nil, err
+	}
+
+	dates := make([]time.Time, 0)
+	for tag, date := range tagDates {
+		if semver.IsValid(tag) && semver.Prerelease(tag) == "" {
+			dates = append(dates, date)
+		}
+	}
+	return dates, nil
+}
+
+// Returns the cadence of releases made at dates, from the median time
+// between the most recent of them.
+func cadenceOf(dates []time.Time) ReleaseCadence {
+	if len(dates) == 0 {
+		return ReleaseCadence{}
+	}
+	dates = slices.SortedFunc(slices.Values(dates), time.Time.Compare)
+	if len(dates) > cadenceReleases {
+		dates = dates[len(dates)-cadenceReleases:]
+	}
+
+	cadence := ReleaseCadence{LastRelease: dates[len(dates)-1]}
+	intervals := make([]time.Duration, 0, len(dates)-1)
+	for i := 1; i < len(dates); i++ {
+		intervals = append(intervals, dates[i].Sub(dates[i-1]))
+	}
+	if len(intervals) > 0 {
+		slices.Sort(intervals)
+		cadence.Interval = intervals[len(intervals)/2]
+	}
+	return cadence
+}
+
+// Returns how often the plugin is worth checking for new versions, a
+// quarter of the time between its releases, between MinCheckInterval and
+// MaxCheckInterval. Plugins with an unknown cadence are checked daily.
+func (c ReleaseCadence) CheckInterval() time.Duration {
+	return min(max(c.Interval/4, MinCheckInterval), MaxCheckInterval)
+}
+
+// Returns true if a check made at checkedAt is recent enough, according
+// to CheckInterval, that the plugin need not be checked again yet.
+func (c ReleaseCadence) CheckedRecently(checkedAt time.Time) bool {
+	return time.Since(checkedAt) < c.CheckInterval()
+}
@@ -0,0 +1,59 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCadenceOf(t *testing.T) {
+	day := 24 * time.Hour
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(days ...int) []time.Time {
+		dates := make([]time.Time, 0, len(days))
+		for _, d := range days {
+			dates = append(dates, start.Add(time.Duration(d)*day))
+		}
+		return dates
+	}
+
+	tests := []struct {
+		name          string
+		dates         []time.Time
+		wantInterval  time.Duration
+		wantLast      time.Time
+		wantCheckedIn time.Duration
+	}{
+		{"none", nil, 0, time.Time{}, MinCheckInterval},
+		{"one", at(10), 0, start.Add(10 * day), MinCheckInterval},
+		{"monthly, unsorted", at(60, 0, 30, 92), 30 * day, start.Add(92 * day), 30 * day / 4},
+		{"a burst among monthly", at(0, 1, 31, 61, 91), 30 * day, start.Add(91 * day), 30 * day / 4},
+		{"yearly", at(0, 365, 730), 365 * day, start.Add(730 * day), MaxCheckInterval},
+		{"only recent releases count", at(0, 1000, 1001, 1002, 1003, 1004, 1005, 1006, 1007, 1008, 1009), day, start.Add(1009 * day), MinCheckInterval},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cadenceOf(tt.dates)
+			if got.Interval != tt.wantInterval || !got.LastRelease.Equal(tt.wantLast) {
+				t.Errorf("cadenceOf() = %+v, want interval %s, last %s", got, tt.wantInterval, tt.wantLast)
+			}
+			if interval := got.CheckInterval(); interval != tt.wantCheckedIn {
+				t.Errorf("CheckInterval() = %s, want %s", interval, tt.wantCheckedIn)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	_ "embed"
+	"encoding/json"
+	"slices"
+	"strings"
+)
+
+// Groups of popular plugins doing the same job, keyed by category name.
+// Refresh this file along with data/compat.json when cutting a release.
+//
+//go:embed data/categories.json
+var categoriesData []byte
+
+// A group of plugins doing the same job.
+type PluginCategory struct {
+	// Describes the plugins in the category, such as "status line themes".
+	Description string `json:"description"`
+
+	// Whether installing more than one plugin in the category causes
+	// problems, rather than the plugins working together.
+	Exclusive bool `json:"exclusive"`
+
+	// What goes wrong with more than one plugin in an exclusive category.
+	Conflict string `json:"conflict,omitempty"`
+
+	Plugins []string `json:"plugins"`
+}
+
+// Installed plugins in the same exclusive category.
+type CategoryOverlap struct {
+	Category    string   `json:"category"`
+	Description string   `json:"description"`
+	Conflict    string   `json:"conflict"`
+	Plugins     []string `json:"plugins"`
+}
+
+// Returns the exclusive categories more than one of the given plugins is
+// in, sorted by category name.
+func CategoryOverlaps(pluginNames []string) ([]CategoryOverlap, error) {
+	table := make(map[string]PluginCategory)
+	if err := json.Unmarshal(categoriesData, &table); err != nil {
+		return nil, err
+	}
+
+	overlaps := make([]CategoryOverlap, 0)
+	for name, category := range table {
+		if !category.Exclusive {
+			continue
+		}
+		found := make([]string, 0)
+		for _, pluginName := range pluginNames {
+			if slices.Contains(category.Plugins, strings.ToLower(pluginName)) {
+				found = append(found, pluginName)
+			}
+		}
+		if len(found) > 1 {
+			slices.Sort(found)
+			overlaps = append(overlaps, CategoryOverlap{
+				Category:    name,
+				Description: category.Description,
+				Conflict:    category.Conflict,
+				Plugins:     found,
+			})
+		}
+	}
+	slices.SortFunc(overlaps, func(a, b CategoryOverlap) int {
+		return strings.Compare(a.Category, b.Category)
+	})
+	return overlaps, nil
+}
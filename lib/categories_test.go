@@ -0,0 +1,47 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCategoryOverlaps(t *testing.T) {
+	tests := []struct {
+		plugins []string
+		want    []string
+	}{
+		{[]string{"catppuccin/tmux", "tmux-plugins/tmux-yank"}, nil},
+		{[]string{"Dracula/tmux", "catppuccin/tmux", "tmux-plugins/tmux-yank"}, []string{"theme"}},
+		// Plugins that work together are not reported.
+		{[]string{"tmux-plugins/tmux-resurrect", "tmux-plugins/tmux-continuum"}, nil},
+		{[]string{"rose-pine/tmux", "dracula/tmux", "wfxr/tmux-fzf-url", "tmux-plugins/tmux-urlview"}, []string{"theme", "url-picker"}},
+	}
+	for _, test := range tests {
+		overlaps, err := CategoryOverlaps(test.plugins)
+		if err != nil {
+			t.Fatalf("CategoryOverlaps(%v) failed: %v", test.plugins, err)
+		}
+		got := make([]string, 0)
+		for _, overlap := range overlaps {
+			got = append(got, overlap.Category)
+		}
+		if !slices.Equal(got, test.want) && len(got)+len(test.want) > 0 {
+			t.Errorf("CategoryOverlaps(%v) = %v; want %v", test.plugins, got, test.want)
+		}
+	}
+}
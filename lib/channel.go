@@ -0,0 +1,73 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// Which pre-releases of a plugin are installed and upgraded to, set by
+// the plugin's channel key in the config file.
+type Channel string
+
+const (
+	// Only releases, such as "v2.0.0". The default.
+	ChannelStable Channel = "stable"
+	// Release candidates too, such as "v2.0.0-rc.1".
+	ChannelRC Channel = "rc"
+	// Betas and release candidates.
+	ChannelBeta Channel = "beta"
+	// Every pre-release, such as "v2.0.0-alpha".
+	ChannelAny Channel = "any"
+)
+
+// The pre-release stages each channel allows, besides releases.
+var channelStages = map[Channel][]string{
+	ChannelStable: {},
+	ChannelRC:     {"rc"},
+	ChannelBeta:   {"rc", "beta"},
+}
+
+// Returns the channel named name, ChannelStable if it is empty.
+func ParseChannel(name string) (Channel, error) {
+	channel := Channel(name)
+	if name == "" {
+		return ChannelStable, nil
+	}
+	if _, ok := channelStages[channel]; !ok && channel != ChannelAny {
+		return "", fmt.Errorf("unknown channel %q, expected %q, %q, %q or %q",
+			name, ChannelStable, ChannelRC, ChannelBeta, ChannelAny)
+	}
+	return channel, nil
+}
+
+// Returns whether the channel allows the semantic version, which is
+// always true of releases.
+func (c Channel) allows(version string) bool {
+	prerelease := strings.TrimPrefix(semver.Prerelease(version), "-")
+	if prerelease == "" || c == ChannelAny {
+		return true
+	}
+	// The stage is the start of the pre-release, such as "rc" of "rc.1"
+	// or "rc2".
+	stage, _, _ := strings.Cut(strings.ToLower(prerelease), ".")
+	stage = strings.TrimRight(stage, "0123456789")
+	return slices.Contains(channelStages[c], stage)
+}
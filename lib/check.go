@@ -0,0 +1,135 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import "fmt"
+
+// CheckOutcome describes how the installed version compares to upstream.
+type CheckOutcome int
+
+const (
+	// The check could not determine anything, see CheckResult.Err.
+	OutcomeError CheckOutcome = iota
+	// The installed version is the latest available.
+	OutcomeUpToDate
+	// A newer version is available.
+	OutcomeUpgradeAvailable
+	// The installed version is newer than anything upstream.
+	OutcomeAhead
+	// A newer version exists, but a constraint prevents offering it.
+	OutcomeConstrained
+	// There is not enough local information to compare versions.
+	OutcomeUnknown
+)
+
+// ErrorClass groups check failures by their likely cause, so that
+// callers can suggest a remedy without inspecting error strings.
+type ErrorClass int
+
+const (
+	ErrorClassNone ErrorClass = iota
+	// Fetching from the remote failed.
+	ErrorClassNetwork
+	// A local git command failed.
+	ErrorClassGit
+	// The remote has no versions that tim understands.
+	ErrorClassNoVersions
+)
+
+func (c ErrorClass) String() string {
+	switch c {
+	case ErrorClassNetwork:
+		return "network"
+	case ErrorClassGit:
+		return "git"
+	case ErrorClassNoVersions:
+		return "no-versions"
+	default:
+		return "none"
+	}
+}
+
+// CheckResult is the outcome of Version.Check.
+type CheckResult struct {
+	Outcome CheckOutcome
+
+	// The latest version found upstream, nil if none was found.
+	Latest Version
+
+	// The version to upgrade to, only set when Outcome is
+	// OutcomeUpgradeAvailable.
+	Upgrade Version
+
+	// Number of pre-release versions newer than Latest that were ignored.
+	SkippedPrereleases int
+
+	// Describes the constraint that blocked an upgrade when Outcome is
+	// OutcomeConstrained.
+	Constraint string
+
+	Err        error
+	ErrorClass ErrorClass
+}
+
+func checkFailed(class ErrorClass, err error) CheckResult {
+	return CheckResult{
+		Outcome:    OutcomeError,
+		Err:        err,
+		ErrorClass: class,
+	}
+}
+
+// Returns true if an upgrade is available.
+func (r CheckResult) HasUpgrade() bool {
+	return r.Outcome == OutcomeUpgradeAvailable && r.Upgrade != nil
+}
+
+// Returns the result with its upgrade held back by constraint, such as
+// an upgrade policy, so the result explains why it is not offered.
+// Results without an upgrade are returned unchanged.
+func (r CheckResult) Constrain(constraint string) CheckResult {
+	if !r.HasUpgrade() {
+		return r
+	}
+	r.Outcome = OutcomeConstrained
+	r.Upgrade = nil
+	r.Constraint = constraint
+	return r
+}
+
+// Returns a human readable explanation of the result.
+func (r CheckResult) Reason() string {
+	var reason string
+	switch r.Outcome {
+	case OutcomeError:
+		reason = fmt.Sprintf("check failed (%s): %v", r.ErrorClass, r.Err)
+	case OutcomeUpToDate:
+		reason = "up-to-date"
+	case OutcomeUpgradeAvailable:
+		reason = fmt.Sprintf("upgrade available: %s", r.Upgrade)
+	case OutcomeAhead:
+		reason = fmt.Sprintf("installed version is newer than the latest upstream version %s", r.Latest)
+	case OutcomeConstrained:
+		reason = fmt.Sprintf("%s is available but blocked by %s", r.Latest, r.Constraint)
+	case OutcomeUnknown:
+		reason = "no installed commit recorded, unable to compare with upstream"
+	}
+
+	if r.SkippedPrereleases > 0 {
+		reason += fmt.Sprintf(" (%d pre-release versions skipped)", r.SkippedPrereleases)
+	}
+	return reason
+}
@@ -0,0 +1,37 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import "testing"
+
+func TestConstrain(t *testing.T) {
+	latest := &SemanticVersion{currentVersion: "v1.1.0"}
+	result := CheckResult{Outcome: OutcomeUpgradeAvailable, Latest: latest, Upgrade: latest, SkippedPrereleases: 2}
+
+	constrained := result.Constrain("the pinned upgrade policy")
+	if constrained.HasUpgrade() || constrained.Outcome != OutcomeConstrained {
+		t.Errorf("Constrain() = %+v; want OutcomeConstrained without an upgrade", constrained)
+	}
+	want := "v1.1.0 is available but blocked by the pinned upgrade policy (2 pre-release versions skipped)"
+	if got := constrained.Reason(); got != want {
+		t.Errorf("Reason() = %q; want %q", got, want)
+	}
+
+	upToDate := CheckResult{Outcome: OutcomeUpToDate, Latest: latest}
+	if got := upToDate.Constrain("the pinned upgrade policy"); got.Outcome != OutcomeUpToDate {
+		t.Errorf("Constrain() of an up-to-date result = %v; want it unchanged", got.Outcome)
+	}
+}
@@ -0,0 +1,163 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"sync"
+	"time"
+)
+
+// The state file the result of the last check of each plugin is kept in.
+const checkCacheFile = "checks.json"
+
+// How long "tim upgrade --check" reuses the last check of a plugin,
+// unless the config file sets check_ttl.
+const DefaultCheckTTL = 6 * time.Hour
+
+// The results of the last checks of plugins for new versions.
+type CheckCache struct {
+	Plugins map[string]*CachedCheck `json:"plugins"`
+}
+
+// The result of the last successful check of a plugin for a new version.
+type CachedCheck struct {
+	CheckedAt time.Time `json:"checked_at"`
+
+	// The version installed when the plugin was checked.
+	Version string `json:"version"`
+
+	// The channel and tag pattern the plugin was checked with, empty for
+	// the defaults. Changing either can change the upgrade available.
+	Channel    string `json:"channel,omitempty"`
+	TagPattern string `json:"tag_pattern,omitempty"`
+
+	// The latest version found upstream, empty if there were none.
+	Latest string `json:"latest,omitempty"`
+
+	// The version to upgrade to, empty if there was none.
+	Upgrade string `json:"upgrade,omitempty"`
+
+	// Why the last check failed, if it did, in which case the other
+	// fields are from the check before it.
+	Error string `json:"error,omitempty"`
+}
+
+// Guards updating the check cache, as plugins are checked concurrently.
+var checkCacheLock sync.Mutex
+
+// Reads the check cache from the state directory.
+func GetCheckCache() (*CheckCache, error) {
+	cache := &CheckCache{}
+	if err := readStateFile(checkCacheFile, cache); err != nil {
+		return nil, err
+	}
+	if cache.Plugins == nil {
+		cache.Plugins = make(map[string]*CachedCheck)
+	}
+	return cache, nil
+}
+
+// Returns the upgrade available to version according to the cached
+// check, empty if there is none, or false if the check was of another
+// version, or with another channel or tag pattern, so says nothing
+// about this one.
+func (c *CachedCheck) UpgradeFor(version Version) (string, bool) {
+	if version == nil || c.Version != version.String() {
+		return "", false
+	}
+	if channel, tagPattern := releaseSettings(version); c.Channel != channel || c.TagPattern != tagPattern {
+		return "", false
+	}
+	return c.Upgrade, true
+}
+
+// Returns the channel and tag pattern version is upgraded with, empty
+// for the defaults.
+func releaseSettings(version Version) (string, string) {
+	semanticVersion, ok := version.(*SemanticVersion)
+	if !ok {
+		return "", ""
+	}
+	channel := string(semanticVersion.channel)
+	if semanticVersion.channel == ChannelStable {
+		channel = ""
+	}
+	return channel, semanticVersion.tagPattern.String()
+}
+
+// Returns how long the last check of a plugin is reused, zero to always
+// check. check_ttl is checked when the config file is read.
+func (lf *Lockfile) CheckCacheTTL() time.Duration {
+	if lf.CheckTTL == "" {
+		return DefaultCheckTTL
+	}
+	ttl, err := time.ParseDuration(lf.CheckTTL)
+	if err != nil {
+		return DefaultCheckTTL
+	}
+	return max(ttl, 0)
+}
+
+// Returns the upgrade available to version according to the cached check,
+// or false unless the check was of version, succeeded, and was made
+// within ttl.
+func (c *CachedCheck) RecentUpgradeFor(version Version, ttl time.Duration) (string, bool) {
+	if c.Error != "" || time.Since(c.CheckedAt) >= ttl {
+		return "", false
+	}
+	return c.UpgradeFor(version)
+}
+
+// Records the result of checking the plugin at version.
+func recordCheck(pluginName string, version Version, result CheckResult) error {
+	if version == nil {
+		return nil
+	}
+	checkCacheLock.Lock()
+	defer checkCacheLock.Unlock()
+
+	cache, err := GetCheckCache()
+	if err != nil {
+		return err
+	}
+	check, ok := cache.Plugins[pluginName]
+	if !ok {
+		check = &CachedCheck{}
+		cache.Plugins[pluginName] = check
+	}
+
+	if result.Outcome == OutcomeError {
+		check.Error = "check failed"
+		if result.Err != nil {
+			check.Error = result.Err.Error()
+		}
+	} else {
+		channel, tagPattern := releaseSettings(version)
+		*check = CachedCheck{
+			CheckedAt:  time.Now().UTC(),
+			Version:    version.String(),
+			Channel:    channel,
+			TagPattern: tagPattern,
+		}
+		if result.Latest != nil {
+			check.Latest = result.Latest.String()
+		}
+		if result.Upgrade != nil {
+			check.Upgrade = result.Upgrade.String()
+		}
+	}
+	return writeStateFile(checkCacheFile, cache)
+}
@@ -0,0 +1,58 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRecordCheck(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	installed := &SemanticVersion{currentVersion: "v1.0.0"}
+	latest := &SemanticVersion{currentVersion: "v1.1.0"}
+
+	if err := recordCheck("user/plugin", installed, CheckResult{Outcome: OutcomeUpgradeAvailable, Latest: latest, Upgrade: latest}); err != nil {
+		t.Fatal(err)
+	}
+	// A failed check keeps the result of the one before it.
+	if err := recordCheck("user/plugin", installed, checkFailed(ErrorClassNetwork, errors.New("offline"))); err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err := GetCheckCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := cache.Plugins["user/plugin"]
+	if check == nil || check.Latest != "v1.1.0" || check.Error != "offline" {
+		t.Fatalf("GetCheckCache() = %+v; want latest v1.1.0 and the error", check)
+	}
+	if upgrade, ok := check.UpgradeFor(installed); !ok || upgrade != "v1.1.0" {
+		t.Errorf("UpgradeFor(v1.0.0) = %q, %v; want v1.1.0", upgrade, ok)
+	}
+	if _, ok := check.UpgradeFor(latest); ok {
+		t.Errorf("UpgradeFor(v1.1.0) used the check of v1.0.0")
+	}
+	// The rc channel may have an upgrade the stable one does not.
+	if _, ok := check.UpgradeFor(&SemanticVersion{currentVersion: "v1.0.0", channel: ChannelRC}); ok {
+		t.Errorf("UpgradeFor(v1.0.0) on the rc channel used the check of the stable channel")
+	}
+	tagPattern, _ := ParseTagPattern("release-*")
+	if _, ok := check.UpgradeFor(&SemanticVersion{currentVersion: "v1.0.0", tagPattern: tagPattern}); ok {
+		t.Errorf("UpgradeFor(v1.0.0) with a tag pattern used the check without one")
+	}
+}
@@ -0,0 +1,106 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// The compatibility table of popular plugins and the minimum tmux version
+// they need. Refresh this file when cutting a release.
+//
+//go:embed data/compat.json
+var compatData []byte
+
+// Compatibility requirements of a single plugin.
+type PluginCompat struct {
+	MinTmux string `json:"min_tmux"`
+	Reason  string `json:"reason"`
+}
+
+// Returns the compatibility requirements of the given plugin,
+// or nil if the plugin is not in the compatibility table.
+func GetPluginCompat(pluginName string) (*PluginCompat, error) {
+	table := make(map[string]PluginCompat)
+	if err := json.Unmarshal(compatData, &table); err != nil {
+		return nil, err
+	}
+
+	compat, ok := table[strings.ToLower(pluginName)]
+	if !ok {
+		return nil, nil
+	}
+	return &compat, nil
+}
+
+// Checks the plugin against the given tmux version, returning a
+// description of the incompatibility, or an empty string if the
+// plugin is expected to work.
+func CheckPluginCompat(pluginName, tmuxVersion string) (string, error) {
+	compat, err := GetPluginCompat(pluginName)
+	if err != nil || compat == nil {
+		return "", err
+	}
+
+	cmp, err := CompareTmuxVersions(tmuxVersion, compat.MinTmux)
+	if err != nil {
+		return "", err
+	}
+	if cmp >= 0 {
+		return "", nil
+	}
+
+	return fmt.Sprintf("%s requires tmux %s or newer (%s), but tmux %s is installed",
+		pluginName, compat.MinTmux, compat.Reason, tmuxVersion), nil
+}
+
+var tmuxVersionRegexp = regexp.MustCompile(`^(?:next-)?(\d+)\.(\d+)([a-z]?)(?:-rc\d*)?$`)
+
+// Compares two tmux versions, such as "3.3a" and "3.2", returning
+// -1, 0 or 1 in the same way as semver.Compare.
+func CompareTmuxVersions(a, b string) (int, error) {
+	aSemver, err := tmuxToSemver(a)
+	if err != nil {
+		return 0, err
+	}
+	bSemver, err := tmuxToSemver(b)
+	if err != nil {
+		return 0, err
+	}
+
+	return semver.Compare(aSemver, bSemver), nil
+}
+
+// Converts a tmux version to semver. A trailing patch letter becomes the
+// patch number, so "3.3a" is "v3.3.1".
+func tmuxToSemver(version string) (string, error) {
+	match := tmuxVersionRegexp.FindStringSubmatch(strings.TrimSpace(version))
+	if match == nil {
+		return "", fmt.Errorf("unrecognised tmux version: %q", version)
+	}
+
+	patch := 0
+	if match[3] != "" {
+		patch = int(match[3][0]-'a') + 1
+	}
+	return fmt.Sprintf("v%s.%s.%d", match[1], match[2], patch), nil
+}
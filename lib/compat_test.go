@@ -0,0 +1,47 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"testing"
+)
+
+func TestCompareTmuxVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"3.3a", "3.3", 1},
+		{"3.2", "3.2", 0},
+		{"2.9a", "3.2", -1},
+		{"next-3.5", "3.4", 1},
+		{"3.4\n", "3.4", 0},
+	}
+
+	for _, test := range tests {
+		got, err := CompareTmuxVersions(test.a, test.b)
+		if err != nil {
+			t.Errorf("CompareTmuxVersions(%q, %q) returned error %v", test.a, test.b, err)
+		}
+		if got != test.want {
+			t.Errorf("CompareTmuxVersions(%q, %q) = %d; want %d", test.a, test.b, got, test.want)
+		}
+	}
+
+	if _, err := CompareTmuxVersions("banana", "3.2"); err == nil {
+		t.Errorf("CompareTmuxVersions(\"banana\", \"3.2\") returned no error")
+	}
+}
@@ -0,0 +1,107 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/kjnsn/tim/lib/message"
+	"golang.org/x/mod/semver"
+)
+
+// The state file completions are served from.
+const completionCacheFile = "completion.json"
+
+// How long the completion cache is used before it is refreshed.
+const CompletionCacheTTL = 10 * time.Minute
+
+// What shell completion needs to know about plugins, kept up-to-date by
+// other commands so completion never waits on git or the network.
+type CompletionCache struct {
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Plugins map[string]CachedPlugin `json:"plugins"`
+}
+
+// What shell completion needs to know about a single plugin.
+type CachedPlugin struct {
+	// Version spec from the config file.
+	Version string `json:"version"`
+
+	// Semantic version tags of the installed plugin, newest first.
+	Tags []string `json:"tags,omitempty"`
+}
+
+// Reads the completion cache. Returns an empty cache if there is none.
+func GetCompletionCache() (*CompletionCache, error) {
+	cache := &CompletionCache{Plugins: make(map[string]CachedPlugin)}
+	if err := readStateFile(completionCacheFile, cache); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// Returns true if the cache should be refreshed, because it is older
+// than CompletionCacheTTL or than the config file.
+func (c *CompletionCache) Stale(lockFile *Lockfile) bool {
+	if time.Since(c.UpdatedAt) > CompletionCacheTTL {
+		return true
+	}
+	info, err := os.Stat(lockFile.Path())
+	return err != nil || info.ModTime().After(c.UpdatedAt)
+}
+
+// Rebuilds the completion cache from the config file and the tags of
+// installed plugins. Only local repositories are read, nothing is fetched.
+func RefreshCompletionCache(ctx context.Context, lockFile *Lockfile) error {
+	cache := &CompletionCache{
+		UpdatedAt: time.Now().UTC(),
+		Plugins:   make(map[string]CachedPlugin),
+	}
+
+	for _, plugin := range lockFile.Plugins() {
+		cache.Plugins[plugin.Name] = CachedPlugin{
+			Version: lockFile.PluginSpecs[plugin.Name].Version,
+			// A broken plugin still completes, just without its tags.
+			Tags: completionTags(ctx, &plugin),
+		}
+	}
+
+	return writeStateFile(completionCacheFile, cache)
+}
+
+// Returns the plugin's release tags, newest first, or nil if it isn't
+// installed or its tags can't be read.
+func completionTags(ctx context.Context, plugin *Plugin) []string {
+	pluginDir, err := plugin.RepoDir()
+	if err != nil || !IsRepository(pluginDir) {
+		return nil
+	}
+	tags, err := Git.Tags(ctx, pluginDir)
+	if err != nil {
+		message.Debug("Unable to read the tags of %s for completions: %s", plugin.Name, err)
+		return nil
+	}
+	tags = slices.DeleteFunc(tags, func(tag string) bool {
+		return !semver.IsValid(tag)
+	})
+	semver.Sort(tags)
+	slices.Reverse(tags)
+	return tags
+}
@@ -0,0 +1,637 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// The formats a config file can be written in, chosen by its extension.
+const (
+	formatJSON = "json"
+	formatTOML = "toml"
+	formatYAML = "yaml"
+)
+
+// The names the config file is looked for by, in tim's config directory.
+var configFileNames = []string{"tim.json", "tim.toml", "tim.yaml", "tim.yml"}
+
+// Returns the format of the config file at configPath.
+func configFormat(configPath string) string {
+	switch strings.ToLower(filepath.Ext(configPath)) {
+	case ".toml":
+		return formatTOML
+	case ".yaml", ".yml":
+		return formatYAML
+	}
+	return formatJSON
+}
+
+// Converts the contents of the config file at configPath to JSON, so
+// that every format is decoded the same way.
+func configJSON(configPath string, contents []byte) ([]byte, error) {
+	var document map[string]any
+	switch configFormat(configPath) {
+	case formatTOML:
+		if err := toml.Unmarshal(contents, &document); err != nil {
+			return nil, err
+		}
+	case formatYAML:
+		if err := yaml.Unmarshal(contents, &document); err != nil {
+			return nil, err
+		}
+	default:
+		return contents, nil
+	}
+
+	if document == nil {
+		// Only comments.
+		document = make(map[string]any)
+	}
+	return json.Marshal(document)
+}
+
+// Converts document, the config as JSON, to the format of the config
+// file at configPath. The comments and order of keys in original, the
+// current contents of the file, are kept.
+func formatConfig(configPath string, original, document []byte) ([]byte, error) {
+	switch configFormat(configPath) {
+	case formatTOML:
+		return formatTOMLConfig(original, document)
+	case formatYAML:
+		return formatYAMLConfig(original, document)
+	}
+	return document, nil
+}
+
+// Decodes a JSON document into a yaml node, which keeps the order of
+// its keys.
+func orderedDocument(document []byte) (*yaml.Node, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(document, &root); err != nil {
+		return nil, err
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("the config is not an object")
+	}
+	clearStyle(root.Content[0])
+	return root.Content[0], nil
+}
+
+// Resets the style of node and its children, so JSON is written as
+// block style yaml.
+func clearStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearStyle(child)
+	}
+}
+
+// Returns the value at path in a mapping node, or nil if there is none.
+func lookupNode(node *yaml.Node, path []string) *yaml.Node {
+	for _, key := range path {
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				next = node.Content[i+1]
+			}
+		}
+		if next == nil || next.Tag == "!!null" {
+			return nil
+		}
+		node = next
+	}
+	return node
+}
+
+// Checks if old, a value decoded from the config file at path, is the
+// same as value. A plugin written as just its version is the same as
+// an object with only that version.
+func sameConfigValue(path []string, old any, value *yaml.Node) bool {
+	if version, ok := old.(string); ok && len(path) == 2 && path[0] == "plugins" {
+		spec := pluginVersionOnly(path, value)
+		return spec != nil && spec.Value == version
+	}
+
+	var decoded any
+	if err := value.Decode(&decoded); err != nil {
+		return false
+	}
+	oldJSON, err := json.Marshal(old)
+	if err != nil {
+		return false
+	}
+	newJSON, err := json.Marshal(decoded)
+	return err == nil && bytes.Equal(oldJSON, newJSON)
+}
+
+// Returns the version of the plugin at path, if that is all it has,
+// so it can be written as just the version.
+func pluginVersionOnly(path []string, value *yaml.Node) *yaml.Node {
+	if len(path) != 2 || path[0] != "plugins" || len(value.Content) != 2 {
+		return nil
+	}
+	return lookupNode(value, []string{"version"})
+}
+
+// Writes the config as yaml, merged into original.
+func formatYAMLConfig(original, document []byte) ([]byte, error) {
+	config, err := orderedDocument(document)
+	if err != nil {
+		return nil, err
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(original, &root); err != nil {
+		return nil, err
+	}
+	if len(root.Content) == 0 {
+		root.Kind = yaml.DocumentNode
+		root.Content = []*yaml.Node{config}
+	} else {
+		root.Content[0] = mergeYAML(nil, root.Content[0], config)
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&root); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Returns value, keeping the comments, order and style of old, the node
+// at path in the existing file, wherever they match.
+func mergeYAML(path []string, old, value *yaml.Node) *yaml.Node {
+	if old.Kind == yaml.MappingNode && value.Kind == yaml.MappingNode {
+		merged := make([]*yaml.Node, 0, len(value.Content))
+		for i := 0; i+1 < len(old.Content); i += 2 {
+			key := old.Content[i]
+			if next := lookupNode(value, []string{key.Value}); next != nil {
+				merged = append(merged, key, mergeYAML(append(slices.Clone(path), key.Value), old.Content[i+1], next))
+			}
+		}
+		for i := 0; i+1 < len(value.Content); i += 2 {
+			key := value.Content[i]
+			if lookupNode(old, []string{key.Value}) == nil && value.Content[i+1].Tag != "!!null" {
+				merged = append(merged, key, value.Content[i+1])
+			}
+		}
+		old.Content = merged
+		return old
+	}
+
+	var decoded any
+	if err := old.Decode(&decoded); err == nil && sameConfigValue(path, decoded, value) {
+		return old
+	}
+	if version := pluginVersionOnly(path, value); version != nil && old.Kind == yaml.ScalarNode {
+		value = version
+	}
+	value.HeadComment, value.LineComment, value.FootComment = old.HeadComment, old.LineComment, old.FootComment
+	return value
+}
+
+// A line of a TOML file, or several for a value spanning lines.
+type tomlEntry struct {
+	lines []string
+
+	// The path of the table, for a table header.
+	header []string
+
+	// The full path of the key, for a key/value pair, and its value.
+	key   []string
+	value any
+
+	// The text before and after the value, kept when it is replaced.
+	prefix, comment string
+}
+
+func (e tomlEntry) isComment() bool {
+	return e.header == nil && e.key == nil && strings.TrimSpace(e.lines[0]) != ""
+}
+
+// Splits a TOML file into entries, tracking the key each line defines.
+func parseTOMLEntries(contents []byte) ([]tomlEntry, error) {
+	text := strings.TrimSuffix(string(contents), "\n")
+	if text == "" {
+		return nil, nil
+	}
+	lines := strings.Split(text, "\n")
+
+	entries := make([]tomlEntry, 0, len(lines))
+	var table []string
+	for i := 0; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			entries = append(entries, tomlEntry{lines: lines[i : i+1]})
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "[") {
+			path, _, err := parseTOMLKey(strings.TrimLeft(trimmed, "["), ']')
+			if err != nil {
+				return nil, err
+			}
+			table = path
+			entries = append(entries, tomlEntry{lines: lines[i : i+1], header: path})
+			continue
+		}
+
+		key, rest, err := parseTOMLKey(lines[i], '=')
+		if err != nil {
+			return nil, err
+		}
+		// The value may span lines, such as a multi-line string.
+		var value map[string]any
+		end := i
+		for raw := rest; ; raw += "\n" + lines[end] {
+			if toml.Unmarshal([]byte("v ="+raw), &value) == nil {
+				break
+			}
+			if end++; end == len(lines) {
+				return nil, errors.New("unable to parse the value of " + strings.Join(key, "."))
+			}
+		}
+
+		entry := tomlEntry{
+			lines:  lines[i : end+1],
+			key:    append(slices.Clone(table), key...),
+			value:  value["v"],
+			prefix: strings.TrimSuffix(lines[i], rest) + " ",
+		}
+		if end == i {
+			entry.comment = tomlComment(rest)
+		}
+		entries = append(entries, entry)
+		i = end
+	}
+	return entries, nil
+}
+
+var bareKeyRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+`)
+
+// Parses a dotted key up to the end character, returning its parts and
+// the text after the end character.
+func parseTOMLKey(s string, end byte) ([]string, string, error) {
+	parts := make([]string, 0)
+	rest := strings.TrimLeft(s, " \t")
+	for {
+		switch {
+		case strings.HasPrefix(rest, `"`):
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				return nil, "", err
+			}
+			part, err := strconv.Unquote(quoted)
+			if err != nil {
+				return nil, "", err
+			}
+			parts = append(parts, part)
+			rest = rest[len(quoted):]
+		case strings.HasPrefix(rest, "'"):
+			part, after, found := strings.Cut(rest[1:], "'")
+			if !found {
+				return nil, "", errors.New("unterminated key: " + s)
+			}
+			parts = append(parts, part)
+			rest = after
+		default:
+			part := bareKeyRegexp.FindString(rest)
+			if part == "" {
+				return nil, "", errors.New("invalid key: " + s)
+			}
+			parts = append(parts, part)
+			rest = rest[len(part):]
+		}
+
+		rest = strings.TrimLeft(rest, " \t")
+		if strings.HasPrefix(rest, ".") {
+			rest = strings.TrimLeft(rest[1:], " \t")
+			continue
+		}
+		if rest == "" || rest[0] != end {
+			return nil, "", errors.New("invalid key: " + s)
+		}
+		return parts, rest[1:], nil
+	}
+}
+
+// Returns the comment at the end of a single line value, with the
+// space before it.
+func tomlComment(value string) string {
+	var quote rune
+	escaped := false
+	for i, r := range value {
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && r == '\\':
+			escaped = true
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case r == quote:
+			quote = 0
+		case quote == 0 && r == '#':
+			return value[len(strings.TrimRight(value[:i], " \t")):]
+		}
+	}
+	return ""
+}
+
+// Writes the config as TOML, merged into original. Lines of original
+// are kept as they are unless their value changed, so comments and the
+// order of keys survive.
+func formatTOMLConfig(original, document []byte) ([]byte, error) {
+	config, err := orderedDocument(document)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := parseTOMLEntries(original)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([][]string, len(entries))
+	// Drops the comments directly above entry i, along with it.
+	dropComments := func(i int) {
+		for j := i - 1; j >= 0 && out[j] != nil && entries[j].isComment(); j-- {
+			out[j] = nil
+		}
+	}
+
+	// The keys written, and the entry each table's new keys go after.
+	written := make(map[string]bool)
+	sections := make(map[string]int)
+	firstHeader := len(entries)
+
+	var table []string
+	dropping := false
+	pending := make([]int, 0)
+	for i, entry := range entries {
+		switch {
+		case entry.header != nil:
+			node := lookupNode(config, entry.header)
+			if node == nil || node.Kind != yaml.MappingNode {
+				dropping = true
+				dropComments(i)
+				pending = pending[:0]
+				continue
+			}
+			if dropping {
+				// Keep the comments of this table from the dropped table above.
+				for j := len(pending) - 1; j >= 0 && entries[pending[j]].isComment(); j-- {
+					out[pending[j]] = entries[pending[j]].lines
+				}
+				dropping = false
+				pending = pending[:0]
+			}
+			table = entry.header
+			sections[tomlPathKey(table)] = i
+			firstHeader = min(firstHeader, i)
+			out[i] = entry.lines
+		case dropping:
+			if entry.key == nil {
+				pending = append(pending, i)
+			}
+		case entry.key != nil:
+			node := lookupNode(config, entry.key)
+			if node == nil {
+				dropComments(i)
+				continue
+			}
+			written[tomlPathKey(entry.key)] = true
+			sections[tomlPathKey(table)] = i
+			if sameConfigValue(entry.key, entry.value, node) {
+				out[i] = entry.lines
+			} else {
+				out[i] = []string{entry.prefix + tomlConfigValue(entry.key, node) + entry.comment}
+			}
+		default:
+			out[i] = entry.lines
+		}
+	}
+
+	// Checks if anything kept defines keys within the table at path.
+	defined := func(path []string) bool {
+		for i, entry := range entries {
+			if out[i] == nil {
+				continue
+			}
+			for _, keyPath := range [][]string{entry.header, entry.key} {
+				if len(keyPath) >= len(path) && slices.Equal(keyPath[:len(path)], path) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	// Missing keys are added after the last key of the closest table
+	// with a header, or before the first header for the top level.
+	after := make(map[int][]string)
+	topLevel := make([]string, 0)
+	appended := make([]string, 0)
+	insert := func(path []string, value *yaml.Node) {
+		table := path[:len(path)-1]
+		for len(table) > 0 {
+			if _, ok := sections[tomlPathKey(table)]; ok {
+				break
+			}
+			table = table[:len(table)-1]
+		}
+		line := tomlKeyPath(path[len(table):]) + " = " + tomlConfigValue(path, value)
+
+		if i, ok := sections[tomlPathKey(table)]; ok {
+			after[i] = append(after[i], line)
+		} else {
+			topLevel = append(topLevel, line)
+		}
+	}
+
+	var addMissing func(path []string, node *yaml.Node)
+	addMissing = func(path []string, node *yaml.Node) {
+		_, hasHeader := sections[tomlPathKey(path)]
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyPath := append(slices.Clone(path), node.Content[i].Value)
+			value := node.Content[i+1]
+			switch {
+			case written[tomlPathKey(keyPath)] || value.Tag == "!!null":
+				continue
+			case value.Kind == yaml.MappingNode && defined(keyPath):
+				addMissing(keyPath, value)
+			case value.Kind != yaml.MappingNode || (hasHeader && len(path) > 0):
+				// Tables within a table with a header are written inline,
+				// matching the keys around them.
+				insert(keyPath, value)
+			default:
+				appended = append(appended, tomlTable(keyPath, value)...)
+			}
+		}
+	}
+	addMissing(nil, config)
+
+	// Top level keys go above the first table and any comments describing it.
+	topLevelAt := firstHeader
+	for topLevelAt > 0 && out[topLevelAt-1] != nil && entries[topLevelAt-1].isComment() {
+		topLevelAt--
+	}
+	if len(topLevel) > 0 && topLevelAt < len(entries) {
+		topLevel = append(topLevel, "")
+	}
+
+	lines := make([]string, 0, len(entries))
+	for i := range out {
+		if i == topLevelAt {
+			lines = append(lines, topLevel...)
+		}
+		lines = append(lines, out[i]...)
+		lines = append(lines, after[i]...)
+	}
+	if topLevelAt == len(entries) {
+		lines = append(lines, topLevel...)
+	}
+	lines = append(lines, appended...)
+
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
+
+// Returns the lines of a new table at path, with its keys first and then
+// its sub-tables. Tables with only sub-tables have no header of their own.
+func tomlTable(path []string, node *yaml.Node) []string {
+	keys := make([]string, 0)
+	subtables := make([]string, 0)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyPath := append(slices.Clone(path), node.Content[i].Value)
+		value := node.Content[i+1]
+		if value.Tag == "!!null" {
+			continue
+		}
+		if value.Kind == yaml.MappingNode {
+			subtables = append(subtables, tomlTable(keyPath, value)...)
+		} else {
+			keys = append(keys, tomlKey(node.Content[i].Value)+" = "+tomlConfigValue(keyPath, value))
+		}
+	}
+
+	if len(keys) == 0 && len(subtables) > 0 {
+		return subtables
+	}
+	lines := append([]string{"", "[" + tomlKeyPath(path) + "]"}, keys...)
+	return append(lines, subtables...)
+}
+
+// Encodes the value at path as TOML, writing plugins with only a
+// version as just the version.
+func tomlConfigValue(path []string, node *yaml.Node) string {
+	if version := pluginVersionOnly(path, node); version != nil {
+		return tomlString(version.Value)
+	}
+	return tomlValue(node)
+}
+
+// Encodes a value as TOML, with objects as inline tables.
+func tomlValue(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		pairs := make([]string, 0)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i+1].Tag != "!!null" {
+				pairs = append(pairs, tomlKey(node.Content[i].Value)+" = "+tomlValue(node.Content[i+1]))
+			}
+		}
+		if len(pairs) == 0 {
+			return "{}"
+		}
+		return "{ " + strings.Join(pairs, ", ") + " }"
+	case yaml.SequenceNode:
+		items := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			items = append(items, tomlValue(item))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+
+	if node.Tag == "!!str" {
+		return tomlString(node.Value)
+	}
+	// JSON numbers and booleans are written the same way in TOML.
+	return node.Value
+}
+
+// Quotes s as a TOML basic string.
+func tomlString(s string) string {
+	var quoted strings.Builder
+	quoted.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			quoted.WriteByte('\\')
+			quoted.WriteRune(r)
+		case r == '\n':
+			quoted.WriteString(`\n`)
+		case r == '\t':
+			quoted.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&quoted, `\u%04x`, r)
+		default:
+			quoted.WriteRune(r)
+		}
+	}
+	quoted.WriteByte('"')
+	return quoted.String()
+}
+
+// Returns key as a TOML key, quoted unless it is a bare key.
+func tomlKey(key string) string {
+	if bareKeyRegexp.FindString(key) == key && key != "" {
+		return key
+	}
+	return tomlString(key)
+}
+
+func tomlKeyPath(path []string) string {
+	keys := make([]string, 0, len(path))
+	for _, key := range path {
+		keys = append(keys, tomlKey(key))
+	}
+	return strings.Join(keys, ".")
+}
+
+func tomlPathKey(path []string) string {
+	return strings.Join(path, "\x00")
+}
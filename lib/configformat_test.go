@@ -0,0 +1,133 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"testing"
+)
+
+func TestFormatTOMLConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		document string
+		want     string
+	}{
+		{
+			name:     "new file",
+			original: "",
+			document: `{"schema_version": 3, "timeouts": {"clone": "10m"}, "plugins": {"a/b": {"version": "v1.0.0", "options": {"@b-dir": "~/b"}}}}`,
+			want: `schema_version = 3
+
+[timeouts]
+clone = "10m"
+
+[plugins."a/b"]
+version = "v1.0.0"
+
+[plugins."a/b".options]
+"@b-dir" = "~/b"
+`,
+		},
+		{
+			name: "keeps comments",
+			original: `# My tmux plugins.
+
+[plugins]
+# Themes.
+"catppuccin/tmux" = "v1.0.0"  # pinned for now
+"a/b" = "main"
+`,
+			document: `{"schema_version": 3, "plugins": {"a/b": {"version": "main"}, "catppuccin/tmux": {"version": "v2.0.0"}}}`,
+			want: `# My tmux plugins.
+
+schema_version = 3
+
+[plugins]
+# Themes.
+"catppuccin/tmux" = "v2.0.0"  # pinned for now
+"a/b" = "main"
+`,
+		},
+		{
+			name: "adds and removes plugins",
+			original: `schema_version = 3
+
+# Sessions.
+[plugins."tmux-plugins/tmux-resurrect"]
+version = "v4.0.0"
+
+# Colours.
+[plugins."catppuccin/tmux"]
+version = "v1.0.0"
+env = { THEME = "mocha" }
+`,
+			document: `{"schema_version": 3, "plugins": {"catppuccin/tmux": {"version": "v1.0.0", "env": {"THEME": "latte"}, "options": {"@x": "y"}}, "a/b": {"version": "v1.0.0"}}}`,
+			want: `schema_version = 3
+
+# Colours.
+[plugins."catppuccin/tmux"]
+version = "v1.0.0"
+env = { THEME = "latte" }
+options = { "@x" = "y" }
+
+[plugins."a/b"]
+version = "v1.0.0"
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := formatTOMLConfig([]byte(test.original), []byte(test.document))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.want {
+				t.Errorf("formatTOMLConfig() =\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFormatYAMLConfig(t *testing.T) {
+	original := `# My tmux plugins.
+plugins:
+  # Themes.
+  catppuccin/tmux: v1.0.0 # pinned for now
+  a/b:
+    version: main
+    options:
+      "@b-dir": ~/b
+`
+	document := `{"schema_version": 3, "plugins": {"a/b": {"version": "main"}, "catppuccin/tmux": {"version": "v2.0.0"}}}`
+	want := `# My tmux plugins.
+plugins:
+  # Themes.
+  catppuccin/tmux: v2.0.0 # pinned for now
+  a/b:
+    version: main
+schema_version: 3
+`
+
+	got, err := formatYAMLConfig([]byte(original), []byte(document))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("formatYAMLConfig() =\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Names of custom commands, which are run as "tim <name>".
+var customCommandRegexp = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// A command defined in the config file, which either runs tim with some
+// arguments, or a shell command.
+type CustomCommand struct {
+	// Arguments to run tim with, such as ["upgrade", "--only", "patch"].
+	Args []string `json:"args,omitempty"`
+
+	// Shell command run with "sh -c" instead, given any arguments as "$@".
+	Shell string `json:"shell,omitempty"`
+
+	// Shown in the help output.
+	Description string `json:"description,omitempty"`
+}
+
+// Accepts either a list of arguments, convenient when editing by hand,
+// or an object.
+func (c *CustomCommand) UnmarshalJSON(data []byte) error {
+	var args []string
+	if err := json.Unmarshal(data, &args); err == nil {
+		*c = CustomCommand{Args: args}
+		return nil
+	}
+
+	type plain CustomCommand
+	return json.Unmarshal(data, (*plain)(c))
+}
+
+// Writes commands that only have arguments as a list, as they are
+// usually written.
+func (c CustomCommand) MarshalJSON() ([]byte, error) {
+	if len(c.Args) > 0 && c.Shell == "" && c.Description == "" {
+		return json.Marshal(c.Args)
+	}
+	type plain CustomCommand
+	return json.Marshal(plain(c))
+}
+
+// Checks that the command has a valid name and runs exactly one thing.
+func (c CustomCommand) Validate(name string) error {
+	if !customCommandRegexp.MatchString(name) {
+		return fmt.Errorf("invalid name %q, use lowercase letters, digits and dashes", name)
+	}
+	if (len(c.Args) == 0) == (c.Shell == "") {
+		return fmt.Errorf("command %s must have either arguments or shell, but not both", name)
+	}
+	return nil
+}
+
+// Returns the custom commands in the config file, without changing or
+// locking it, as they are needed before any command runs. A missing
+// config file has none.
+func ReadCustomCommands(cfgOverride string) (map[string]CustomCommand, error) {
+	configPath, err := lockfilePath(cfgOverride)
+	if err != nil {
+		return nil, err
+	}
+	contents, err := os.ReadFile(configPath)
+	if errors.Is(err, fs.ErrNotExist) || len(contents) == 0 {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	document, err := configJSON(configPath, contents)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", configPath, err)
+	}
+	var config struct {
+		Commands map[string]CustomCommand `json:"commands"`
+	}
+	if err := json.Unmarshal(document, &config); err != nil {
+		return nil, fmt.Errorf("unable to read commands in %s: %w", configPath, err)
+	}
+	return config.Commands, nil
+}
+
+// Describes what the command runs, such as "tim upgrade --only patch".
+func (c CustomCommand) String() string {
+	if c.Shell != "" {
+		return c.Shell
+	}
+	return "tim " + strings.Join(c.Args, " ")
+}
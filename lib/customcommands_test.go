@@ -0,0 +1,86 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestReadCustomCommands(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	configPath := path.Join(t.TempDir(), "tim.json")
+	config := `{"commands": {
+		"refresh": ["upgrade", "--only", "patch"],
+		"reload": {"shell": "tmux source ~/.tmux.conf", "description": "Reloads tmux"}
+	}}`
+	if err := os.WriteFile(configPath, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	commands, err := ReadCustomCommands(configPath)
+	if err != nil {
+		t.Fatalf("ReadCustomCommands() error = %v", err)
+	}
+	if got := commands["refresh"].String(); got != "tim upgrade --only patch" {
+		t.Errorf("refresh = %q", got)
+	}
+	if got := commands["reload"]; got.Shell != "tmux source ~/.tmux.conf" || got.Description != "Reloads tmux" {
+		t.Errorf("reload = %+v", got)
+	}
+
+	// Commands are written back the way they were read.
+	for name, want := range map[string]string{
+		"refresh": `["upgrade","--only","patch"]`,
+		"reload":  `{"shell":"tmux source ~/.tmux.conf","description":"Reloads tmux"}`,
+	} {
+		got, err := json.Marshal(commands[name])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("json.Marshal(%s) = %s, want %s", name, got, want)
+		}
+	}
+
+	missing, err := ReadCustomCommands(path.Join(t.TempDir(), "missing.json"))
+	if err != nil || len(missing) != 0 {
+		t.Errorf("ReadCustomCommands() of a missing file = %v, %v", missing, err)
+	}
+}
+
+func TestCustomCommandValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		command CustomCommand
+		wantErr bool
+	}{
+		{"refresh", CustomCommand{Args: []string{"upgrade"}}, false},
+		{"re-load2", CustomCommand{Shell: "true"}, false},
+		{"Refresh", CustomCommand{Args: []string{"upgrade"}}, true},
+		{"-x", CustomCommand{Args: []string{"upgrade"}}, true},
+		{"both", CustomCommand{Args: []string{"upgrade"}, Shell: "true"}, true},
+		{"neither", CustomCommand{}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.command.Validate(tt.name); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%q) = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
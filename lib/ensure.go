@@ -0,0 +1,150 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// What Ensure is doing to a plugin.
+type EnsureAction int
+
+const (
+	// The plugin is being installed or checked out.
+	EnsureStarted EnsureAction = iota
+	// The plugin is installed at the expected version.
+	EnsureDone
+	// The plugin was installed but is not in the config file, and has
+	// been removed.
+	EnsurePruned
+	// Something went wrong, see EnsureEvent.Err.
+	EnsureFailed
+)
+
+// Describes the progress of Ensure for a single plugin.
+type EnsureEvent struct {
+	Plugin string
+	Action EnsureAction
+
+	// The version being installed, empty for pruned plugins.
+	Version string
+
+	Err error
+}
+
+// Makes the installed plugins match the config file at configPath:
+// plugins in tim.lock are checked out at exactly the recorded commit,
+// other plugins in the config file are installed and recorded in
+// tim.lock, and plugins not in the config file are removed.
+//
+// This is the entry point for programs that embed tim, for example:
+//
+//	err := lib.Ensure(ctx, "", func(event lib.EnsureEvent) {
+//		log.Printf("%s: %v", event.Plugin, event.Action)
+//	})
+//
+// An empty configPath uses the default ~/.config/tim/tim.json. The
+// progress function may be nil. Ensure continues past plugins that fail,
+// returning all of the failures, and stops early if ctx is cancelled.
+func Ensure(ctx context.Context, configPath string, progress func(EnsureEvent)) error {
+	if progress == nil {
+		progress = func(EnsureEvent) {}
+	}
+
+	lockFile, err := GetLockfile(ctx, configPath)
+	if err != nil {
+		return err
+	}
+	defer lockFile.Close()
+
+	errs := make([]error, 0)
+	fail := func(plugin, version string, err error) {
+		progress(EnsureEvent{Plugin: plugin, Action: EnsureFailed, Version: version, Err: err})
+		errs = append(errs, fmt.Errorf("%s: %w", plugin, err))
+	}
+
+	for _, plugin := range lockFile.Plugins() {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		spec := lockFile.PluginSpecs[plugin.Name]
+		locked, isLocked := lockFile.Locked[plugin.Name]
+		isNew := errors.Is(plugin.CheckInstalled(), ErrPluginNotInstalled)
+		if isLocked && locked.Ref == spec.Version {
+			progress(EnsureEvent{Plugin: plugin.Name, Action: EnsureStarted, Version: locked.Ref})
+			if err := plugin.InstallLocked(ctx, locked); err != nil {
+				fail(plugin.Name, locked.Ref, err)
+				continue
+			}
+			if isNew {
+				warnLifecycleHook(ctx, &plugin, LifecyclePostInstall)
+			}
+			progress(EnsureEvent{Plugin: plugin.Name, Action: EnsureDone, Version: locked.Ref})
+			continue
+		}
+
+		// Not locked, or the config file has changed since it was.
+		plugin.Version = nil
+		progress(EnsureEvent{Plugin: plugin.Name, Action: EnsureStarted, Version: spec.Version})
+		if err := plugin.Install(ctx, spec.Version); err != nil {
+			fail(plugin.Name, spec.Version, err)
+			continue
+		}
+		if isNew {
+			warnLifecycleHook(ctx, &plugin, LifecyclePostInstall)
+		}
+		if err := lockFile.Record(ctx, &plugin); err != nil {
+			fail(plugin.Name, spec.Version, err)
+			continue
+		}
+		progress(EnsureEvent{Plugin: plugin.Name, Action: EnsureDone, Version: plugin.Version.String()})
+	}
+
+	if ctx.Err() == nil {
+		errs = append(errs, pruneExtraPlugins(ctx, lockFile, progress))
+	}
+
+	if err := lockFile.Save(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Removes installed plugins that are not in the config file.
+func pruneExtraPlugins(ctx context.Context, lockFile *Lockfile, progress func(EnsureEvent)) error {
+	orphaned, err := lockFile.OrphanedPlugins()
+	if err != nil {
+		return err
+	}
+
+	errs := make([]error, 0)
+	for _, name := range orphaned {
+		plugin := Plugin{Name: name}
+		warnLifecycleHook(ctx, &plugin, LifecyclePreUninstall)
+		if err := plugin.Uninstall(); err != nil {
+			progress(EnsureEvent{Plugin: name, Action: EnsureFailed, Err: err})
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		delete(lockFile.Locked, name)
+		progress(EnsureEvent{Plugin: name, Action: EnsurePruned})
+	}
+	return errors.Join(errs...)
+}
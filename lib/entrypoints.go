@@ -0,0 +1,203 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/kjnsn/tim/lib/message"
+)
+
+// Directories not searched for scripts, as they hold tests, examples or
+// dependencies rather than the plugin itself.
+var skippedScriptDirs = []string{"doc", "docs", "example", "examples", "test", "tests", "vendor", "node_modules"}
+
+// Returns the absolute paths of the scripts run when loading the plugin,
+// see findEntrypoints.
+func (p *Plugin) Entrypoints() ([]string, error) {
+	pluginDir, err := p.Dir()
+	if err != nil {
+		return nil, err
+	}
+	run, err := p.runPatterns()
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0)
+	err = fs.WalkDir(os.DirFS(pluginDir), ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Never searched, and can hold many thousands of files.
+		if entry.IsDir() && name != "." && (strings.HasPrefix(entry.Name(), ".") || entry.Name() == "node_modules") {
+			return fs.SkipDir
+		}
+		// Symlinks are checked by checkEntrypoint once found.
+		if entry.Type().IsRegular() || entry.Type()&fs.ModeSymlink != 0 {
+			files = append(files, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := findEntrypoints(files, run)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", p.Name, err)
+	}
+	entrypoints := make([]string, 0, len(found))
+	for _, entrypoint := range found {
+		if err := checkEntrypoint(pluginDir, entrypoint); err != nil {
+			message.Fields{Plugin: p.Name, Code: string(CodeUnsafeScript)}.Warning(
+				"Skipping script %s of plugin %s: %s", entrypoint, p.Name, err)
+			continue
+		}
+		entrypoints = append(entrypoints, path.Join(pluginDir, entrypoint))
+	}
+	return entrypoints, nil
+}
+
+// Checks that a script is safe to run: it must be a regular file in the
+// plugin directory once symlinks are followed, that not every user can
+// change, and that does not run with the privileges of its owner or group.
+func checkEntrypoint(pluginDir, script string) error {
+	root, err := filepath.EvalSymlinks(pluginDir)
+	if err != nil {
+		return err
+	}
+	resolved, err := filepath.EvalSymlinks(filepath.Join(pluginDir, script))
+	if err != nil {
+		return err
+	}
+	if relative, err := filepath.Rel(root, resolved); err != nil || !filepath.IsLocal(relative) {
+		return fmt.Errorf("it links to %s, outside the plugin directory", resolved)
+	}
+
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return err
+	}
+	switch mode := info.Mode(); {
+	case !mode.IsRegular():
+		return fmt.Errorf("it is not a regular file")
+	case mode&(fs.ModeSetuid|fs.ModeSetgid) != 0:
+		return fmt.Errorf("it is setuid or setgid")
+	case mode.Perm()&0o002 != 0:
+		return fmt.Errorf("any user can change it")
+	}
+	return nil
+}
+
+// Returns the scripts the plugin's "run" names, from the config file, or
+// else its manifest. Empty if neither names any.
+func (p *Plugin) runPatterns() ([]string, error) {
+	if len(p.Run) > 0 {
+		return p.Run, nil
+	}
+	manifest, err := p.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	if manifest != nil {
+		return manifest.Run, nil
+	}
+	return nil, nil
+}
+
+// Returns the scripts to run when loading a plugin, given the paths of
+// all of its files relative to its directory. If run is set, the scripts
+// it names are run, which may be glob patterns. Otherwise scripts named
+// *.tmux are run, or *.tmux.sh if there are none, from the top of the
+// plugin if there are any there, or else from the shallowest directories
+// that have them.
+func findEntrypoints(files []string, run []string) ([]string, error) {
+	files = slices.Sorted(slices.Values(files))
+	if len(run) > 0 {
+		return matchRunPatterns(files, run)
+	}
+
+	for _, suffix := range []string{".tmux", ".tmux.sh"} {
+		if found := shallowestScripts(files, suffix); len(found) > 0 {
+			return found, nil
+		}
+	}
+	return []string{}, nil
+}
+
+// Returns the files named *suffix in the shallowest directory that has
+// any, other than those in skipped directories.
+func shallowestScripts(files []string, suffix string) []string {
+	found := make([]string, 0)
+	depth := -1
+	for _, file := range files {
+		if !strings.HasSuffix(path.Base(file), suffix) || inSkippedDir(file) {
+			continue
+		}
+		fileDepth := strings.Count(file, "/")
+		if depth == -1 || fileDepth < depth {
+			found, depth = found[:0], fileDepth
+		}
+		if fileDepth == depth {
+			found = append(found, file)
+		}
+	}
+	return found
+}
+
+// Returns the files matching each of the patterns in run, in order.
+func matchRunPatterns(files []string, run []string) ([]string, error) {
+	found := make([]string, 0)
+	for _, pattern := range run {
+		if !filepath.IsLocal(pattern) {
+			return nil, fmt.Errorf("script %s to run is outside the plugin directory", pattern)
+		}
+		pattern = path.Clean(pattern)
+		matched := false
+		for _, file := range files {
+			if ok, err := path.Match(pattern, file); err != nil {
+				return nil, fmt.Errorf("invalid script pattern %q to run: %w", pattern, err)
+			} else if ok {
+				matched = true
+				if !slices.Contains(found, file) {
+					found = append(found, file)
+				}
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("no scripts match %s, which is set to run", pattern)
+		}
+	}
+	return found, nil
+}
+
+// Returns true if file is in a hidden directory, or one of skippedScriptDirs.
+func inSkippedDir(file string) bool {
+	dirs := strings.Split(path.Dir(file), "/")
+	for _, dir := range dirs {
+		if dir != "." && (strings.HasPrefix(dir, ".") || slices.Contains(skippedScriptDirs, dir)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"testing"
+)
+
+func TestFindEntrypoints(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string
+		run     []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "top level",
+			files: []string{"b.tmux", "a.tmux", "scripts/helper.sh", "README.md"},
+			want:  []string{"a.tmux", "b.tmux"},
+		},
+		{
+			name:  "tmux.sh",
+			files: []string{"plugin.tmux.sh", "other.sh"},
+			want:  []string{"plugin.tmux.sh"},
+		},
+		{
+			name:  "tmux.sh beside tmux",
+			files: []string{"plugin.tmux", "scripts.tmux.sh", "src/other.tmux.sh"},
+			want:  []string{"plugin.tmux"},
+		},
+		{
+			name:  "tmux.sh deeper than tmux",
+			files: []string{"plugin.tmux.sh", "src/plugin.tmux"},
+			want:  []string{"src/plugin.tmux"},
+		},
+		{
+			name:  "shallowest subdirectory",
+			files: []string{"src/plugin.tmux", "src/deep/other.tmux", "tests/test.tmux", ".github/x.tmux"},
+			want:  []string{"src/plugin.tmux"},
+		},
+		{
+			name:  "top level wins",
+			files: []string{"plugin.tmux", "src/other.tmux"},
+			want:  []string{"plugin.tmux"},
+		},
+		{
+			name:  "none",
+			files: []string{"README.md", "tests/test.tmux"},
+			want:  []string{},
+		},
+		{
+			name:  "run",
+			files: []string{"plugin.tmux", "bin/start.sh", "bin/b.sh", "bin/a.sh"},
+			run:   []string{"bin/start.sh", "bin/*.sh"},
+			want:  []string{"bin/start.sh", "bin/a.sh", "bin/b.sh"},
+		},
+		{
+			name:    "run matching nothing",
+			files:   []string{"plugin.tmux"},
+			run:     []string{"missing.sh"},
+			wantErr: true,
+		},
+		{
+			name:    "run outside the plugin",
+			files:   []string{"plugin.tmux"},
+			run:     []string{"../other/plugin.tmux"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findEntrypoints(tt.files, tt.run)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("findEntrypoints() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !slices.Equal(got, tt.want) {
+				t.Errorf("findEntrypoints() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckEntrypoint(t *testing.T) {
+	pluginDir := t.TempDir()
+	outside := path.Join(t.TempDir(), "evil.sh")
+	for _, file := range []string{"plugin.tmux", "scripts/main.tmux", "setuid.tmux", "writable.tmux", outside} {
+		if !path.IsAbs(file) {
+			file = path.Join(pluginDir, file)
+		}
+		if err := os.MkdirAll(path.Dir(file), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte("#!/bin/sh\n"), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{
+		"linked.tmux":  "scripts/main.tmux",
+		"escape.tmux":  outside,
+		"dir.tmux":     "scripts",
+		"missing.tmux": "nowhere.tmux",
+	} {
+		if err := os.Symlink(target, path.Join(pluginDir, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(path.Join(pluginDir, "setuid.tmux"), 0700|fs.ModeSetuid); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path.Join(pluginDir, "writable.tmux"), 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		script  string
+		wantErr bool
+	}{
+		{"plugin.tmux", false},
+		{"linked.tmux", false},
+		{"escape.tmux", true},
+		{"dir.tmux", true},
+		{"missing.tmux", true},
+		{"setuid.tmux", true},
+		{"writable.tmux", true},
+	}
+	for _, tt := range tests {
+		if err := checkEntrypoint(pluginDir, tt.script); (err != nil) != tt.wantErr {
+			t.Errorf("checkEntrypoint(%s) = %v, wantErr %v", tt.script, err, tt.wantErr)
+		}
+	}
+}
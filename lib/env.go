@@ -0,0 +1,156 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Prefix of env values that are replaced by the output of a command, such
+// as "cmd:pass show tmux/api-key", so secrets need not be in the config.
+const envCommandPrefix = "cmd:"
+
+// The version of tim, given to plugin scripts as TIM_VERSION.
+var TimVersion string
+
+// Returns the environment to load the plugin with, that is tim's own
+// environment, plus the variables from TimEnv, plus the plugin's env
+// from the config file. Commands in env values are run with "sh -c", and
+// their output used as the value.
+func (p *Plugin) Environ(ctx context.Context) ([]string, error) {
+	timEnv, err := p.TimEnv()
+	if err != nil {
+		return nil, err
+	}
+	configEnv, err := p.ConfigEnv(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	env := os.Environ()
+	for _, envVar := range slices.Concat(timEnv, configEnv) {
+		env = append(env, envVar.Name+"="+envVar.Value)
+	}
+	return env, nil
+}
+
+// Returns the variables tim sets for the plugin's scripts. As TPM does,
+// TMUX_PLUGIN_MANAGER_PATH is the directory the plugin is in, with a
+// trailing slash, so that scripts finding themselves with it work.
+func (p *Plugin) TimEnv() ([]EnvVar, error) {
+	pluginDir, err := p.Dir()
+	if err != nil {
+		return nil, err
+	}
+	return []EnvVar{
+		{Name: "TIM_PLUGIN_DIR", Value: pluginDir},
+		{Name: "TIM_VERSION", Value: TimVersion},
+		{Name: "TMUX_PLUGIN_MANAGER_PATH", Value: path.Dir(pluginDir) + "/"},
+	}, nil
+}
+
+// An environment variable plugin scripts are run with.
+type EnvVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	// Set by the plugin's env in the config file, rather than inherited.
+	Config bool `json:"config,omitempty"`
+	// The value is a "cmd:" command that was not run.
+	Unresolved bool `json:"unresolved,omitempty"`
+}
+
+// Returns the variables in env sorted by name. Later values of a variable
+// replace earlier ones, as they do when running a command.
+func ParseEnviron(env []string) []EnvVar {
+	values := make(map[string]string)
+	for _, entry := range env {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok || name == "" {
+			continue
+		}
+		values[name] = value
+	}
+
+	vars := make([]EnvVar, 0, len(values))
+	for _, name := range slices.Sorted(maps.Keys(values)) {
+		vars = append(vars, EnvVar{Name: name, Value: values[name]})
+	}
+	return vars
+}
+
+// Returns the plugin's env from the config file, sorted by name. Commands
+// in values are only run if resolve is true.
+func (p *Plugin) ConfigEnv(ctx context.Context, resolve bool) ([]EnvVar, error) {
+	vars := make([]EnvVar, 0, len(p.Env))
+	for _, name := range slices.Sorted(maps.Keys(p.Env)) {
+		envVar := EnvVar{Name: name, Value: p.Env[name], Config: true}
+		if !resolve {
+			envVar.Unresolved = strings.HasPrefix(envVar.Value, envCommandPrefix)
+		} else {
+			value, err := resolveEnvValue(ctx, envVar.Value)
+			if err != nil {
+				return nil, fmt.Errorf("env %s of plugin %s: %w", name, p.Name, err)
+			}
+			envVar.Value = value
+		}
+		vars = append(vars, envVar)
+	}
+	return vars, nil
+}
+
+// Returns the interpreter named by the #! line of a script, or an empty
+// string if it has none, in which case it fails to run.
+func ScriptInterpreter(script string) (string, error) {
+	file, err := os.Open(script)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	line, err := bufio.NewReader(file).ReadString('\n')
+	if err != nil && line == "" {
+		return "", nil
+	}
+	interpreter, ok := strings.CutPrefix(line, "#!")
+	if !ok {
+		return "", nil
+	}
+	return strings.TrimSpace(interpreter), nil
+}
+
+// Runs the command in value if it has the "cmd:" prefix, returning its
+// output without trailing newlines. Other values are returned unchanged.
+func resolveEnvValue(ctx context.Context, value string) (string, error) {
+	command, ok := strings.CutPrefix(value, envCommandPrefix)
+	if !ok {
+		return value, nil
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stderr = Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("command %q failed: %w", command, err)
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
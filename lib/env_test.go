@@ -0,0 +1,116 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"os"
+	"path"
+	"slices"
+	"testing"
+)
+
+func TestPluginEnviron(t *testing.T) {
+	root := t.TempDir()
+	plugin := Plugin{
+		Name: "user/weather",
+		Root: root,
+		Env: map[string]string{
+			"WEATHER_UNITS":   "metric",
+			"WEATHER_API_KEY": "cmd:printf 'secret\\n'",
+		},
+	}
+
+	env, err := plugin.Environ(context.Background())
+	if err != nil {
+		t.Fatalf("Environ() error = %v", err)
+	}
+	for _, want := range []string{
+		"WEATHER_UNITS=metric",
+		"WEATHER_API_KEY=secret",
+		"TIM_PLUGIN_DIR=" + path.Join(root, "user/weather"),
+		"TMUX_PLUGIN_MANAGER_PATH=" + path.Join(root, "user") + "/",
+	} {
+		if !slices.Contains(env, want) {
+			t.Errorf("Environ() does not contain %q", want)
+		}
+	}
+
+	plugin.Env = map[string]string{"WEATHER_API_KEY": "cmd:exit 1"}
+	if _, err := plugin.Environ(context.Background()); err == nil {
+		t.Errorf("Environ() with a failing command did not return an error")
+	}
+}
+
+func TestParseEnviron(t *testing.T) {
+	got := ParseEnviron([]string{"B=2", "A=1", "B=3", "C=x=y", "junk"})
+	want := []EnvVar{{Name: "A", Value: "1"}, {Name: "B", Value: "3"}, {Name: "C", Value: "x=y"}}
+	if !slices.Equal(got, want) {
+		t.Errorf("ParseEnviron() = %v, want %v", got, want)
+	}
+}
+
+func TestConfigEnv(t *testing.T) {
+	plugin := Plugin{
+		Name: "user/weather",
+		Env:  map[string]string{"KEY": "cmd:echo secret", "UNITS": "metric"},
+	}
+
+	got, err := plugin.ConfigEnv(context.Background(), false)
+	if err != nil {
+		t.Fatalf("ConfigEnv() error = %v", err)
+	}
+	want := []EnvVar{
+		{Name: "KEY", Value: "cmd:echo secret", Config: true, Unresolved: true},
+		{Name: "UNITS", Value: "metric", Config: true},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("ConfigEnv(false) = %v, want %v", got, want)
+	}
+
+	got, err = plugin.ConfigEnv(context.Background(), true)
+	if err != nil {
+		t.Fatalf("ConfigEnv() error = %v", err)
+	}
+	if got[0].Value != "secret" || got[0].Unresolved {
+		t.Errorf("ConfigEnv(true) = %v, want KEY resolved to secret", got)
+	}
+}
+
+func TestScriptInterpreter(t *testing.T) {
+	tests := []struct {
+		contents string
+		want     string
+	}{
+		{"#!/usr/bin/env bash\necho hi\n", "/usr/bin/env bash"},
+		{"#! /bin/sh", "/bin/sh"},
+		{"echo hi\n", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		script := path.Join(t.TempDir(), "plugin.tmux")
+		if err := os.WriteFile(script, []byte(tt.contents), 0700); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ScriptInterpreter(script)
+		if err != nil {
+			t.Fatalf("ScriptInterpreter(%q) error = %v", tt.contents, err)
+		}
+		if got != tt.want {
+			t.Errorf("ScriptInterpreter(%q) = %q, want %q", tt.contents, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// A stable code identifying the cause of an error, shown with errors in
+// JSON output so that wrappers can handle failures without matching
+// messages. Codes are never renamed or reused.
+type ErrorCode string
+
+const (
+	// No more specific code applies.
+	CodeUnknown ErrorCode = "E_UNKNOWN"
+	// A git command failed.
+	CodeGit ErrorCode = "E_GIT"
+	// The remote rejected the credentials, or asked for some.
+	CodeGitAuth ErrorCode = "E_GIT_AUTH"
+	// The remote repository does not exist.
+	CodeRemoteNotFound ErrorCode = "E_REMOTE_NOT_FOUND"
+	// The remote could not be reached.
+	CodeNetwork ErrorCode = "E_NETWORK"
+	// The plugin argument is not a repository name or a git URL.
+	CodeInvalidPlugin ErrorCode = "E_INVALID_PLUGIN"
+	// The plugin is not in the config file.
+	CodePluginNotFound ErrorCode = "E_PLUGIN_NOT_FOUND"
+	// The plugin is in the config file, but not installed.
+	CodePluginNotInstalled ErrorCode = "E_PLUGIN_NOT_INSTALLED"
+	// The remote has no versions that tim understands.
+	CodeNoVersions ErrorCode = "E_NO_VERSIONS"
+	// The requested version does not exist on the remote.
+	CodeUnknownVersion ErrorCode = "E_UNKNOWN_VERSION"
+	// Some of the plugins a command worked on failed, each of which is
+	// reported in a warning with its own code.
+	CodePluginsFailed ErrorCode = "E_PLUGINS_FAILED"
+	// There is no previous version to roll back to.
+	CodeNoHistory ErrorCode = "E_NO_HISTORY"
+	// tmux is not installed.
+	CodeTmuxMissing ErrorCode = "E_TMUX_MISSING"
+	// The config file or tim.lock could not be parsed.
+	CodeLockfileCorrupt ErrorCode = "E_LOCKFILE_CORRUPT"
+	// The config file has unknown keys, and would lose them if saved.
+	CodeDirtyConfig ErrorCode = "E_DIRTY_CONFIG"
+	// The config file was written by a newer version of tim.
+	CodeNewerSchema ErrorCode = "E_NEWER_SCHEMA"
+	// Another tim process is changing plugins.
+	CodeLocked ErrorCode = "E_LOCKED"
+	// The policy file forbids the plugin.
+	CodePolicyViolation ErrorCode = "E_POLICY_VIOLATION"
+	// The operation took longer than its timeout.
+	CodeTimeout ErrorCode = "E_TIMEOUT"
+	// The operation was interrupted.
+	CodeCancelled ErrorCode = "E_CANCELLED"
+	// The installed plugin differs from the commit recorded in tim.lock.
+	CodeVerifyFailed ErrorCode = "E_VERIFY_FAILED"
+	// The version is not signed by a trusted key.
+	CodeBadSignature ErrorCode = "E_BAD_SIGNATURE"
+	// The running tim differs from the binary published in its release.
+	CodeBinaryMismatch ErrorCode = "E_BINARY_MISMATCH"
+	// A plugin script was skipped as unsafe to run, such as a symlink to
+	// a file outside the plugin.
+	CodeUnsafeScript ErrorCode = "E_UNSAFE_SCRIPT"
+	// The plugin has local changes that upgrading would overwrite.
+	CodeLocalChanges ErrorCode = "E_LOCAL_CHANGES"
+	// A tag, branch or commit is not in the repository, or on the remote.
+	CodeRefNotFound ErrorCode = "E_REF_NOT_FOUND"
+)
+
+// Errors that callers can match with errors.Is, whichever git backend
+// failed. Errors with the code of one of these match it, see codeErrors.
+var (
+	ErrGit            = errors.New("git failed")
+	ErrGitAuth        = errors.New("git authentication failed")
+	ErrRemoteNotFound = errors.New("remote repository not found")
+	ErrNetwork        = errors.New("remote could not be reached")
+	ErrRefNotFound    = errors.New("ref not found")
+	// Matched as well as the cause of a failed clone.
+	ErrCloneFailed = errors.New("clone failed")
+)
+
+// The errors matched by errors with each code.
+var codeErrors = map[ErrorCode]error{
+	CodeGit:            ErrGit,
+	CodeGitAuth:        ErrGitAuth,
+	CodeRemoteNotFound: ErrRemoteNotFound,
+	CodeNetwork:        ErrNetwork,
+	CodeRefNotFound:    ErrRefNotFound,
+}
+
+// The codes of errors that are matched with errors.Is, most specific first.
+var errorCodes = []struct {
+	err  error
+	code ErrorCode
+}{
+	{ErrPolicyViolation, CodePolicyViolation},
+	{ErrVerifyFailed, CodeVerifyFailed},
+	{ErrBadSignature, CodeBadSignature},
+	{ErrBinaryMismatch, CodeBinaryMismatch},
+	{ErrLocked, CodeLocked},
+	{ErrNewerSchema, CodeNewerSchema},
+	{ErrDirtyConfig, CodeDirtyConfig},
+	{ErrPluginNotInstalled, CodePluginNotInstalled},
+	{ErrNoVersions, CodeNoVersions},
+	{ErrUnknownVersion, CodeUnknownVersion},
+	{ErrNoHistory, CodeNoHistory},
+	{ErrDirtyWorktree, CodeLocalChanges},
+	{plumbing.ErrReferenceNotFound, CodeRefNotFound},
+	{transport.ErrAuthenticationRequired, CodeGitAuth},
+	{transport.ErrAuthorizationFailed, CodeGitAuth},
+	{transport.ErrInvalidAuthMethod, CodeGitAuth},
+	{transport.ErrRepositoryNotFound, CodeRemoteNotFound},
+	{context.DeadlineExceeded, CodeTimeout},
+	{context.Canceled, CodeCancelled},
+}
+
+// Output of git commands that identifies the cause of their failure.
+var gitErrorOutput = []struct {
+	output string
+	code   ErrorCode
+}{
+	{"authentication failed", CodeGitAuth},
+	{"could not read username", CodeGitAuth},
+	{"permission denied (publickey", CodeGitAuth},
+	{"host key verification failed", CodeGitAuth},
+	{"repository not found", CodeRemoteNotFound},
+	{"does not appear to be a git repository", CodeRemoteNotFound},
+	{"did not match any file(s) known to git", CodeRefNotFound},
+	{"unknown revision", CodeRefNotFound},
+	{"needed a single revision", CodeRefNotFound},
+	{"couldn't find remote ref", CodeRefNotFound},
+	{"could not resolve host", CodeNetwork},
+	{"failed to connect", CodeNetwork},
+	{"connection timed out", CodeNetwork},
+	{"connection refused", CodeNetwork},
+	{"network is unreachable", CodeNetwork},
+	{"temporary failure in name resolution", CodeNetwork},
+	{"connection reset", CodeNetwork},
+	{"the remote end hung up unexpectedly", CodeNetwork},
+	{"early eof", CodeNetwork},
+	{"rpc failed", CodeNetwork},
+	{"returned error: 502", CodeNetwork},
+	{"returned error: 503", CodeNetwork},
+	{"returned error: 504", CodeNetwork},
+}
+
+// An error with a code, see WithErrorCode.
+type codedError struct {
+	code ErrorCode
+	err  error
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codedError) Unwrap() error {
+	return e.err
+}
+
+// Matches the error of the code, such as ErrNetwork for CodeNetwork.
+func (e *codedError) Is(target error) bool {
+	return codeErrors[e.code] == target
+}
+
+// Attaches code to err, without changing its message.
+func WithErrorCode(code ErrorCode, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &codedError{code: code, err: err}
+}
+
+// Returns the code of err. Codes attached with WithErrorCode take
+// precedence over those of the errors they wrap.
+func ErrorCodeOf(err error) ErrorCode {
+	if err == nil {
+		return ""
+	}
+	var coded *codedError
+	if errors.As(err, &coded) {
+		return coded.code
+	}
+	for _, known := range errorCodes {
+		if errors.Is(err, known.err) {
+			return known.code
+		}
+	}
+	return CodeUnknown
+}
+
+// Attaches a code to the error of a failed git command, from its output.
+func gitError(output string, err error) error {
+	if err == nil {
+		return nil
+	}
+	output = strings.ToLower(output)
+	for _, known := range gitErrorOutput {
+		if strings.Contains(output, known.output) {
+			return WithErrorCode(known.code, err)
+		}
+	}
+	return WithErrorCode(CodeGit, err)
+}
+
+// An error that also matches kind with errors.Is, see withKind.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() []error {
+	return []error{e.err, e.kind}
+}
+
+// Makes err match kind with errors.Is, as well as its cause, without
+// changing its message.
+func withKind(kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{kind: kind, err: err}
+}
+
+// An error of a plugin, so that callers working on many plugins can tell
+// which failed. The message is that of Err, which usually names the
+// plugin already.
+type PluginError struct {
+	Plugin string
+	Err    error
+}
+
+func (e *PluginError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PluginError) Unwrap() error {
+	return e.Err
+}
+
+// Matches the error of the code of Err, such as ErrGitAuth for the
+// errors of either git backend.
+func (e *PluginError) Is(target error) bool {
+	return codeErrors[ErrorCodeOf(e.Err)] == target
+}
+
+// Returns err as a PluginError of the plugin.
+func (p *Plugin) wrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PluginError{Plugin: p.Name, Err: err}
+}
+
+// Returns the error for a plugin that is not in the config file.
+func PluginNotFound(name string) error {
+	return WithErrorCode(CodePluginNotFound, fmt.Errorf("plugin %s not found in the config file", name))
+}
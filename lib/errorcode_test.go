@@ -0,0 +1,103 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/kjnsn/tim/lib/gittest"
+)
+
+func TestErrorCodeOf(t *testing.T) {
+	failed := &exec.ExitError{}
+	tests := []struct {
+		err  error
+		want ErrorCode
+	}{
+		{nil, ""},
+		{errors.New("something"), CodeUnknown},
+		{fmt.Errorf("plugin a/b: %w", ErrNoHistory), CodeNoHistory},
+		{fmt.Errorf("clone: %w", transport.ErrAuthenticationRequired), CodeGitAuth},
+		{fmt.Errorf("git fetch: %w", context.DeadlineExceeded), CodeTimeout},
+		{PluginNotFound("a/b"), CodePluginNotFound},
+		{WithErrorCode(CodeLockfileCorrupt, fmt.Errorf("wrapped: %w", ErrDirtyConfig)), CodeLockfileCorrupt},
+		{gitError("fatal: Authentication failed for 'https://github.com/a/b'", failed), CodeGitAuth},
+		{gitError("ERROR: Repository not found.", failed), CodeRemoteNotFound},
+		{gitError("fatal: unable to access: Could not resolve host: github.com", failed), CodeNetwork},
+		{gitError("error: pathspec 'v9' did not match", failed), CodeGit},
+	}
+	for _, test := range tests {
+		if got := ErrorCodeOf(test.err); got != test.want {
+			t.Errorf("ErrorCodeOf(%v) = %q; want %q", test.err, got, test.want)
+		}
+	}
+	if gitError("output", nil) != nil {
+		t.Errorf("gitError(nil) is not nil")
+	}
+}
+
+func TestErrorsMatchCodes(t *testing.T) {
+	failed := &exec.ExitError{}
+	plugin := &Plugin{Name: "a/b"}
+	tests := []struct {
+		err    error
+		target error
+		want   bool
+	}{
+		{gitError("fatal: unable to access: Could not resolve host: github.com", failed), ErrNetwork, true},
+		{gitError("fatal: unable to access: Could not resolve host: github.com", failed), ErrGitAuth, false},
+		{gitError("error: pathspec 'v9' did not match any file(s) known to git", failed), ErrRefNotFound, true},
+		{withKind(ErrCloneFailed, gitError("ERROR: Repository not found.", failed)), ErrCloneFailed, true},
+		{withKind(ErrCloneFailed, gitError("ERROR: Repository not found.", failed)), ErrRemoteNotFound, true},
+		{plugin.wrapError(fmt.Errorf("clone: %w", transport.ErrAuthenticationRequired)), ErrGitAuth, true},
+		{plugin.wrapError(fmt.Errorf("upgrade: %w", ErrDirtyWorktree)), ErrDirtyWorktree, true},
+		{plugin.wrapError(errors.New("something")), ErrGit, false},
+	}
+	for _, test := range tests {
+		if got := errors.Is(test.err, test.target); got != test.want {
+			t.Errorf("errors.Is(%v, %v) = %t; want %t", test.err, test.target, got, test.want)
+		}
+	}
+
+	var pluginErr *PluginError
+	if err := plugin.wrapError(ErrNoVersions); !errors.As(err, &pluginErr) || pluginErr.Plugin != "a/b" {
+		t.Errorf("errors.As(%v, *PluginError) did not find plugin a/b", err)
+	}
+	if err := withKind(ErrCloneFailed, ErrNoVersions); err.Error() != ErrNoVersions.Error() {
+		t.Errorf("withKind() changed the message to %q", err)
+	}
+}
+
+func TestRunGitErrorCode(t *testing.T) {
+	repo := gittest.New(t)
+	repo.Commit("initial", map[string]string{"plugin.tmux": ""})
+	ctx := context.Background()
+
+	_, err := RunGitCommand(ctx, repo.Dir, "checkout", "-q", "v9")
+	if got := ErrorCodeOf(err); got != CodeRefNotFound {
+		t.Errorf("ErrorCodeOf(git checkout v9) = %q; want %q", got, CodeRefNotFound)
+	}
+	_, err = RunGitCommand(ctx, repo.Dir, "ls-remote", path.Join(t.TempDir(), "missing"))
+	if got := ErrorCodeOf(err); got != CodeRemoteNotFound {
+		t.Errorf("ErrorCodeOf(git ls-remote missing) = %q; want %q", got, CodeRemoteNotFound)
+	}
+}
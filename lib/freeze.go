@@ -0,0 +1,142 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+)
+
+// A snapshot of the installed plugins, each pinned to the commit checked
+// out and the full URL it was cloned from, so that "tim import" can
+// reproduce exactly the same plugins on another machine. Unlike a team
+// manifest it is for one person's machines, so environment variables
+// are kept.
+type Snapshot struct {
+	SchemaVersion int `json:"schema_version"`
+
+	// When the snapshot was taken.
+	FrozenAt time.Time `json:"frozen_at"`
+
+	Plugins map[string]SnapshotPlugin `json:"plugins"`
+}
+
+// A plugin pinned by a snapshot.
+type SnapshotPlugin struct {
+	// The version spec of the plugin in the config file.
+	Ref string `json:"ref"`
+
+	// The full hash of the commit checked out.
+	Commit string `json:"commit"`
+
+	// URL the plugin was cloned from.
+	Remote string `json:"remote"`
+
+	Env     map[string]string `json:"env,omitempty"`
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// Returns a snapshot of every plugin in the config file at the commit
+// checked out. Plugins must be installed, and plugins loaded from a
+// local directory are left out, as they only exist on this machine.
+func (lf *Lockfile) Freeze(ctx context.Context) (*Snapshot, error) {
+	snapshot := &Snapshot{
+		SchemaVersion: CurrentSchemaVersion,
+		FrozenAt:      time.Now().UTC().Truncate(time.Second),
+		Plugins:       make(map[string]SnapshotPlugin),
+	}
+	for _, plugin := range lf.Plugins() {
+		if plugin.IsLocal() {
+			continue
+		}
+		if err := plugin.CheckInstalled(); err != nil {
+			return nil, fmt.Errorf("plugin %s: %w, run \"tim add\" to install it first", plugin.Name, err)
+		}
+		commit, err := plugin.Commit(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		spec := lf.PluginSpecs[plugin.Name]
+		snapshot.Plugins[plugin.Name] = SnapshotPlugin{
+			Ref:     spec.Version,
+			Commit:  commit,
+			Remote:  plugin.RemoteURL(),
+			Env:     spec.Env,
+			Options: spec.Options,
+		}
+	}
+	return snapshot, nil
+}
+
+// Reads the snapshot written by "tim freeze" at snapshotPath.
+func ReadSnapshot(snapshotPath string) (*Snapshot, error) {
+	contents, err := os.ReadFile(snapshotPath)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &Snapshot{}
+	if err := json.Unmarshal(contents, snapshot); err != nil {
+		return nil, fmt.Errorf("invalid snapshot %s: %w", snapshotPath, err)
+	}
+	if snapshot.SchemaVersion > CurrentSchemaVersion {
+		return nil, fmt.Errorf("snapshot %s has schema version %d, but this version of tim only understands up to %d",
+			snapshotPath, snapshot.SchemaVersion, CurrentSchemaVersion)
+	}
+	for name, plugin := range snapshot.Plugins {
+		if plugin.Commit == "" || plugin.Remote == "" {
+			return nil, fmt.Errorf("plugin %s in snapshot %s has no commit or remote", name, snapshotPath)
+		}
+	}
+	return snapshot, nil
+}
+
+// Returns the plugins in the config file that are not in the snapshot,
+// other than those loaded from a local directory, sorted by name.
+func (s *Snapshot) Extra(lf *Lockfile) []string {
+	extra := make([]string, 0)
+	for name, spec := range lf.PluginSpecs {
+		if _, ok := s.Plugins[name]; !ok && spec.Path == "" {
+			extra = append(extra, name)
+		}
+	}
+	slices.Sort(extra)
+	return extra
+}
+
+// Returns the plugins in the snapshot, pinned to their commits.
+func (s *Snapshot) Pins() map[string]Pin {
+	pins := make(map[string]Pin, len(s.Plugins))
+	for name, plugin := range s.Plugins {
+		remote := plugin.Remote
+		if remote == (&Plugin{Name: name}).RemoteURL() {
+			remote = ""
+		}
+		pins[name] = Pin{
+			Ref:     plugin.Ref,
+			Commit:  plugin.Commit,
+			Remote:  remote,
+			Options: plugin.Options,
+			Env:     plugin.Env,
+		}
+	}
+	return pins
+}
@@ -0,0 +1,63 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSnapshotApply(t *testing.T) {
+	snapshot := &Snapshot{
+		Plugins: map[string]SnapshotPlugin{
+			"a/github": {Ref: "v1.1.0", Commit: "aaa", Remote: "https://github.com/a/github.git"},
+			"b/other":  {Ref: "main", Commit: "bbb", Remote: "https://example.com/b.git", Env: map[string]string{"B": "1"}},
+		},
+	}
+	lockFile := &Lockfile{
+		PluginSpecs: map[string]PluginSpec{
+			"a/github": {Version: "v1.0.0", Disabled: true, Env: map[string]string{"A": "1"}},
+			"c/extra":  {Version: "v1.0.0"},
+			"d/local":  {Version: LocalVersionSpec, Path: "/src/d"},
+		},
+		Locked: map[string]LockedPlugin{
+			"a/github": {Ref: "v1.0.0", Commit: "old"},
+		},
+	}
+
+	if got := snapshot.Extra(lockFile); !reflect.DeepEqual(got, []string{"c/extra"}) {
+		t.Errorf("Extra() = %v; want [c/extra]", got)
+	}
+
+	for name, pin := range snapshot.Pins() {
+		lockFile.ApplyPin(name, pin)
+	}
+	// Settings the snapshot doesn't have are kept, but the environment
+	// comes from the snapshot.
+	wantSpecs := map[string]PluginSpec{
+		"a/github": {Version: "v1.1.0", Disabled: true},
+		"b/other":  {Version: "main", Remote: "https://example.com/b.git", Env: map[string]string{"B": "1"}},
+	}
+	for name, want := range wantSpecs {
+		if got := lockFile.PluginSpecs[name]; !reflect.DeepEqual(got, want) {
+			t.Errorf("PluginSpecs[%s] = %+v; want %+v", name, got, want)
+		}
+		locked := lockFile.Locked[name]
+		if locked.Commit != snapshot.Plugins[name].Commit || locked.Remote != want.Remote {
+			t.Errorf("Locked[%s] = %+v; want commit %s", name, locked, snapshot.Plugins[name].Commit)
+		}
+	}
+}
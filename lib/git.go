@@ -16,42 +16,614 @@ limitations under the License.
 package lib
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/kjnsn/tim/lib/message"
+)
+
+// The default value of GitTimeout.
+const DefaultGitTimeout = 5 * time.Minute
+
+// The longest a single git command may run before it is killed,
+// zero for no limit. Set from the git_timeout key in the config file.
+var GitTimeout = DefaultGitTimeout
+
+// The longest cloning and fetching a plugin may take, set from the
+// timeouts key in the config file. GitTimeout is used if zero.
+var (
+	CloneTimeout time.Duration
+	FetchTimeout time.Duration
+)
+
+// Returns the timeout of a phase, falling back to GitTimeout.
+func phaseTimeout(timeout time.Duration) time.Duration {
+	if timeout > 0 {
+		return timeout
+	}
+	return GitTimeout
+}
+
+// Arguments to fetch only the latest commit of each ref, leaving file
+// contents to be fetched when they are checked out.
+var shallowFetchArgs = []string{"--depth", "1", "--filter=blob:none"}
+
+// Names of the git backends, set with the git_backend key in the config file.
+const (
+	// Runs the git binary, the default.
+	GitBackendExec = "exec"
+
+	// Uses go-git, for systems without a git binary.
+	GitBackendGoGit = "go-git"
 )
 
-// Returns the default branch of the given repo at basedir (what does the upstream default to).
-func DefaultBranch(basedir string) (string, error) {
-	return GetRef(basedir, "--abbrev-ref", "origin/HEAD")
+// Performs the git operations tim needs on plugin repositories.
+type GitClient interface {
+	// Shallow clones remote into dir, resuming an interrupted clone.
+	Clone(ctx context.Context, dir, remote string) error
+
+	// Fetches branches and tags from origin, keeping shallow
+	// repositories shallow.
+	Fetch(ctx context.Context, dir string) error
+
+	// Fetches the full history of a shallow repository.
+	Deepen(ctx context.Context, dir string) error
+
+	// Returns true if the repository is missing some history.
+	IsShallow(ctx context.Context, dir string) bool
+
+	// Returns the full hash of the commit that ref names.
+	ResolveCommit(ctx context.Context, dir, ref string) (string, error)
+
+	// Returns the remote default branch recorded by origin/HEAD, such
+	// as "origin/main".
+	DefaultBranch(ctx context.Context, dir string) (string, error)
+
+	// Asks origin for its default branch, such as "main".
+	RemoteHead(ctx context.Context, dir string) (string, error)
+
+	// Records branch as the default branch of origin, in origin/HEAD.
+	SetRemoteHead(ctx context.Context, dir, branch string) error
+
+	// Returns the full hash of the upstream of the checked out branch.
+	Upstream(ctx context.Context, dir string) (string, error)
+
+	// Returns the names of all tags.
+	Tags(ctx context.Context, dir string) ([]string, error)
+
+	// Returns the commits reachable from to but not from, newest first,
+	// each as its abbreviated hash and subject, such as "1a2b3c4 Fix".
+	Log(ctx context.Context, dir, from, to string) ([]string, error)
+
+	// Returns when each tag was made, by name: when an annotated tag was
+	// created, or when the commit of a lightweight tag was.
+	TagDates(ctx context.Context, dir string) (map[string]time.Time, error)
+
+	// Returns the names of the tags and branches of remote, without
+	// cloning it.
+	RemoteRefs(ctx context.Context, remote string) ([]string, error)
+
+	// Checks out ref, discarding local changes if force is true.
+	Checkout(ctx context.Context, dir, ref string, force bool) error
+
+	// Points the local branch at ref.
+	SetBranch(ctx context.Context, dir, branch, ref string) error
+
+	// Fast forwards the checked out branch to its upstream.
+	Pull(ctx context.Context, dir string) error
+
+	// Returns true if the working tree has uncommitted changes or
+	// untracked files.
+	IsDirty(ctx context.Context, dir string) (bool, error)
+
+	// Returns the state of the working tree and the checked out branch.
+	Status(ctx context.Context, dir string) (WorktreeStatus, error)
+
+	// Stashes uncommitted changes and untracked files, described by
+	// message.
+	Stash(ctx context.Context, dir, message string) error
+
+	// Checks the signature of ref, a tag if tag is true or else a commit.
+	// Returns the verifier's output, which names the keys that made the
+	// signature, and an error if ref is not validly signed.
+	VerifySignature(ctx context.Context, dir, ref string, tag bool) (string, error)
+
+	// Checks out only subdir, in this and later checkouts.
+	SparseCheckout(ctx context.Context, dir, subdir string) error
+
+	// Returns the names of the files under dir in the commit ref,
+	// relative to dir.
+	Files(ctx context.Context, dir, ref string) ([]string, error)
+
+	// Returns the contents of the file name, relative to dir, in the
+	// commit ref.
+	ReadFile(ctx context.Context, dir, ref, name string) ([]byte, error)
+
+	// Returns the changes between the commits from and to, as a patch,
+	// to the files under dir matching one of patterns. Patterns are
+	// relative to dir: a directory, or a glob matched as git pathspecs
+	// are, so "*.sh" matches scripts in subdirectories too.
+	Diff(ctx context.Context, dir, from, to string, patterns []string) (string, error)
 }
 
-// Runs `get rev-parse` with the given flag and pathspec.
-func GetRef(basedir, flag, pathspec string) (string, error) {
-	return RunGitCommand(basedir, "rev-parse", flag, pathspec)
+// The client used for all git operations. Selected with SetGitBackend.
+var Git GitClient = execGitClient{}
+
+// Selects the git backend by name, one of GitBackendExec or GitBackendGoGit.
+func SetGitBackend(name string) error {
+	switch name {
+	case GitBackendExec, "":
+		Git = execGitClient{}
+	case GitBackendGoGit:
+		Git = goGitClient{}
+	default:
+		return fmt.Errorf("unknown git backend %q, expected %q or %q", name, GitBackendExec, GitBackendGoGit)
+	}
+	return nil
+}
+
+// Common names of default branches, tried in order when neither origin
+// nor origin/HEAD gives one that exists.
+var defaultBranchNames = []string{"main", "master", "trunk", "develop"}
+
+// Returns the default branch of the repo at basedir, such as "main". The
+// branch origin says is its default is used, then the one origin/HEAD
+// names, as origin may be unreachable, then the first branch with a common
+// name. Only branches fetched from origin are used, as origin/HEAD can be
+// unset or stale. The branch chosen is recorded as origin/HEAD.
+func DefaultBranch(ctx context.Context, basedir string) (string, error) {
+	exists := func(branch string) bool {
+		_, err := Git.ResolveCommit(ctx, basedir, "origin/"+branch)
+		return branch != "" && err == nil
+	}
+
+	branch, err := Git.RemoteHead(ctx, basedir)
+	if err != nil {
+		message.Debug("Unable to ask origin of %s for its default branch: %s", basedir, err)
+	}
+	if !exists(branch) {
+		upstream, err := Git.DefaultBranch(ctx, basedir)
+		if err != nil {
+			message.Debug("%s has no origin/HEAD: %s", basedir, err)
+		}
+		branch = strings.TrimPrefix(upstream, "origin/")
+	}
+	if !exists(branch) {
+		i := slices.IndexFunc(defaultBranchNames, exists)
+		if i < 0 {
+			return "", fmt.Errorf("unable to find the default branch of %s", basedir)
+		}
+		branch = defaultBranchNames[i]
+		message.Debug("Guessed the default branch of %s is %s", basedir, branch)
+	}
+
+	if err := Git.SetRemoteHead(ctx, basedir, branch); err != nil {
+		return "", err
+	}
+	return branch, nil
 }
 
 // Checks out and updates `branch` from the remote.
-func UpdateBranch(baseDir, branch string) error {
-	_, err := RunGitCommand(baseDir, "checkout", "-f", branch)
+func UpdateBranch(ctx context.Context, baseDir, branch string) error {
+	if err := Git.Checkout(ctx, baseDir, branch, true); err != nil {
+		return err
+	}
+	if err := Git.Pull(ctx, baseDir); err != nil {
+		return err
+	}
+	return pullLFS(ctx, baseDir)
+}
+
+// Shallow clones remote into baseDir. An interrupted transfer can be
+// resumed by calling Clone again. Errors match ErrCloneFailed.
+func Clone(ctx context.Context, baseDir, remote string) error {
+	ctx, err := startPhase(ctx, PhaseClone)
+	if err != nil {
+		return err
+	}
+	return withKind(ErrCloneFailed, Git.Clone(ctx, baseDir, remote))
+}
+
+// Returns true if baseDir is the root of a git repository.
+func IsRepository(baseDir string) bool {
+	_, err := os.Stat(path.Join(baseDir, ".git"))
+	return err == nil
+}
+
+// Returns true if the repository at baseDir has a commit checked out,
+// that is, it is not an empty or partially cloned repository.
+func HasCheckout(ctx context.Context, baseDir string) bool {
+	if !IsRepository(baseDir) {
+		return false
+	}
+	_, err := Git.ResolveCommit(ctx, baseDir, "HEAD")
+	return err == nil
+}
+
+// Fetches branches and tags from origin. Shallow repositories only
+// fetch the latest commit of each, so they stay shallow.
+func FetchTags(ctx context.Context, baseDir string) error {
+	ctx, err := startPhase(ctx, PhaseFetch)
 	if err != nil {
 		return err
 	}
+	return Git.Fetch(ctx, baseDir)
+}
 
-	_, err = RunGitCommand(baseDir, "pull", "--ff-only", "-q")
+// Returns true if the repository at baseDir is missing some history.
+func IsShallow(ctx context.Context, baseDir string) bool {
+	return Git.IsShallow(ctx, baseDir)
+}
+
+// Returns true if ref names a commit in the repository at baseDir.
+func HasCommit(ctx context.Context, baseDir, ref string) bool {
+	_, err := Git.ResolveCommit(ctx, baseDir, ref)
+	return err == nil
+}
+
+// Fetches the full history of a shallow repository. Does nothing if
+// the repository is not shallow.
+func Deepen(ctx context.Context, baseDir string) error {
+	if !Git.IsShallow(ctx, baseDir) {
+		return nil
+	}
+	message.Debug("Fetching the full history of %s", baseDir)
+	return Git.Deepen(ctx, baseDir)
+}
+
+// Checks out ref, first fetching the full history if ref is not in a
+// shallow repository, such as an old commit. Files stored with git LFS
+// are fetched afterwards.
+func Checkout(ctx context.Context, baseDir, ref string, force bool) error {
+	ctx, err := startPhase(ctx, PhaseCheckout)
+	if err != nil {
+		return err
+	}
+	if !HasCommit(ctx, baseDir, ref) {
+		if err := Deepen(ctx, baseDir); err != nil {
+			return err
+		}
+	}
+	if err := Git.Checkout(ctx, baseDir, ref, force); err != nil {
+		return err
+	}
+	return pullLFS(ctx, baseDir)
+}
+
+// Returns true if the working tree at baseDir has uncommitted changes
+// or untracked files.
+func IsDirty(ctx context.Context, baseDir string) (bool, error) {
+	return Git.IsDirty(ctx, baseDir)
+}
+
+// Runs the git operation fn with ctx limited to timeout, unless it is
+// zero. Errors caused by the timeout, or by ctx ending, are explained.
+func withTimeout(ctx context.Context, timeout time.Duration, operation string, fn func(ctx context.Context) error) error {
+	limited, cancel := ctx, context.CancelFunc(func() {})
+	if timeout > 0 {
+		limited, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+
+	err := fn(limited)
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("git %s: %w", operation, ctx.Err())
+	}
+	if errors.Is(limited.Err(), context.DeadlineExceeded) {
+		return WithErrorCode(CodeTimeout, fmt.Errorf("git %s timed out after %s", operation, timeout))
+	}
 	return err
 }
 
-// Runs the given git command.
-func RunGitCommand(basedir string, args ...string) (string, error) {
-	var out strings.Builder
-	cmd := exec.Command("git", args...)
-	cmd.Dir = basedir
-	cmd.Stdout = &out
-	cmd.Stderr = os.Stderr
+// Runs the given git command. The command is killed if ctx is cancelled,
+// or if it runs for longer than GitTimeout.
+func RunGitCommand(ctx context.Context, basedir string, args ...string) (string, error) {
+	return runGit(ctx, GitTimeout, basedir, nil, args...)
+}
 
-	if err := cmd.Run(); err != nil {
+// Runs the given git command, which contacts remote, with the network
+// settings applied, killing it after timeout. An empty remote is the
+// origin remote of the repository at basedir.
+func runRemoteGit(ctx context.Context, timeout time.Duration, basedir, remote string, args ...string) (string, error) {
+	// The URL is only needed to pick the credentials.
+	if remote == "" && len(Network.Credentials) > 0 {
+		remote, _ = RunGitCommand(ctx, basedir, "remote", "get-url", "origin")
+	}
+	networkEnv, err := Network.gitEnv(ctx, remote)
+	if err != nil {
+		return "", err
+	}
+	return runGit(ctx, timeout, basedir, networkEnv, args...)
+}
+
+// Runs the given git command with env added to the environment, killing
+// it after timeout.
+func runGit(ctx context.Context, timeout time.Duration, basedir string, env []string, args ...string) (string, error) {
+	var out, errOut strings.Builder
+	err := withTimeout(ctx, timeout, args[0], func(ctx context.Context) error {
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Dir = basedir
+		if len(env) > 0 {
+			cmd.Env = append(os.Environ(), env...)
+		}
+		cmd.Stdout = &out
+		// Kept to find the cause of failures, see gitError.
+		cmd.Stderr = io.MultiWriter(gitProgressOutput(ctx), &errOut)
+		start := time.Now()
+		err := cmd.Run()
+		logGitCommand(basedir, args, start, err, errOut.String())
+		// Progress is drawn rather than written to stderr, so the reason
+		// git gave would otherwise be lost.
+		if err != nil && progressOf(ctx) != nil {
+			if tail := stderrTail(errOut.String()); len(tail) > 0 {
+				err = fmt.Errorf("%s (%w)", tail[len(tail)-1], err)
+			}
+		}
+		return gitError(errOut.String(), err)
+	})
+	if err != nil {
 		return "", err
 	}
 	return strings.TrimSpace(out.String()), nil
 }
+
+// A GitClient running the git binary.
+type execGitClient struct{}
+
+// Rather than using `git clone`, which deletes everything on failure, the
+// repository is initialised and then fetched.
+func (execGitClient) Clone(ctx context.Context, baseDir, remote string) error {
+	if !IsRepository(baseDir) {
+		if _, err := RunGitCommand(ctx, baseDir, "init", "-q"); err != nil {
+			return err
+		}
+		if _, err := RunGitCommand(ctx, baseDir, "remote", "add", "origin", remote); err != nil {
+			return err
+		}
+	} else {
+		message.Info("Resuming partial clone of %s", remote)
+	}
+
+	// A retried fetch resumes from the objects already fetched.
+	args := append([]string{"fetch", "--progress", "--tags"}, shallowFetchArgs...)
+	err := withRetries(ctx, "clone", func() error {
+		_, err := runRemoteGit(ctx, phaseTimeout(CloneTimeout), baseDir, remote, append(args, "origin")...)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	branch, err := DefaultBranch(ctx, baseDir)
+	if err != nil {
+		return err
+	}
+	_, err = RunGitCommand(ctx, baseDir, "checkout", "-q", "-B", branch, "--track", "origin/"+branch)
+	return err
+}
+
+func (c execGitClient) Fetch(ctx context.Context, baseDir string) error {
+	args := []string{"fetch", "-q", "--tags"}
+	if progressOf(ctx) != nil {
+		args[1] = "--progress"
+	}
+	if c.IsShallow(ctx, baseDir) {
+		args = append(args, shallowFetchArgs...)
+	}
+	return withRetries(ctx, "fetch", func() error {
+		_, err := runRemoteGit(ctx, phaseTimeout(FetchTimeout), baseDir, "", append(args, "origin")...)
+		return err
+	})
+}
+
+func (execGitClient) Deepen(ctx context.Context, baseDir string) error {
+	return withRetries(ctx, "fetch", func() error {
+		_, err := runRemoteGit(ctx, phaseTimeout(FetchTimeout), baseDir, "", "fetch", "-q", "--unshallow", "--tags", "origin")
+		return err
+	})
+}
+
+func (execGitClient) IsShallow(ctx context.Context, baseDir string) bool {
+	shallow, err := RunGitCommand(ctx, baseDir, "rev-parse", "--is-shallow-repository")
+	return err == nil && shallow == "true"
+}
+
+func (execGitClient) ResolveCommit(ctx context.Context, baseDir, ref string) (string, error) {
+	return RunGitCommand(ctx, baseDir, "rev-parse", "-q", "--verify", ref+"^{commit}")
+}
+
+func (execGitClient) DefaultBranch(ctx context.Context, baseDir string) (string, error) {
+	return RunGitCommand(ctx, baseDir, "rev-parse", "--abbrev-ref", "origin/HEAD")
+}
+
+func (execGitClient) RemoteHead(ctx context.Context, baseDir string) (string, error) {
+	out, err := runRemoteGit(ctx, phaseTimeout(FetchTimeout), baseDir, "", "ls-remote", "--symref", "origin", "HEAD", "refs/heads/*")
+	if err != nil {
+		return "", err
+	}
+	return parseRemoteHead(out)
+}
+
+// Returns the default branch in the output of "git ls-remote --symref",
+// from the HEAD symref, or failing that the branch at the same commit as
+// HEAD.
+func parseRemoteHead(out string) (string, error) {
+	head := ""
+	branches := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		target, name, _ := strings.Cut(line, "\t")
+		if symref, ok := strings.CutPrefix(target, "ref: "); ok {
+			if name == "HEAD" {
+				return strings.TrimPrefix(symref, "refs/heads/"), nil
+			}
+			continue
+		}
+		if name == "HEAD" {
+			head = target
+		} else if branch, ok := strings.CutPrefix(name, "refs/heads/"); ok {
+			branches[target] = branch
+		}
+	}
+	if branch, ok := branches[head]; ok && head != "" {
+		return branch, nil
+	}
+	return "", errors.New("remote has no HEAD")
+}
+
+func (execGitClient) SetRemoteHead(ctx context.Context, baseDir, branch string) error {
+	_, err := RunGitCommand(ctx, baseDir, "remote", "set-head", "origin", branch)
+	return err
+}
+
+func (execGitClient) Upstream(ctx context.Context, baseDir string) (string, error) {
+	return RunGitCommand(ctx, baseDir, "rev-parse", "--verify", "@{u}")
+}
+
+func (execGitClient) Tags(ctx context.Context, baseDir string) ([]string, error) {
+	tags, err := RunGitCommand(ctx, baseDir, "tag", "--list")
+	if err != nil || tags == "" {
+		return nil, err
+	}
+	return strings.Split(tags, "\n"), nil
+}
+
+func (execGitClient) Log(ctx context.Context, baseDir, from, to string) ([]string, error) {
+	out, err := RunGitCommand(ctx, baseDir, "log", "--format=%h %s", from+".."+to)
+	if err != nil || out == "" {
+		return nil, err
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+func (execGitClient) TagDates(ctx context.Context, baseDir string) (map[string]time.Time, error) {
+	out, err := RunGitCommand(ctx, baseDir, "for-each-ref", "--format=%(refname:short) %(creatordate:unix)", "refs/tags")
+	if err != nil {
+		return nil, err
+	}
+	dates := make(map[string]time.Time)
+	for _, line := range strings.Split(out, "\n") {
+		tag, unix, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		seconds, err := strconv.ParseInt(unix, 10, 64)
+		if err != nil {
+			continue
+		}
+		dates[tag] = time.Unix(seconds, 0)
+	}
+	return dates, nil
+}
+
+func (execGitClient) RemoteRefs(ctx context.Context, remote string) ([]string, error) {
+	var out string
+	err := withRetries(ctx, "ls-remote", func() error {
+		var err error
+		out, err = runRemoteGit(ctx, GitTimeout, "", remote, "ls-remote", "--tags", "--heads", "--refs", remote)
+		return err
+	})
+	if err != nil || out == "" {
+		return nil, err
+	}
+	refs := make([]string, 0)
+	for _, line := range strings.Split(out, "\n") {
+		_, ref, _ := strings.Cut(line, "\t")
+		ref = strings.TrimPrefix(ref, "refs/tags/")
+		refs = append(refs, strings.TrimPrefix(ref, "refs/heads/"))
+	}
+	return refs, nil
+}
+
+func (execGitClient) Checkout(ctx context.Context, baseDir, ref string, force bool) error {
+	args := []string{"checkout", "-q"}
+	if force {
+		args = append(args, "-f")
+	}
+	_, err := RunGitCommand(ctx, baseDir, append(args, ref)...)
+	return err
+}
+
+func (execGitClient) SetBranch(ctx context.Context, baseDir, branch, ref string) error {
+	_, err := RunGitCommand(ctx, baseDir, "branch", "-f", branch, ref)
+	return err
+}
+
+func (execGitClient) Pull(ctx context.Context, baseDir string) error {
+	_, err := runRemoteGit(ctx, phaseTimeout(FetchTimeout), baseDir, "", "pull", "--ff-only", "-q")
+	return err
+}
+
+func (execGitClient) IsDirty(ctx context.Context, baseDir string) (bool, error) {
+	status, err := RunGitCommand(ctx, baseDir, "status", "--porcelain")
+	if err != nil {
+		return false, err
+	}
+	return status != "", nil
+}
+
+func (execGitClient) Stash(ctx context.Context, baseDir, message string) error {
+	_, err := RunGitCommand(ctx, baseDir, "stash", "push", "-q", "--include-untracked", "-m", message)
+	return err
+}
+
+func (execGitClient) VerifySignature(ctx context.Context, baseDir, ref string, tag bool) (string, error) {
+	verify := "verify-commit"
+	if tag {
+		verify = "verify-tag"
+	}
+	var output strings.Builder
+	err := withTimeout(ctx, GitTimeout, verify, func(ctx context.Context) error {
+		cmd := exec.CommandContext(ctx, "git", verify, "--raw", ref)
+		cmd.Dir = baseDir
+		// git writes the result of verifying to stderr.
+		cmd.Stdout, cmd.Stderr = &output, &output
+		start := time.Now()
+		err := cmd.Run()
+		logGitCommand(baseDir, cmd.Args[1:], start, err, output.String())
+		return err
+	})
+	return output.String(), err
+}
+
+func (execGitClient) SparseCheckout(ctx context.Context, baseDir, subdir string) error {
+	_, err := RunGitCommand(ctx, baseDir, "sparse-checkout", "set", "--", subdir)
+	return err
+}
+
+func (execGitClient) Files(ctx context.Context, baseDir, ref string) ([]string, error) {
+	out, err := RunGitCommand(ctx, baseDir, "ls-tree", "-r", "-z", "--name-only", ref)
+	if err != nil || out == "" {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(out, "\x00"), "\x00"), nil
+}
+
+func (execGitClient) ReadFile(ctx context.Context, baseDir, ref, name string) ([]byte, error) {
+	// "./" makes name relative to baseDir rather than the repository.
+	contents, err := RunGitCommand(ctx, baseDir, "show", ref+":./"+name)
+	return []byte(contents), err
+}
+
+func (execGitClient) Diff(ctx context.Context, baseDir, from, to string, patterns []string) (string, error) {
+	return RunGitCommand(ctx, baseDir, append([]string{"diff", from, to, "--"}, patterns...)...)
+}
+
+func (execGitClient) Status(ctx context.Context, baseDir string) (WorktreeStatus, error) {
+	out, err := RunGitCommand(ctx, baseDir, "status", "--porcelain=v2", "--branch", "-z")
+	if err != nil {
+		return WorktreeStatus{}, err
+	}
+	return parseStatus(out)
+}
@@ -0,0 +1,175 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"path"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kjnsn/tim/lib/gittest"
+)
+
+func TestParseRemoteHead(t *testing.T) {
+	tests := []struct {
+		out  string
+		want string
+	}{
+		{"ref: refs/heads/trunk\tHEAD\naaa\tHEAD\naaa\trefs/heads/trunk", "trunk"},
+		// Servers not advertising the HEAD symref.
+		{"bbb\tHEAD\naaa\trefs/heads/main\nbbb\trefs/heads/stable", "stable"},
+		{"aaa\trefs/heads/main", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		got, err := parseRemoteHead(test.out)
+		if got != test.want || (err != nil) != (test.want == "") {
+			t.Errorf("parseRemoteHead(%q) = %q, %v; want %q", test.out, got, err, test.want)
+		}
+	}
+}
+
+func TestDefaultBranch(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	defer func() { Git = execGitClient{} }()
+
+	unborn := []string{"symbolic-ref", "HEAD", "refs/heads/deleted"}
+	// Each changes the remote before and after it is cloned. It starts
+	// with "develop" and "master" branches, and HEAD at "develop".
+	tests := []struct {
+		name          string
+		before, after []string
+		want          string
+	}{
+		{"HEAD", nil, nil, "develop"},
+		{"unborn HEAD", unborn, nil, "master"},
+		{"moved HEAD", nil, []string{"symbolic-ref", "HEAD", "refs/heads/master"}, "master"},
+		{"HEAD unborn since cloning", nil, unborn, "develop"},
+	}
+	for _, backend := range []GitClient{execGitClient{}, goGitClient{}} {
+		Git = backend
+		for _, test := range tests {
+			remote := gittest.New(t)
+			remote.Branch("develop")
+			remote.Commit("first", nil)
+			remote.Git("branch", "master")
+			if test.before != nil {
+				remote.Git(test.before...)
+			}
+			clone := t.TempDir()
+			if err := Clone(context.Background(), clone, remote.Dir); err != nil {
+				t.Fatalf("%T: Clone() = %v", backend, err)
+			}
+			if test.after != nil {
+				remote.Git(test.after...)
+			}
+
+			branch, err := DefaultBranch(context.Background(), clone)
+			if branch != test.want || err != nil {
+				t.Errorf("%T: %s: DefaultBranch() = %q, %v; want %q", backend, test.name, branch, err, test.want)
+			}
+			if upstream, err := backend.DefaultBranch(context.Background(), clone); upstream != "origin/"+test.want {
+				t.Errorf("%T: %s: origin/HEAD is %q, %v; want origin/%s", backend, test.name, upstream, err, test.want)
+			}
+		}
+	}
+}
+
+func TestFilesAndDiff(t *testing.T) {
+	defer func() { Git = execGitClient{} }()
+
+	repo := gittest.New(t)
+	repo.Commit("first", map[string]string{
+		"other.sh":         "echo other\n",
+		"sub/plugin.tmux":  "#!/bin/sh\n",
+		"sub/README.md":    "first\n",
+		"sub/scripts/a.sh": "echo first\n",
+	})
+	repo.Tag("v1.0.0")
+	repo.Commit("second", map[string]string{
+		"other.sh":         "echo changed\n",
+		"sub/README.md":    "second\n",
+		"sub/scripts/a.sh": "echo second\n",
+	})
+	dir := path.Join(repo.Dir, "sub")
+
+	for _, backend := range []GitClient{execGitClient{}, goGitClient{}} {
+		Git = backend
+		files, err := Git.Files(context.Background(), dir, "v1.0.0")
+		slices.Sort(files)
+		if want := []string{"README.md", "plugin.tmux", "scripts/a.sh"}; !slices.Equal(files, want) || err != nil {
+			t.Errorf("%T: Files() = %v, %v; want %v", backend, files, err, want)
+		}
+
+		contents, err := Git.ReadFile(context.Background(), dir, "v1.0.0", "scripts/a.sh")
+		if got := strings.TrimSpace(string(contents)); got != "echo first" || err != nil {
+			t.Errorf("%T: ReadFile() = %q, %v; want %q", backend, got, err, "echo first")
+		}
+
+		diff, err := Git.Diff(context.Background(), dir, "v1.0.0", "HEAD", []string{"*.sh"})
+		if err != nil {
+			t.Fatalf("%T: Diff() = %v", backend, err)
+		}
+		if !strings.Contains(diff, "+echo second") || strings.Contains(diff, "README") || strings.Contains(diff, "other.sh") {
+			t.Errorf("%T: Diff() = %q; want only the changes to sub/scripts/a.sh", backend, diff)
+		}
+	}
+}
+
+func TestTagDates(t *testing.T) {
+	defer func() { Git = execGitClient{} }()
+
+	repo := gittest.New(t)
+	committed := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tagged := committed.Add(48 * time.Hour)
+	t.Setenv("GIT_COMMITTER_DATE", committed.Format(time.RFC3339))
+	repo.Commit("first", nil)
+	repo.Git("tag", "lightweight")
+	t.Setenv("GIT_COMMITTER_DATE", tagged.Format(time.RFC3339))
+	repo.Tag("v1.0.0")
+
+	for _, backend := range []GitClient{execGitClient{}, goGitClient{}} {
+		Git = backend
+		dates, err := Git.TagDates(context.Background(), repo.Dir)
+		if err != nil {
+			t.Fatalf("%T: TagDates() = %v", backend, err)
+		}
+		if !dates["lightweight"].Equal(committed) || !dates["v1.0.0"].Equal(tagged) || len(dates) != 2 {
+			t.Errorf("%T: TagDates() = %v; want lightweight at %s and v1.0.0 at %s", backend, dates, committed, tagged)
+		}
+	}
+}
+
+func TestLog(t *testing.T) {
+	defer func() { Git = execGitClient{} }()
+
+	repo := gittest.New(t)
+	from := repo.Commit("first", nil)
+	second := repo.Commit("second\nwrapped\n\nbody", nil)
+	third := repo.Commit("third", nil)
+
+	want := []string{third[:7] + " third", second[:7] + " second wrapped"}
+	for _, backend := range []GitClient{execGitClient{}, goGitClient{}} {
+		Git = backend
+		got, err := Git.Log(context.Background(), repo.Dir, from, third)
+		if !slices.Equal(got, want) || err != nil {
+			t.Errorf("%T: Log() = %q, %v; want %q", backend, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kjnsn/tim/lib/message"
+)
+
+// Checks github hosted plugins for new versions with the github API,
+// rather than fetching with git. Set with "github_api" in the config file.
+var GithubAPIChecks = false
+
+// The github REST API, a variable so tests can replace it.
+var githubAPIURL = "https://api.github.com"
+
+// The state file github API responses are cached in.
+const githubAPICacheFile = "github-api.json"
+
+// The most tags requested at once. Repositories with more fall back to
+// git, rather than requesting every page.
+const githubTagsPerPage = 100
+
+// Cached github API responses, by URL, so that requests can be made
+// conditional on the response having changed. Conditional requests
+// answered from the cache do not count against the rate limit.
+type githubAPICache struct {
+	Responses map[string]cachedResponse `json:"responses"`
+}
+
+type cachedResponse struct {
+	ETag string `json:"etag"`
+	Body string `json:"body"`
+}
+
+// Guards the cache state file, as plugins are checked concurrently.
+var githubAPICacheLock sync.Mutex
+
+// Checks the plugin's remote for a new version. Github hosted plugins
+// are checked with the github API when GithubAPIChecks is set, falling
+// back to git if that fails, for example when rate limited or offline.
+// The result is recorded in the check cache, see GetCheckCache.
+func (p *Plugin) CheckForUpgrade(ctx context.Context) CheckResult {
+	result := p.checkForUpgrade(ctx)
+	if err := recordCheck(p.Name, p.Version, result); err != nil {
+		message.Debug("Unable to record the check of %s: %s", p.Name, err)
+	}
+	return result
+}
+
+func (p *Plugin) checkForUpgrade(ctx context.Context) CheckResult {
+	ctx = withProgressPlugin(ctx, p.Name)
+	defer finishProgress(ctx)
+	ctx, err := startPhase(ctx, PhaseCheck)
+	if err != nil {
+		return checkFailed(ErrorClassNetwork, err)
+	}
+
+	if GithubAPIChecks {
+		if repo, ok := p.githubRepo(); ok {
+			result, err := checkWithGithubAPI(ctx, repo, p.Version)
+			if err == nil {
+				return result
+			}
+			message.Debug("Unable to check %s with the github API, using git instead: %s", p.Name, err)
+		}
+	}
+
+	pluginDir, err := p.RepoDir()
+	if err != nil {
+		return checkFailed(ErrorClassGit, err)
+	}
+	return p.Version.Check(ctx, pluginDir)
+}
+
+// Returns the <owner>/<repo> of a plugin hosted on github.
+func (p *Plugin) githubRepo() (string, bool) {
+	host, repoPath, err := splitRemote(p.RemoteURL())
+	if err != nil || host != githubHost {
+		return "", false
+	}
+	return strings.TrimSuffix(strings.Trim(repoPath, "/"), ".git"), true
+}
+
+func checkWithGithubAPI(ctx context.Context, repo string, version Version) (CheckResult, error) {
+	switch version := version.(type) {
+	case *SemanticVersion:
+		tags, err := githubTags(ctx, repo)
+		if err != nil {
+			return CheckResult{}, err
+		}
+		return version.compareTags(tags), nil
+	case *GitVersion:
+		body, err := githubAPIGet(ctx, "/repos/"+repo+"/commits/"+url.PathEscape(version.branch),
+			"application/vnd.github.sha")
+		if err != nil {
+			return CheckResult{}, err
+		}
+		return version.compareHash(strings.TrimSpace(string(body))), nil
+	}
+	return CheckResult{}, fmt.Errorf("unsupported version %s", version)
+}
+
+// Returns the names of the repository's tags.
+func githubTags(ctx context.Context, repo string) ([]string, error) {
+	body, err := githubAPIGet(ctx, fmt.Sprintf("/repos/%s/tags?per_page=%d", repo, githubTagsPerPage),
+		"application/vnd.github+json")
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(body, &tags); err != nil {
+		return nil, err
+	}
+	if len(tags) == githubTagsPerPage {
+		return nil, fmt.Errorf("%s has more than %d tags", repo, githubTagsPerPage)
+	}
+
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	return names, nil
+}
+
+// Requests path from the github API, authenticated with GITHUB_TOKEN or
+// GH_TOKEN if either is set. The response is cached, and only fetched
+// again if it has changed.
+func githubAPIGet(ctx context.Context, path, accept string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	requestURL := githubAPIURL + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	if token := githubToken(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	cached, ok, err := cachedGithubResponse(requestURL)
+	if err != nil {
+		return nil, err
+	}
+	if ok && cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		if ok {
+			return []byte(cached.Body), nil
+		}
+		return nil, fmt.Errorf("github API: %s without a cached response", resp.Status)
+	case http.StatusOK:
+	default:
+		return nil, fmt.Errorf("github API: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		if err := cacheGithubResponse(requestURL, cachedResponse{ETag: etag, Body: string(body)}); err != nil {
+			message.Debug("Unable to cache the github API response: %s", err)
+		}
+	}
+	return body, nil
+}
+
+// Returns the cached response to requestURL, if there is one.
+func cachedGithubResponse(requestURL string) (cachedResponse, bool, error) {
+	githubAPICacheLock.Lock()
+	defer githubAPICacheLock.Unlock()
+	cache := &githubAPICache{}
+	if err := readStateFile(githubAPICacheFile, cache); err != nil {
+		return cachedResponse{}, false, err
+	}
+	cached, ok := cache.Responses[requestURL]
+	return cached, ok, nil
+}
+
+// Records the response to requestURL in the cache, keeping the responses
+// cached by plugins checked at the same time.
+func cacheGithubResponse(requestURL string, response cachedResponse) error {
+	githubAPICacheLock.Lock()
+	defer githubAPICacheLock.Unlock()
+	cache := &githubAPICache{}
+	if err := readStateFile(githubAPICacheFile, cache); err != nil {
+		return err
+	}
+	if cache.Responses == nil {
+		cache.Responses = make(map[string]cachedResponse)
+	}
+	cache.Responses[requestURL] = response
+	return writeStateFile(githubAPICacheFile, cache)
+}
+
+// Returns the token github API requests are authenticated with, if any.
+func githubToken() string {
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		return token
+	}
+	return os.Getenv("GH_TOKEN")
+}
@@ -0,0 +1,93 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Points github API requests at url for the rest of the test.
+func setGithubAPIURL(t *testing.T, url string) {
+	t.Helper()
+	saved := githubAPIURL
+	githubAPIURL = url
+	t.Cleanup(func() { githubAPIURL = saved })
+}
+
+func TestCheckForUpgradeWithGithubAPI(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("GITHUB_TOKEN", "secret")
+	defer func() { GithubAPIChecks = false }()
+	GithubAPIChecks = true
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		switch r.URL.Path {
+		case "/repos/user/plugin/tags":
+			w.Header().Set("ETag", `"v1"`)
+			w.Write([]byte(`[{"name": "v1.2.0"}, {"name": "v1.1.0"}, {"name": "nightly"}]`))
+		case "/repos/user/plugin/commits/main":
+			w.Write([]byte("abc123"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	setGithubAPIURL(t, server.URL)
+
+	plugin := Plugin{Name: "user/plugin", Version: &SemanticVersion{currentVersion: "v1.1.0"}}
+	for range 2 {
+		result := plugin.CheckForUpgrade(context.Background())
+		if result.Outcome != OutcomeUpgradeAvailable || result.Upgrade.String() != "v1.2.0" {
+			t.Errorf("CheckForUpgrade() = %+v; want an upgrade to v1.2.0", result)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("requested %d times; want 2", requests)
+	}
+
+	plugin.Version = &GitVersion{branch: "main", currentHash: "abc123"}
+	if result := plugin.CheckForUpgrade(context.Background()); result.Outcome != OutcomeUpToDate {
+		t.Errorf("CheckForUpgrade() = %+v; want up to date", result)
+	}
+
+	tests := []struct {
+		plugin Plugin
+		repo   string
+		ok     bool
+	}{
+		{Plugin{Name: "user/plugin"}, "user/plugin", true},
+		{Plugin{Name: "plugin", Remote: "git@github.com:user/plugin.git"}, "user/plugin", true},
+		{Plugin{Name: "plugin", Remote: "https://gitlab.com/user/plugin.git"}, "", false},
+	}
+	for _, test := range tests {
+		repo, ok := test.plugin.githubRepo()
+		if repo != test.repo || ok != test.ok {
+			t.Errorf("githubRepo(%+v) = %q, %v; want %q, %v", test.plugin, repo, ok, test.repo, test.ok)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package gittest creates throwaway git repositories for tests, to stand
+// in for the remotes of plugins.
+package gittest
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The identity commits and tags are made with, also set in the
+// environment of tests that call New, as git commands run by tim may
+// need one too, such as stashing.
+var identity = map[string]string{
+	"GIT_AUTHOR_NAME":     "tim",
+	"GIT_AUTHOR_EMAIL":    "tim@example.com",
+	"GIT_COMMITTER_NAME":  "tim",
+	"GIT_COMMITTER_EMAIL": "tim@example.com",
+}
+
+// A repository in a temporary directory, deleted when the test ends.
+type Repo struct {
+	t testing.TB
+
+	// The directory of the repository, which is also its clone URL.
+	Dir string
+
+	// Incremented by each commit, so their contents differ.
+	commits int
+}
+
+// Creates an empty repository on the branch "main". Skips the test if git
+// is not installed.
+func New(t testing.TB) *Repo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	for name, value := range identity {
+		t.Setenv(name, value)
+	}
+
+	r := &Repo{t: t, Dir: t.TempDir()}
+	r.Git("init", "-q", "--initial-branch=main")
+	return r
+}
+
+// Runs git in the repository and returns its output, failing the test
+// if it fails.
+func (r *Repo) Git(args ...string) string {
+	r.t.Helper()
+	args = append([]string{"-C", r.Dir, "-c", "commit.gpgSign=false", "-c", "tag.gpgSign=false"}, args...)
+	out, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		r.t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// Writes files, relative paths mapped to their contents, and commits
+// them with every other change. Returns the hash of the commit.
+func (r *Repo) Commit(message string, files map[string]string) string {
+	r.t.Helper()
+	for name, contents := range files {
+		file := filepath.Join(r.Dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0750); err != nil {
+			r.t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(contents), 0750); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+	r.Git("add", "--all")
+	r.Git("commit", "-q", "--allow-empty", "-m", message)
+	r.commits++
+	return r.Head()
+}
+
+// Moves the current branch forward by a commit, as upstream does between
+// upgrades. The commit changes plugin.tmux. Returns its hash.
+func (r *Repo) Advance() string {
+	r.t.Helper()
+	return r.Commit(fmt.Sprintf("commit %d", r.commits+1), map[string]string{
+		"plugin.tmux": fmt.Sprintf("#!/bin/sh\n# commit %d\n", r.commits+1),
+	})
+}
+
+// Advances the current branch and tags the commit with each of tags, as
+// a release. Returns the hash of the commit.
+func (r *Repo) Release(tags ...string) string {
+	r.t.Helper()
+	commit := r.Advance()
+	for _, tag := range tags {
+		r.Tag(tag)
+	}
+	return commit
+}
+
+// Tags HEAD with an annotated tag.
+func (r *Repo) Tag(name string) {
+	r.t.Helper()
+	r.Git("tag", "-a", "-m", name, name)
+}
+
+// Creates the branch at HEAD, and checks it out.
+func (r *Repo) Branch(name string) {
+	r.t.Helper()
+	r.Git("checkout", "-q", "-b", name)
+}
+
+// Checks out ref, such as a branch.
+func (r *Repo) Checkout(ref string) {
+	r.t.Helper()
+	r.Git("checkout", "-q", ref)
+}
+
+// Clones the repository to dir, which must not exist or be empty, as a
+// plugin is installed. The clone is deleted when the test ends if dir is
+// a temporary directory.
+func (r *Repo) Clone(dir string) *Repo {
+	r.t.Helper()
+	r.Git("clone", "-q", r.Dir, dir)
+	return &Repo{t: r.t, Dir: dir}
+}
+
+// Returns the hash of HEAD.
+func (r *Repo) Head() string {
+	r.t.Helper()
+	return r.Git("rev-parse", "HEAD")
+}
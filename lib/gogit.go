@@ -0,0 +1,771 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/kjnsn/tim/lib/message"
+)
+
+// A GitClient using go-git, which does not need a git binary.
+type goGitClient struct{}
+
+// Opens the repository at dir and its working tree.
+func openGoGit(dir string) (*git.Repository, *git.Worktree, error) {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return nil, nil, err
+	}
+	return repo, worktree, nil
+}
+
+// Opens the repository containing dir, which may be a subdirectory of
+// its working tree. Returns the path of dir within the working tree,
+// ending in a slash, or "" if dir is the top level.
+func openGoGitAt(dir string) (*git.Repository, string, error) {
+	repo, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, "", err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return nil, "", err
+	}
+	prefix, err := filepath.Rel(worktree.Filesystem.Root(), dir)
+	if err != nil || prefix == "." {
+		return repo, "", err
+	}
+	return repo, filepath.ToSlash(prefix) + "/", nil
+}
+
+// Returns the tree of the commit that ref names.
+func goGitTree(repo *git.Repository, ref string) (*object.Tree, error) {
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ref, err)
+	}
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, err
+	}
+	return commit.Tree()
+}
+
+// Returns true if name matches one of patterns, as git pathspecs do: a
+// pattern naming a directory matches the files under it, and a leading
+// "*" matches across directories, unlike in path.Match.
+func matchesPathspec(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if name == pattern || strings.HasPrefix(name, pattern+"/") {
+			return true
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+		if strings.HasPrefix(pattern, "*") && !strings.Contains(pattern, "/") {
+			if ok, _ := path.Match(pattern, path.Base(name)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Runs the go-git operation fn on the repository at dir, logging it to
+// tim.log as the git command args, as the exec backend does.
+func logGoGit(dir string, args []string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	logGitCommand(dir, args, start, err, "")
+	return err
+}
+
+// Fetches branches and tags from origin into the repository at dir,
+// limiting history to depth commits if depth is not zero.
+func goGitFetch(ctx context.Context, timeout time.Duration, dir string, repo *git.Repository, depth int, progress bool) error {
+	remote, err := repo.Remote("origin")
+	if err != nil {
+		return err
+	}
+	auth, err := Network.goGitAuth(ctx, remote.Config().URLs[0])
+	if err != nil {
+		return err
+	}
+	options := &git.FetchOptions{
+		RemoteName:   "origin",
+		Depth:        depth,
+		Tags:         git.AllTags,
+		Force:        true,
+		Auth:         auth,
+		ProxyOptions: Network.goGitProxy(),
+	}
+	if progress || progressOf(ctx) != nil {
+		options.Progress = gitProgressOutput(ctx)
+	}
+	args := []string{"fetch", "--tags", "origin"}
+	if depth != 0 {
+		args = []string{"fetch", "--depth", strconv.Itoa(depth), "--tags", "origin"}
+	}
+
+	return withRetries(ctx, "fetch", func() error {
+		return withTimeout(ctx, timeout, "fetch", func(ctx context.Context) error {
+			return logGoGit(dir, args, func() error {
+				err := repo.FetchContext(ctx, options)
+				if errors.Is(err, git.NoErrAlreadyUpToDate) {
+					return nil
+				}
+				return err
+			})
+		})
+	})
+}
+
+// Returns the options listing the refs of remote, with its network settings.
+func goGitListOptions(ctx context.Context, remote string) (*git.ListOptions, error) {
+	auth, err := Network.goGitAuth(ctx, remote)
+	if err != nil {
+		return nil, err
+	}
+	return &git.ListOptions{Auth: auth, ProxyOptions: Network.goGitProxy()}, nil
+}
+
+// Returns the name of the default branch of origin of the repository at
+// dir, such as "main".
+func goGitRemoteHead(ctx context.Context, dir string, repo *git.Repository) (string, error) {
+	remote, err := repo.Remote("origin")
+	if err != nil {
+		return "", err
+	}
+	options, err := goGitListOptions(ctx, remote.Config().URLs[0])
+	if err != nil {
+		return "", err
+	}
+	var refs []*plumbing.Reference
+	err = withTimeout(ctx, GitTimeout, "ls-remote", func(ctx context.Context) error {
+		return logGoGit(dir, []string{"ls-remote", "--symref", "origin"}, func() error {
+			refs, err = remote.ListContext(ctx, options)
+			return err
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var head *plumbing.Reference
+	for _, ref := range refs {
+		if ref.Name() == plumbing.HEAD {
+			head = ref
+		}
+	}
+	if head == nil {
+		return "", errors.New("remote has no HEAD")
+	}
+	if head.Type() == plumbing.SymbolicReference {
+		return head.Target().Short(), nil
+	}
+
+	// Servers not advertising the HEAD symref only send its hash.
+	for _, ref := range refs {
+		if ref.Name().IsBranch() && ref.Hash() == head.Hash() {
+			return ref.Name().Short(), nil
+		}
+	}
+	return "", errors.New("unable to find the default branch of the remote")
+}
+
+func (goGitClient) Clone(ctx context.Context, dir, remote string) error {
+	var repo *git.Repository
+	var err error
+	if !IsRepository(dir) {
+		repo, err = git.PlainInit(dir, false)
+		if err != nil {
+			return err
+		}
+		_, err = repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{remote}})
+		if err != nil {
+			return err
+		}
+	} else {
+		message.Info("Resuming partial clone of %s", remote)
+		repo, err = git.PlainOpen(dir)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := goGitFetch(ctx, phaseTimeout(CloneTimeout), dir, repo, 1, true); err != nil {
+		return err
+	}
+
+	branch, err := DefaultBranch(ctx, dir)
+	if err != nil {
+		return err
+	}
+	upstreamRef, err := repo.Reference(plumbing.NewRemoteReferenceName("origin", branch), true)
+	if err != nil {
+		return err
+	}
+
+	// Equivalent to `git checkout -B branch --track origin/branch`.
+	branchRef := plumbing.NewBranchReferenceName(branch)
+	if err := repo.Storer.SetReference(plumbing.NewHashReference(branchRef, upstreamRef.Hash())); err != nil {
+		return err
+	}
+	err = repo.CreateBranch(&config.Branch{Name: branch, Remote: "origin", Merge: branchRef})
+	if err != nil && !errors.Is(err, git.ErrBranchExists) {
+		return err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	return logGoGit(dir, []string{"checkout", "-B", branch, "--track", "origin/" + branch}, func() error {
+		return worktree.Checkout(&git.CheckoutOptions{Branch: branchRef, Force: true})
+	})
+}
+
+func (c goGitClient) Fetch(ctx context.Context, dir string) error {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return err
+	}
+	depth := 0
+	if c.IsShallow(ctx, dir) {
+		depth = 1
+	}
+	return goGitFetch(ctx, phaseTimeout(FetchTimeout), dir, repo, depth, false)
+}
+
+func (goGitClient) Deepen(ctx context.Context, dir string) error {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return err
+	}
+	// The same depth `git fetch --unshallow` asks for.
+	return goGitFetch(ctx, phaseTimeout(FetchTimeout), dir, repo, math.MaxInt32, false)
+}
+
+func (goGitClient) IsShallow(ctx context.Context, dir string) bool {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return false
+	}
+	shallow, err := repo.Storer.Shallow()
+	return err == nil && len(shallow) > 0
+}
+
+func (goGitClient) ResolveCommit(ctx context.Context, dir, ref string) (string, error) {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return "", err
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", ref, err)
+	}
+	return hash.String(), nil
+}
+
+func (goGitClient) DefaultBranch(ctx context.Context, dir string) (string, error) {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return "", err
+	}
+	head, err := repo.Reference(plumbing.NewRemoteHEADReferenceName("origin"), false)
+	if err != nil {
+		return "", err
+	}
+	return head.Target().Short(), nil
+}
+
+func (goGitClient) RemoteHead(ctx context.Context, dir string) (string, error) {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return "", err
+	}
+	return goGitRemoteHead(ctx, dir, repo)
+}
+
+func (goGitClient) SetRemoteHead(ctx context.Context, dir, branch string) error {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return err
+	}
+	upstream := plumbing.NewRemoteReferenceName("origin", branch)
+	return repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.NewRemoteHEADReferenceName("origin"), upstream))
+}
+
+func (goGitClient) Upstream(ctx context.Context, dir string) (string, error) {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return "", err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", err
+	}
+	if !head.Name().IsBranch() {
+		return "", errors.New("HEAD is not on a branch")
+	}
+	branch, err := repo.Branch(head.Name().Short())
+	if err != nil {
+		return "", fmt.Errorf("branch %s has no upstream: %w", head.Name().Short(), err)
+	}
+	upstream, err := repo.Reference(plumbing.NewRemoteReferenceName(branch.Remote, branch.Merge.Short()), true)
+	if err != nil {
+		return "", err
+	}
+	return upstream.Hash().String(), nil
+}
+
+func (goGitClient) Tags(ctx context.Context, dir string) ([]string, error) {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return nil, err
+	}
+	iter, err := repo.Tags()
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]string, 0)
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		tags = append(tags, ref.Name().Short())
+		return nil
+	})
+	return tags, err
+}
+
+func (goGitClient) Log(ctx context.Context, dir, from, to string) ([]string, error) {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return nil, err
+	}
+	commits := make([]string, 0)
+	err = logGoGit(dir, []string{"log", "--format=%h %s", from + ".." + to}, func() error {
+		fromHash, err := repo.ResolveRevision(plumbing.Revision(from))
+		if err != nil {
+			return fmt.Errorf("%s: %w", from, err)
+		}
+		toHash, err := repo.ResolveRevision(plumbing.Revision(to))
+		if err != nil {
+			return fmt.Errorf("%s: %w", to, err)
+		}
+		excluded, err := goGitAncestors(repo, *fromHash)
+		if err != nil {
+			return err
+		}
+		log, err := repo.Log(&git.LogOptions{From: *toHash})
+		if err != nil {
+			return err
+		}
+		err = log.ForEach(func(commit *object.Commit) error {
+			if !excluded[commit.Hash] {
+				// As git's "%s", the first paragraph on one line.
+				subject, _, _ := strings.Cut(strings.TrimSpace(commit.Message), "\n\n")
+				commits = append(commits, commit.Hash.String()[:7]+" "+strings.ReplaceAll(subject, "\n", " "))
+			}
+			return nil
+		})
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			// The history of a shallow repository ends early.
+			return nil
+		}
+		return err
+	})
+	return commits, err
+}
+
+func (goGitClient) TagDates(ctx context.Context, dir string) (map[string]time.Time, error) {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return nil, err
+	}
+	iter, err := repo.Tags()
+	if err != nil {
+		return nil, err
+	}
+	dates := make(map[string]time.Time)
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if tag, err := repo.TagObject(ref.Hash()); err == nil {
+			dates[ref.Name().Short()] = tag.Tagger.When
+		} else if commit, err := repo.CommitObject(ref.Hash()); err == nil {
+			dates[ref.Name().Short()] = commit.Committer.When
+		}
+		return nil
+	})
+	return dates, err
+}
+
+func (goGitClient) RemoteRefs(ctx context.Context, remote string) ([]string, error) {
+	list := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: "origin", URLs: []string{remote}})
+	options, err := goGitListOptions(ctx, remote)
+	if err != nil {
+		return nil, err
+	}
+	var refs []*plumbing.Reference
+	err = withRetries(ctx, "ls-remote", func() error {
+		return withTimeout(ctx, GitTimeout, "ls-remote", func(ctx context.Context) error {
+			return logGoGit("", []string{"ls-remote", "--tags", "--heads", remote}, func() error {
+				var err error
+				refs, err = list.ListContext(ctx, options)
+				return err
+			})
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		if ref.Name().IsTag() || ref.Name().IsBranch() {
+			names = append(names, ref.Name().Short())
+		}
+	}
+	return names, nil
+}
+
+func (goGitClient) Checkout(ctx context.Context, dir, ref string, force bool) error {
+	repo, worktree, err := openGoGit(dir)
+	if err != nil {
+		return err
+	}
+
+	options := &git.CheckoutOptions{Force: force}
+	args := []string{"checkout", ref}
+	if force {
+		args = []string{"checkout", "-f", ref}
+	}
+	branch := plumbing.NewBranchReferenceName(ref)
+	if _, err := repo.Reference(branch, false); err == nil {
+		options.Branch = branch
+	} else {
+		hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+		if err != nil {
+			return fmt.Errorf("%s: %w", ref, err)
+		}
+		options.Hash = *hash
+	}
+	return logGoGit(dir, args, func() error { return worktree.Checkout(options) })
+}
+
+func (goGitClient) SetBranch(ctx context.Context, dir, branch, ref string) error {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return err
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return fmt.Errorf("%s: %w", ref, err)
+	}
+	return repo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName(branch), *hash))
+}
+
+func (goGitClient) Pull(ctx context.Context, dir string) error {
+	_, worktree, err := openGoGit(dir)
+	if err != nil {
+		return err
+	}
+
+	return withTimeout(ctx, phaseTimeout(FetchTimeout), "pull", func(ctx context.Context) error {
+		return logGoGit(dir, []string{"pull", "--ff-only"}, func() error {
+			err := worktree.PullContext(ctx, &git.PullOptions{RemoteName: "origin"})
+			if errors.Is(err, git.NoErrAlreadyUpToDate) {
+				return nil
+			}
+			return err
+		})
+	})
+}
+
+func (goGitClient) IsDirty(ctx context.Context, dir string) (bool, error) {
+	_, worktree, err := openGoGit(dir)
+	if err != nil {
+		return false, err
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return false, err
+	}
+	return !status.IsClean(), nil
+}
+
+func (c goGitClient) Status(ctx context.Context, dir string) (WorktreeStatus, error) {
+	repo, worktree, err := openGoGit(dir)
+	if err != nil {
+		return WorktreeStatus{}, err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return WorktreeStatus{}, err
+	}
+	status := WorktreeStatus{Commit: head.Hash().String()}
+
+	files, err := worktree.Status()
+	if err != nil {
+		return WorktreeStatus{}, err
+	}
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		switch file := files[name]; {
+		case file.Worktree == git.Untracked:
+			status.Untracked = append(status.Untracked, name)
+		case file.Worktree != git.Unmodified || file.Staging != git.Unmodified:
+			status.Modified = append(status.Modified, name)
+		}
+	}
+
+	if !head.Name().IsBranch() {
+		return status, nil
+	}
+	status.Branch = head.Name().Short()
+	upstream, err := c.Upstream(ctx, dir)
+	if err != nil {
+		// Without an upstream, nothing is ahead or behind.
+		return status, nil
+	}
+	local, err := goGitAncestors(repo, head.Hash())
+	if err != nil {
+		return WorktreeStatus{}, err
+	}
+	remote, err := goGitAncestors(repo, plumbing.NewHash(upstream))
+	if err != nil {
+		return WorktreeStatus{}, err
+	}
+	for hash := range local {
+		if !remote[hash] {
+			status.Ahead++
+		}
+	}
+	for hash := range remote {
+		if !local[hash] {
+			status.Behind++
+		}
+	}
+	return status, nil
+}
+
+// Returns the commits reachable from commit, including itself, as far
+// back as the history of a shallow repository goes.
+func goGitAncestors(repo *git.Repository, commit plumbing.Hash) (map[plumbing.Hash]bool, error) {
+	log, err := repo.Log(&git.LogOptions{From: commit})
+	if err != nil {
+		return nil, err
+	}
+	ancestors := make(map[plumbing.Hash]bool)
+	err = log.ForEach(func(commit *object.Commit) error {
+		ancestors[commit.Hash] = true
+		return nil
+	})
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		err = nil
+	}
+	return ancestors, err
+}
+
+func (goGitClient) Files(ctx context.Context, dir, ref string) ([]string, error) {
+	repo, prefix, err := openGoGitAt(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0)
+	err = logGoGit(dir, []string{"ls-tree", "-r", "--name-only", ref}, func() error {
+		tree, err := goGitTree(repo, ref)
+		if err != nil {
+			return err
+		}
+		return tree.Files().ForEach(func(file *object.File) error {
+			if name, ok := strings.CutPrefix(file.Name, prefix); ok {
+				files = append(files, name)
+			}
+			return nil
+		})
+	})
+	return files, err
+}
+
+func (goGitClient) ReadFile(ctx context.Context, dir, ref, name string) ([]byte, error) {
+	repo, prefix, err := openGoGitAt(dir)
+	if err != nil {
+		return nil, err
+	}
+	var contents string
+	err = logGoGit(dir, []string{"show", ref + ":./" + name}, func() error {
+		tree, err := goGitTree(repo, ref)
+		if err != nil {
+			return err
+		}
+		file, err := tree.File(prefix + name)
+		if err != nil {
+			return fmt.Errorf("%s:%s: %w", ref, name, err)
+		}
+		contents, err = file.Contents()
+		return err
+	})
+	return []byte(contents), err
+}
+
+func (goGitClient) Diff(ctx context.Context, dir, from, to string, patterns []string) (string, error) {
+	repo, prefix, err := openGoGitAt(dir)
+	if err != nil {
+		return "", err
+	}
+	var patch *object.Patch
+	err = logGoGit(dir, append([]string{"diff", from, to, "--"}, patterns...), func() error {
+		fromTree, err := goGitTree(repo, from)
+		if err != nil {
+			return err
+		}
+		toTree, err := goGitTree(repo, to)
+		if err != nil {
+			return err
+		}
+		changes, err := object.DiffTreeContext(ctx, fromTree, toTree)
+		if err != nil {
+			return err
+		}
+		matching := make(object.Changes, 0, len(changes))
+		for _, change := range changes {
+			name := change.To.Name
+			if name == "" {
+				name = change.From.Name
+			}
+			if name, ok := strings.CutPrefix(name, prefix); ok && matchesPathspec(patterns, name) {
+				matching = append(matching, change)
+			}
+		}
+		patch, err = matching.PatchContext(ctx)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(patch.String()), nil
+}
+
+// Verifies GPG signatures with gpg, as git does, so keys git trusts are
+// trusted here too. SSH signatures are not supported.
+func (goGitClient) VerifySignature(ctx context.Context, dir, ref string, tag bool) (string, error) {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return "", err
+	}
+	var signature string
+	payload := &plumbing.MemoryObject{}
+	if tag {
+		tagRef, err := repo.Tag(ref)
+		if err != nil {
+			return "", err
+		}
+		tagObject, err := repo.TagObject(tagRef.Hash())
+		if err != nil {
+			return "", fmt.Errorf("%s is not an annotated tag: %w", ref, err)
+		}
+		signature = tagObject.PGPSignature
+		if err := tagObject.EncodeWithoutSignature(payload); err != nil {
+			return "", err
+		}
+	} else {
+		hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", ref, err)
+		}
+		commit, err := repo.CommitObject(*hash)
+		if err != nil {
+			return "", err
+		}
+		signature = commit.PGPSignature
+		if err := commit.EncodeWithoutSignature(payload); err != nil {
+			return "", err
+		}
+	}
+	if signature == "" {
+		return "", errors.New("no signature found")
+	}
+	if strings.HasPrefix(signature, "-----BEGIN SSH SIGNATURE-----") {
+		return "", fmt.Errorf("SSH signatures are not supported by the %s git backend, set \"git_backend\" to %q",
+			GitBackendGoGit, GitBackendExec)
+	}
+
+	signatureFile, err := os.CreateTemp("", "tim-signature-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(signatureFile.Name())
+	_, err = signatureFile.WriteString(signature)
+	if closeErr := signatureFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	reader, err := payload.Reader()
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	var output strings.Builder
+	verify := []string{"verify-commit", "--raw", ref}
+	if tag {
+		verify[0] = "verify-tag"
+	}
+	err = withTimeout(ctx, GitTimeout, verify[0], func(ctx context.Context) error {
+		return logGoGit(dir, verify, func() error {
+			cmd := exec.CommandContext(ctx, "gpg", "--status-fd=1", "--keyid-format=long", "--verify", signatureFile.Name(), "-")
+			cmd.Stdin = reader
+			cmd.Stdout, cmd.Stderr = &output, &output
+			if err := cmd.Run(); err != nil {
+				return err
+			}
+			if !strings.Contains(output.String(), "[GNUPG:] GOODSIG ") {
+				return errors.New("bad signature")
+			}
+			return nil
+		})
+	})
+	return output.String(), err
+}
+
+func (goGitClient) SparseCheckout(ctx context.Context, dir, subdir string) error {
+	return fmt.Errorf("sparse checkout is not supported by the %s git backend", GitBackendGoGit)
+}
+
+func (goGitClient) Stash(ctx context.Context, dir, message string) error {
+	return fmt.Errorf("stashing changes is not supported by the %s git backend, set \"git_backend\" to %q",
+		GitBackendGoGit, GitBackendExec)
+}
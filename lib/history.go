@@ -0,0 +1,182 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The state file previous versions of plugins are kept in.
+const historyFile = "history.json"
+
+// The number of previous versions kept for each plugin by default.
+const DefaultHistoryDepth = 5
+
+var ErrNoHistory = errors.New("no previous version to roll back to")
+
+// A version of a plugin that was replaced by an upgrade.
+type Revision struct {
+	// The git ref the plugin was resolved to, a tag or a branch.
+	Ref string `json:"ref"`
+
+	// The full hash of the commit that was checked out.
+	Commit string `json:"commit"`
+
+	// URL the plugin was cloned from, empty for the default github remote.
+	Remote string `json:"remote,omitempty"`
+
+	// When the version was replaced. Versions replaced by the same
+	// command share the same time.
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+// Previous versions of each plugin, newest first, persisted in the
+// state directory.
+type History struct {
+	Plugins map[string][]Revision `json:"plugins"`
+}
+
+// Reads the history of previous versions from the state directory.
+func GetHistory() (*History, error) {
+	history := &History{}
+	if err := readStateFile(historyFile, history); err != nil {
+		return nil, err
+	}
+	if history.Plugins == nil {
+		history.Plugins = make(map[string][]Revision)
+	}
+	return history, nil
+}
+
+// Writes the history to the state directory.
+func (h *History) Save() error {
+	return writeStateFile(historyFile, h)
+}
+
+// Returns the names of plugins replaced by the most recent command that
+// replaced any, such as the last "tim upgrade".
+func (h *History) LastReplaced() []string {
+	var latest time.Time
+	for _, revisions := range h.Plugins {
+		if len(revisions) > 0 && revisions[0].ReplacedAt.After(latest) {
+			latest = revisions[0].ReplacedAt
+		}
+	}
+
+	names := make([]string, 0)
+	for name, revisions := range h.Plugins {
+		if len(revisions) > 0 && revisions[0].ReplacedAt.Equal(latest) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Returns the configured number of previous versions kept per plugin.
+func (lf *Lockfile) historyDepth() int {
+	if lf.HistoryDepth == nil {
+		return DefaultHistoryDepth
+	}
+	return max(*lf.HistoryDepth, 0)
+}
+
+// Remembers the version of the plugin in tim.lock before it is replaced,
+// to be added to the history on Save.
+func (lf *Lockfile) rememberReplaced(name string, previous LockedPlugin) {
+	if lf.replaced == nil {
+		lf.replaced = make(map[string]LockedPlugin)
+	}
+	if _, ok := lf.replaced[name]; !ok {
+		lf.replaced[name] = previous
+	}
+}
+
+// Adds the versions replaced since the config file was read to the
+// history, keeping at most the configured depth.
+func (lf *Lockfile) saveHistory() error {
+	if len(lf.replaced) == 0 {
+		return nil
+	}
+	history, err := GetHistory()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	depth := lf.historyDepth()
+	for name, previous := range lf.replaced {
+		if lf.Locked[name].Commit == previous.Commit {
+			continue
+		}
+		revisions := append([]Revision{{
+			Ref:        previous.Ref,
+			Commit:     previous.Commit,
+			Remote:     previous.Remote,
+			ReplacedAt: now,
+		}}, history.Plugins[name]...)
+		history.Plugins[name] = revisions[:min(len(revisions), depth)]
+		if depth == 0 {
+			delete(history.Plugins, name)
+		}
+	}
+	lf.replaced = nil
+	return history.Save()
+}
+
+// Sets the plugin back to spec and locked in the config file and tim.lock,
+// as they were before being replaced since the config file was read.
+// Unlike Rollback, the history is unchanged, as if they were never
+// replaced. The plugin must already be checked out at locked.
+func (lf *Lockfile) Revert(name string, spec PluginSpec, locked LockedPlugin) {
+	lf.PluginSpecs[name] = spec
+	lf.Locked[name] = locked
+	delete(lf.replaced, name)
+}
+
+// Checks out the version the plugin had before it was last replaced,
+// and sets it in the config file and tim.lock. The version is removed
+// from the history once the config file is saved, with the history.
+func (lf *Lockfile) Rollback(ctx context.Context, history *History, name string) (Revision, error) {
+	revisions := history.Plugins[name]
+	if len(revisions) == 0 {
+		return Revision{}, fmt.Errorf("plugin %s: %w", name, ErrNoHistory)
+	}
+	spec, ok := lf.PluginSpecs[name]
+	if !ok {
+		return Revision{}, PluginNotFound(name)
+	}
+	revision := revisions[0]
+
+	plugin := Plugin{Name: name, Remote: revision.Remote}
+	locked := LockedPlugin{Ref: revision.Ref, Commit: revision.Commit, Remote: revision.Remote}
+	if err := plugin.InstallLocked(ctx, locked); err != nil {
+		return Revision{}, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	locked.UpdatedAt = &now
+	lf.Locked[name] = locked
+	spec.Version, spec.Remote = revision.Ref, revision.Remote
+	lf.PluginSpecs[name] = spec
+	history.Plugins[name] = revisions[1:]
+	if len(revisions) == 1 {
+		delete(history.Plugins, name)
+	}
+	return revision, nil
+}
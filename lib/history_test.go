@@ -0,0 +1,60 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSaveHistory(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	depth := 2
+	lockFile := &Lockfile{HistoryDepth: &depth, Locked: make(map[string]LockedPlugin)}
+
+	// Upgrade a/plugin three times, and b/plugin only in the last upgrade.
+	for i, commit := range []string{"aaa", "bbb", "ccc", "ddd"} {
+		if i > 0 {
+			lockFile.rememberReplaced("a/plugin", lockFile.Locked["a/plugin"])
+		}
+		if i == 3 {
+			lockFile.rememberReplaced("b/plugin", LockedPlugin{Ref: "main", Commit: "old"})
+			lockFile.Locked["b/plugin"] = LockedPlugin{Ref: "main", Commit: "new"}
+		}
+		lockFile.Locked["a/plugin"] = LockedPlugin{Ref: "v1.0." + commit, Commit: commit}
+		if err := lockFile.saveHistory(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history, err := GetHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commits := make([]string, 0)
+	for _, revision := range history.Plugins["a/plugin"] {
+		commits = append(commits, revision.Commit)
+	}
+	if want := []string{"ccc", "bbb"}; !slices.Equal(commits, want) {
+		t.Errorf("history of a/plugin = %v; want %v", commits, want)
+	}
+
+	replaced := history.LastReplaced()
+	slices.Sort(replaced)
+	if want := []string{"a/plugin", "b/plugin"}; !slices.Equal(replaced, want) {
+		t.Errorf("LastReplaced() = %v; want %v", replaced, want)
+	}
+}
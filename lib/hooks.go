@@ -0,0 +1,147 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// Starts the comment above each hook in the managed block.
+const hookHeaderPrefix = "# hook "
+
+// Matches the names of tmux hooks, such as "client-attached".
+var hookEventRegexp = regexp.MustCompile(`^[a-z]+(-[a-z]+)*$`)
+
+// Matches the arguments a hook may pass to tim, which need no quoting.
+var hookArgRegexp = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// A tim command run by tmux whenever an event happens, such as a client
+// attaching.
+type Hook struct {
+	// The tmux hook, such as "client-attached".
+	Event string `json:"event"`
+
+	// The arguments tim is run with, such as "upgrade --check".
+	Action string `json:"action"`
+}
+
+// Returns a hook running tim with args on event, checking that both can
+// be written to the tmux config as they are.
+func NewHook(event string, args []string) (Hook, error) {
+	if !hookEventRegexp.MatchString(event) {
+		return Hook{}, fmt.Errorf("invalid tmux hook %q", event)
+	}
+	if len(args) == 0 {
+		return Hook{}, fmt.Errorf("a tim command to run is required")
+	}
+	for _, arg := range args {
+		if !hookArgRegexp.MatchString(arg) {
+			return Hook{}, fmt.Errorf("invalid argument %q, hooks may only pass letters, numbers and punctuation such as - and = to tim", arg)
+		}
+	}
+	return Hook{Event: event, Action: strings.Join(args, " ")}, nil
+}
+
+// Returns the command tmux runs for the hook. The tim in PATH is run if
+// executable is empty, as with TimLoadLine.
+func (h Hook) command(executable string) string {
+	tim := "tim"
+	if executable != "" {
+		// run-shell expands formats, so "#" is escaped as "##".
+		tim = strings.ReplaceAll(ShellQuote(executable), "#", "##")
+	}
+	return "run-shell -b " + TmuxQuote(tim+" "+h.Action)
+}
+
+// Returns the hook option, such as "client-attached[4211]". Each hook has
+// its own index of the event's array of commands, so that sourcing the
+// tmux config again replaces it rather than running it twice, and other
+// commands on the same event are kept. Indexes start at 1000, above those
+// likely to be used by hand.
+func (h Hook) option() string {
+	hash := fnv.New32a()
+	hash.Write([]byte(h.Action))
+	return fmt.Sprintf("%s[%d]", h.Event, 1000+hash.Sum32()%9000)
+}
+
+// Checks if the hooks are different but would replace each other in tmux.
+func (h Hook) Conflicts(other Hook) bool {
+	return h != other && h.option() == other.option()
+}
+
+// Returns the line added to the tmux config to register the hook.
+func (h Hook) Line(executable string) string {
+	return "set-hook -g " + h.option() + " " + TmuxQuote(h.command(executable))
+}
+
+// Returns the comment written above the hook in the managed block.
+func (h Hook) header() string {
+	return fmt.Sprintf("%s%s: %s", hookHeaderPrefix, h.Event, h.Action)
+}
+
+// Adds the hook to the tim managed block of the tmux config. Returns the
+// new config, and whether any changes were made.
+func InsertHook(tmuxConfig string, hook Hook, executable string) (string, bool) {
+	return insertManaged(tmuxConfig, hook.header(), hook.Line(executable))
+}
+
+// Removes the hook from the tim managed block of the tmux config. Returns
+// the new config, and whether any changes were made.
+func RemoveHook(tmuxConfig string, hook Hook) (string, bool) {
+	header := hook.header()
+	return removeManaged(tmuxConfig, func(line string) bool {
+		return line == header
+	})
+}
+
+// Returns the hooks in the tim managed block of the tmux config.
+func TmuxConfigHooks(tmuxConfig string) []Hook {
+	hooks := make([]Hook, 0)
+	inBlock := false
+	for _, line := range strings.Split(tmuxConfig, "\n") {
+		switch {
+		case line == managedBlockBegin:
+			inBlock = true
+		case line == managedBlockEnd:
+			inBlock = false
+		case inBlock && strings.HasPrefix(line, hookHeaderPrefix):
+			event, action, ok := strings.Cut(strings.TrimPrefix(line, hookHeaderPrefix), ": ")
+			if ok {
+				hooks = append(hooks, Hook{Event: event, Action: action})
+			}
+		}
+	}
+	return hooks
+}
+
+// Registers the hook in the running tmux server.
+func (h Hook) Set(executable string) error {
+	cmd := exec.Command("tmux", "set-hook", "-g", h.option(), h.command(executable))
+	cmd.Stderr = Stderr
+	return cmd.Run()
+}
+
+// Unregisters the hook from the running tmux server, leaving any other
+// commands run on the same event.
+func (h Hook) Unset() error {
+	cmd := exec.Command("tmux", "set-hook", "-gu", h.option())
+	cmd.Stderr = Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,80 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNewHook(t *testing.T) {
+	tests := []struct {
+		event string
+		args  []string
+		valid bool
+	}{
+		{"client-attached", []string{"upgrade", "--check"}, true},
+		{"session-created", []string{"load", "--timeout=30s"}, true},
+		{"client attached", []string{"load"}, false},
+		{"client-attached", nil, false},
+		{"client-attached", []string{"load;", "rm"}, false},
+		{"client-attached", []string{`"$(id)"`}, false},
+	}
+	for _, test := range tests {
+		_, err := NewHook(test.event, test.args)
+		if (err == nil) != test.valid {
+			t.Errorf("NewHook(%q, %q) = %v; want valid %v", test.event, test.args, err, test.valid)
+		}
+	}
+}
+
+func TestInsertHook(t *testing.T) {
+	hook := Hook{Event: "client-attached", Action: "upgrade --check"}
+	config, _ := InsertSnippet(snippetTmuxConfig, "user/plugin", Snippet{Path: "example.conf", Content: "set -g @theme 'dark'"})
+
+	got, changed := InsertHook(config, hook, "")
+	if !changed {
+		t.Fatalf("InsertHook() made no changes")
+	}
+	if !strings.Contains(got, hook.Line("")+"\n# END tim managed block") {
+		t.Errorf("InsertHook() = %q; want the hook at the end of the managed block", got)
+	}
+	if hooks := TmuxConfigHooks(got); !slices.Equal(hooks, []Hook{hook}) {
+		t.Errorf("TmuxConfigHooks() = %v; want [%v]", hooks, hook)
+	}
+	if _, changed := InsertHook(got, hook, ""); changed {
+		t.Errorf("InsertHook() inserted a hook twice")
+	}
+
+	// Removing the hook must leave the snippet before it.
+	got, changed = RemoveHook(got, hook)
+	if !changed || got != config {
+		t.Errorf("RemoveHook() = %q, %v; want %q", got, changed, config)
+	}
+}
+
+func TestHookLine(t *testing.T) {
+	hook := Hook{Event: "client-attached", Action: "upgrade --check"}
+	line := hook.Line("/home/me/go/bin/tim")
+	want := `"run-shell -b \"'/home/me/go/bin/tim' upgrade --check\""`
+	if !strings.HasPrefix(line, "set-hook -g client-attached[") || !strings.HasSuffix(line, want) {
+		t.Errorf("Line() = %q; want it to set %s", line, want)
+	}
+	if line != hook.Line("/home/me/go/bin/tim") {
+		t.Errorf("Line() is not the same each time")
+	}
+}
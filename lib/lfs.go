@@ -0,0 +1,59 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+
+	"github.com/kjnsn/tim/lib/message"
+)
+
+var lfsAttributeRegexp = regexp.MustCompile(`(?m)^[^#]*\bfilter=lfs\b`)
+
+// Returns true if the repository at baseDir stores files with git LFS,
+// according to its .gitattributes.
+func UsesLFS(baseDir string) bool {
+	attributes, err := os.ReadFile(path.Join(baseDir, ".gitattributes"))
+	if err != nil {
+		return false
+	}
+	return lfsAttributeRegexp.Match(attributes)
+}
+
+// Fetches the git LFS files of the checkout at baseDir, if it has any.
+// Without git-lfs installed they are left as pointer files, with a
+// warning, since plugins usually work without their assets.
+func pullLFS(ctx context.Context, baseDir string) error {
+	if !UsesLFS(baseDir) {
+		return nil
+	}
+	if _, err := exec.LookPath("git-lfs"); err != nil {
+		message.Warning("%s stores files with git LFS, but git-lfs is not installed, so they are only "+
+			"placeholders. Install git-lfs, then run \"tim add\" to fetch them", baseDir)
+		return nil
+	}
+
+	message.Debug("Fetching git LFS files of %s", baseDir)
+	if _, err := runRemoteGit(ctx, phaseTimeout(FetchTimeout), baseDir, "", "lfs", "pull"); err != nil {
+		return fmt.Errorf("unable to fetch git LFS files: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestUsesLFS(t *testing.T) {
+	tests := []struct {
+		attributes string
+		want       bool
+	}{
+		{"", false},
+		{"*.png filter=lfs diff=lfs merge=lfs -text\n", true},
+		{"*.sh text eol=lf\nassets/** filter=lfs diff=lfs merge=lfs\n", true},
+		{"# *.png filter=lfs diff=lfs merge=lfs\n", false},
+		{"*.sh filter=lfsx\n", false},
+	}
+
+	for _, test := range tests {
+		dir := t.TempDir()
+		if err := os.WriteFile(path.Join(dir, ".gitattributes"), []byte(test.attributes), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if got := UsesLFS(dir); got != test.want {
+			t.Errorf("UsesLFS(%q) = %v; want %v", test.attributes, got, test.want)
+		}
+	}
+}
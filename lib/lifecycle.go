@@ -0,0 +1,98 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+
+	"github.com/kjnsn/tim/lib/message"
+)
+
+// Scripts plugins can ship in their hooks directory, which tim runs at
+// moments in the plugin's life.
+const (
+	// Run once the plugin has been installed, such as to build caches.
+	LifecyclePostInstall = "post-install"
+	// Run before the plugin is removed, such as to unset its tmux options.
+	LifecyclePreUninstall = "pre-uninstall"
+)
+
+// The directory in a plugin holding its lifecycle hooks.
+const lifecycleHooksDir = "hooks"
+
+// Returns the paths, relative to the plugin directory, of the lifecycle
+// hooks among files.
+func lifecycleHooks(files []string) []string {
+	hooks := make([]string, 0)
+	for _, event := range []string{LifecyclePostInstall, LifecyclePreUninstall} {
+		if hook := path.Join(lifecycleHooksDir, event); slices.Contains(files, hook) {
+			hooks = append(hooks, hook)
+		}
+	}
+	return hooks
+}
+
+// Runs the plugin's lifecycle hook for event, one of the Lifecycle
+// constants, if it has one. The hook is run as the plugin's scripts are,
+// with TIM_LIFECYCLE_EVENT set to event as well.
+func (p *Plugin) RunLifecycleHook(ctx context.Context, event string) error {
+	pluginDir, err := p.Dir()
+	if err != nil {
+		return err
+	}
+	hook := path.Join(lifecycleHooksDir, event)
+	if _, err := os.Lstat(path.Join(pluginDir, hook)); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	policy, err := GetPolicy()
+	if err != nil {
+		return err
+	}
+	if err := policy.CheckScripts(p); err != nil {
+		message.Debug("Not running the %s hook of %s: %s", event, p.Name, err)
+		return nil
+	}
+	if err := checkEntrypoint(pluginDir, hook); err != nil {
+		return WithErrorCode(CodeUnsafeScript, fmt.Errorf("refusing to run %s: %w", hook, err))
+	}
+
+	env, err := p.Environ(ctx)
+	if err != nil {
+		return err
+	}
+	env = append(env, "TIM_LIFECYCLE_EVENT="+event)
+	message.Debug("Running the %s hook of %s", event, p.Name)
+	if err := runScript(ctx, []string{path.Join(pluginDir, hook)}, env, p.scriptTimeout()); err != nil {
+		return fmt.Errorf("%s hook failed: %w", event, err)
+	}
+	return nil
+}
+
+// Runs the plugin's lifecycle hook for event, only warning if it fails.
+func warnLifecycleHook(ctx context.Context, p *Plugin, event string) {
+	if err := p.RunLifecycleHook(ctx, event); err != nil {
+		message.Fields{Plugin: p.Name, Code: string(ErrorCodeOf(err))}.Warning("Plugin %s: %s", p.Name, err)
+	}
+}
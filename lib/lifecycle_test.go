@@ -0,0 +1,69 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"os"
+	"path"
+	"slices"
+	"testing"
+)
+
+func TestRunLifecycleHook(t *testing.T) {
+	plugin := Plugin{Name: "user/plugin", Root: t.TempDir()}
+	pluginDir, err := plugin.Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(path.Join(pluginDir, lifecycleHooksDir), 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	// Plugins without the hook are fine.
+	if err := plugin.RunLifecycleHook(context.Background(), LifecyclePreUninstall); err != nil {
+		t.Errorf("RunLifecycleHook() without a hook = %v", err)
+	}
+
+	hook := "#!/bin/sh\necho \"$TIM_LIFECYCLE_EVENT\" > \"$TIM_PLUGIN_DIR/ran\"\n"
+	if err := os.WriteFile(path.Join(pluginDir, lifecycleHooksDir, LifecyclePostInstall), []byte(hook), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := plugin.RunLifecycleHook(context.Background(), LifecyclePostInstall); err != nil {
+		t.Fatalf("RunLifecycleHook() = %v", err)
+	}
+	ran, err := os.ReadFile(path.Join(pluginDir, "ran"))
+	if err != nil || string(ran) != "post-install\n" {
+		t.Errorf("the hook wrote %q, %v; want post-install", ran, err)
+	}
+
+	review, err := plugin.ReviewInstalled()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"hooks/post-install"}; !slices.Equal(review.Hooks, want) {
+		t.Errorf("ReviewInstalled().Hooks = %v; want %v", review.Hooks, want)
+	}
+
+	// Hooks any user can change are refused.
+	if err := os.Chmod(path.Join(pluginDir, lifecycleHooksDir, LifecyclePostInstall), 0777); err != nil {
+		t.Fatal(err)
+	}
+	err = plugin.RunLifecycleHook(context.Background(), LifecyclePostInstall)
+	if ErrorCodeOf(err) != CodeUnsafeScript {
+		t.Errorf("RunLifecycleHook() of a world writable hook = %v; want E_UNSAFE_SCRIPT", err)
+	}
+}
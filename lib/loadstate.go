@@ -0,0 +1,121 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"errors"
+	"os/exec"
+	"time"
+)
+
+const loadStateFile = "load.json"
+
+// The number of consecutive load failures after which a plugin
+// is quarantined by default.
+const DefaultQuarantineThreshold = 3
+
+// The outcome of recent attempts to load plugins, persisted in the
+// state directory between runs of `tim load`.
+type LoadState struct {
+	Plugins map[string]*PluginLoadState `json:"plugins"`
+}
+
+// The outcome of recent attempts to load a single plugin.
+type PluginLoadState struct {
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	LastExitCode        int    `json:"last_exit_code"`
+	LastError           string `json:"last_error,omitempty"`
+
+	// When the plugin last failed to load.
+	LastFailure *time.Time `json:"last_failure,omitempty"`
+
+	// Quarantined plugins are skipped by `tim load` until released.
+	Quarantined bool `json:"quarantined"`
+
+	// How long the plugin took to load the last time it loaded.
+	LastLoadDuration time.Duration `json:"last_load_duration,omitempty"`
+}
+
+// Reads the load state from the state directory.
+func GetLoadState() (*LoadState, error) {
+	state := &LoadState{
+		Plugins: make(map[string]*PluginLoadState),
+	}
+	if err := readStateFile(loadStateFile, state); err != nil {
+		return nil, err
+	}
+	if state.Plugins == nil {
+		state.Plugins = make(map[string]*PluginLoadState)
+	}
+	return state, nil
+}
+
+// Writes the load state to the state directory.
+func (s *LoadState) Save() error {
+	return writeStateFile(loadStateFile, s)
+}
+
+func (s *LoadState) plugin(name string) *PluginLoadState {
+	if _, ok := s.Plugins[name]; !ok {
+		s.Plugins[name] = &PluginLoadState{}
+	}
+	return s.Plugins[name]
+}
+
+// Returns true if the plugin has been quarantined.
+func (s *LoadState) IsQuarantined(name string) bool {
+	state, ok := s.Plugins[name]
+	return ok && state.Quarantined
+}
+
+// Records that the plugin loaded successfully in duration, resetting its
+// failure count.
+func (s *LoadState) RecordSuccess(name string, duration time.Duration) {
+	s.Plugins[name] = &PluginLoadState{LastLoadDuration: duration}
+}
+
+// Records that the plugin failed to load. Once it has failed threshold
+// times in a row it is quarantined, and true is returned. A threshold of
+// zero never quarantines.
+func (s *LoadState) RecordFailure(name string, err error, threshold int) bool {
+	state := s.plugin(name)
+	state.ConsecutiveFailures++
+	state.LastExitCode = ExitCode(err)
+	state.LastError = err.Error()
+	now := time.Now().UTC().Truncate(time.Second)
+	state.LastFailure = &now
+
+	if threshold > 0 && state.ConsecutiveFailures >= threshold && !state.Quarantined {
+		state.Quarantined = true
+		return true
+	}
+	return false
+}
+
+// Releases the plugin from quarantine, so it is loaded again.
+func (s *LoadState) Release(name string) {
+	delete(s.Plugins, name)
+}
+
+// Returns the exit code of a failed command, or -1 if the
+// command did not run to completion.
+func ExitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
@@ -0,0 +1,98 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+)
+
+// The version spec of plugins loaded from a local directory.
+const LocalVersionSpec = "local"
+
+// The version of a plugin loaded from a directory on this machine, such
+// as one being developed. tim never checks it out or upgrades it.
+type LocalVersion struct{}
+
+// Local plugins are always up to date, being whatever is in their directory.
+func (LocalVersion) Check(ctx context.Context, pluginDir string) CheckResult {
+	return CheckResult{Outcome: OutcomeUpToDate, Latest: LocalVersion{}}
+}
+
+func (LocalVersion) Upgrade(ctx context.Context, pluginDir string) error {
+	return errors.New("plugins loaded from a local directory are not upgraded")
+}
+
+func (LocalVersion) String() string {
+	return LocalVersionSpec
+}
+
+func (LocalVersion) GitRef() string {
+	return ""
+}
+
+// Returns true if the plugin is loaded from a local directory.
+func (p *Plugin) IsLocal() bool {
+	return p.Path != ""
+}
+
+// Installs a local plugin by linking its directory into the plugins
+// directory, where it is loaded from like any other plugin.
+func (p *Plugin) linkLocal() error {
+	info, err := os.Stat(p.Path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", p.Path)
+	}
+
+	pluginDir, err := p.Dir()
+	if err != nil {
+		return err
+	}
+	p.Version = LocalVersion{}
+
+	info, err = os.Lstat(pluginDir)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return err
+	case info.Mode()&fs.ModeSymlink == 0:
+		return fmt.Errorf("plugin %s is already installed at %s, run \"tim remove %s\" first",
+			p.Name, pluginDir, p.Name)
+	default:
+		target, err := os.Readlink(pluginDir)
+		if err != nil {
+			return err
+		}
+		if target == p.Path {
+			return nil
+		}
+		if err := os.Remove(pluginDir); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(path.Dir(pluginDir), 0750); err != nil {
+		return err
+	}
+	return os.Symlink(p.Path, pluginDir)
+}
@@ -0,0 +1,239 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The resolved state of a plugin, recorded in tim.lock.
+type LockedPlugin struct {
+	// The git ref the plugin was resolved to, a tag or a branch.
+	Ref string `json:"ref"`
+
+	// The full hash of the commit checked out.
+	Commit string `json:"commit"`
+
+	// URL the plugin was cloned from, empty for the default github remote.
+	Remote string `json:"remote,omitempty"`
+
+	// When the plugin was last installed or upgraded to a new commit.
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// The contents of tim.lock.
+type lockContents struct {
+	SchemaVersion int                     `json:"schema_version"`
+	Plugins       map[string]LockedPlugin `json:"plugins"`
+}
+
+// Returns the path of the generated lock that accompanies the config
+// file at configPath, for example ~/.config/tim/tim.lock.
+func lockPathFor(configPath string) string {
+	return strings.TrimSuffix(configPath, filepath.Ext(configPath)) + ".lock"
+}
+
+// Reads the resolved plugins from the lock at lockPath. A missing
+// lock has no plugins.
+func readLock(lockPath string) (map[string]LockedPlugin, error) {
+	contents, err := os.ReadFile(lockPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return make(map[string]LockedPlugin), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lock := lockContents{}
+	if err := json.Unmarshal(contents, &lock); err != nil {
+		return nil, WithErrorCode(CodeLockfileCorrupt, fmt.Errorf("unable to read %s: %w", lockPath, err))
+	}
+	if lock.Plugins == nil {
+		lock.Plugins = make(map[string]LockedPlugin)
+	}
+	return lock.Plugins, nil
+}
+
+// Writes the resolved plugins to the lock at lockPath.
+func writeLock(lockPath string, plugins map[string]LockedPlugin) error {
+	encoded, err := json.MarshalIndent(lockContents{
+		SchemaVersion: CurrentSchemaVersion,
+		Plugins:       plugins,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ReplaceFile(lockPath, append(encoded, '\n'), 0600)
+}
+
+// Adds the plugin to the config file, and records its resolved commit.
+// Settings of an existing entry other than the version and remote are kept.
+func (lf *Lockfile) SetPlugin(ctx context.Context, plugin *Plugin) error {
+	spec := lf.PluginSpecs[plugin.Name]
+	resolved := plugin.Spec()
+	spec.Version, spec.Remote, spec.Path = resolved.Version, resolved.Remote, resolved.Path
+	lf.PluginSpecs[plugin.Name] = spec
+	return lf.Record(ctx, plugin)
+}
+
+// Records the resolved version and commit of the installed plugin, to
+// be written to tim.lock on Save.
+func (lf *Lockfile) Record(ctx context.Context, plugin *Plugin) error {
+	// Local plugins are whatever is in their directory.
+	if plugin.IsLocal() {
+		delete(lf.Locked, plugin.Name)
+		return nil
+	}
+
+	commit, err := plugin.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	previous, ok := lf.Locked[plugin.Name]
+	if ok && previous.Commit != commit {
+		lf.rememberReplaced(plugin.Name, previous)
+	}
+
+	updatedAt := previous.UpdatedAt
+	if updatedAt == nil || previous.Commit != commit {
+		now := time.Now().UTC().Truncate(time.Second)
+		updatedAt = &now
+	}
+
+	lf.Locked[plugin.Name] = LockedPlugin{
+		Ref:       plugin.Spec().Version,
+		Commit:    commit,
+		Remote:    plugin.Remote,
+		UpdatedAt: updatedAt,
+	}
+	return nil
+}
+
+// Sets the version spec of the plugin in the config file without
+// installing it, along with its remote unless that is empty. If either
+// changed, the plugin is removed from the lock so the next sync
+// installs it.
+func (lf *Lockfile) SetSpec(name, version, remote string) {
+	previous := lf.PluginSpecs[name]
+	spec := previous
+	spec.Version = version
+	if remote != "" {
+		spec.Remote = remote
+	}
+	lf.PluginSpecs[name] = spec
+
+	if spec.Version != previous.Version || spec.Remote != previous.Remote {
+		lf.RemoveLocked(name)
+	}
+}
+
+// Sets whether "tim load" skips the plugin, returning whether that
+// changed it.
+func (lf *Lockfile) SetDisabled(name string, disabled bool) (bool, error) {
+	spec, ok := lf.PluginSpecs[name]
+	if !ok {
+		return false, PluginNotFound(name)
+	}
+	if spec.Disabled == disabled {
+		return false, nil
+	}
+	spec.Disabled = disabled
+	lf.PluginSpecs[name] = spec
+	return true, nil
+}
+
+// Removes the plugin from both the config file and the lock.
+func (lf *Lockfile) Remove(name string) {
+	delete(lf.PluginSpecs, name)
+	delete(lf.Locked, name)
+}
+
+// Removes the plugin from the lock, keeping it in the config file so
+// it is installed again on the next sync.
+func (lf *Lockfile) RemoveLocked(name string) {
+	delete(lf.Locked, name)
+}
+
+// Returns the full hash of the commit checked out for the plugin.
+func (p *Plugin) Commit(ctx context.Context) (string, error) {
+	pluginDir, err := p.RepoDir()
+	if err != nil {
+		return "", err
+	}
+	return Git.ResolveCommit(ctx, pluginDir, "HEAD")
+}
+
+// Installs the plugin and checks out exactly the commit recorded in the
+// lock. Errors are PluginErrors.
+func (p *Plugin) InstallLocked(ctx context.Context, locked LockedPlugin) error {
+	return p.wrapError(p.installLocked(ctx, locked))
+}
+
+func (p *Plugin) installLocked(ctx context.Context, locked LockedPlugin) error {
+	if err := checkInstallPolicy(p); err != nil {
+		return err
+	}
+
+	ctx = withProgressPlugin(ctx, p.Name)
+	defer finishProgress(ctx)
+
+	pluginDir, err := p.RepoDir()
+	if err != nil {
+		return err
+	}
+
+	cloned := false
+	if !HasCheckout(ctx, pluginDir) {
+		if err := os.MkdirAll(pluginDir, 0750); err != nil {
+			return err
+		}
+		if err := Clone(ctx, pluginDir, p.RemoteURL()); err != nil {
+			return err
+		}
+		p.sparseCheckout(ctx, pluginDir)
+		cloned = true
+	}
+
+	if !HasCommit(ctx, pluginDir, locked.Commit) {
+		// The commit is not available locally yet.
+		if err := FetchTags(ctx, pluginDir); err != nil {
+			return err
+		}
+	}
+
+	// Tags are signed rather than the commits they point to.
+	signed := locked.Commit
+	if commit, err := Git.ResolveCommit(ctx, pluginDir, "refs/tags/"+locked.Ref); err == nil && commit == locked.Commit {
+		signed = locked.Ref
+	}
+	if err := p.verifySignature(ctx, pluginDir, signed); err != nil {
+		return p.discardUnsigned(err, cloned)
+	}
+	if err := Checkout(ctx, pluginDir, locked.Commit, false); err != nil {
+		return err
+	}
+	return p.checkSubdir()
+}
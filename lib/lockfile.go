@@ -16,16 +16,179 @@ limitations under the License.
 package lib
 
 import (
+	"cmp"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
 )
 
+var ErrDirtyConfig = errors.New("refusing to overwrite the config file")
+
+// Allows saving a config file that has unknown keys or a newer schema
+// version, discarding whatever this version of tim does not understand.
+var AllowDirtyConfig = false
+
 type Lockfile struct {
 	file *os.File
 
-	PluginSpecs map[string]string `json:"plugins"`
+	// Releases the lock taken by GetLockfile, nil if read only.
+	release func()
+
+	// The contents of the file when it was last read or written, whose
+	// comments are kept when it is saved as TOML or yaml.
+	contents []byte
+
+	// Top level keys in the file that tim does not understand.
+	unknownKeys []string
+
+	// PluginRoots, resolved to absolute paths.
+	pluginRoots []string
+
+	SchemaVersion int `json:"schema_version"`
+
+	// The longest a single git command may run, such as "2m".
+	GitTimeout string `json:"git_timeout,omitempty"`
+
+	// Timeouts of each phase of work, overriding GitTimeout.
+	Timeouts *PhaseTimeouts `json:"timeouts,omitempty"`
+
+	// How git is accessed, either "exec" (the default) or "go-git".
+	GitBackend string `json:"git_backend,omitempty"`
+
+	// Whether to check github hosted plugins for new versions with the
+	// github API, rather than fetching with git.
+	GithubAPI bool `json:"github_api,omitempty"`
+
+	// How git reaches remotes, such as through a proxy.
+	Network *NetworkConfig `json:"network,omitempty"`
+
+	// How long "tim upgrade --check" reuses the last check of each
+	// plugin, such as "1h", DefaultCheckTTL if unset.
+	CheckTTL string `json:"check_ttl,omitempty"`
+
+	// Whether to check for new releases of tim once a week.
+	UpdateCheck bool `json:"update_check,omitempty"`
+
+	// The number of previous versions of each plugin kept for
+	// "tim rollback", DefaultHistoryDepth if unset.
+	HistoryDepth *int `json:"history_depth,omitempty"`
+
+	// Fingerprints of the keys trusted to sign every plugin with
+	// verify_signatures set.
+	TrustedKeys []string `json:"trusted_keys,omitempty"`
+
+	// Whether to stop warning about installing plugins that do the same
+	// job, such as two status line themes.
+	AllowOverlap bool `json:"allow_overlap,omitempty"`
+
+	// The directory plugins are installed in, instead of tim/plugins
+	// in xdg-data-home.
+	PluginDir string `json:"plugin_dir,omitempty"`
+
+	// Read-only directories of plugins that tim loads but does not
+	// manage, such as "/usr/share/tmux-plugins", see UnmanagedPlugins.
+	PluginRoots []string `json:"plugin_roots,omitempty"`
+
+	// Commands run as "tim <name>", see CustomCommand.
+	Commands map[string]CustomCommand `json:"commands,omitempty"`
+
+	// Short names standing for plugins, such as "resurrect" for
+	// "tmux-plugins/tmux-resurrect", see ResolveAlias.
+	Aliases map[string]string `json:"aliases,omitempty"`
+
+	PluginSpecs map[string]PluginSpec `json:"plugins"`
+
+	// The resolved state of each plugin, stored separately in tim.lock.
+	Locked map[string]LockedPlugin `json:"-"`
+
+	// Entries of tim.lock replaced since it was read, by plugin name.
+	replaced map[string]LockedPlugin
+}
+
+// The longest each phase of work may take, such as "10m". Empty
+// values use the defaults.
+type PhaseTimeouts struct {
+	// Cloning a plugin.
+	Clone string `json:"clone,omitempty"`
+
+	// Fetching updates to a plugin.
+	Fetch string `json:"fetch,omitempty"`
+
+	// Running each of a plugin's scripts when loading it.
+	Script string `json:"script,omitempty"`
+}
+
+// The lockfile entry for a single plugin.
+type PluginSpec struct {
+	// Version spec, either a semantic version or a branch name.
+	Version string `json:"version"`
+
+	// URL to clone the plugin from, empty for the default github remote.
+	Remote string `json:"remote,omitempty"`
+
+	// Directory the plugin is loaded from instead of being cloned, for
+	// plugins being developed. Its version is "local".
+	Path string `json:"path,omitempty"`
+
+	// Environment variables set when loading the plugin. Values starting
+	// with "cmd:" are replaced with the output of the command.
+	Env map[string]string `json:"env,omitempty"`
+
+	// Global tmux options set before loading the plugin, such as
+	// "@resurrect-dir".
+	Options map[string]string `json:"options,omitempty"`
+
+	// Whether to refuse versions not signed by a trusted key.
+	VerifySignatures bool `json:"verify_signatures,omitempty"`
+
+	// Fingerprints of the keys trusted to sign this plugin, as well as
+	// the top level trusted_keys. Without any, every key git trusts is.
+	TrustedKeys []string `json:"trusted_keys,omitempty"`
+
+	// Whether "tim load" skips the plugin, which stays installed at its
+	// version.
+	Disabled bool `json:"disabled,omitempty"`
+
+	// Scripts run when loading the plugin, relative to its directory,
+	// instead of those found. May be glob patterns, such as "bin/*.sh".
+	Run []string `json:"run,omitempty"`
+
+	// The longest each of the plugin's scripts may run when loading it,
+	// such as "1m", instead of timeouts.script. "0" for no limit.
+	Timeout string `json:"timeout,omitempty"`
+
+	// Which pre-releases are installed, see Channel. Releases only if
+	// empty.
+	Channel string `json:"channel,omitempty"`
+
+	// Which tags are releases, see TagPattern. Those that are semantic
+	// versions if empty.
+	TagPattern string `json:"tag_pattern,omitempty"`
+
+	// Whether the plugin is upgraded automatically, only reported, or
+	// never upgraded, see UpgradePolicy.
+	Upgrade string `json:"upgrade,omitempty"`
+}
+
+// Accepts either a plain version string, as written by schema version 1
+// and convenient when editing by hand, or an object.
+func (ps *PluginSpec) UnmarshalJSON(data []byte) error {
+	var version string
+	if err := json.Unmarshal(data, &version); err == nil {
+		*ps = PluginSpec{Version: version}
+		return nil
+	}
+
+	type plain PluginSpec
+	return json.Unmarshal(data, (*plain)(ps))
 }
 
 func (lf *Lockfile) Path() string {
@@ -34,15 +197,47 @@ func (lf *Lockfile) Path() string {
 
 func (lf *Lockfile) Plugins() []Plugin {
 	plugins := make([]Plugin, 0)
-	for name, versionSpec := range lf.PluginSpecs {
-		plugins = append(plugins, Plugin{
-			Name:    name,
-			Version: VersionFromSpec(versionSpec),
-		})
+	for name, spec := range lf.PluginSpecs {
+		plugins = append(plugins, lf.plugin(name, spec))
 	}
 	return plugins
 }
 
+// Returns the plugin configured by spec.
+func (lf *Lockfile) plugin(name string, spec PluginSpec) Plugin {
+	// Channels, tag patterns and upgrade policies are checked when the
+	// config file is read.
+	channel, _ := ParseChannel(spec.Channel)
+	tagPattern, _ := ParseTagPattern(spec.TagPattern)
+	upgradePolicy, _ := ParseUpgradePolicy(spec.Upgrade)
+	// Plugins in a subdirectory have their own default tag pattern.
+	named := Plugin{Name: name, TagPattern: tagPattern}
+	version := versionFromSpec(spec.Version, channel, named.tagPattern())
+	if gitVersion, ok := version.(*GitVersion); ok {
+		gitVersion.currentHash = lf.Locked[name].Commit
+	}
+	if spec.Path != "" {
+		version = LocalVersion{}
+	}
+
+	return Plugin{
+		Name:             name,
+		Version:          version,
+		Remote:           spec.Remote,
+		Path:             spec.Path,
+		Env:              spec.Env,
+		Options:          spec.Options,
+		VerifySignatures: spec.VerifySignatures,
+		TrustedKeys:      slices.Concat(spec.TrustedKeys, lf.TrustedKeys),
+		Disabled:         spec.Disabled,
+		Run:              spec.Run,
+		ScriptTimeout:    spec.scriptTimeout(),
+		Channel:          channel,
+		TagPattern:       tagPattern,
+		UpgradePolicy:    upgradePolicy,
+	}
+}
+
 // Attempts to find a plugin with the given name. Returns nil if the given plugin cannot be found.
 func (lf *Lockfile) GetPlugin(name string) *Plugin {
 	for _, plugin := range lf.Plugins() {
@@ -54,30 +249,108 @@ func (lf *Lockfile) GetPlugin(name string) *Plugin {
 	return nil
 }
 
-// Closes all resources associated with this lock file.
+// Returns the names of plugins installed in the plugins directory that
+// are not in the config file.
+func (lf *Lockfile) OrphanedPlugins() ([]string, error) {
+	installed, err := FindInstalledPlugins()
+	if err != nil {
+		return nil, err
+	}
+
+	orphaned := make([]string, 0)
+	for _, name := range installed {
+		if _, ok := lf.PluginSpecs[name]; !ok {
+			orphaned = append(orphaned, name)
+		}
+	}
+	return orphaned, nil
+}
+
+// Closes all resources associated with this lock file, and lets other
+// tim processes change it.
 func (lf *Lockfile) Close() {
 	lf.file.Close()
+	if lf.release != nil {
+		lf.release()
+		lf.release = nil
+	}
+}
+
+// Returns an ErrDirtyConfig error if saving the lockfile would lose
+// data, because it has unknown keys or was written by a newer tim.
+func (lf *Lockfile) CheckClean() error {
+	if lf.SchemaVersion > CurrentSchemaVersion {
+		return fmt.Errorf("%w: %s has schema version %d, but this version of tim only understands up to %d. "+
+			"Upgrade tim, or pass --allow-dirty-config to discard anything it does not understand",
+			ErrDirtyConfig, lf.Path(), lf.SchemaVersion, CurrentSchemaVersion)
+	}
+	if len(lf.unknownKeys) > 0 {
+		return fmt.Errorf("%w: %s contains unknown keys: %s. "+
+			"Remove them, upgrade tim, or pass --allow-dirty-config to discard them",
+			ErrDirtyConfig, lf.Path(), strings.Join(lf.unknownKeys, ", "))
+	}
+	return nil
 }
 
-// Writes the lock file to disk.
+// Returns the path of tim.lock.
+func (lf *Lockfile) LockPath() string {
+	return lockPathFor(lf.Path())
+}
+
+// Writes the lock file, and tim.lock, to disk.
 func (lf *Lockfile) Save() error {
-	if err := lf.file.Truncate(0); err != nil {
+	if lf.release == nil {
+		return fmt.Errorf("%s was opened read only", lf.Path())
+	}
+	if !AllowDirtyConfig {
+		if err := lf.CheckClean(); err != nil {
+			return err
+		}
+	}
+
+	lf.SchemaVersion = CurrentSchemaVersion
+
+	document, err := json.MarshalIndent(lf, "", "  ")
+	if err != nil {
 		return err
 	}
-	if _, err := lf.file.Seek(0, 0); err != nil {
+	contents, err := formatConfig(lf.Path(), lf.contents, append(document, '\n'))
+	if err != nil {
 		return err
 	}
 
-	defer lf.file.Sync()
+	if err := ReplaceFile(lf.Path(), contents, 0600); err != nil {
+		return err
+	}
+	lf.contents = contents
 
-	encoder := json.NewEncoder(lf.file)
-	encoder.SetIndent("", "  ")
+	if err := writeLock(lf.LockPath(), lf.Locked); err != nil {
+		return err
+	}
+	return lf.saveHistory()
+}
 
-	return encoder.Encode(lf)
+// Loads the lockfile to change it, creating one if required. Other tim
+// processes are prevented from changing it until it is closed, and if
+// one already is, this waits for it with WaitForLock, or fails with
+// ErrLocked.
+func GetLockfile(ctx context.Context, cfgOverride string) (*Lockfile, error) {
+	release, err := acquireProcessLock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lockFile, err := ReadLockfile(cfgOverride)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	lockFile.release = release
+	return lockFile, nil
 }
 
-// Loads the lockfile, creating one if required.
-func GetLockfile(cfgOverride string) (*Lockfile, error) {
+// Loads the lockfile without preventing other tim processes from
+// changing it. It cannot be saved.
+func ReadLockfile(cfgOverride string) (*Lockfile, error) {
 	lockPath, err := lockfilePath(cfgOverride)
 	if err != nil {
 		return nil, err
@@ -93,23 +366,155 @@ func GetLockfile(cfgOverride string) (*Lockfile, error) {
 	}
 
 	lockFile := &Lockfile{
-		file:        actualLockFile,
-		PluginSpecs: make(map[string]string),
+		file:          actualLockFile,
+		contents:      lockFileContents,
+		SchemaVersion: CurrentSchemaVersion,
+		PluginSpecs:   make(map[string]PluginSpec),
 	}
 
 	// Only try and parse the contents if the file is non-empty.
 	if len(lockFileContents) > 0 {
-		if err := json.Unmarshal(lockFileContents, lockFile); err != nil {
+		document, err := configJSON(lockPath, lockFileContents)
+		if err != nil {
+			return nil, WithErrorCode(CodeLockfileCorrupt, fmt.Errorf("unable to read %s: %w", lockPath, err))
+		}
+		if err := json.Unmarshal(document, lockFile); err != nil {
+			return nil, WithErrorCode(CodeLockfileCorrupt, fmt.Errorf("unable to read %s: %w", lockPath, err))
+		}
+
+		lockFile.unknownKeys, err = unknownKeys(document, lockFile)
+		if err != nil {
 			return nil, err
 		}
 	}
 
+	if err := lockFile.applyTimeouts(); err != nil {
+		return nil, fmt.Errorf("invalid timeout in %s: %w", lockPath, err)
+	}
+	if err := lockFile.checkReleases(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", lockPath, err)
+	}
+	if err := lockFile.checkTrustedKeys(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", lockPath, err)
+	}
+	if err := lockFile.checkAliases(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", lockPath, err)
+	}
+
+	if err := SetGitBackend(lockFile.GitBackend); err != nil {
+		return nil, fmt.Errorf("invalid git_backend in %s: %w", lockPath, err)
+	}
+	GithubAPIChecks = lockFile.GithubAPI
+	Network = NetworkConfig{}
+	if lockFile.Network != nil {
+		if err := lockFile.Network.validate(); err != nil {
+			return nil, fmt.Errorf("invalid network in %s: %w", lockPath, err)
+		}
+		Network = *lockFile.Network
+	}
+	pluginDir, err := resolvePluginDir(lockFile.PluginDir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plugin_dir in %s: %w", lockPath, err)
+	}
+	PluginsDir = cmp.Or(pluginDirOverride, pluginDir)
+	for _, root := range lockFile.PluginRoots {
+		resolved, err := resolvePluginDir(root)
+		if err != nil {
+			return nil, fmt.Errorf("invalid plugin_roots in %s: %w", lockPath, err)
+		}
+		lockFile.pluginRoots = append(lockFile.pluginRoots, resolved)
+	}
+
+	lockFile.Locked, err = readLock(lockPathFor(lockPath))
+	if err != nil {
+		return nil, err
+	}
+
 	return lockFile, nil
 }
 
+// Sets the timeouts of git commands and scripts from the config file.
+func (lf *Lockfile) applyTimeouts() error {
+	durations := map[string]*time.Duration{"git_timeout": &GitTimeout}
+	values := map[string]string{"git_timeout": lf.GitTimeout}
+	if lf.Timeouts != nil {
+		durations["timeouts.clone"] = &CloneTimeout
+		durations["timeouts.fetch"] = &FetchTimeout
+		durations["timeouts.script"] = &ScriptTimeout
+		values["timeouts.clone"] = lf.Timeouts.Clone
+		values["timeouts.fetch"] = lf.Timeouts.Fetch
+		values["timeouts.script"] = lf.Timeouts.Script
+	}
+
+	for key, value := range values {
+		if value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		*durations[key] = duration
+	}
+
+	if _, err := time.ParseDuration(lf.CheckTTL); lf.CheckTTL != "" && err != nil {
+		return fmt.Errorf("check_ttl: %w", err)
+	}
+	for name, spec := range lf.PluginSpecs {
+		if _, err := time.ParseDuration(spec.Timeout); spec.Timeout != "" && err != nil {
+			return fmt.Errorf("timeout of plugin %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Checks the channel, tag pattern and upgrade policy of every plugin.
+func (lf *Lockfile) checkReleases() error {
+	for name, spec := range lf.PluginSpecs {
+		if _, err := ParseChannel(spec.Channel); err != nil {
+			return fmt.Errorf("channel of plugin %s: %w", name, err)
+		}
+		if _, err := ParseTagPattern(spec.TagPattern); err != nil {
+			return fmt.Errorf("tag_pattern of plugin %s: %w", name, err)
+		}
+		if _, err := ParseUpgradePolicy(spec.Upgrade); err != nil {
+			return fmt.Errorf("upgrade of plugin %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Checks that every trusted key is a full fingerprint.
+func (lf *Lockfile) checkTrustedKeys() error {
+	for _, key := range lf.TrustedKeys {
+		if err := checkTrustedKey(key); err != nil {
+			return err
+		}
+	}
+	for name, spec := range lf.PluginSpecs {
+		for _, key := range spec.TrustedKeys {
+			if err := checkTrustedKey(key); err != nil {
+				return fmt.Errorf("plugin %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the plugin's timeout, or nil if it has none. Timeouts are
+// checked when the config file is read.
+func (ps PluginSpec) scriptTimeout() *time.Duration {
+	timeout, err := time.ParseDuration(ps.Timeout)
+	if ps.Timeout == "" || err != nil {
+		return nil
+	}
+	return &timeout
+}
+
 // Returns the path to the lockfile.
 // Preferences, in order:
 // - pathOverride
+// - whichever of tim.json, tim.toml or tim.yaml exists in ~/.config/tim
 // - ~/.config/tim/tim.json
 func lockfilePath(pathOverride string) (string, error) {
 	// Ensure the correct directories are created.
@@ -122,5 +527,43 @@ func lockfilePath(pathOverride string) (string, error) {
 		return pathOverride, nil
 	}
 
-	return path.Join(timDir, "tim.json"), nil
+	found := make([]string, 0)
+	for _, name := range configFileNames {
+		if _, err := os.Stat(path.Join(timDir, name)); err == nil {
+			found = append(found, path.Join(timDir, name))
+		}
+	}
+	switch len(found) {
+	case 0:
+		return path.Join(timDir, configFileNames[0]), nil
+	case 1:
+		return found[0], nil
+	}
+	return "", fmt.Errorf("found more than one config file, remove all but one of: %s", strings.Join(found, ", "))
+}
+
+// Returns the top level keys in contents that do not map to a field of v.
+func unknownKeys(contents []byte, v any) ([]string, error) {
+	document := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(contents, &document); err != nil {
+		return nil, err
+	}
+
+	known := make([]string, 0)
+	t := reflect.TypeOf(v).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			known = append(known, name)
+		}
+	}
+
+	unknown := make([]string, 0)
+	for key := range document {
+		if !slices.Contains(known, key) {
+			unknown = append(unknown, key)
+		}
+	}
+	slices.Sort(unknown)
+	return unknown, nil
 }
@@ -0,0 +1,143 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestSaveRefusesDirtyConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	lockPath := path.Join(t.TempDir(), "tim.json")
+	if err := os.WriteFile(lockPath, []byte(`{"plugins": {}, "future_key": true}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	lockFile, err := GetLockfile(context.Background(), lockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lockFile.Close()
+
+	if err := lockFile.Save(); !errors.Is(err, ErrDirtyConfig) {
+		t.Errorf("Save() = %v; want ErrDirtyConfig", err)
+	}
+}
+
+func TestSaveKeepsMode(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	lockPath := path.Join(dir, "tim.json")
+	if err := os.WriteFile(lockPath, []byte(`{"plugins": {}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lockFile, err := GetLockfile(context.Background(), lockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lockFile.Close()
+	if err := lockFile.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if info, err := os.Stat(lockPath); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("mode after Save() = %v, %v; want 0644", info.Mode().Perm(), err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".tmp") {
+			t.Errorf("Save() left %s behind", entry.Name())
+		}
+	}
+}
+
+func TestGetLockfileIsExclusive(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	lockPath := path.Join(t.TempDir(), "tim.json")
+
+	first, err := GetLockfile(context.Background(), lockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetLockfile(context.Background(), lockPath); !errors.Is(err, ErrLocked) {
+		t.Errorf("GetLockfile() while locked = %v; want ErrLocked", err)
+	}
+
+	reader, err := ReadLockfile(lockPath)
+	if err != nil {
+		t.Fatalf("ReadLockfile() while locked = %v", err)
+	}
+	reader.Close()
+
+	first.Close()
+	second, err := GetLockfile(context.Background(), lockPath)
+	if err != nil {
+		t.Fatalf("GetLockfile() after Close() = %v", err)
+	}
+	second.Close()
+}
+
+func TestSetDisabled(t *testing.T) {
+	lockFile := Lockfile{PluginSpecs: map[string]PluginSpec{"user/plugin": {Version: "v1.0.0"}}}
+
+	changed, err := lockFile.SetDisabled("user/plugin", true)
+	if err != nil || !changed {
+		t.Fatalf("SetDisabled(true) = %v, %v; want true, nil", changed, err)
+	}
+	if plugins := lockFile.Plugins(); !plugins[0].Disabled || plugins[0].Version.String() != "v1.0.0" {
+		t.Errorf("Plugins() = %+v; want disabled at v1.0.0", plugins[0])
+	}
+	if changed, _ := lockFile.SetDisabled("user/plugin", true); changed {
+		t.Errorf("SetDisabled(true) again changed the plugin")
+	}
+	if _, err := lockFile.SetDisabled("user/missing", true); ErrorCodeOf(err) != CodePluginNotFound {
+		t.Errorf("SetDisabled() of a missing plugin = %v; want E_PLUGIN_NOT_FOUND", err)
+	}
+}
+
+func TestCheckAliases(t *testing.T) {
+	tests := []struct {
+		aliases map[string]string
+		wantErr bool
+	}{
+		{map[string]string{"resurrect": "tmux-plugins/tmux-resurrect"}, false},
+		{map[string]string{"resurrect": "https://gitlab.com/user/tmux-resurrect.git"}, false},
+		{map[string]string{"user/resurrect": "tmux-plugins/tmux-resurrect"}, true},
+		{map[string]string{"": "tmux-plugins/tmux-resurrect"}, true},
+		{map[string]string{"resurrect": "https://"}, true},
+	}
+	for _, tt := range tests {
+		lf := &Lockfile{Aliases: tt.aliases}
+		if err := lf.checkAliases(); (err != nil) != tt.wantErr {
+			t.Errorf("checkAliases(%v) = %v; wantErr %t", tt.aliases, err, tt.wantErr)
+		}
+	}
+}
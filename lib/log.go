@@ -0,0 +1,269 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kjnsn/tim/lib/message"
+)
+
+// Name of the log of git commands run, in the state directory.
+const LogFileName = "tim.log"
+
+// Size the log may grow to before it is rotated.
+const maxLogSize = 1 << 20
+
+// Number of rotated logs kept, named tim.log.1 (the newest) to tim.log.3.
+const logBackups = 3
+
+// A git command run by tim, written to the log as a line of JSON.
+type LogEntry struct {
+	Time time.Time `json:"time"`
+
+	// The arguments git was run with, with passwords in URLs removed.
+	Args []string `json:"args"`
+	Dir  string   `json:"dir"`
+
+	DurationMs int64 `json:"duration_ms"`
+
+	// The exit status of git, or -1 if it did not exit, for example
+	// because it could not be started or was stopped by a timeout.
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+
+	// The last lines git wrote to stderr if it failed, without progress.
+	Stderr []string `json:"stderr,omitempty"`
+}
+
+// Number of lines of stderr logged for a failed git command.
+const logStderrLines = 10
+
+// Guards writing and rotating the log, as plugins are installed concurrently.
+var logLock sync.Mutex
+
+// Returns the path of the log file.
+func LogPath() (string, error) {
+	stateDir, err := GetStateDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(stateDir, LogFileName), nil
+}
+
+// Logs a git command run in dir, which started at start and failed with
+// err if it is not nil, writing stderr. Failing to write the log never
+// stops tim working, so errors are only shown with --verbose.
+func logGitCommand(dir string, args []string, start time.Time, err error, stderr string) {
+	entry := LogEntry{
+		Time:       start,
+		Args:       make([]string, len(args)),
+		Dir:        dir,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	for i, arg := range args {
+		entry.Args[i] = redactURL(arg)
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		entry.ExitCode = exitErr.ExitCode()
+		entry.Error = err.Error()
+	default:
+		entry.ExitCode = -1
+		entry.Error = err.Error()
+	}
+	if err != nil {
+		entry.Stderr = stderrTail(stderr)
+	}
+
+	if err := writeLogEntry(entry); err != nil {
+		message.Debug("Unable to write to %s: %s", LogFileName, err)
+	}
+}
+
+func writeLogEntry(entry LogEntry) error {
+	logPath, err := LogPath()
+	if err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	logLock.Lock()
+	defer logLock.Unlock()
+	if info, err := os.Stat(logPath); err == nil && info.Size() >= maxLogSize {
+		rotateLog(logPath)
+	}
+	file, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(encoded, '\n'))
+	return err
+}
+
+// Moves tim.log to tim.log.1, tim.log.1 to tim.log.2 and so on, deleting
+// the oldest.
+func rotateLog(logPath string) {
+	for i := logBackups - 1; i >= 1; i-- {
+		os.Rename(logPath+"."+strconv.Itoa(i), logPath+"."+strconv.Itoa(i+1))
+	}
+	os.Rename(logPath, logPath+".1")
+}
+
+// Returns the last lines of stderr that are not progress, which git
+// redraws with carriage returns.
+func stderrTail(stderr string) []string {
+	lines := make([]string, 0)
+	for _, line := range strings.FieldsFunc(stderr, func(r rune) bool { return r == '\r' || r == '\n' }) {
+		line = strings.TrimSpace(line)
+		if line != "" && !gitProgressLine.MatchString(line) {
+			lines = append(lines, line)
+		}
+	}
+	return lines[max(0, len(lines)-logStderrLines):]
+}
+
+// Removes the password from arg if it is a URL with one, such as a clone
+// URL holding an access token.
+func redactURL(arg string) string {
+	parsed, err := url.Parse(arg)
+	if err != nil || parsed.User == nil {
+		return arg
+	}
+	if _, ok := parsed.User.Password(); !ok {
+		return arg
+	}
+	return parsed.Redacted()
+}
+
+// Returns the last n entries of the log, oldest first, or every entry if
+// n is 0. Lines that are not valid entries are skipped.
+func ReadLog(n int) ([]LogEntry, error) {
+	logPath, err := LogPath()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(logPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := make([]LogEntry, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry LogEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil {
+			continue
+		}
+		if n > 0 && len(entries) == n {
+			entries = entries[1:]
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// How often FollowLog checks for new entries.
+const followInterval = 500 * time.Millisecond
+
+// Calls fn with each entry written to the log from now on, until ctx is
+// done, following the log when it is rotated.
+func FollowLog(ctx context.Context, fn func(LogEntry)) error {
+	logPath, err := LogPath()
+	if err != nil {
+		return err
+	}
+	var offset int64
+	if info, err := os.Stat(logPath); err == nil {
+		offset = info.Size()
+	}
+
+	ticker := time.NewTicker(followInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(logPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if info.Size() < offset {
+			// Rotated, so the log has started again.
+			offset = 0
+		}
+		if info.Size() == offset {
+			continue
+		}
+
+		contents, err := readFrom(logPath, offset)
+		if err != nil {
+			return err
+		}
+		// Only whole lines are read, the rest is read once it is written.
+		end := bytes.LastIndexByte(contents, '\n') + 1
+		for _, line := range bytes.Split(contents[:end], []byte("\n")) {
+			var entry LogEntry
+			if json.Unmarshal(line, &entry) == nil {
+				fn(entry)
+			}
+		}
+		offset += int64(end)
+	}
+}
+
+// Reads the file from offset to its end.
+func readFrom(filePath string, offset int64) ([]byte, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(file)
+}
@@ -0,0 +1,76 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The file a plugin may ship in its root directory to describe itself to tim.
+const ManifestFileName = "tim-plugin.json"
+
+// A manifest in yaml, which some plugins ship instead of ManifestFileName.
+const ManifestYAMLFileName = "plugin.yaml"
+
+// Optional metadata declared by a plugin in ManifestFileName.
+type PluginManifest struct {
+	// Paths of example tmux config snippets, relative to the plugin directory.
+	Snippets []string `json:"snippets,omitempty" yaml:"snippets,omitempty"`
+
+	// Scripts run when loading the plugin, see PluginSpec.Run.
+	Run []string `json:"run,omitempty" yaml:"run,omitempty"`
+}
+
+// Reads the plugin's manifest, from ManifestFileName or else
+// ManifestYAMLFileName. Returns a nil manifest if the plugin does not
+// ship one.
+func (p *Plugin) Manifest() (*PluginManifest, error) {
+	pluginDir, err := p.Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range []string{ManifestFileName, ManifestYAMLFileName} {
+		contents, err := os.ReadFile(path.Join(pluginDir, name))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		return parseManifest(name, contents)
+	}
+	return nil, nil
+}
+
+// Parses the contents of the manifest file called name.
+func parseManifest(name string, contents []byte) (*PluginManifest, error) {
+	manifest := &PluginManifest{}
+	var err error
+	if name == ManifestYAMLFileName {
+		err = yaml.Unmarshal(contents, manifest)
+	} else {
+		err = json.Unmarshal(contents, manifest)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return manifest, nil
+}
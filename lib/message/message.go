@@ -16,8 +16,11 @@ limitations under the License.
 package message
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"sync/atomic"
 
 	"github.com/fatih/color"
 )
@@ -26,29 +29,119 @@ import (
 // level messages.
 var DebugEnabled bool = false
 
+// Switches all messages to JSON, writing one object per line.
+var JSONEnabled bool = false
+
+// Treats warnings as errors, see WarningCount.
+var StrictEnabled bool = false
+
+// The exit status used when warnings are emitted in strict mode.
+const ExitStrictWarnings = 2
+
+// The exit status of "upgrade --check" when there are updates.
+const ExitUpdatesAvailable = 10
+
+// The exit status of "upgrade --check" when plugins could not be checked.
+const ExitCheckFailed = 11
+
+// Warnings are emitted by plugins operated on concurrently.
+var warningCount atomic.Int64
+
+// Returns the number of warnings emitted so far.
+func WarningCount() int {
+	return int(warningCount.Load())
+}
+
+// Starts counting warnings from zero, such as for another run of tim.
+func ResetWarningCount() {
+	warningCount.Store(0)
+}
+
+// Where all messages are written. This is stdout, unless
+// a pager is running.
+var Output io.Writer = os.Stdout
+
+// Structured information attached to a message. Fields are only
+// shown in JSON mode, so the message itself should still make sense.
+type Fields struct {
+	Plugin  string `json:"plugin,omitempty"`
+	Version string `json:"version,omitempty"`
+
+	// The stable code of the error being reported, if any.
+	Code string `json:"code,omitempty"`
+
+	// Any other machine readable details.
+	Data any `json:"data,omitempty"`
+}
+
+// A message as written in JSON mode.
+type event struct {
+	Level   string `json:"level"`
+	Message string `json:"message"`
+	Fields
+}
+
 // Prints an info level message to the output.
 func Info(format string, a ...any) {
-	// No color, just plain output.
-	fmt.Printf(format+"\n", a...)
+	Fields{}.Info(format, a...)
 }
 
 // Prints a debug level message to the output.
 func Debug(format string, a ...any) {
-	if DebugEnabled {
-		fmt.Printf(color.BlueString("DEBUG ")+format+"\n", a...)
-	}
+	Fields{}.Debug(format, a...)
 }
 
 // Prints a warning level message to the output.
 func Warning(format string, a ...any) {
-	fmt.Printf(color.YellowString("WARNING ")+format+"\n", a...)
+	Fields{}.Warning(format, a...)
 }
 
-// Prints an error level message to the output,
-// quitting with an exit status of 1.
+// Prints an error level message to the output. Commands return their
+// errors instead, which are printed once tim is about to exit.
 func Error(format string, a ...any) {
-	fmt.Printf(color.RedString("ERROR ")+format+"\n", a...)
-	os.Exit(1)
+	Fields{}.Error(format, a...)
+}
+
+// Prints an info level message with fields to the output.
+func (f Fields) Info(format string, a ...any) {
+	// No color, just plain output.
+	f.emit("info", "", format, a...)
+}
+
+// Prints a debug level message with fields to the output.
+func (f Fields) Debug(format string, a ...any) {
+	if DebugEnabled {
+		f.emit("debug", color.BlueString("DEBUG "), format, a...)
+	}
+}
+
+// Prints a warning level message with fields to the output.
+func (f Fields) Warning(format string, a ...any) {
+	warningCount.Add(1)
+	f.emit("warning", color.YellowString("WARNING "), format, a...)
+}
+
+// Prints an error level message with fields to the output.
+func (f Fields) Error(format string, a ...any) {
+	f.emit("error", color.RedString("ERROR "), format, a...)
+}
+
+func (f Fields) emit(level, prefix, format string, a ...any) {
+	if !JSONEnabled {
+		fmt.Fprintf(Output, prefix+format+"\n", a...)
+		return
+	}
+
+	encoded, err := json.Marshal(event{
+		Level:   level,
+		Message: fmt.Sprintf(format, a...),
+		Fields:  f,
+	})
+	if err != nil {
+		// Only possible if Data can't be encoded.
+		encoded, _ = json.Marshal(event{Level: "error", Message: err.Error()})
+	}
+	fmt.Fprintln(Output, string(encoded))
 }
 
 var osc8Escape = string([]byte{'\x1b', ']', '8', ';', ';'})
@@ -0,0 +1,98 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package message
+
+import (
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/mattn/go-isatty"
+)
+
+// Disables the pager, for example with the --no-pager flag.
+var PagerDisabled bool = false
+
+// The running pager, nil if there is none.
+var pager *exec.Cmd
+var pagerInput io.WriteCloser
+
+// Where output went before the pager started.
+var pagedOutput io.Writer
+
+// Pipes all further output through the user's pager, like git does.
+// The pager is $TIM_PAGER or $PAGER, defaulting to less. Nothing
+// happens if the pager is disabled, output is JSON or plain text, or
+// is not to stdout, or stdout is not a terminal.
+//
+// Call StopPager once all output is written.
+func StartPager() {
+	if PagerDisabled || JSONEnabled || PlainEnabled || pager != nil ||
+		Output != io.Writer(os.Stdout) || !isatty.IsTerminal(os.Stdout.Fd()) {
+		return
+	}
+
+	command := os.Getenv("TIM_PAGER")
+	if command == "" {
+		command = os.Getenv("PAGER")
+	}
+	if command == "" {
+		command = "less"
+	}
+	if command == "cat" {
+		return
+	}
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	if os.Getenv("LESS") == "" {
+		// Quit if the output fits on one screen, keep colors, and
+		// don't clear the screen on exit.
+		cmd.Env = append(cmd.Env, "LESS=FRX")
+	}
+
+	input, err := cmd.StdinPipe()
+	if err != nil {
+		Debug("Unable to start pager: %s", err)
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		Debug("Unable to start pager %q: %s", command, err)
+		return
+	}
+
+	pager = cmd
+	pagerInput = input
+	pagedOutput = Output
+	Output = input
+}
+
+// Waits for the user to quit the pager, restoring output to where it was.
+// Does nothing if no pager is running.
+func StopPager() {
+	if pager == nil {
+		return
+	}
+
+	pagerInput.Close()
+	pager.Wait()
+
+	pager = nil
+	pagerInput = nil
+	Output = pagedOutput
+}
@@ -0,0 +1,82 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package message
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Switches all output to plain text, printed line by line, without
+// colors, hyperlinks or anything else that needs control sequences.
+// Enabled when TERM is unset, "dumb", or has no terminfo entry, as in
+// many editor terminals and CI logs.
+var PlainEnabled bool = isDumbTerminal(os.Getenv("TERM"))
+
+func init() {
+	if PlainEnabled {
+		color.NoColor = true
+	}
+}
+
+// Returns true if a terminal of type term can't show control sequences.
+func isDumbTerminal(term string) bool {
+	if term == "" || term == "dumb" {
+		return true
+	}
+	dirs := terminfoDirs()
+	if len(dirs) == 0 {
+		// Without a terminfo database there's no telling, and most
+		// terminals understand the few sequences tim uses.
+		return false
+	}
+	for _, dir := range dirs {
+		// Entries are under their first letter, or its hex code on macOS.
+		for _, sub := range []string{term[:1], fmt.Sprintf("%x", term[0])} {
+			if _, err := os.Stat(path.Join(dir, sub, term)); err == nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Returns the terminfo directories that exist, in the order ncurses
+// searches them.
+func terminfoDirs() []string {
+	candidates := []string{os.Getenv("TERMINFO")}
+	if home, err := os.UserHomeDir(); err == nil {
+		candidates = append(candidates, filepath.Join(home, ".terminfo"))
+	}
+	if dirs := os.Getenv("TERMINFO_DIRS"); dirs != "" {
+		candidates = append(candidates, strings.Split(dirs, ":")...)
+	}
+	candidates = append(candidates, "/etc/terminfo", "/lib/terminfo", "/usr/share/terminfo",
+		"/usr/lib/terminfo", "/usr/local/share/terminfo")
+
+	dirs := make([]string, 0, len(candidates))
+	for _, dir := range candidates {
+		if info, err := os.Stat(dir); dir != "" && err == nil && info.IsDir() {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
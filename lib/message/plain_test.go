@@ -0,0 +1,72 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package message
+
+import (
+	"errors"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestParseChoices(t *testing.T) {
+	tests := []struct {
+		answer  string
+		want    []int
+		wantErr bool
+	}{
+		{"", []int{}, false},
+		{"3 1", []int{0, 2}, false},
+		{"2,2", []int{1}, false},
+		{"all", []int{0, 1, 2}, false},
+		{"4", nil, true},
+		{"x", nil, true},
+	}
+
+	for _, test := range tests {
+		got, err := parseChoices(test.answer, 3)
+		if (err != nil) != test.wantErr || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseChoices(%q) = %v, %v; want %v", test.answer, got, err, test.want)
+		}
+	}
+	if _, err := parseChoices("q", 3); !errors.Is(err, ErrCancelled) {
+		t.Errorf("parseChoices(\"q\") = %v; want %v", err, ErrCancelled)
+	}
+}
+
+func TestIsDumbTerminal(t *testing.T) {
+	terminfo := t.TempDir()
+	t.Setenv("TERMINFO", terminfo)
+	t.Setenv("TERMINFO_DIRS", "")
+	if err := os.MkdirAll(path.Join(terminfo, "x"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path.Join(terminfo, "x", "xterm-known"), nil, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	for term, want := range map[string]bool{
+		"":            true,
+		"dumb":        true,
+		"xterm-known": false,
+		"zz-unknown":  true,
+	} {
+		if got := isDumbTerminal(term); got != want {
+			t.Errorf("isDumbTerminal(%q) = %v; want %v", term, got, want)
+		}
+	}
+}
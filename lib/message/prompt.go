@@ -0,0 +1,48 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package message
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+)
+
+// Returns true if the user can be asked questions, that is stdin is a
+// terminal and output is not JSON.
+func Interactive() bool {
+	return isatty.IsTerminal(os.Stdin.Fd()) && !JSONEnabled
+}
+
+// Asks the user a yes or no question, returning true if they answer yes.
+// Returns false without asking if stdin is not a terminal.
+func Confirm(format string, a ...any) bool {
+	if !isatty.IsTerminal(os.Stdin.Fd()) {
+		return false
+	}
+
+	fmt.Fprintf(Output, format+" [y/N] ", a...)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
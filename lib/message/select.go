@@ -0,0 +1,163 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package message
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+	"golang.org/x/term"
+)
+
+// Returned by Select when there is no terminal to prompt on.
+var ErrNotTerminal = errors.New("an interactive terminal is required")
+
+// Returned by Select when the user cancels the prompt.
+var ErrCancelled = errors.New("cancelled")
+
+// Asks the user to choose any number of options from a list, moving with
+// the arrow keys and toggling with space. Returns the indexes of the
+// chosen options in order.
+func Select(title string, options []string) ([]int, error) {
+	if !isatty.IsTerminal(os.Stdin.Fd()) || !isatty.IsTerminal(os.Stdout.Fd()) || JSONEnabled {
+		return nil, ErrNotTerminal
+	}
+	if PlainEnabled {
+		return selectPlain(title, options, bufio.NewReader(os.Stdin))
+	}
+
+	state, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return nil, err
+	}
+	defer term.Restore(int(os.Stdin.Fd()), state)
+
+	selected := make([]bool, len(options))
+	cursor := 0
+	fmt.Fprintf(Output, "%s\r\n", title)
+	fmt.Fprintf(Output, "  (up/down to move, space to toggle, a to toggle all, enter to confirm, q to cancel)\r\n")
+	drawSelect(options, selected, cursor, false)
+
+	buf := make([]byte, 8)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+
+		switch key := string(buf[:n]); key {
+		case "\x1b[A", "k":
+			cursor = (cursor + len(options) - 1) % len(options)
+		case "\x1b[B", "j":
+			cursor = (cursor + 1) % len(options)
+		case " ":
+			selected[cursor] = !selected[cursor]
+		case "a":
+			all := true
+			for _, s := range selected {
+				all = all && s
+			}
+			for i := range selected {
+				selected[i] = !all
+			}
+		case "\r", "\n":
+			chosen := make([]int, 0)
+			for i, s := range selected {
+				if s {
+					chosen = append(chosen, i)
+				}
+			}
+			return chosen, nil
+		case "q", "\x1b", "\x03":
+			return nil, ErrCancelled
+		}
+		drawSelect(options, selected, cursor, true)
+	}
+}
+
+// Draws the options of Select, first moving over the previous drawing if redraw is true.
+func drawSelect(options []string, selected []bool, cursor int, redraw bool) {
+	if redraw {
+		fmt.Fprintf(Output, "\x1b[%dA", len(options))
+	}
+	for i, option := range options {
+		pointer := " "
+		if i == cursor {
+			pointer = ">"
+		}
+		check := " "
+		if selected[i] {
+			check = "x"
+		}
+		fmt.Fprintf(Output, "\x1b[2K%s [%s] %s\r\n", pointer, check, option)
+	}
+}
+
+// Asks the user to choose options by typing their numbers, for terminals
+// where the options can't be redrawn.
+func selectPlain(title string, options []string, input *bufio.Reader) ([]int, error) {
+	fmt.Fprintln(Output, title)
+	for i, option := range options {
+		fmt.Fprintf(Output, "%3d. %s\n", i+1, option)
+	}
+
+	for {
+		fmt.Fprint(Output, "Numbers to choose, separated by spaces, \"all\", or \"q\" to cancel: ")
+		answer, err := input.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		chosen, err := parseChoices(strings.TrimSpace(answer), len(options))
+		if err == nil || errors.Is(err, ErrCancelled) {
+			return chosen, err
+		}
+		fmt.Fprintln(Output, err)
+	}
+}
+
+// Parses the numbers typed into selectPlain, returning the indexes of
+// the chosen options in order.
+func parseChoices(answer string, count int) ([]int, error) {
+	switch strings.ToLower(answer) {
+	case "q":
+		return nil, ErrCancelled
+	case "all":
+		chosen := make([]int, count)
+		for i := range chosen {
+			chosen[i] = i
+		}
+		return chosen, nil
+	}
+
+	chosen := make([]int, 0)
+	for _, field := range strings.FieldsFunc(answer, func(r rune) bool { return r == ' ' || r == ',' }) {
+		number, err := strconv.Atoi(field)
+		if err != nil || number < 1 || number > count {
+			return nil, fmt.Errorf("%q is not a number from 1 to %d", field, count)
+		}
+		if !slices.Contains(chosen, number-1) {
+			chosen = append(chosen, number-1)
+		}
+	}
+	slices.Sort(chosen)
+	return chosen, nil
+}
@@ -0,0 +1,85 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package message
+
+import (
+	"fmt"
+	"time"
+)
+
+// Shows absolute times in UTC rather than the local timezone,
+// for reproducible logs.
+var UseUTC bool = false
+
+// Formats t for output: as an absolute ISO 8601 timestamp in JSON mode,
+// and relative to now, such as "3 days ago", otherwise.
+func FormatTime(t time.Time) string {
+	if JSONEnabled {
+		return Timestamp(t)
+	}
+	return RelativeTime(t)
+}
+
+// Formats t as an absolute ISO 8601 timestamp, in the local timezone
+// unless UseUTC is set, whatever timezone t is in.
+func Timestamp(t time.Time) string {
+	if UseUTC {
+		t = t.UTC()
+	} else {
+		t = t.Local()
+	}
+	return t.Format(time.RFC3339)
+}
+
+// Formats t relative to now, such as "3 days ago" or "in 2 hours".
+func RelativeTime(t time.Time) string {
+	return relativeTime(t, time.Now())
+}
+
+func relativeTime(t, now time.Time) string {
+	d := now.Sub(t)
+	future := d < 0
+	if future {
+		d = -d
+	}
+
+	if d < time.Minute {
+		return "just now"
+	}
+
+	var amount int
+	var unit string
+	switch {
+	case d < time.Hour:
+		amount, unit = int(d/time.Minute), "minute"
+	case d < 24*time.Hour:
+		amount, unit = int(d/time.Hour), "hour"
+	case d < 30*24*time.Hour:
+		amount, unit = int(d/(24*time.Hour)), "day"
+	case d < 365*24*time.Hour:
+		amount, unit = int(d/(30*24*time.Hour)), "month"
+	default:
+		amount, unit = int(d/(365*24*time.Hour)), "year"
+	}
+	if amount != 1 {
+		unit += "s"
+	}
+
+	if future {
+		return fmt.Sprintf("in %d %s", amount, unit)
+	}
+	return fmt.Sprintf("%d %s ago", amount, unit)
+}
@@ -0,0 +1,59 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package message
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRelativeTime(t *testing.T) {
+	now := time.Date(2024, 10, 19, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		t    time.Time
+		want string
+	}{
+		{now.Add(-10 * time.Second), "just now"},
+		{now.Add(-1 * time.Minute), "1 minute ago"},
+		{now.Add(-3 * time.Hour), "3 hours ago"},
+		{now.Add(-3 * 24 * time.Hour), "3 days ago"},
+		{now.Add(-65 * 24 * time.Hour), "2 months ago"},
+		{now.Add(-800 * 24 * time.Hour), "2 years ago"},
+		{now.Add(2 * time.Hour), "in 2 hours"},
+	}
+
+	for _, test := range tests {
+		if got := relativeTime(test.t, now); got != test.want {
+			t.Errorf("relativeTime(%v) = %q; want %q", test.t, got, test.want)
+		}
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("AEST", 10*60*60)
+	t.Cleanup(func() { time.Local, UseUTC = local, false })
+
+	// Times read from the config file are in UTC.
+	stored := time.Date(2024, 10, 19, 12, 0, 0, 0, time.UTC)
+	if got, want := Timestamp(stored), "2024-10-19T22:00:00+10:00"; got != want {
+		t.Errorf("Timestamp() = %q; want %q", got, want)
+	}
+	UseUTC = true
+	if got, want := Timestamp(stored.In(time.Local)), "2024-10-19T12:00:00Z"; got != want {
+		t.Errorf("Timestamp() with UseUTC = %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,301 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"syscall"
+
+	"github.com/kjnsn/tim/lib/message"
+)
+
+var ErrNewerSchema = errors.New("config file was written by a newer version of tim")
+
+// The schema version of tim's state written by this version of tim.
+// Bump it, and add a Migration, whenever the lockfile format or the
+// layout of tim's directories changes.
+const CurrentSchemaVersion = 4
+
+// Lockfiles without a schema_version are version 1.
+const initialSchemaVersion = 1
+
+// A single step upgrading tim's state to Version. Migrations must be
+// idempotent, as a failed run may be retried from the start.
+type Migration struct {
+	Version     int
+	Description string
+
+	// Modifies the decoded lockfile in place. Any changes outside of the
+	// lockfile must be skipped when state.DryRun is set.
+	Apply func(ctx context.Context, state *MigrationState) error
+}
+
+// The state that migrations operate on.
+type MigrationState struct {
+	// Path to the lockfile being migrated.
+	LockfilePath string
+
+	// The decoded contents of the lockfile.
+	Document map[string]any
+
+	DryRun bool
+}
+
+// All migrations, in order.
+var migrations = []Migration{
+	{
+		Version:     2,
+		Description: "Record plugins as objects instead of version strings",
+		Apply:       migratePluginSpecsToObjects,
+	},
+	{
+		Version:     3,
+		Description: "Record the commits of installed plugins in tim.lock",
+		Apply:       migrateGenerateLock,
+	},
+	{
+		Version:     4,
+		Description: "Move installed plugins from the config directory to xdg-data-home",
+		Apply:       migratePluginsToDataHome,
+	},
+}
+
+// Runs any pending migrations against the lockfile, returning the
+// migrations that were (or with dryRun, would be) applied. A backup of
+// the lockfile is written before it is modified.
+func Migrate(ctx context.Context, cfgOverride string, dryRun bool) ([]Migration, error) {
+	lockPath, err := lockfilePath(cfgOverride)
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := os.ReadFile(lockPath)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && len(contents) == 0) {
+		// First run, the lockfile is created at the current version.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := configJSON(lockPath, contents)
+	if err != nil {
+		return nil, WithErrorCode(CodeLockfileCorrupt, fmt.Errorf("unable to read %s: %w", lockPath, err))
+	}
+	document := make(map[string]any)
+	if err := json.Unmarshal(decoded, &document); err != nil {
+		return nil, WithErrorCode(CodeLockfileCorrupt, fmt.Errorf("unable to read %s: %w", lockPath, err))
+	}
+
+	current := schemaVersion(document)
+	if current > CurrentSchemaVersion {
+		return nil, fmt.Errorf("%w: %s has schema version %d, but this version of tim only understands up to %d",
+			ErrNewerSchema, lockPath, current, CurrentSchemaVersion)
+	}
+
+	pending := make([]Migration, 0)
+	for _, migration := range migrations {
+		if migration.Version > current {
+			pending = append(pending, migration)
+		}
+	}
+	if len(pending) == 0 || dryRun {
+		return pending, nil
+	}
+
+	release, err := acquireProcessLock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	backupPath := fmt.Sprintf("%s.v%d.bak", lockPath, current)
+	if err := os.WriteFile(backupPath, contents, 0600); err != nil {
+		return nil, err
+	}
+
+	state := &MigrationState{
+		LockfilePath: lockPath,
+		Document:     document,
+		DryRun:       dryRun,
+	}
+	for _, migration := range pending {
+		if err := migration.Apply(ctx, state); err != nil {
+			return nil, fmt.Errorf("migration to schema version %d failed, a backup is at %s: %w",
+				migration.Version, backupPath, err)
+		}
+		document["schema_version"] = migration.Version
+	}
+
+	migrated, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	migrated, err = formatConfig(lockPath, contents, append(migrated, '\n'))
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(lockPath, migrated, 0600); err != nil {
+		return nil, err
+	}
+
+	return pending, nil
+}
+
+// Returns the schema version recorded in the decoded lockfile.
+func schemaVersion(document map[string]any) int {
+	version, ok := document["schema_version"].(float64)
+	if !ok {
+		return initialSchemaVersion
+	}
+	return int(version)
+}
+
+// Version 1 stored each plugin as a plain version string.
+func migratePluginSpecsToObjects(ctx context.Context, state *MigrationState) error {
+	plugins, ok := state.Document["plugins"].(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	for name, spec := range plugins {
+		if version, ok := spec.(string); ok {
+			plugins[name] = map[string]any{"version": version}
+		}
+	}
+	return nil
+}
+
+// Version 3 split the resolved commits of plugins out into tim.lock.
+func migrateGenerateLock(ctx context.Context, state *MigrationState) error {
+	if state.DryRun {
+		return nil
+	}
+
+	legacyPluginsDir, err := getLegacyPluginsDir()
+	if err != nil {
+		return err
+	}
+	lockPath := lockPathFor(state.LockfilePath)
+	locked, err := readLock(lockPath)
+	if err != nil {
+		return err
+	}
+
+	plugins, _ := state.Document["plugins"].(map[string]any)
+	for name, spec := range plugins {
+		fields, _ := spec.(map[string]any)
+		version, _ := fields["version"].(string)
+		remote, _ := fields["remote"].(string)
+
+		// Plugins are moved out of the config directory by version 4.
+		plugin := Plugin{Name: name, Remote: remote, Root: legacyPluginsDir}
+		if err := plugin.CheckInstalled(); err != nil {
+			continue
+		}
+		commit, err := plugin.Commit(ctx)
+		if err != nil {
+			// Not a usable clone, "tim add" will fix it.
+			continue
+		}
+
+		locked[name] = LockedPlugin{
+			Ref:    version,
+			Commit: commit,
+			Remote: remote,
+		}
+	}
+
+	return writeLock(lockPath, locked)
+}
+
+// Version 4 moved plugins out of the config directory, where versions
+// before it installed them, into xdg-data-home or plugin_dir.
+func migratePluginsToDataHome(ctx context.Context, state *MigrationState) error {
+	if state.DryRun {
+		return nil
+	}
+
+	legacyDir, err := getLegacyPluginsDir()
+	if err != nil {
+		return err
+	}
+	pluginDir, _ := state.Document["plugin_dir"].(string)
+	pluginsDir, err := resolvePluginDir(pluginDir)
+	if err != nil {
+		return err
+	}
+	if pluginsDir == "" {
+		if pluginsDir, err = defaultPluginsDir(); err != nil {
+			return err
+		}
+	}
+	if pluginsDir == legacyDir {
+		return nil
+	}
+
+	owners, err := os.ReadDir(legacyDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// Plugins are moved one at a time, so that a failed run can be retried.
+	for _, owner := range owners {
+		if !owner.IsDir() {
+			continue
+		}
+		entries, err := os.ReadDir(path.Join(legacyDir, owner.Name()))
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			name := path.Join(owner.Name(), entry.Name())
+			if err := movePlugin(legacyDir, pluginsDir, name); err != nil {
+				return err
+			}
+		}
+		os.Remove(path.Join(legacyDir, owner.Name()))
+	}
+	os.Remove(legacyDir)
+	return nil
+}
+
+// Moves the plugin name from one plugins directory to another, leaving it
+// in place if it is already installed in the other directory.
+func movePlugin(from, to, name string) error {
+	target := path.Join(to, name)
+	if _, err := os.Lstat(target); err == nil {
+		message.Warning("Plugin %s is installed in both %s and %s, leaving the copy in %s", name, from, to, from)
+		return nil
+	}
+	if err := os.MkdirAll(path.Dir(target), 0750); err != nil {
+		return err
+	}
+	err := os.Rename(path.Join(from, name), target)
+	if errors.Is(err, syscall.EXDEV) {
+		return fmt.Errorf("unable to move plugins from %s to %s, as they are on different filesystems. "+
+			"Set \"plugin_dir\" in the config file to %q to keep them where they are: %w", from, to, from, err)
+	}
+	return err
+}
@@ -0,0 +1,110 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	lockPath := path.Join(t.TempDir(), "tim.json")
+	if err := os.WriteFile(lockPath, []byte(`{"plugins": {"user/repo": "v1.0.0"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := Migrate(context.Background(), lockPath, false)
+	if err != nil {
+		t.Fatalf("Migrate() returned error %v", err)
+	}
+	if len(applied) != len(migrations) {
+		t.Errorf("Migrate() applied %d migrations; want %d", len(applied), len(migrations))
+	}
+
+	lockFile, err := ReadLockfile(lockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lockFile.Close()
+	if got := lockFile.SchemaVersion; got != CurrentSchemaVersion {
+		t.Errorf("SchemaVersion = %d; want %d", got, CurrentSchemaVersion)
+	}
+	if got := lockFile.PluginSpecs["user/repo"].Version; got != "v1.0.0" {
+		t.Errorf("PluginSpecs[\"user/repo\"].Version = %q; want v1.0.0", got)
+	}
+
+	// Migrating again is a no-op.
+	applied, err = Migrate(context.Background(), lockPath, false)
+	if err != nil || len(applied) != 0 {
+		t.Errorf("second Migrate() = %v, %v; want no migrations", applied, err)
+	}
+}
+
+func TestMigratePluginsToDataHome(t *testing.T) {
+	configHome := t.TempDir()
+	dataHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("XDG_DATA_HOME", dataHome)
+	legacyDir := path.Join(configHome, "tim/plugins")
+	pluginsDir := path.Join(dataHome, "tim/plugins")
+	for _, dir := range []string{path.Join(legacyDir, "user/moved"), path.Join(legacyDir, "user/both"), path.Join(pluginsDir, "user/both")} {
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Retrying after a failed run moves the rest.
+	for range 2 {
+		state := &MigrationState{Document: map[string]any{}}
+		if err := migratePluginsToDataHome(context.Background(), state); err != nil {
+			t.Fatalf("migratePluginsToDataHome() = %v", err)
+		}
+	}
+	if _, err := os.Stat(path.Join(pluginsDir, "user/moved")); err != nil {
+		t.Errorf("plugin was not moved: %v", err)
+	}
+	if _, err := os.Stat(path.Join(legacyDir, "user/moved")); err == nil {
+		t.Errorf("plugin was left in %s", legacyDir)
+	}
+	if _, err := os.Stat(path.Join(legacyDir, "user/both")); err != nil {
+		t.Errorf("plugin installed in both directories was deleted: %v", err)
+	}
+}
+
+func TestResolvePluginDir(t *testing.T) {
+	t.Setenv("HOME", "/home/user")
+	tests := []struct {
+		dir     string
+		want    string
+		wantErr bool
+	}{
+		{dir: "", want: ""},
+		{dir: "/data/tim/", want: "/data/tim"},
+		{dir: "~/plugins", want: "/home/user/plugins"},
+		{dir: "plugins", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := resolvePluginDir(tt.dir)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("resolvePluginDir(%q) = %q, %v; want %q", tt.dir, got, err, tt.want)
+		}
+	}
+}
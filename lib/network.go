@@ -0,0 +1,231 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// How git reaches remotes, from the network key in the config file.
+type NetworkConfig struct {
+	// Proxy for https remotes, such as "http://proxy.example.com:3128".
+	// The https_proxy environment variable is used if it is empty.
+	HTTPSProxy string `json:"https_proxy,omitempty"`
+
+	// Command run instead of ssh for ssh remotes, as GIT_SSH_COMMAND.
+	// Only used by the exec git backend.
+	SSHCommand string `json:"ssh_command,omitempty"`
+
+	// Credentials for https remotes, by host such as "git.example.com".
+	Credentials map[string]HostCredentials `json:"credentials,omitempty"`
+
+	// How many times cloning and fetching are retried after a transient
+	// failure, DefaultRetries if nil, and how long to wait before the
+	// first retry, such as "1s". The wait doubles after each retry.
+	Retries      *int   `json:"retries,omitempty"`
+	RetryBackoff string `json:"retry_backoff,omitempty"`
+}
+
+// Credentials for the https remotes on a host. Exactly one of Token,
+// TokenEnv and CredentialHelper is set.
+type HostCredentials struct {
+	// Sent with the token, "x-access-token" if empty, as github expects.
+	Username string `json:"username,omitempty"`
+
+	// The token itself, or the environment variable holding it.
+	Token    string `json:"token,omitempty"`
+	TokenEnv string `json:"token_env,omitempty"`
+
+	// Ask the credential helpers configured in git, with "git credential
+	// fill". The exec git backend always does, so this is only needed
+	// by the go-git backend.
+	CredentialHelper bool `json:"credential_helper,omitempty"`
+}
+
+// The network settings used by git, set from the config file.
+var Network NetworkConfig
+
+// Checks the network settings are usable.
+func (n NetworkConfig) validate() error {
+	if n.HTTPSProxy != "" {
+		proxy, err := url.Parse(n.HTTPSProxy)
+		if err != nil || proxy.Host == "" {
+			return fmt.Errorf("https_proxy must be a URL such as \"http://proxy:3128\", not %q", n.HTTPSProxy)
+		}
+	}
+	if n.Retries != nil && *n.Retries < 0 {
+		return fmt.Errorf("retries must not be negative")
+	}
+	if n.RetryBackoff != "" {
+		if _, err := time.ParseDuration(n.RetryBackoff); err != nil {
+			return fmt.Errorf("retry_backoff: %w", err)
+		}
+	}
+	for host, credentials := range n.Credentials {
+		set := 0
+		for _, isSet := range []bool{credentials.Token != "", credentials.TokenEnv != "", credentials.CredentialHelper} {
+			if isSet {
+				set++
+			}
+		}
+		if set != 1 {
+			return fmt.Errorf("credentials for %s must set one of token, token_env or credential_helper", host)
+		}
+	}
+	return nil
+}
+
+// Returns how many times network git operations are retried.
+func (n NetworkConfig) retries() int {
+	if n.Retries == nil {
+		return DefaultRetries
+	}
+	return *n.Retries
+}
+
+// Returns how long to wait before the first retry. The backoff is
+// checked when the config file is read.
+func (n NetworkConfig) retryBackoff() time.Duration {
+	backoff, err := time.ParseDuration(n.RetryBackoff)
+	if n.RetryBackoff == "" || err != nil {
+		return DefaultRetryBackoff
+	}
+	return backoff
+}
+
+// Returns the username and password for the host's https remotes, or
+// empty strings if none are configured.
+func (n NetworkConfig) credentialsFor(ctx context.Context, host string) (string, string, error) {
+	credentials, ok := n.Credentials[host]
+	if !ok {
+		return "", "", nil
+	}
+	username := credentials.Username
+	if username == "" {
+		username = "x-access-token"
+	}
+
+	switch {
+	case credentials.Token != "":
+		return username, credentials.Token, nil
+	case credentials.TokenEnv != "":
+		token := os.Getenv(credentials.TokenEnv)
+		if token == "" {
+			return "", "", WithErrorCode(CodeGitAuth, fmt.Errorf(
+				"the token for %s is read from %s, which is not set", host, credentials.TokenEnv))
+		}
+		return username, token, nil
+	default:
+		return gitCredentialFill(ctx, host)
+	}
+}
+
+// Asks git's credential helpers for the credentials of host, without
+// prompting for them.
+func gitCredentialFill(ctx context.Context, host string) (string, string, error) {
+	cmd := exec.CommandContext(ctx, "git", "credential", "fill")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("protocol=https\nhost=%s\n\n", host))
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	out, err := cmd.Output()
+	if err != nil {
+		return "", "", WithErrorCode(CodeGitAuth, fmt.Errorf("no credentials for %s from git's credential helpers: %w", host, err))
+	}
+
+	var username, password string
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, _ := strings.Cut(line, "=")
+		switch key {
+		case "username":
+			username = value
+		case "password":
+			password = value
+		}
+	}
+	return username, password, nil
+}
+
+// Returns the environment variables that apply the network settings to
+// the git binary contacting remote. Only the credentials of remote's host
+// are looked up. Tokens are given to git by a credential helper reading
+// them from the environment, so they never appear in its arguments.
+func (n NetworkConfig) gitEnv(ctx context.Context, remote string) ([]string, error) {
+	env := make([]string, 0)
+	if n.HTTPSProxy != "" {
+		env = append(env, "https_proxy="+n.HTTPSProxy, "HTTPS_PROXY="+n.HTTPSProxy)
+	}
+	if n.SSHCommand != "" {
+		env = append(env, "GIT_SSH_COMMAND="+n.SSHCommand)
+	}
+
+	// Only https remotes are authenticated.
+	parsed, err := url.Parse(remote)
+	if err != nil || parsed.Scheme != "https" {
+		return env, nil
+	}
+	host := parsed.Hostname()
+	// git asks its own credential helpers.
+	if credentials, ok := n.Credentials[host]; !ok || credentials.CredentialHelper {
+		return env, nil
+	}
+	username, token, err := n.credentialsFor(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	// Added to any config already given in the environment.
+	count, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+	helper := fmt.Sprintf("credential.https://%s.helper", host)
+	return append(env,
+		// An empty helper stops any others configured for host being asked.
+		fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", count, helper),
+		fmt.Sprintf("GIT_CONFIG_VALUE_%d=", count),
+		fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", count+1, helper),
+		fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", count+1,
+			`!f() { test "$1" = get && printf 'username=%s\npassword=%s\n' "$TIM_GIT_USERNAME" "$TIM_GIT_TOKEN"; }; f`),
+		fmt.Sprintf("GIT_CONFIG_COUNT=%d", count+2),
+		"TIM_GIT_USERNAME="+username,
+		"TIM_GIT_TOKEN="+token), nil
+}
+
+// Returns the authentication go-git uses for remote, nil if there is
+// none configured for its host. Only https remotes are authenticated.
+func (n NetworkConfig) goGitAuth(ctx context.Context, remote string) (transport.AuthMethod, error) {
+	parsed, err := url.Parse(remote)
+	if err != nil || parsed.Scheme != "https" {
+		return nil, nil
+	}
+	username, password, err := n.credentialsFor(ctx, parsed.Hostname())
+	if err != nil || password == "" {
+		return nil, err
+	}
+	return &githttp.BasicAuth{Username: username, Password: password}, nil
+}
+
+// Returns the proxy go-git uses, which falls back to the https_proxy
+// environment variable if none is configured.
+func (n NetworkConfig) goGitProxy() transport.ProxyOptions {
+	return transport.ProxyOptions{URL: n.HTTPSProxy}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+func TestNetworkValidate(t *testing.T) {
+	tests := []struct {
+		network NetworkConfig
+		wantErr bool
+	}{
+		{network: NetworkConfig{HTTPSProxy: "http://proxy:3128"}},
+		{network: NetworkConfig{HTTPSProxy: "proxy"}, wantErr: true},
+		{network: NetworkConfig{Credentials: map[string]HostCredentials{"git.example.com": {TokenEnv: "TOKEN"}}}},
+		{network: NetworkConfig{Credentials: map[string]HostCredentials{"git.example.com": {}}}, wantErr: true},
+		{network: NetworkConfig{Credentials: map[string]HostCredentials{
+			"git.example.com": {Token: "secret", CredentialHelper: true},
+		}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := tt.network.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate(%+v) = %v; want error %t", tt.network, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNetworkGitEnvCredentials(t *testing.T) {
+	// Isolated from the credential helpers of whoever runs the test.
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("TEST_TOKEN", "s3cret")
+	network := NetworkConfig{Credentials: map[string]HostCredentials{
+		"git.example.com": {TokenEnv: "TEST_TOKEN"},
+		// Not looked up, so its missing token is no error.
+		"other.example.com": {TokenEnv: "TEST_UNSET_TOKEN"},
+	}}
+
+	env, err := network.gitEnv(context.Background(), "https://git.example.com/user/plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("git", "credential", "fill")
+	cmd.Env = append(append(os.Environ(), "GIT_TERMINAL_PROMPT=0"), env...)
+	cmd.Stdin = strings.NewReader("protocol=https\nhost=git.example.com\n\n")
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("git credential fill = %v", err)
+	}
+	for _, want := range []string{"username=x-access-token", "password=s3cret"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("git credential fill = %q; want %s", out, want)
+		}
+	}
+	for _, variable := range env {
+		if strings.HasPrefix(variable, "GIT_CONFIG_VALUE_") && strings.Contains(variable, "s3cret") {
+			t.Errorf("token is in git's config: %s", variable)
+		}
+	}
+}
+
+func TestNetworkGoGitAuth(t *testing.T) {
+	network := NetworkConfig{Credentials: map[string]HostCredentials{
+		"git.example.com": {Username: "me", Token: "s3cret"},
+	}}
+	tests := []struct {
+		remote string
+		want   bool
+	}{
+		{remote: "https://git.example.com/user/plugin", want: true},
+		{remote: "https://github.com/user/plugin", want: false},
+		{remote: "git@git.example.com:user/plugin", want: false},
+	}
+	for _, tt := range tests {
+		auth, err := network.goGitAuth(context.Background(), tt.remote)
+		if err != nil {
+			t.Fatal(err)
+		}
+		basic, ok := auth.(*githttp.BasicAuth)
+		if ok != tt.want || (ok && (basic.Username != "me" || basic.Password != "s3cret")) {
+			t.Errorf("goGitAuth(%q) = %v; want credentials %t", tt.remote, auth, tt.want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kjnsn/tim/lib/message"
+	"golang.org/x/mod/semver"
+)
+
+// The most releases of a plugin, lines of each release's notes, and
+// commits, included in the notes of an upgrade.
+const (
+	notesReleases     = 5
+	notesExcerptLines = 15
+	notesCommits      = 20
+)
+
+// What changed when a plugin was upgraded.
+type UpgradeNotes struct {
+	Plugin string       `json:"plugin"`
+	From   LockedPlugin `json:"from"`
+	To     LockedPlugin `json:"to"`
+
+	// The plugin's web page, and the page comparing the two versions,
+	// empty if unknown.
+	URL        string `json:"url,omitempty"`
+	CompareURL string `json:"compare_url,omitempty"`
+
+	// The releases made since From, newest first.
+	Releases []ReleaseNotes `json:"releases,omitempty"`
+
+	// The subjects of the commits since From, newest first, when no
+	// release has notes. MoreCommits is the number left out.
+	Commits     []string `json:"commits,omitempty"`
+	MoreCommits int      `json:"more_commits,omitempty"`
+}
+
+// A release of a plugin, and the start of its notes.
+type ReleaseNotes struct {
+	Tag   string `json:"tag"`
+	URL   string `json:"url,omitempty"`
+	Notes string `json:"notes,omitempty"`
+
+	// Whether Notes is only the start of the release's notes.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Gathers what changed in the installed plugin since it was at from.
+// Release notes are fetched from github for plugins hosted there. Nothing
+// here is essential, so anything that can't be found is left out.
+func (p *Plugin) UpgradeNotes(ctx context.Context, from, to LockedPlugin) UpgradeNotes {
+	notes := UpgradeNotes{Plugin: p.Name, From: from, To: to, URL: p.WebURL()}
+	if notes.URL != "" {
+		if semver.IsValid(from.Ref) && semver.IsValid(to.Ref) {
+			notes.CompareURL = p.CompareURL(from.Ref, to.Ref)
+		} else if from.Commit != "" {
+			notes.CompareURL = p.CompareURL(from.Commit, to.Commit)
+		}
+	}
+
+	pluginDir, err := p.RepoDir()
+	if err != nil {
+		return notes
+	}
+	tags, err := Git.Tags(ctx, pluginDir)
+	if err != nil {
+		message.Debug("Unable to list the tags of %s: %s", p.Name, err)
+	}
+	hasNotes := false
+	for _, tag := range releasesBetween(tags, from.Ref, to.Ref) {
+		release := ReleaseNotes{Tag: tag}
+		if notes.URL != "" {
+			release.URL = p.TagURL(tag)
+		}
+		if body, err := p.githubReleaseNotes(ctx, tag); err != nil {
+			message.Debug("Unable to fetch the notes of %s %s: %s", p.Name, tag, err)
+		} else {
+			release.Notes, release.Truncated = excerpt(body, notesExcerptLines)
+			hasNotes = hasNotes || release.Notes != ""
+		}
+		notes.Releases = append(notes.Releases, release)
+	}
+	if hasNotes || from.Commit == "" {
+		return notes
+	}
+
+	notes.Commits, err = Git.Log(ctx, pluginDir, from.Commit, to.Commit)
+	if err != nil {
+		message.Debug("Unable to list the commits of %s: %s", p.Name, err)
+		return notes
+	}
+	if len(notes.Commits) > notesCommits {
+		notes.MoreCommits = len(notes.Commits) - notesCommits
+		notes.Commits = notes.Commits[:notesCommits]
+	}
+	return notes
+}
+
+// Returns the semver tags after from, up to and including to, newest
+// first, limited to notesReleases of them.
+func releasesBetween(tags []string, from, to string) []string {
+	if !semver.IsValid(from) || !semver.IsValid(to) {
+		return nil
+	}
+	releases := make([]string, 0)
+	for _, tag := range tags {
+		if semver.IsValid(tag) && semver.Compare(tag, from) > 0 && semver.Compare(tag, to) <= 0 {
+			releases = append(releases, tag)
+		}
+	}
+	slices.SortFunc(releases, func(a, b string) int { return semver.Compare(b, a) })
+	if len(releases) > notesReleases {
+		releases = releases[:notesReleases]
+	}
+	return releases
+}
+
+// Returns the notes of the plugin's github release for tag, or an empty
+// string for plugins not hosted on github.
+func (p *Plugin) githubReleaseNotes(ctx context.Context, tag string) (string, error) {
+	repo, ok := p.githubRepo()
+	if !ok {
+		return "", nil
+	}
+	body, err := githubAPIGet(ctx, "/repos/"+repo+"/releases/tags/"+url.PathEscape(tag),
+		"application/vnd.github+json")
+	if err != nil {
+		return "", err
+	}
+	var release struct {
+		Body string `json:"body"`
+	}
+	if err := json.Unmarshal(body, &release); err != nil {
+		return "", err
+	}
+	return release.Body, nil
+}
+
+// Returns the first lines of text, trimmed, and whether any were left out.
+func excerpt(text string, lines int) (string, bool) {
+	all := strings.Split(strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n")), "\n")
+	if len(all) <= lines {
+		return strings.Join(all, "\n"), false
+	}
+	return strings.TrimSpace(strings.Join(all[:lines], "\n")), true
+}
+
+// Returns a markdown report of the plugins upgraded, and those that failed
+// to upgrade, at the given time. It is meant to be committed alongside
+// dotfiles, or posted somewhere the team will read it.
+func UpgradeReport(upgraded []UpgradeNotes, failed []string, at time.Time) string {
+	var report strings.Builder
+	fmt.Fprintf(&report, "# tmux plugins upgraded on %s\n\n", at.Format(time.DateOnly))
+	switch len(upgraded) {
+	case 0:
+		report.WriteString("No plugins were upgraded.\n")
+	case 1:
+		report.WriteString("1 plugin was upgraded.\n")
+	default:
+		fmt.Fprintf(&report, "%d plugins were upgraded.\n", len(upgraded))
+	}
+	if len(failed) > 0 {
+		fmt.Fprintf(&report, "\nFailed to upgrade: %s.\n", strings.Join(failed, ", "))
+	}
+
+	for _, notes := range upgraded {
+		report.WriteString("\n## ")
+		report.WriteString(markdownLink(notes.Plugin, notes.URL))
+		fmt.Fprintf(&report, "\n\n%s → %s", describeLocked(notes.From), describeLocked(notes.To))
+		if notes.CompareURL != "" {
+			fmt.Fprintf(&report, " ([changes](%s))", notes.CompareURL)
+		}
+		report.WriteString("\n")
+
+		for _, release := range notes.Releases {
+			fmt.Fprintf(&report, "\n### %s\n", markdownLink(release.Tag, release.URL))
+			if release.Notes == "" {
+				continue
+			}
+			report.WriteString("\n")
+			for _, line := range strings.Split(release.Notes, "\n") {
+				report.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+			}
+			if release.Truncated {
+				report.WriteString(">\n> …\n")
+			}
+		}
+
+		if len(notes.Commits) > 0 {
+			report.WriteString("\n")
+			for _, commit := range notes.Commits {
+				fmt.Fprintf(&report, "- %s\n", commit)
+			}
+			if notes.MoreCommits > 0 {
+				fmt.Fprintf(&report, "- and %d more\n", notes.MoreCommits)
+			}
+		}
+	}
+	return report.String()
+}
+
+// Describes a locked version as its ref, with the commit for branches.
+func describeLocked(locked LockedPlugin) string {
+	switch {
+	case locked.Ref == "":
+		return fmt.Sprintf("`%.10s`", locked.Commit)
+	case locked.Commit == "" || semver.IsValid(locked.Ref):
+		return "`" + locked.Ref + "`"
+	default:
+		return fmt.Sprintf("`%s` (`%.10s`)", locked.Ref, locked.Commit)
+	}
+}
+
+func markdownLink(text, url string) string {
+	if url == "" {
+		return text
+	}
+	return "[" + text + "](" + url + ")"
+}
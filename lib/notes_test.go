@@ -0,0 +1,98 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReleasesBetween(t *testing.T) {
+	tags := []string{"v1.0.0", "v1.1.0", "nightly", "v1.2.0-rc.1", "v1.2.0", "v2.0.0"}
+	tests := []struct {
+		from, to string
+		want     []string
+	}{
+		{"v1.0.0", "v1.2.0", []string{"v1.2.0", "v1.2.0-rc.1", "v1.1.0"}},
+		{"v1.2.0", "v2.0.0", []string{"v2.0.0"}},
+		{"v1.0.0", "v1.0.0", []string{}},
+		{"main", "v1.1.0", nil},
+	}
+	for _, test := range tests {
+		if got := releasesBetween(tags, test.from, test.to); !slices.Equal(got, test.want) {
+			t.Errorf("releasesBetween(%q, %q) = %v; want %v", test.from, test.to, got, test.want)
+		}
+	}
+}
+
+func TestUpgradeReport(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/user/plugin/releases/tags/v1.1.0" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"body": "## Fixes\r\n\r\n- Works with tmux 3.4"}`))
+	}))
+	defer server.Close()
+	setGithubAPIURL(t, server.URL)
+
+	plugin := Plugin{Name: "user/plugin"}
+	notes, err := plugin.githubReleaseNotes(context.Background(), "v1.1.0")
+	if err != nil {
+		t.Fatalf("githubReleaseNotes() = %v", err)
+	}
+	excerpt, truncated := excerpt(notes, 2)
+
+	upgraded := []UpgradeNotes{{
+		Plugin:     plugin.Name,
+		From:       LockedPlugin{Ref: "v1.0.0", Commit: "aaaa"},
+		To:         LockedPlugin{Ref: "v1.1.0", Commit: "bbbb"},
+		URL:        plugin.WebURL(),
+		CompareURL: plugin.CompareURL("v1.0.0", "v1.1.0"),
+		Releases: []ReleaseNotes{
+			{Tag: "v1.1.0", URL: plugin.TagURL("v1.1.0"), Notes: excerpt, Truncated: truncated},
+		},
+	}}
+	report := UpgradeReport(upgraded, []string{"other/plugin"}, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	want := `# tmux plugins upgraded on 2024-05-01
+
+1 plugin was upgraded.
+
+Failed to upgrade: other/plugin.
+
+## [user/plugin](https://github.com/user/plugin)
+
+` + "`v1.0.0` → `v1.1.0`" + ` ([changes](https://github.com/user/plugin/compare/v1.0.0...v1.1.0))
+
+### [v1.1.0](https://github.com/user/plugin/releases/tag/v1.1.0)
+
+> ## Fixes
+>
+> …
+`
+	if report != want {
+		t.Errorf("UpgradeReport() =\n%s\nwant\n%s", report, want)
+	}
+	if !strings.Contains(UpgradeReport(nil, nil, time.Now()), "No plugins were upgraded.") {
+		t.Errorf("UpgradeReport() of nothing doesn't say so")
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// Where tim keeps its files, so that scripts can find them.
+type Paths struct {
+	TimDir     string `json:"tim_dir"`
+	PluginsDir string `json:"plugins_dir"`
+	Config     string `json:"config"`
+	Lock       string `json:"lock"`
+
+	// The tmux config file in use, empty if there is none.
+	TmuxConfig string `json:"tmux_conf,omitempty"`
+
+	// Where state, caches and logs are kept.
+	StateDir string `json:"state_dir"`
+}
+
+// Resolves the paths tim uses, reading only plugin_dir from the config
+// file. The config file is configOverride, if it is set.
+func GetPaths(configOverride string) (*Paths, error) {
+	timDir, err := GetTimDir()
+	if err != nil {
+		return nil, err
+	}
+	configPath, err := lockfilePath(configOverride)
+	if err != nil {
+		return nil, err
+	}
+	if err := readPluginDir(configPath); err != nil {
+		return nil, err
+	}
+	pluginsDir, err := GetPluginsDir()
+	if err != nil {
+		return nil, err
+	}
+	stateDir, err := GetStateDir()
+	if err != nil {
+		return nil, err
+	}
+	tmuxConfig, err := GetTmuxConfigPath()
+	if err != nil && !errors.Is(err, ErrNoTmuxConfig) {
+		return nil, err
+	}
+
+	return &Paths{
+		TimDir:     timDir,
+		PluginsDir: pluginsDir,
+		Config:     configPath,
+		Lock:       lockPathFor(configPath),
+		TmuxConfig: tmuxConfig,
+		StateDir:   stateDir,
+	}, nil
+}
+
+// Sets PluginsDir from plugin_dir in the config file at configPath, if
+// the config file exists, or from SetPluginDirOverride.
+func readPluginDir(configPath string) error {
+	var config struct {
+		PluginDir string `json:"plugin_dir"`
+	}
+	contents, err := os.ReadFile(configPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if pluginDirOverride == "" && len(contents) > 0 {
+		decoded, err := configJSON(configPath, contents)
+		if err != nil {
+			return WithErrorCode(CodeLockfileCorrupt, fmt.Errorf("unable to read %s: %w", configPath, err))
+		}
+		if err := json.Unmarshal(decoded, &config); err != nil {
+			return WithErrorCode(CodeLockfileCorrupt, fmt.Errorf("unable to read %s: %w", configPath, err))
+		}
+	}
+	pluginDir, err := resolvePluginDir(config.PluginDir)
+	if err != nil {
+		return fmt.Errorf("invalid plugin_dir in %s: %w", configPath, err)
+	}
+	PluginsDir = cmp.Or(pluginDirOverride, pluginDir)
+	return nil
+}
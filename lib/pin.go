@@ -0,0 +1,70 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import "time"
+
+// A plugin pinned to an exact commit, by a snapshot or a team manifest.
+type Pin struct {
+	// The version spec of the plugin in the config file.
+	Ref string
+
+	// The full hash of the commit to check out.
+	Commit string
+
+	// URL to clone the plugin from, empty for the default github remote.
+	Remote string
+
+	Options map[string]string
+
+	// Keep the environment variables in the config file rather than
+	// setting them to Env, for pins that don't carry any.
+	KeepEnv bool
+	Env     map[string]string
+}
+
+// Returns the plugin as it is once pinned, without changing the config
+// file: its existing settings, with the version, remote, options and
+// environment of the pin.
+func (lf *Lockfile) PinnedPlugin(name string, pin Pin) Plugin {
+	return lf.plugin(name, lf.pinnedSpec(name, pin))
+}
+
+// Sets the plugin in the config file, and its commit in tim.lock, to
+// the pin. Other settings of the plugin are kept.
+func (lf *Lockfile) ApplyPin(name string, pin Pin) {
+	lf.PluginSpecs[name] = lf.pinnedSpec(name, pin)
+
+	locked, ok := lf.Locked[name]
+	if ok && locked.Commit != pin.Commit {
+		lf.rememberReplaced(name, locked)
+	}
+	if locked.UpdatedAt == nil || locked.Commit != pin.Commit {
+		now := time.Now().UTC().Truncate(time.Second)
+		locked.UpdatedAt = &now
+	}
+	locked.Ref, locked.Commit, locked.Remote = pin.Ref, pin.Commit, pin.Remote
+	lf.Locked[name] = locked
+}
+
+func (lf *Lockfile) pinnedSpec(name string, pin Pin) PluginSpec {
+	spec := lf.PluginSpecs[name]
+	spec.Version, spec.Remote, spec.Options = pin.Ref, pin.Remote, pin.Options
+	if !pin.KeepEnv {
+		spec.Env = pin.Env
+	}
+	return spec
+}
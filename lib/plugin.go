@@ -16,21 +16,59 @@ limitations under the License.
 package lib
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"io/fs"
+	"maps"
 	"os"
 	"os/exec"
 	"path"
+	"slices"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/kjnsn/tim/lib/message"
 )
 
 var ErrPluginNotInstalled = errors.New("Plugin not installed")
 
+var ErrDirtyWorktree = errors.New("plugin has local changes")
+
+// What upgrading does with local changes to a plugin.
+type LocalChanges int
+
+const (
+	// Refuse to upgrade the plugin, the default.
+	RefuseLocalChanges LocalChanges = iota
+	// Overwrite them.
+	DiscardLocalChanges
+	// Stash them with git first.
+	StashLocalChanges
+)
+
+// What upgrading does with local changes, set by the upgrade command.
+var LocalChangesMode = RefuseLocalChanges
+
+// Where the output of commands tim runs is written, such as plugin
+// scripts and git's progress.
+var (
+	Stdout io.Writer = os.Stdout
+	Stderr io.Writer = os.Stderr
+)
+
+// The longest each of a plugin's scripts may run when loading it, zero
+// for no limit. Set from the timeouts key in the config file.
+var ScriptTimeout = DefaultScriptTimeout
+
+// The default ScriptTimeout, so a hung script cannot stop tmux starting.
+const DefaultScriptTimeout = 30 * time.Second
+
 // Gets the tim directory, creating it if it does not already exist.
-// The tim directory is inside xdg-config-home, usually "~/.config".
-// Directories ~/.config/tim and ~/.config/tim/plugins are created.
+// The tim directory is inside xdg-config-home, usually "~/.config", and
+// holds the config file.
 func GetTimDir() (string, error) {
 	configDir, err := os.UserConfigDir()
 	if err != nil {
@@ -48,24 +86,89 @@ func GetTimDir() (string, error) {
 	}
 
 	timDir := path.Join(configDir, "/tim")
+	if err := os.MkdirAll(timDir, 0750); err != nil {
+		return "", err
+	}
+	return timDir, nil
+}
+
+// The directory plugins are installed in, set from the plugin_dir key in
+// the config file. Empty for the default, see GetPluginsDir.
+var PluginsDir = ""
+
+// Used instead of the plugin_dir key in the config file if it is set,
+// see SetPluginDirOverride.
+var pluginDirOverride = ""
 
-	pluginDir := path.Join(timDir, "/plugins")
-	err = os.MkdirAll(pluginDir, 0750)
+// Installs plugins in dir, whatever plugin_dir in the config file is set
+// to. An empty dir clears the override.
+func SetPluginDirOverride(dir string) error {
+	resolved, err := resolvePluginDir(dir)
 	if err != nil {
+		return err
+	}
+	pluginDirOverride = resolved
+	PluginsDir = resolved
+	return nil
+}
+
+// Returns the plugins install directory, creating it if it does not
+// already exist. This is PluginsDir if set, otherwise "tim/plugins" in
+// xdg-data-home, usually "~/.local/share", as plugins are data rather
+// than configuration.
+func GetPluginsDir() (string, error) {
+	pluginsDir := PluginsDir
+	if pluginsDir == "" {
+		var err error
+		pluginsDir, err = defaultPluginsDir()
+		if err != nil {
+			return "", err
+		}
+	}
+	if err := os.MkdirAll(pluginsDir, 0750); err != nil {
 		return "", err
 	}
+	return pluginsDir, nil
+}
 
-	return timDir, nil
+func defaultPluginsDir() (string, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dataHome = path.Join(homeDir, ".local/share")
+	}
+	return path.Join(dataHome, "tim/plugins"), nil
 }
 
-// Returns the plugins install directory. This is `GetTimDir() + "/plugins"`
-func GetPluginsDir() (string, error) {
+// Returns where versions of tim before schema version 4 installed plugins.
+func getLegacyPluginsDir() (string, error) {
 	timDir, err := GetTimDir()
 	if err != nil {
 		return "", err
 	}
+	return path.Join(timDir, "plugins"), nil
+}
 
-	return path.Join(timDir, "/plugins"), nil
+// Returns the absolute directory named by plugin_dir in the config file,
+// which may start with "~/", or an empty string if it is unset.
+func resolvePluginDir(dir string) (string, error) {
+	if dir == "" {
+		return "", nil
+	}
+	if dir == "~" || strings.HasPrefix(dir, "~/") {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = homeDir + dir[1:]
+	}
+	if !path.IsAbs(dir) {
+		return "", fmt.Errorf("must be an absolute path, not %q", dir)
+	}
+	return path.Clean(dir), nil
 }
 
 type Plugin struct {
@@ -74,49 +177,259 @@ type Plugin struct {
 
 	// Semantic version of the plugin as currently installed.
 	Version Version
+
+	// URL to clone the plugin from. Empty for plugins using the
+	// default github remote.
+	Remote string
+
+	// Directory the plugin is loaded from, for plugins being developed
+	// locally. Empty for plugins cloned from Remote.
+	Path string
+
+	// Directory the plugin is installed under, instead of the
+	// plugins directory.
+	Root string
+
+	// Environment variables set when loading the plugin, see PluginSpec.
+	Env map[string]string
+
+	// Global tmux options set before loading the plugin.
+	Options map[string]string
+
+	// Whether versions must be signed before they are checked out, and
+	// the keys trusted to sign them, see PluginSpec.
+	VerifySignatures bool
+	TrustedKeys      []string
+
+	// Whether the plugin is skipped when loading, see PluginSpec.
+	Disabled bool
+
+	// Whether the plugin is in one of the config file's plugin_roots, so
+	// is loaded but never installed, upgraded or removed by tim.
+	Unmanaged bool
+
+	// Scripts run when loading the plugin instead of those found, see
+	// PluginSpec.
+	Run []string
+
+	// The longest each script may run, or nil for ScriptTimeout.
+	ScriptTimeout *time.Duration
+
+	// Which pre-releases are installed when no version is given.
+	Channel Channel
+
+	// Which tags are releases, nil for those that are semantic versions.
+	TagPattern *TagPattern
+
+	// How the plugin is upgraded, empty if the config file does not say.
+	UpgradePolicy UpgradePolicy
 }
 
-// Loads the plugin by running all of it's scripts.
-func (p *Plugin) Load() error {
-	pluginDir, err := p.Dir()
+// Returns the lockfile entry describing this plugin.
+func (p *Plugin) Spec() PluginSpec {
+	spec := PluginSpec{Remote: p.Remote}
+	if p.Version != nil {
+		spec.Version = p.Version.GitRef()
+	}
+	if p.IsLocal() {
+		spec.Version, spec.Path = LocalVersionSpec, p.Path
+	}
+	return spec
+}
+
+// Loads the plugin by setting its tmux options, then running all of it's
+// scripts. Plugins that the policy forbids are not loaded.
+func (p *Plugin) Load(ctx context.Context) error {
+	return p.LoadInto(ctx, TmuxTarget{})
+}
+
+// Loads the plugin into target: its options are set in the target, and
+// its scripts run with TMUX_PANE set to the active pane of the target,
+// so the tmux commands they run apply to it.
+func (p *Plugin) LoadInto(ctx context.Context, target TmuxTarget) error {
+	entrypoints, env, err := p.prepareLoad(ctx, target)
 	if err != nil {
 		return err
 	}
+	for _, entrypoint := range entrypoints {
+		if err := runScript(ctx, []string{entrypoint}, env, p.scriptTimeout()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	dir := os.DirFS(pluginDir)
-	entries, err := fs.ReadDir(dir, ".")
+// Runs just one of the plugin's scripts, as loading it would, after
+// setting its options. script is the path of the script in the plugin's
+// directory, or its name if no other script has it. With trace, shell
+// scripts print each command they run.
+func (p *Plugin) RunScript(ctx context.Context, script string, trace bool) error {
+	entrypoints, env, err := p.prepareLoad(ctx, TmuxTarget{})
+	if err != nil {
+		return err
+	}
+	entrypoint, err := p.findScript(entrypoints, script)
 	if err != nil {
 		return err
 	}
 
-	for _, entry := range entries {
-		if strings.HasSuffix(entry.Name(), ".tmux") && entry.Type().IsRegular() {
-			cmd := exec.Command(path.Join(pluginDir, entry.Name()))
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			err = cmd.Run()
-			if err != nil {
-				return err
-			}
+	command := []string{entrypoint}
+	if trace {
+		interpreter, err := ScriptInterpreter(entrypoint)
+		if err != nil {
+			return err
+		}
+		if traced := traceCommand(interpreter, entrypoint); traced != nil {
+			command = traced
+		} else {
+			message.Warning("Only shell scripts can be traced, %s is run by %s", script, interpreter)
 		}
 	}
+	message.Debug("Running %s", strings.Join(command, " "))
+	return runScript(ctx, command, env, p.scriptTimeout())
+}
 
+// Returns the command running the shell script at entrypoint, which is
+// run by interpreter, so that it prints each command it runs. Returns
+// nil if it isn't a shell script.
+func traceCommand(interpreter, entrypoint string) []string {
+	shell := strings.Fields(interpreter)
+	if len(shell) == 0 {
+		return nil
+	}
+	// "#!/usr/bin/env bash" finds the shell on PATH.
+	if len(shell) > 1 && path.Base(shell[0]) == "env" {
+		shell = shell[:2]
+	} else {
+		shell = shell[:1]
+	}
+	switch path.Base(shell[len(shell)-1]) {
+	case "sh", "bash", "dash", "zsh", "ksh":
+		return slices.Concat(shell, []string{"-x", entrypoint})
+	}
 	return nil
 }
 
-// Returns the absolute path to this plugin's directory.
-func (p *Plugin) Dir() (string, error) {
-	pluginsDir, err := GetPluginsDir()
+// Returns the entrypoint named script, by its path in the plugin's
+// directory or its name.
+func (p *Plugin) findScript(entrypoints []string, script string) (string, error) {
+	pluginDir, err := p.Dir()
 	if err != nil {
 		return "", err
 	}
+	matches := make([]string, 0)
+	for _, entrypoint := range entrypoints {
+		if entrypoint == path.Join(pluginDir, script) {
+			return entrypoint, nil
+		}
+		if path.Base(entrypoint) == script {
+			matches = append(matches, entrypoint)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("plugin %s has no script %s", p.Name, script)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("plugin %s has more than one script named %s, give its path instead", p.Name, script)
+	}
+}
+
+// Checks the plugin may be loaded into target and sets its options there,
+// returning the scripts to run and their environment.
+func (p *Plugin) prepareLoad(ctx context.Context, target TmuxTarget) ([]string, []string, error) {
+	policy, err := GetPolicy()
+	if err != nil {
+		return nil, nil, err
+	}
+	// Unmanaged plugins are checked too, as anyone can add a plugin root.
+	if err := policy.CheckInstall(p); err != nil {
+		return nil, nil, err
+	}
+
+	entrypoints, err := p.Entrypoints()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(entrypoints) > 0 {
+		if err := policy.CheckScripts(p); err != nil {
+			return nil, nil, err
+		}
+	}
+	env, err := p.Environ(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !target.IsGlobal() {
+		pane, err := target.Pane()
+		if err != nil {
+			return nil, nil, err
+		}
+		env = append(env, "TMUX_PANE="+pane)
+	}
+
+	if len(p.Options) > 0 {
+		batch := TmuxBatch{}
+		for _, name := range slices.Sorted(maps.Keys(p.Options)) {
+			batch.SetOptionIn(target, name, p.Options[name])
+		}
+		if err := batch.Run(); err != nil {
+			return nil, nil, fmt.Errorf("unable to set options: %w", err)
+		}
+	}
+	return entrypoints, env, nil
+}
+
+// Returns the longest each of the plugin's scripts may run.
+func (p *Plugin) scriptTimeout() time.Duration {
+	if p.ScriptTimeout != nil {
+		return *p.ScriptTimeout
+	}
+	return ScriptTimeout
+}
+
+// Runs a plugin script, killing it and every process it started after
+// timeout, unless timeout is zero. command is the script, after any
+// interpreter to run it with.
+func runScript(ctx context.Context, command []string, env []string, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 
-	return path.Join(pluginsDir, p.Name), nil
+	script := command[len(command)-1]
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Env = env
+	cmd.Stdout = Stdout
+	cmd.Stderr = Stderr
+	// The script runs in its own process group, so that processes it
+	// started are killed with it rather than left hanging.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	err := cmd.Run()
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return WithErrorCode(CodeTimeout, fmt.Errorf("%s timed out after %s", path.Base(script), timeout))
+	}
+	return err
+}
+
+// Returns the absolute path to this plugin's directory, which is a
+// subdirectory of RepoDir for plugins in one.
+func (p *Plugin) Dir() (string, error) {
+	repoDir, err := p.RepoDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(repoDir, p.Subdir()), nil
 }
 
 // Checks that the given plugin is installed. Returns a nil error if successful.
 func (p *Plugin) CheckInstalled() error {
-	pluginDir, err := p.Dir()
+	pluginDir, err := p.RepoDir()
 	if err != nil {
 		return err
 	}
@@ -132,9 +445,23 @@ func (p *Plugin) CheckInstalled() error {
 }
 
 // Installs the given plugin with git, overwriting any existing configuration.
-// Uses the given version spec to install at the provided version.
-func (p *Plugin) Install(versionSpec string) error {
-	pluginDir, err := p.Dir()
+// Uses the given version spec to install at the provided version. Errors
+// are PluginErrors.
+func (p *Plugin) Install(ctx context.Context, versionSpec string) error {
+	return p.wrapError(p.install(ctx, versionSpec))
+}
+
+func (p *Plugin) install(ctx context.Context, versionSpec string) error {
+	if err := checkInstallPolicy(p); err != nil {
+		return err
+	}
+	if p.IsLocal() {
+		return p.linkLocal()
+	}
+	ctx = withProgressPlugin(ctx, p.Name)
+	defer finishProgress(ctx)
+
+	pluginDir, err := p.RepoDir()
 	if err != nil {
 		return err
 	}
@@ -148,23 +475,39 @@ func (p *Plugin) Install(versionSpec string) error {
 		}
 	}
 
-	if !pluginExistsOnFilesystem {
+	// Check the version exists before cloning, unless it is already here.
+	ref := ""
+	if p.Version != nil {
+		ref = p.Version.GitRef()
+	} else if versionSpec != "" {
+		ref = versionFromSpec(versionSpec, p.Channel, p.tagPattern()).GitRef()
+	}
+	if ref != "" && !(pluginExistsOnFilesystem && HasCommit(ctx, pluginDir, ref)) {
+		if err := checkRemoteRef(ctx, p.RemoteURL(), ref); err != nil {
+			return err
+		}
+	}
+
+	cloned := false
+	if !pluginExistsOnFilesystem || !HasCheckout(ctx, pluginDir) {
 		message.Debug("Cloning %s to %s", p.Name, pluginDir)
 		if err := os.MkdirAll(pluginDir, 0750); err != nil {
 			return err
 		}
-		if _, err := RunGitCommand(pluginDir, "clone", "https://github.com/"+p.Name+".git", pluginDir); err != nil {
+		if err := Clone(ctx, pluginDir, p.RemoteURL()); err != nil {
 			return err
 		}
+		p.sparseCheckout(ctx, pluginDir)
+		cloned = true
 	} else {
 		message.Debug("Plugin %s already exists at %s, not cloning", p.Name, pluginDir)
 	}
 
 	if p.Version == nil {
 		if versionSpec != "" {
-			p.Version = VersionFromSpec(versionSpec)
+			p.Version = versionFromSpec(versionSpec, p.Channel, p.tagPattern())
 		} else {
-			bestVersion, err := FindBestVersion(pluginDir)
+			bestVersion, err := FindBestVersion(ctx, pluginDir, p.Channel, p.tagPattern())
 			if err != nil {
 				return err
 			}
@@ -174,26 +517,84 @@ func (p *Plugin) Install(versionSpec string) error {
 	}
 
 	if p.Version != nil {
-		return p.CheckoutVersion(p.Version)
+		if err := p.discardUnsigned(p.CheckoutVersion(ctx, p.Version), cloned); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return p.checkSubdir()
 }
 
 // Checks out the given version.
-func (p *Plugin) CheckoutVersion(version Version) error {
-	pluginDir, err := p.Dir()
+func (p *Plugin) CheckoutVersion(ctx context.Context, version Version) error {
+	pluginDir, err := p.RepoDir()
 	if err != nil {
 		return err
 	}
+	if err := p.verifySignature(ctx, pluginDir, versionRef(version)); err != nil {
+		return err
+	}
 
-	_, err = RunGitCommand(pluginDir, "checkout", "-q", version.GitRef())
-	return err
+	return Checkout(ctx, pluginDir, version.GitRef(), false)
+}
+
+// Upgrades the installed plugin to version. Errors are PluginErrors.
+func (p *Plugin) Upgrade(ctx context.Context, version Version) error {
+	return p.wrapError(p.upgrade(ctx, version))
+}
+
+func (p *Plugin) upgrade(ctx context.Context, version Version) error {
+	ctx = withProgressPlugin(ctx, p.Name)
+	defer finishProgress(ctx)
+
+	pluginDir, err := p.RepoDir()
+	if err != nil {
+		return err
+	}
+	if p.VerifySignatures {
+		// The new version must be fetched to check its signature.
+		if err := FetchTags(ctx, pluginDir); err != nil {
+			return err
+		}
+		if err := p.verifySignature(ctx, pluginDir, versionRef(version)); err != nil {
+			return err
+		}
+	}
+	if err := p.checkLocalChanges(ctx, pluginDir, version); err != nil {
+		return err
+	}
+	return version.Upgrade(ctx, pluginDir)
+}
+
+// Handles local changes to the plugin, which upgrading would overwrite,
+// as LocalChangesMode says. Untracked files are kept by upgrading, so
+// only changes to files git tracks count.
+func (p *Plugin) checkLocalChanges(ctx context.Context, pluginDir string, version Version) error {
+	status, err := Git.Status(ctx, pluginDir)
+	if err != nil || len(status.Modified) == 0 {
+		return err
+	}
+	switch LocalChangesMode {
+	case DiscardLocalChanges:
+		message.Fields{Plugin: p.Name}.Warning("Discarding local changes to plugin %s", p.Name)
+		return nil
+	case StashLocalChanges:
+		stash := fmt.Sprintf("tim: local changes before upgrading to %s", version)
+		if err := Git.Stash(ctx, pluginDir, stash); err != nil {
+			return err
+		}
+		message.Fields{Plugin: p.Name}.Info(
+			"Stashed local changes to plugin %s, run \"git stash pop\" in %s to restore them", p.Name, pluginDir)
+		return nil
+	default:
+		return fmt.Errorf("%w that upgrading would overwrite, run \"tim status %s\" to see them",
+			ErrDirtyWorktree, p.Name)
+	}
 }
 
 // Removes all files related to this plugin from the filesystem.
 func (p *Plugin) Uninstall() error {
-	pluginDir, err := p.Dir()
+	pluginDir, err := p.RepoDir()
 	if err != nil {
 		return err
 	}
@@ -201,5 +602,42 @@ func (p *Plugin) Uninstall() error {
 	if err := os.RemoveAll(pluginDir); err != nil {
 		return err
 	}
-	return os.Remove(pluginDir)
+
+	// Tidy up the now empty parent directories, such as the username.
+	pluginsDir, err := GetPluginsDir()
+	if err != nil {
+		return err
+	}
+	for dir := path.Dir(pluginDir); dir != pluginsDir && strings.HasPrefix(dir, pluginsDir); dir = path.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// Returns the names of all plugins installed in the plugins directory,
+// whether or not they are in the config file. Any directory containing
+// a git repository is a plugin.
+func FindInstalledPlugins() ([]string, error) {
+	pluginsDir, err := GetPluginsDir()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0)
+	err = fs.WalkDir(os.DirFS(pluginsDir), ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() || name == "." {
+			return nil
+		}
+		if IsRepository(path.Join(pluginsDir, name)) {
+			names = append(names, pluginNameOfDir(name))
+			return fs.SkipDir
+		}
+		return nil
+	})
+	return names, err
 }
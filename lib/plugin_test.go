@@ -0,0 +1,243 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kjnsn/tim/lib/gittest"
+)
+
+func TestLoadWithExoticPaths(t *testing.T) {
+	root := path.Join(t.TempDir(), "José's plugins", "$HOME #1")
+	plugin := Plugin{Name: "user/my plugin", Root: root}
+	pluginDir, err := plugin.Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(pluginDir, 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	output := path.Join(root, "ran ü")
+	script := "#!/bin/sh\necho \"$0\" > \"$OUTPUT\"\n"
+	if err := os.WriteFile(path.Join(pluginDir, "plugin.tmux"), []byte(script), 0750); err != nil {
+		t.Fatal(err)
+	}
+	plugin.Env = map[string]string{"OUTPUT": output}
+
+	if err := plugin.Load(context.Background()); err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := path.Join(pluginDir, "plugin.tmux") + "\n"; string(got) != want {
+		t.Errorf("script ran as %q; want %q", got, want)
+	}
+}
+
+func TestInstallLocal(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	setPolicyPath(t, path.Join(t.TempDir(), "none.json"))
+	localPath := t.TempDir()
+	plugin := Plugin{Name: "local/dev", Path: localPath, Root: t.TempDir()}
+
+	// Installing again, as syncing does, leaves the link alone.
+	for range 2 {
+		if err := plugin.Install(context.Background(), ""); err != nil {
+			t.Fatalf("Install() = %v", err)
+		}
+	}
+	pluginDir, err := plugin.Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target, err := os.Readlink(pluginDir); err != nil || target != localPath {
+		t.Errorf("plugin directory links to %q, %v; want %q", target, err, localPath)
+	}
+	if spec := plugin.Spec(); spec.Version != LocalVersionSpec || spec.Path != localPath {
+		t.Errorf("Spec() = %+v", spec)
+	}
+
+	if err := plugin.Uninstall(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(localPath); err != nil {
+		t.Errorf("uninstalling deleted the local directory: %v", err)
+	}
+}
+
+func TestTraceCommand(t *testing.T) {
+	tests := []struct {
+		interpreter string
+		want        []string
+	}{
+		{interpreter: "/bin/bash", want: []string{"/bin/bash", "-x", "a.tmux"}},
+		{interpreter: "/bin/sh -e", want: []string{"/bin/sh", "-x", "a.tmux"}},
+		{interpreter: "/usr/bin/env bash", want: []string{"/usr/bin/env", "bash", "-x", "a.tmux"}},
+		{interpreter: "/usr/bin/env python3", want: nil},
+		{interpreter: "/usr/bin/env", want: nil},
+		{interpreter: "", want: nil},
+	}
+	for _, tt := range tests {
+		if got := traceCommand(tt.interpreter, "a.tmux"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("traceCommand(%q) = %q; want %q", tt.interpreter, got, tt.want)
+		}
+	}
+}
+
+func TestRunScriptTimeout(t *testing.T) {
+	script := path.Join(t.TempDir(), "hang.tmux")
+	// The child outlives the script, unless its whole process group is
+	// killed.
+	contents := "#!/bin/sh\n(sleep 1; touch \"$0.child\") &\nsleep 30\n"
+	if err := os.WriteFile(script, []byte(contents), 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	started := time.Now()
+	err := runScript(context.Background(), []string{script}, os.Environ(), 100*time.Millisecond)
+	if ErrorCodeOf(err) != CodeTimeout {
+		t.Fatalf("runScript() = %v; want E_TIMEOUT", err)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("runScript() took %s to time out", elapsed)
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+	if _, err := os.Stat(script + ".child"); err == nil {
+		t.Errorf("a process started by the script kept running after it timed out")
+	}
+}
+
+func TestFindScript(t *testing.T) {
+	plugin := Plugin{Name: "user/plugin", Root: "/plugins"}
+	entrypoints := []string{
+		"/plugins/user/plugin/a/main.tmux",
+		"/plugins/user/plugin/b/main.tmux",
+		"/plugins/user/plugin/b/other.tmux",
+	}
+	tests := []struct {
+		script string
+		want   string
+	}{
+		{"other.tmux", "/plugins/user/plugin/b/other.tmux"},
+		{"a/main.tmux", "/plugins/user/plugin/a/main.tmux"},
+		{"main.tmux", ""},
+		{"missing.tmux", ""},
+	}
+	for _, test := range tests {
+		got, err := plugin.findScript(entrypoints, test.script)
+		if got != test.want || (err != nil) != (test.want == "") {
+			t.Errorf("findScript(%q) = %q, %v; want %q", test.script, got, err, test.want)
+		}
+	}
+}
+
+func TestCheckLocalChanges(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	// Stashing commits the changes, with the identity New sets.
+	remote := gittest.New(t)
+	remote.Commit("first", map[string]string{"edited.tmux": ""})
+	defer func() { LocalChangesMode = RefuseLocalChanges }()
+
+	tests := []struct {
+		mode      LocalChanges
+		wantErr   bool
+		wantClean bool
+	}{
+		{RefuseLocalChanges, true, false},
+		{DiscardLocalChanges, false, false},
+		{StashLocalChanges, false, true},
+	}
+	for _, tt := range tests {
+		plugin := Plugin{Name: "user/plugin", Root: t.TempDir()}
+		pluginDir, err := plugin.Dir()
+		if err != nil {
+			t.Fatal(err)
+		}
+		remote.Clone(pluginDir)
+
+		// Untracked files are kept by upgrading, so aren't refused.
+		LocalChangesMode = RefuseLocalChanges
+		if err := os.WriteFile(path.Join(pluginDir, "untracked.conf"), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := plugin.checkLocalChanges(context.Background(), pluginDir, &SemanticVersion{}); err != nil {
+			t.Errorf("checkLocalChanges() with an untracked file = %v; want nil", err)
+		}
+
+		if err := os.WriteFile(path.Join(pluginDir, "edited.tmux"), []byte("# edited\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		LocalChangesMode = tt.mode
+		err = plugin.checkLocalChanges(context.Background(), pluginDir, &SemanticVersion{})
+		if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrDirtyWorktree)) {
+			t.Errorf("checkLocalChanges(%d) = %v; want error %t", tt.mode, err, tt.wantErr)
+		}
+		if dirty, _ := IsDirty(context.Background(), pluginDir); dirty == tt.wantClean {
+			t.Errorf("checkLocalChanges(%d) left the plugin dirty = %t", tt.mode, dirty)
+		}
+	}
+}
+
+func TestInstall(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	remote := gittest.New(t)
+	first := remote.Release("v1.0.0")
+	latest := remote.Release("v1.1.0")
+	remote.Advance()
+
+	tests := []struct {
+		versionSpec string
+		wantVersion string
+		wantCommit  string
+		wantErr     error
+	}{
+		{"", "v1.1.0", latest, nil},
+		{"v1.0.0", "v1.0.0", first, nil},
+		{"v1.0", "", "", ErrUnknownVersion},
+	}
+	for _, test := range tests {
+		plugin := Plugin{Name: "user/plugin", Remote: remote.Dir, Root: t.TempDir()}
+		err := plugin.Install(context.Background(), test.versionSpec)
+		if !errors.Is(err, test.wantErr) {
+			t.Errorf("Install(%q) = %v; want %v", test.versionSpec, err, test.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		if plugin.Version.String() != test.wantVersion {
+			t.Errorf("Install(%q) installed %v; want %s", test.versionSpec, plugin.Version, test.wantVersion)
+		}
+		if commit, _ := plugin.Commit(context.Background()); commit != test.wantCommit {
+			t.Errorf("Install(%q) checked out %s; want %s", test.versionSpec, commit, test.wantCommit)
+		}
+		entrypoints, err := plugin.Entrypoints()
+		if err != nil || len(entrypoints) != 1 {
+			t.Errorf("Install(%q) entrypoints = %v, %v; want plugin.tmux", test.versionSpec, entrypoints, err)
+		}
+	}
+}
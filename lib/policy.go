@@ -0,0 +1,139 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// Where the policy of managed environments is read from.
+const DefaultPolicyPath = "/etc/tim/policy.json"
+
+// The policy file read, only changed by tests. Users can't point it
+// elsewhere, or they could opt out of the policy.
+var policyPath = DefaultPolicyPath
+
+var ErrPolicyViolation = errors.New("policy violation")
+
+// Restrictions on which plugins may be installed and what they may do,
+// set by the administrator of a managed environment.
+type Policy struct {
+	path string
+
+	// Patterns of plugin sources that may be installed, such as
+	// "github.com/myorg/*". Any source is allowed if empty.
+	Allow []string `json:"allow,omitempty"`
+
+	// Hosts that plugins may never be installed from.
+	DenyHosts []string `json:"deny_hosts,omitempty"`
+
+	// Whether plugins' scripts may be run when loading them. Scripts are
+	// allowed if unset.
+	AllowScripts *bool `json:"allow_scripts,omitempty"`
+}
+
+// Reads the policy file. Returns an empty policy, allowing everything,
+// if there is none.
+func GetPolicy() (*Policy, error) {
+	policy := &Policy{path: policyPath}
+	contents, err := os.ReadFile(policyPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return policy, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(contents, policy); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %w", policyPath, err)
+	}
+	for _, pattern := range policy.Allow {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q in policy file %s: %w", pattern, policyPath, err)
+		}
+	}
+	return policy, nil
+}
+
+// Returns an error wrapping ErrPolicyViolation if the plugin may not be
+// installed from its remote.
+func (p *Policy) CheckInstall(plugin *Plugin) error {
+	source := plugin.Source()
+	host, _, _ := strings.Cut(source, "/")
+	for _, denied := range p.DenyHosts {
+		if strings.EqualFold(host, denied) {
+			return fmt.Errorf("%w: plugin %s is from %s, which is denied by %s", ErrPolicyViolation, plugin.Name, host, p.path)
+		}
+	}
+
+	if len(p.Allow) == 0 {
+		return nil
+	}
+	for _, pattern := range p.Allow {
+		// Remote sources are lower case, as hosts are case insensitive,
+		// but paths on disk are not.
+		if !path.IsAbs(source) {
+			pattern = strings.ToLower(pattern)
+		}
+		if matched, _ := path.Match(pattern, source); matched {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: plugin %s from %s is not in the allowlist of %s", ErrPolicyViolation, plugin.Name, source, p.path)
+}
+
+// Returns an error wrapping ErrPolicyViolation if plugins' scripts may
+// not be run.
+func (p *Policy) CheckScripts(plugin *Plugin) error {
+	if p.AllowScripts != nil && !*p.AllowScripts {
+		return fmt.Errorf("%w: plugin %s has scripts, and running them is not allowed by %s",
+			ErrPolicyViolation, plugin.Name, p.path)
+	}
+	return nil
+}
+
+// Checks the plugin may be installed under the current policy.
+func checkInstallPolicy(plugin *Plugin) error {
+	policy, err := GetPolicy()
+	if err != nil {
+		return err
+	}
+	return policy.CheckInstall(plugin)
+}
+
+// Returns where the plugin comes from as <host>/<path>, such as
+// "github.com/tmux-plugins/tmux-resurrect", for matching against a policy.
+// Remotes that are not URLs, such as local paths, are returned as is, and
+// plugins loaded from a local directory or in a plugin root are their
+// directory, whatever they are named.
+func (p *Plugin) Source() string {
+	if p.IsLocal() {
+		return path.Clean(p.Path)
+	}
+	if p.Unmanaged {
+		return path.Join(p.Root, p.Name)
+	}
+	host, repoPath, err := splitRemote(p.RemoteURL())
+	if err != nil {
+		return p.RemoteURL()
+	}
+	return strings.ToLower(host + "/" + strings.TrimSuffix(strings.Trim(repoPath, "/"), ".git"))
+}
@@ -0,0 +1,95 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"errors"
+	"os"
+	"path"
+	"testing"
+)
+
+// Reads the policy from file for the rest of the test.
+func setPolicyPath(t *testing.T, file string) {
+	t.Helper()
+	saved := policyPath
+	policyPath = file
+	t.Cleanup(func() { policyPath = saved })
+}
+
+func TestPolicyCheckInstall(t *testing.T) {
+	setPolicyPath(t, path.Join(t.TempDir(), "policy.json"))
+	contents := `{
+		"allow": ["github.com/tmux-plugins/*", "gitlab.example.com/*/*", "/usr/share/tmux-plugins/*", "/opt/Tmux-Plugins/*"],
+		"deny_hosts": ["gitlab.example.com"],
+		"allow_scripts": false
+	}`
+	if err := os.WriteFile(policyPath, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := GetPolicy()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		plugin  Plugin
+		allowed bool
+	}{
+		{Plugin{Name: "tmux-plugins/tmux-resurrect"}, true},
+		{Plugin{Name: "Tmux-Plugins/tmux-yank", Remote: "git@github.com:Tmux-Plugins/tmux-yank.git"}, true},
+		{Plugin{Name: "someone/tmux-plugins"}, false},
+		{Plugin{Name: "gitlab.example.com/team/plugin", Remote: "https://gitlab.example.com/team/plugin.git"}, false},
+		{Plugin{Name: "local/plugin", Remote: "/srv/plugins/plugin"}, false},
+		{Plugin{Name: "tmux-sensible", Root: "/usr/share/tmux-plugins", Unmanaged: true}, true},
+		{Plugin{Name: "tmux-sensible", Root: "/home/me/plugins", Unmanaged: true}, false},
+		// Paths are matched case sensitively.
+		{Plugin{Name: "tmux-sensible", Root: "/opt/Tmux-Plugins", Unmanaged: true}, true},
+		{Plugin{Name: "tmux-sensible", Root: "/opt/tmux-plugins", Unmanaged: true}, false},
+		{Plugin{Name: "local/sensible", Path: "/opt/Tmux-Plugins/sensible"}, true},
+		// Local plugins are their directory, not the repository they are named after.
+		{Plugin{Name: "tmux-plugins/tmux-yank", Path: "/home/me/evil"}, false},
+		{Plugin{Name: "local/sensible", Path: "/usr/share/tmux-plugins/sensible/"}, true},
+	}
+	for _, test := range tests {
+		err := policy.CheckInstall(&test.plugin)
+		if test.allowed && err != nil {
+			t.Errorf("CheckInstall(%s) = %v; want allowed", test.plugin.Name, err)
+		}
+		if !test.allowed && !errors.Is(err, ErrPolicyViolation) {
+			t.Errorf("CheckInstall(%s) = %v; want ErrPolicyViolation", test.plugin.Name, err)
+		}
+	}
+
+	if err := policy.CheckScripts(&tests[0].plugin); !errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("CheckScripts() = %v; want ErrPolicyViolation", err)
+	}
+}
+
+func TestNoPolicy(t *testing.T) {
+	setPolicyPath(t, path.Join(t.TempDir(), "missing.json"))
+	policy, err := GetPolicy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugin := Plugin{Name: "local/plugin", Remote: "/srv/plugins/plugin"}
+	if err := policy.CheckInstall(&plugin); err != nil {
+		t.Errorf("CheckInstall() = %v; want allowed without a policy", err)
+	}
+	if err := policy.CheckScripts(&plugin); err != nil {
+		t.Errorf("CheckScripts() = %v; want allowed without a policy", err)
+	}
+}
@@ -0,0 +1,126 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/kjnsn/tim/lib/message"
+)
+
+var ErrLocked = errors.New("another tim process is running")
+
+var ErrWatching = errors.New("tim watch is already running")
+
+// Waits for other tim processes to finish, rather than failing with
+// ErrLocked.
+var WaitForLock = false
+
+// How often the lock is tried again while waiting for it.
+const lockPollInterval = 200 * time.Millisecond
+
+// Takes the lock held while tim changes the config file or plugins, so
+// that concurrent tim processes do not overwrite each other's changes.
+// Returns a function releasing it.
+func acquireProcessLock(ctx context.Context) (func(), error) {
+	stateDir, err := GetStateDir()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path.Join(stateDir, "tim.pid"), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	waiting := false
+	for {
+		err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			file.Close()
+			return nil, err
+		}
+
+		holder := lockHolder(file)
+		if !WaitForLock {
+			file.Close()
+			return nil, fmt.Errorf("%w%s. Wait for it to finish, or pass --wait", ErrLocked, holder)
+		}
+		if !waiting {
+			message.Info("Waiting for another tim process%s to finish", holder)
+			waiting = true
+		}
+		select {
+		case <-ctx.Done():
+			file.Close()
+			return nil, fmt.Errorf("gave up waiting for another tim process%s: %w", holder, ctx.Err())
+		case <-time.After(lockPollInterval):
+		}
+	}
+
+	// Record who holds the lock, for the error above.
+	if err := file.Truncate(0); err == nil {
+		file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return func() { file.Close() }, nil
+}
+
+// Takes the lock held while "tim watch" runs, so that only one runs at a
+// time. Returns a function releasing it, or ErrWatching.
+func AcquireWatchLock() (func(), error) {
+	stateDir, err := GetStateDir()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path.Join(stateDir, "watch.pid"), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		holder := lockHolder(file)
+		file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("%w%s", ErrWatching, holder)
+		}
+		return nil, err
+	}
+
+	if err := file.Truncate(0); err == nil {
+		file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return func() { file.Close() }, nil
+}
+
+// Describes the process holding the lock, from the pid it wrote.
+func lockHolder(file *os.File) string {
+	contents := make([]byte, 32)
+	n, _ := file.ReadAt(contents, 0)
+	pid := strings.TrimSpace(string(contents[:n]))
+	if pid == "" {
+		return ""
+	}
+	return " (pid " + pid + ")"
+}
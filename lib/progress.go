@@ -0,0 +1,166 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A step in installing or upgrading a plugin.
+type Phase string
+
+const (
+	// Looking for a new version of an installed plugin.
+	PhaseCheck    Phase = "check"
+	PhaseClone    Phase = "clone"
+	PhaseFetch    Phase = "fetch"
+	PhaseCheckout Phase = "checkout"
+	// tim has finished with the plugin, whether or not it succeeded. A
+	// plugin checked for upgrades is reported again if it is upgraded.
+	PhaseDone Phase = "done"
+)
+
+// Describes how far tim has got with a plugin.
+type ProgressEvent struct {
+	Phase  Phase
+	Plugin string
+
+	// How much of the phase is complete, from 0 to 100, or -1 if unknown.
+	Percent int
+
+	// What git is doing, such as "Receiving objects", if anything.
+	Message string
+}
+
+// Called as plugins are installed and upgraded. It may be called from
+// several goroutines at once when plugins are installed concurrently.
+type ProgressFunc func(ProgressEvent)
+
+type progressKey struct{}
+
+// The progress function and the plugin events are reported for.
+type progressState struct {
+	fn     ProgressFunc
+	plugin string
+	phase  Phase
+}
+
+// Returns a context under which installing and upgrading plugins reports
+// progress to fn, instead of printing the progress of git to stderr.
+// For example, with Ensure:
+//
+//	ctx = lib.WithProgress(ctx, func(event lib.ProgressEvent) {
+//		log.Printf("%s: %s %d%%", event.Plugin, event.Phase, event.Percent)
+//	})
+//	err := lib.Ensure(ctx, "", nil)
+//
+// Cancelling ctx stops work between phases, so a plugin is never left
+// half checked out by tim itself.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, &progressState{fn: fn})
+}
+
+// Returns the progress state of ctx, or nil if progress is not reported.
+func progressOf(ctx context.Context) *progressState {
+	state, _ := ctx.Value(progressKey{}).(*progressState)
+	return state
+}
+
+// Returns a context reporting progress for the named plugin.
+func withProgressPlugin(ctx context.Context, plugin string) context.Context {
+	state := progressOf(ctx)
+	if state == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, progressKey{}, &progressState{fn: state.fn, plugin: plugin})
+}
+
+// Starts phase, returning a context reporting git's progress as part of
+// it. This is a safe point to stop at, so it fails if ctx is cancelled.
+func startPhase(ctx context.Context, phase Phase) (context.Context, error) {
+	if err := ctx.Err(); err != nil {
+		return ctx, err
+	}
+	state := progressOf(ctx)
+	if state == nil {
+		return ctx, nil
+	}
+	state = &progressState{fn: state.fn, plugin: state.plugin, phase: phase}
+	state.report(0, "")
+	return context.WithValue(ctx, progressKey{}, state), nil
+}
+
+// Reports that tim has finished with the plugin of ctx.
+func finishProgress(ctx context.Context) {
+	if state := progressOf(ctx); state != nil {
+		state.fn(ProgressEvent{Phase: PhaseDone, Plugin: state.plugin, Percent: 100})
+	}
+}
+
+func (s *progressState) report(percent int, message string) {
+	s.fn(ProgressEvent{Phase: s.phase, Plugin: s.plugin, Percent: percent, Message: message})
+}
+
+// Where git should write its progress under ctx.
+func gitProgressOutput(ctx context.Context) io.Writer {
+	state := progressOf(ctx)
+	if state == nil {
+		return Stderr
+	}
+	return &progressWriter{state: state}
+}
+
+// Matches progress lines written by git, such as
+// "remote: Counting objects:  42% (6/14)".
+var gitProgressLine = regexp.MustCompile(`^(?:remote: )?([A-Za-z ]+):\s+(\d+)%`)
+
+// Turns the progress git writes into progress events.
+type progressWriter struct {
+	state *progressState
+	line  []byte
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.line = append(w.line, p...)
+	for {
+		// git redraws progress lines with a carriage return.
+		end := bytes.IndexAny(w.line, "\r\n")
+		if end < 0 {
+			return len(p), nil
+		}
+		w.reportLine(string(w.line[:end]))
+		w.line = w.line[end+1:]
+	}
+}
+
+func (w *progressWriter) reportLine(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+	match := gitProgressLine.FindStringSubmatch(line)
+	if match == nil {
+		w.state.report(-1, line)
+		return
+	}
+	percent, _ := strconv.Atoi(match[2])
+	w.state.report(percent, match[1])
+}
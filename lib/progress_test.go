@@ -0,0 +1,59 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestGitProgressOutput(t *testing.T) {
+	events := make([]ProgressEvent, 0)
+	ctx := withProgressPlugin(WithProgress(context.Background(), func(event ProgressEvent) {
+		events = append(events, event)
+	}), "a/plugin")
+	ctx, err := startPhase(ctx, PhaseClone)
+	if err != nil {
+		t.Fatalf("startPhase() error = %v", err)
+	}
+
+	output := gitProgressOutput(ctx)
+	fmt.Fprint(output, "remote: Counting objects:  7% (1/14)\rremote: Counting")
+	fmt.Fprint(output, " objects: 100% (14/14), done.\nFrom /tmp/up\n")
+	finishProgress(ctx)
+
+	want := []ProgressEvent{
+		{Phase: PhaseClone, Plugin: "a/plugin", Percent: 0},
+		{Phase: PhaseClone, Plugin: "a/plugin", Percent: 7, Message: "Counting objects"},
+		{Phase: PhaseClone, Plugin: "a/plugin", Percent: 100, Message: "Counting objects"},
+		{Phase: PhaseClone, Plugin: "a/plugin", Percent: -1, Message: "From /tmp/up"},
+		{Phase: PhaseDone, Plugin: "a/plugin", Percent: 100},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %+v; want %+v", events, want)
+	}
+}
+
+func TestStartPhaseCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := startPhase(ctx, PhaseCheckout); !errors.Is(err, context.Canceled) {
+		t.Errorf("startPhase() error = %v; want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,164 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const githubHost = "github.com"
+
+// Matches scp-like ssh remotes, for example git@gitlab.com:user/repo.git
+var scpRemoteRegexp = regexp.MustCompile(`^(?:[\w.-]+@)?([\w.-]+):([^/].*)$`)
+
+// Matches the github shorthand <username>/<repo>.
+var shorthandRegexp = regexp.MustCompile(`^[\w.-]+/[\w.-]+$`)
+
+// Parses a plugin argument, which is either the github shorthand
+// <username>/<repo>, or a full https or ssh clone URL.
+//
+// Returns the plugin name and the remote to clone from. The remote is
+// empty for github shorthands, as the default github remote is used.
+// Plugins on other hosts are named <host>/<path>.
+func ParsePluginArg(arg string) (name, remote string, err error) {
+	arg = strings.TrimSpace(arg)
+
+	if repo, subdir := splitSubdir(arg); subdir != "" || repo != arg {
+		subdir, err := cleanSubdir(arg, subdir)
+		if err != nil {
+			return "", "", err
+		}
+		name, remote, err := ParsePluginArg(repo)
+		if err != nil {
+			return "", "", err
+		}
+		return name + subdirSeparator + subdir, remote, nil
+	}
+
+	if shorthandRegexp.MatchString(arg) {
+		return strings.ToLower(arg), "", nil
+	}
+
+	host, repoPath, err := splitRemote(arg)
+	if err != nil {
+		return "", "", err
+	}
+
+	repoPath = strings.TrimSuffix(strings.Trim(repoPath, "/"), ".git")
+	if repoPath == "" {
+		return "", "", WithErrorCode(CodeInvalidPlugin, fmt.Errorf("invalid plugin %q: missing repository path", arg))
+	}
+
+	name = strings.ToLower(host + "/" + repoPath)
+	if host == githubHost {
+		// Github plugins keep the short name, but remember the remote
+		// in case it uses ssh.
+		name = strings.ToLower(repoPath)
+	}
+	return name, arg, nil
+}
+
+// Splits a remote URL into its host and repository path.
+func splitRemote(remote string) (host, repoPath string, err error) {
+	if strings.Contains(remote, "://") {
+		u, err := url.Parse(remote)
+		if err != nil {
+			return "", "", WithErrorCode(CodeInvalidPlugin, fmt.Errorf("invalid plugin URL %q: %w", remote, err))
+		}
+		if u.Hostname() == "" {
+			return "", "", WithErrorCode(CodeInvalidPlugin, fmt.Errorf("invalid plugin URL %q: missing host", remote))
+		}
+		return u.Hostname(), u.Path, nil
+	}
+
+	if match := scpRemoteRegexp.FindStringSubmatch(remote); match != nil {
+		return match[1], match[2], nil
+	}
+
+	return "", "", WithErrorCode(CodeInvalidPlugin, fmt.Errorf("invalid plugin %q: expected <username>/<repo> or a git URL", remote))
+}
+
+// Returns the URL to clone this plugin from.
+func (p *Plugin) RemoteURL() string {
+	if p.Remote != "" {
+		return p.Remote
+	}
+	return "https://" + githubHost + "/" + p.RepoName() + ".git"
+}
+
+// Returns the URL of the plugin's web page.
+func (p *Plugin) WebURL() string {
+	host, repoPath, err := splitRemote(p.RemoteURL())
+	if err != nil {
+		return ""
+	}
+	return "https://" + host + "/" + strings.TrimSuffix(strings.Trim(repoPath, "/"), ".git")
+}
+
+// Returns the URL of the web page for the given tag.
+func (p *Plugin) TagURL(tag string) string {
+	switch p.forge() {
+	case "github":
+		return p.WebURL() + "/releases/tag/" + tag
+	case "gitlab":
+		return p.WebURL() + "/-/tags/" + tag
+	default:
+		return p.WebURL() + "/src/tag/" + tag
+	}
+}
+
+// Returns the URL of the web page for the given branch.
+func (p *Plugin) TreeURL(branch string) string {
+	switch p.forge() {
+	case "github":
+		return p.WebURL() + "/tree/" + branch
+	case "gitlab":
+		return p.WebURL() + "/-/tree/" + branch
+	default:
+		return p.WebURL() + "/src/branch/" + branch
+	}
+}
+
+// Returns the URL of the web page comparing two tags or commits.
+func (p *Plugin) CompareURL(from, to string) string {
+	switch p.forge() {
+	case "gitlab":
+		return p.WebURL() + "/-/compare/" + from + "..." + to
+	default:
+		return p.WebURL() + "/compare/" + from + "..." + to
+	}
+}
+
+// Guesses the forge software hosting the plugin, which determines
+// the layout of its web URLs. Hosts that are neither github nor
+// gitlab are assumed to be gitea or forgejo.
+func (p *Plugin) forge() string {
+	host, _, err := splitRemote(p.RemoteURL())
+	if err != nil {
+		return ""
+	}
+	switch {
+	case host == githubHost:
+		return "github"
+	case strings.Contains(host, "gitlab"):
+		return "gitlab"
+	default:
+		return "gitea"
+	}
+}
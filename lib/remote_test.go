@@ -0,0 +1,62 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"testing"
+)
+
+func TestParsePluginArg(t *testing.T) {
+	tests := []struct {
+		arg, name, remote string
+	}{
+		{"User/Repo", "user/repo", ""},
+		{"https://github.com/user/repo.git", "user/repo", "https://github.com/user/repo.git"},
+		{"https://gitlab.com/group/sub/repo", "gitlab.com/group/sub/repo", "https://gitlab.com/group/sub/repo"},
+		{"git@gitea.example.com:user/repo.git", "gitea.example.com/user/repo", "git@gitea.example.com:user/repo.git"},
+		{"ssh://git@host.io:2222/user/repo.git", "host.io/user/repo", "ssh://git@host.io:2222/user/repo.git"},
+		{"User/Repo//plugins/Theme/", "user/repo//plugins/Theme", ""},
+		{"https://gitlab.com/group/repo.git//tmux/a", "gitlab.com/group/repo//tmux/a", "https://gitlab.com/group/repo.git"},
+	}
+
+	for _, test := range tests {
+		name, remote, err := ParsePluginArg(test.arg)
+		if err != nil {
+			t.Errorf("ParsePluginArg(%q) returned error %v", test.arg, err)
+		}
+		if name != test.name || remote != test.remote {
+			t.Errorf("ParsePluginArg(%q) = %q, %q; want %q, %q", test.arg, name, remote, test.name, test.remote)
+		}
+	}
+
+	for _, arg := range []string{"not a plugin", "user/repo//", "user/repo//../other"} {
+		if _, _, err := ParsePluginArg(arg); err == nil {
+			t.Errorf("ParsePluginArg(%q) returned no error", arg)
+		}
+	}
+}
+
+func TestPluginWebURL(t *testing.T) {
+	plugin := Plugin{Name: "gitlab.com/user/repo", Remote: "git@gitlab.com:user/repo.git"}
+	if got := plugin.TagURL("v1.0.0"); got != "https://gitlab.com/user/repo/-/tags/v1.0.0" {
+		t.Errorf("TagURL(\"v1.0.0\") = %q", got)
+	}
+
+	plugin = Plugin{Name: "user/repo"}
+	if got := plugin.TreeURL("main"); got != "https://github.com/user/repo/tree/main" {
+		t.Errorf("TreeURL(\"main\") = %q", got)
+	}
+}
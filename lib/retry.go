@@ -0,0 +1,78 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/kjnsn/tim/lib/message"
+)
+
+// The default number of times a network git operation is retried after
+// a transient failure, and how long to wait before the first retry.
+const (
+	DefaultRetries      = 2
+	DefaultRetryBackoff = time.Second
+)
+
+// The longest to wait between retries, however many there have been.
+const maxRetryBackoff = 30 * time.Second
+
+// Returns whether err is a network failure that may not happen again,
+// such as a dropped connection or a stalled clone timing out, rather than
+// one retrying can't fix, such as a bad ref or failed authentication.
+func isTransient(err error) bool {
+	switch ErrorCodeOf(err) {
+	case CodeNetwork:
+		return true
+	case CodeTimeout:
+		// The timeout of the phase, such as "clone" in "timeouts", rather than
+		// the --timeout of the whole command, which leaves no time to retry.
+		return !errors.Is(err, context.DeadlineExceeded)
+	case CodeGit, CodeUnknown:
+		// go-git returns the errors of the connection itself.
+		var netErr net.Error
+		return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+	default:
+		return false
+	}
+}
+
+// Runs fn, the git operation named operation, retrying it after transient
+// failures with exponential backoff, as set by the network key in the
+// config file.
+func withRetries(ctx context.Context, operation string, fn func() error) error {
+	attempts := Network.retries() + 1
+	backoff := Network.retryBackoff()
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= attempts || ctx.Err() != nil || !isTransient(err) {
+			return err
+		}
+		message.Debug("git %s failed, retrying in %s (attempt %d of %d): %s",
+			operation, backoff, attempt+1, attempts, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxRetryBackoff)
+	}
+}
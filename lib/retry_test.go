@@ -0,0 +1,80 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"git network", gitError("fatal: unable to access: Could not resolve host: github.com", errors.New("exit status 128")), true},
+		{"git hung up", gitError("fatal: the remote end hung up unexpectedly", errors.New("exit status 128")), true},
+		{"git auth", gitError("fatal: Authentication failed for 'https://github.com/a/b'", errors.New("exit status 128")), false},
+		{"git bad ref", gitError("error: pathspec 'v9' did not match any file(s) known to git", errors.New("exit status 1")), false},
+		{"connection", fmt.Errorf("fetch: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")}), true},
+		{"eof", fmt.Errorf("fetch: %w", io.ErrUnexpectedEOF), true},
+		{"phase timeout", WithErrorCode(CodeTimeout, errors.New("git fetch timed out after 1m0s")), true},
+		{"command timeout", fmt.Errorf("git fetch: %w", context.DeadlineExceeded), false},
+	}
+	for _, tt := range tests {
+		if got := isTransient(tt.err); got != tt.want {
+			t.Errorf("isTransient(%s) = %t; want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWithRetries(t *testing.T) {
+	retries := 2
+	Network = NetworkConfig{Retries: &retries, RetryBackoff: "1ms"}
+	t.Cleanup(func() { Network = NetworkConfig{} })
+	transient := WithErrorCode(CodeNetwork, errors.New("connection reset"))
+
+	tests := []struct {
+		name         string
+		failures     []error
+		wantAttempts int
+		wantErr      bool
+	}{
+		{"succeeds", nil, 1, false},
+		{"recovers", []error{transient, transient}, 3, false},
+		{"gives up", []error{transient, transient, transient, transient}, 3, true},
+		{"hard failure", []error{WithErrorCode(CodeGitAuth, errors.New("denied"))}, 1, true},
+	}
+	for _, tt := range tests {
+		attempts := 0
+		err := withRetries(context.Background(), "fetch", func() error {
+			attempts++
+			if attempts <= len(tt.failures) {
+				return tt.failures[attempts-1]
+			}
+			return nil
+		})
+		if attempts != tt.wantAttempts || (err != nil) != tt.wantErr {
+			t.Errorf("%s: withRetries() = %v after %d attempts; want %d attempts, error %t",
+				tt.name, err, attempts, tt.wantAttempts, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// The scripts a plugin runs when it is loaded, for the user to review
+// before they are run.
+type ScriptReview struct {
+	// Names of the scripts run, relative to the plugin directory.
+	Scripts []string
+
+	// Names of the lifecycle hooks the plugin has, run when it is
+	// installed or removed.
+	Hooks []string
+
+	// Changes to the plugin's scripts made by an upgrade, empty if there
+	// are none or the plugin is new.
+	Diff string
+}
+
+// Returns the scripts the installed plugin runs when loaded.
+func (p *Plugin) ReviewInstalled() (*ScriptReview, error) {
+	entrypoints, err := p.Entrypoints()
+	if err != nil {
+		return nil, err
+	}
+	pluginDir, err := p.Dir()
+	if err != nil {
+		return nil, err
+	}
+	review := &ScriptReview{Scripts: make([]string, 0, len(entrypoints))}
+	for _, entrypoint := range entrypoints {
+		review.Scripts = append(review.Scripts, strings.TrimPrefix(entrypoint, pluginDir+"/"))
+	}
+	hooks, err := filepath.Glob(filepath.Join(pluginDir, lifecycleHooksDir, "*"))
+	if err != nil {
+		return nil, err
+	}
+	for i, hook := range hooks {
+		hooks[i] = strings.TrimPrefix(hook, pluginDir+"/")
+	}
+	review.Hooks = lifecycleHooks(hooks)
+	return review, nil
+}
+
+// Returns the scripts the plugin will run once upgraded to version, and
+// how its scripts differ from those checked out, without checking the
+// new version out. The new version is fetched if needed.
+func (p *Plugin) ReviewUpgrade(ctx context.Context, version Version) (*ScriptReview, error) {
+	pluginDir, err := p.Dir()
+	if err != nil {
+		return nil, err
+	}
+	ref := versionRef(version)
+	if !HasCommit(ctx, pluginDir, ref) {
+		if err := FetchTags(ctx, pluginDir); err != nil {
+			return nil, err
+		}
+	}
+
+	// The scripts are found as Entrypoints does, from the files at ref.
+	files, err := Git.Files(ctx, pluginDir, ref)
+	if err != nil {
+		return nil, err
+	}
+	run, err := runPatternsAt(ctx, p, files, ref)
+	if err != nil {
+		return nil, err
+	}
+	review := &ScriptReview{Hooks: lifecycleHooks(files)}
+	review.Scripts, err = findEntrypoints(files, run)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", p.Name, err)
+	}
+
+	// Scripts often run others, so changes to any shell script are shown,
+	// along with changes to which scripts the manifest runs.
+	review.Diff, err = Git.Diff(ctx, pluginDir, "HEAD", ref,
+		[]string{"*.tmux", "*.sh", lifecycleHooksDir, ManifestFileName, ManifestYAMLFileName})
+	if err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+// Returns the scripts the plugin's "run" names, as runPatterns does, but
+// from the manifest in files at ref rather than the one checked out.
+func runPatternsAt(ctx context.Context, p *Plugin, files []string, ref string) ([]string, error) {
+	if len(p.Run) > 0 {
+		return p.Run, nil
+	}
+	pluginDir, err := p.Dir()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{ManifestFileName, ManifestYAMLFileName} {
+		if !slices.Contains(files, name) {
+			continue
+		}
+		// Relative to pluginDir, which may be a subdirectory.
+		contents, err := Git.ReadFile(ctx, pluginDir, ref, name)
+		if err != nil {
+			return nil, err
+		}
+		manifest, err := parseManifest(name, contents)
+		if err != nil {
+			return nil, err
+		}
+		return manifest.Run, nil
+	}
+	return nil, nil
+}
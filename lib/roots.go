@@ -0,0 +1,75 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+
+	"github.com/kjnsn/tim/lib/message"
+)
+
+// Returns the plugins in the config file's plugin_roots, each directory
+// in a root being a plugin named after it. Plugins with the same name as
+// one in the config file, such as "tmux-sensible" and
+// "tmux-plugins/tmux-sensible", or in an earlier root, are left out.
+func (lf *Lockfile) UnmanagedPlugins() []Plugin {
+	seen := make(map[string]bool)
+	for name := range lf.PluginSpecs {
+		seen[path.Base(name)] = true
+	}
+
+	plugins := make([]Plugin, 0)
+	for _, root := range lf.pluginRoots {
+		entries, err := os.ReadDir(root)
+		if errors.Is(err, fs.ErrNotExist) {
+			message.Debug("Plugin root %s does not exist", root)
+			continue
+		}
+		if err != nil {
+			message.Warning("Unable to read plugin root %s: %s", root, err)
+			continue
+		}
+		for _, entry := range entries {
+			// Plugins are often links to where a package installed them.
+			if info, err := os.Stat(path.Join(root, entry.Name())); err != nil || !info.IsDir() {
+				continue
+			}
+			if seen[entry.Name()] {
+				message.Debug("Skipping %s in plugin root %s, as a plugin with the same name is loaded", entry.Name(), root)
+				continue
+			}
+			seen[entry.Name()] = true
+			plugins = append(plugins, Plugin{Name: entry.Name(), Root: root, Unmanaged: true})
+		}
+	}
+	return plugins
+}
+
+// Returns the error for a plugin that is not in the config file, saying
+// so if it is in one of the plugin roots, which tim does not change.
+func (lf *Lockfile) PluginNotFound(name string) error {
+	for _, plugin := range lf.UnmanagedPlugins() {
+		if plugin.Name == name {
+			return WithErrorCode(CodePluginNotFound, fmt.Errorf(
+				"plugin %s is in the read-only plugin root %s, which tim does not change", name, plugin.Root))
+		}
+	}
+	return PluginNotFound(name)
+}
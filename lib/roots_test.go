@@ -0,0 +1,57 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"os"
+	"path"
+	"slices"
+	"testing"
+)
+
+func TestUnmanagedPlugins(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	for _, dir := range []string{
+		path.Join(first, "tmux-sensible"),
+		path.Join(first, "tmux-yank"),
+		path.Join(second, "tmux-yank"),
+		path.Join(second, "tmux-open"),
+	} {
+		if err := os.Mkdir(dir, 0750); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(path.Join(second, "README"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	lockFile := Lockfile{
+		PluginSpecs: map[string]PluginSpec{"tmux-plugins/tmux-sensible": {Version: "v3.0.0"}},
+		pluginRoots: []string{first, second, path.Join(first, "missing")},
+	}
+	got := make([]string, 0)
+	for _, plugin := range lockFile.UnmanagedPlugins() {
+		got = append(got, path.Join(plugin.Root, plugin.Name))
+	}
+	want := []string{path.Join(first, "tmux-yank"), path.Join(second, "tmux-open")}
+	if !slices.Equal(got, want) {
+		t.Errorf("UnmanagedPlugins() = %v; want %v", got, want)
+	}
+
+	if err := lockFile.PluginNotFound("tmux-open"); ErrorCodeOf(err) != CodePluginNotFound {
+		t.Errorf("PluginNotFound() = %v; want E_PLUGIN_NOT_FOUND", err)
+	}
+}
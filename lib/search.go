@@ -0,0 +1,176 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// The state file search results are cached in.
+const searchCacheFile = "search.json"
+
+// How long search results are used before searching again.
+const SearchCacheTTL = 24 * time.Hour
+
+// The github topic tmux plugins are searched for.
+const pluginTopic = "tmux-plugin"
+
+// The github repository search API, a variable so tests can replace it.
+var searchURL = "https://api.github.com/search/repositories"
+
+// A plugin found by searching.
+type SearchResult struct {
+	// Name of the plugin in the form <username>/<repo>, as accepted by "tim add".
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Stars       int    `json:"stars"`
+	URL         string `json:"url"`
+}
+
+// Cached results of previous searches, by query.
+type searchCache struct {
+	Searches map[string]cachedSearch `json:"searches"`
+}
+
+type cachedSearch struct {
+	SearchedAt time.Time      `json:"searched_at"`
+	Results    []SearchResult `json:"results"`
+}
+
+// Searches github for repositories with the tmux-plugin topic matching
+// query, most starred first. Results are cached for SearchCacheTTL.
+func SearchPlugins(ctx context.Context, query string) ([]SearchResult, error) {
+	query = strings.ToLower(strings.TrimSpace(query))
+	cache, err := readSearchCache()
+	if err != nil {
+		return nil, err
+	}
+	if cached, ok := cache.Searches[query]; ok && time.Since(cached.SearchedAt) < SearchCacheTTL {
+		return cached.Results, nil
+	}
+
+	results, err := searchGithub(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, cached := range cache.Searches {
+		if time.Since(cached.SearchedAt) >= SearchCacheTTL {
+			delete(cache.Searches, key)
+		}
+	}
+	cache.Searches[query] = cachedSearch{SearchedAt: time.Now().UTC(), Results: results}
+	if err := writeStateFile(searchCacheFile, cache); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func readSearchCache() (*searchCache, error) {
+	cache := &searchCache{}
+	if err := readStateFile(searchCacheFile, cache); err != nil {
+		return nil, err
+	}
+	if cache.Searches == nil {
+		cache.Searches = make(map[string]cachedSearch)
+	}
+	return cache, nil
+}
+
+func searchGithub(ctx context.Context, query string) ([]SearchResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	params := url.Values{
+		"q":        {strings.TrimSpace(query + " topic:" + pluginTopic)},
+		"sort":     {"stars"},
+		"per_page": {"50"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, searchURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to search for plugins: %s", resp.Status)
+	}
+
+	body := struct {
+		Items []struct {
+			FullName    string `json:"full_name"`
+			Description string `json:"description"`
+			Stars       int    `json:"stargazers_count"`
+			URL         string `json:"html_url"`
+		} `json:"items"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, len(body.Items))
+	for _, item := range body.Items {
+		results = append(results, SearchResult{
+			Name:        strings.ToLower(item.FullName),
+			Description: item.Description,
+			Stars:       item.Stars,
+			URL:         item.URL,
+		})
+	}
+	return results, nil
+}
+
+// Resolves a bare repository name, such as "tmux-resurrect", to the
+// plugin of that name found by a previous search. Any other argument,
+// or a name matching several plugins, is returned unchanged.
+func ResolvePluginArg(arg string) string {
+	if strings.ContainsAny(arg, "/:") {
+		return arg
+	}
+	cache, err := readSearchCache()
+	if err != nil {
+		return arg
+	}
+
+	match := ""
+	for _, cached := range cache.Searches {
+		for _, result := range cached.Results {
+			_, repo, _ := strings.Cut(result.Name, "/")
+			if !strings.EqualFold(repo, arg) || result.Name == match {
+				continue
+			}
+			if match != "" {
+				return arg
+			}
+			match = result.Name
+		}
+	}
+	if match == "" {
+		return arg
+	}
+	return match
+}
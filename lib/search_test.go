@@ -0,0 +1,70 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSearchPlugins(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if got := r.URL.Query().Get("q"); got != "resurrect topic:tmux-plugin" {
+			t.Errorf("query = %q", got)
+		}
+		w.Write([]byte(`{"items": [
+			{"full_name": "tmux-plugins/tmux-resurrect", "description": "Persists tmux environment", "stargazers_count": 11000, "html_url": "https://github.com/tmux-plugins/tmux-resurrect"},
+			{"full_name": "someone/resurrect-extras", "stargazers_count": 3}
+		]}`))
+	}))
+	defer server.Close()
+	saved := searchURL
+	searchURL = server.URL
+	t.Cleanup(func() { searchURL = saved })
+
+	for range 2 {
+		results, err := SearchPlugins(context.Background(), "Resurrect")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 2 || results[0].Name != "tmux-plugins/tmux-resurrect" || results[0].Stars != 11000 {
+			t.Errorf("SearchPlugins() = %+v", results)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("searched %d times; want 1, then use the cache", requests)
+	}
+
+	tests := []struct {
+		arg, want string
+	}{
+		{"tmux-resurrect", "tmux-plugins/tmux-resurrect"},
+		{"TMUX-RESURRECT", "tmux-plugins/tmux-resurrect"},
+		{"unknown", "unknown"},
+		{"other/tmux-resurrect", "other/tmux-resurrect"},
+	}
+	for _, test := range tests {
+		if got := ResolvePluginArg(test.arg); got != test.want {
+			t.Errorf("ResolvePluginArg(%q) = %q; want %q", test.arg, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The longest a release download may take.
+const downloadTimeout = 5 * time.Minute
+
+// Finds the asset containing the tim binary for the given platform, either
+// the binary itself or a .tar.gz archive, named like "tim_linux_amd64".
+func (r *TimRelease) AssetFor(goos, goarch string) (*ReleaseAsset, error) {
+	for _, separator := range []string{"_", "-"} {
+		platform := separator + goos + separator + goarch
+		for i, asset := range r.Assets {
+			name := strings.TrimSuffix(strings.TrimSuffix(asset.Name, ".tar.gz"), ".exe")
+			if strings.HasSuffix(name, platform) {
+				return &r.Assets[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("release %s has no binary for %s/%s", r.TagName, goos, goarch)
+}
+
+// Finds the asset listing the checksums of the other assets.
+func (r *TimRelease) checksumsAsset() (*ReleaseAsset, error) {
+	for i, asset := range r.Assets {
+		if strings.HasSuffix(asset.Name, "checksums.txt") {
+			return &r.Assets[i], nil
+		}
+	}
+	return nil, fmt.Errorf("release %s has no checksums", r.TagName)
+}
+
+// Parses a checksums file in the format written by sha256sum, returning
+// the hex encoded checksum by file name.
+func parseChecksums(checksums string) map[string]string {
+	parsed := make(map[string]string)
+	for _, line := range strings.Split(checksums, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		parsed[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+	}
+	return parsed
+}
+
+// Downloads the tim binary for the given platform from the release,
+// verifying its checksum. Returns the contents of the binary.
+func (r *TimRelease) DownloadBinary(ctx context.Context, goos, goarch string) ([]byte, error) {
+	asset, err := r.AssetFor(goos, goarch)
+	if err != nil {
+		return nil, err
+	}
+	checksumsAsset, err := r.checksumsAsset()
+	if err != nil {
+		return nil, err
+	}
+
+	checksums, err := download(ctx, checksumsAsset.URL)
+	if err != nil {
+		return nil, err
+	}
+	want, ok := parseChecksums(string(checksums))[asset.Name]
+	if !ok {
+		return nil, fmt.Errorf("no checksum for %s in %s", asset.Name, checksumsAsset.Name)
+	}
+
+	contents, err := download(ctx, asset.URL)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(contents)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return nil, fmt.Errorf("checksum mismatch for %s: got %s, want %s", asset.Name, got, want)
+	}
+
+	if strings.HasSuffix(asset.Name, ".tar.gz") {
+		return extractBinary(contents)
+	}
+	return contents, nil
+}
+
+// The running binary is not the one published in its release.
+var ErrBinaryMismatch = errors.New("tim does not match its release")
+
+// Checks that binary is the tim published in the release for the given
+// platform, downloading the release's binary, verified by its checksum,
+// to compare.
+func (r *TimRelease) VerifyBinary(ctx context.Context, goos, goarch string, binary []byte) error {
+	published, err := r.DownloadBinary(ctx, goos, goarch)
+	if err != nil {
+		return err
+	}
+	got, want := sha256.Sum256(binary), sha256.Sum256(published)
+	if got != want {
+		return fmt.Errorf("%w: its SHA-256 is %s, but the %s release for %s/%s is %s", ErrBinaryMismatch,
+			hex.EncodeToString(got[:]), r.TagName, goos, goarch, hex.EncodeToString(want[:]))
+	}
+	return nil
+}
+
+// Downloads the file at url.
+func download(ctx context.Context, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, downloadTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to download %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// Extracts the tim binary from a .tar.gz archive.
+func extractBinary(archive []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	tarReader := tar.NewReader(gz)
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("no tim binary found in the release archive")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := filepath.Base(header.Name)
+		if header.Typeflag == tar.TypeReg && (name == "tim" || name == "tim.exe") {
+			return io.ReadAll(tarReader)
+		}
+	}
+}
+
+// Replaces the executable, the resolved path of the running tim, with
+// the given binary. The binary is written next to the executable first,
+// so the replacement is atomic.
+func ReplaceExecutable(executable string, binary []byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(executable), ".tim-update-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(binary); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(temp.Name(), 0755); err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), executable)
+}
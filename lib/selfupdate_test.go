@@ -0,0 +1,93 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReleaseAssetFor(t *testing.T) {
+	release := &TimRelease{
+		TagName: "v1.2.0",
+		Assets: []ReleaseAsset{
+			{Name: "tim_1.2.0_checksums.txt"},
+			{Name: "tim_1.2.0_darwin_arm64.tar.gz"},
+			{Name: "tim_1.2.0_linux_amd64.tar.gz"},
+			{Name: "tim-windows-amd64.exe"},
+		},
+	}
+
+	tests := []struct {
+		goos, goarch string
+		want         string
+	}{
+		{"linux", "amd64", "tim_1.2.0_linux_amd64.tar.gz"},
+		{"darwin", "arm64", "tim_1.2.0_darwin_arm64.tar.gz"},
+		{"windows", "amd64", "tim-windows-amd64.exe"},
+		{"linux", "arm64", ""},
+	}
+	for _, test := range tests {
+		asset, err := release.AssetFor(test.goos, test.goarch)
+		got := ""
+		if err == nil {
+			got = asset.Name
+		}
+		if got != test.want {
+			t.Errorf("AssetFor(%s, %s) = %q, %v; want %q", test.goos, test.goarch, got, err, test.want)
+		}
+	}
+}
+
+func TestParseChecksums(t *testing.T) {
+	got := parseChecksums("ABC123  tim_linux_amd64.tar.gz\ndef456 *tim.exe\n\nmalformed\n")
+	if got["tim_linux_amd64.tar.gz"] != "abc123" || got["tim.exe"] != "def456" || len(got) != 2 {
+		t.Errorf("parseChecksums() = %v", got)
+	}
+}
+
+func TestVerifyBinary(t *testing.T) {
+	binary := []byte("published tim")
+	sum := sha256.Sum256(binary)
+	files := map[string]string{
+		"/checksums.txt":   hex.EncodeToString(sum[:]) + "  tim_linux_amd64\n",
+		"/tim_linux_amd64": string(binary),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(files[r.URL.Path]))
+	}))
+	defer server.Close()
+
+	release := &TimRelease{
+		TagName: "v1.2.0",
+		Assets: []ReleaseAsset{
+			{Name: "checksums.txt", URL: server.URL + "/checksums.txt"},
+			{Name: "tim_linux_amd64", URL: server.URL + "/tim_linux_amd64"},
+		},
+	}
+	ctx := context.Background()
+	if err := release.VerifyBinary(ctx, "linux", "amd64", binary); err != nil {
+		t.Errorf("VerifyBinary() of the published binary = %v; want nil", err)
+	}
+	if err := release.VerifyBinary(ctx, "linux", "amd64", []byte("modified tim")); !errors.Is(err, ErrBinaryMismatch) {
+		t.Errorf("VerifyBinary() of a modified binary = %v; want ErrBinaryMismatch", err)
+	}
+}
@@ -0,0 +1,213 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Plugins that save tmux sessions so they can be restored. Continuum
+// saves with resurrect, so both keep sessions in resurrect's directory.
+var sessionPlugins = []string{
+	"tmux-plugins/tmux-resurrect",
+	"tmux-plugins/tmux-continuum",
+}
+
+// The tmux option setting where resurrect saves sessions.
+const resurrectDirOption = "@resurrect-dir"
+
+// Returns true if the plugin saves tmux sessions.
+func IsSessionPlugin(name string) bool {
+	return slices.Contains(sessionPlugins, name)
+}
+
+// Returns true if any plugin in the config file saves tmux sessions.
+func (lf *Lockfile) HasSessionPlugin() bool {
+	for _, name := range sessionPlugins {
+		if _, ok := lf.PluginSpecs[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the directory tmux sessions are saved in, resolved the way
+// resurrect does: from the @resurrect-dir option in the config file or
+// the running tmux server, or else resurrect's default.
+func (lf *Lockfile) SessionDir() (string, error) {
+	for _, name := range sessionPlugins {
+		if dir := lf.PluginSpecs[name].Options[resurrectDirOption]; dir != "" {
+			return expandSessionDir(dir)
+		}
+	}
+	if TmuxServerRunning() {
+		out, err := exec.Command("tmux", "show-option", "-gqv", resurrectDirOption).Output()
+		if dir := strings.TrimSpace(string(out)); err == nil && dir != "" {
+			return expandSessionDir(dir)
+		}
+	}
+	return DefaultSessionDir()
+}
+
+// Returns the directory resurrect saves sessions in by default.
+func DefaultSessionDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	// Older versions of resurrect saved here, and still do if it exists.
+	legacy := filepath.Join(home, ".tmux/resurrect")
+	if info, err := os.Stat(legacy); err == nil && info.IsDir() {
+		return legacy, nil
+	}
+
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(home, ".local/share")
+	}
+	return filepath.Join(dataHome, "tmux/resurrect"), nil
+}
+
+// Expands ~, $HOME and $HOSTNAME in a @resurrect-dir, as resurrect does.
+func expandSessionDir(dir string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	hostname, _ := os.Hostname()
+
+	if dir == "~" || strings.HasPrefix(dir, "~/") {
+		dir = home + dir[1:]
+	}
+	dir = strings.NewReplacer(
+		"${HOME}", home, "$HOME", home,
+		"${HOSTNAME}", hostname, "$HOSTNAME", hostname,
+	).Replace(dir)
+	return filepath.Clean(dir), nil
+}
+
+// Checks that the session directory exists and that sessions can be
+// saved in it.
+func CheckSessionDir(dir string) error {
+	info, err := os.Stat(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return errors.New("it does not exist, so no sessions have been saved yet")
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.New("it is not a directory")
+	}
+
+	probe, err := os.CreateTemp(dir, ".tim-doctor-*")
+	if err != nil {
+		return errors.New("it is not writable, so sessions can't be saved")
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+// Returns true if path is dir, or inside it.
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// Returns true if the plugin's directory holds the saved sessions in
+// sessionDir, as happens when @resurrect-dir points inside it.
+func (p *Plugin) HoldsSessions(sessionDir string) (bool, error) {
+	pluginDir, err := p.Dir()
+	if err != nil {
+		return false, err
+	}
+	return isWithin(sessionDir, pluginDir), nil
+}
+
+// Writes the saved sessions in sessionDir to a .tar.gz archive at dest.
+func ExportSessions(sessionDir, dest string) error {
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if err := writeSessionsArchive(out, sessionDir); err != nil {
+		os.Remove(dest)
+		return err
+	}
+	return out.Close()
+}
+
+func writeSessionsArchive(out io.Writer, sessionDir string) error {
+	compressed := gzip.NewWriter(out)
+	archive := tar.NewWriter(compressed)
+	err := filepath.WalkDir(sessionDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		// Resurrect links "last" to the latest save.
+		link := ""
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(filepath.Dir(sessionDir), path)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(archive, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return compressed.Close()
+}
@@ -0,0 +1,62 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"os"
+	"testing"
+)
+
+func TestExpandSessionDir(t *testing.T) {
+	t.Setenv("HOME", "/home/user")
+	hostname, _ := os.Hostname()
+
+	tests := []struct {
+		dir, want string
+	}{
+		{"~/.tmux/resurrect", "/home/user/.tmux/resurrect"},
+		{"$HOME/sessions/", "/home/user/sessions"},
+		{"${HOME}/sessions/$HOSTNAME", "/home/user/sessions/" + hostname},
+		{"/var/sessions", "/var/sessions"},
+	}
+	for _, test := range tests {
+		got, err := expandSessionDir(test.dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("expandSessionDir(%q) = %q; want %q", test.dir, got, test.want)
+		}
+	}
+}
+
+func TestIsWithin(t *testing.T) {
+	tests := []struct {
+		path, dir string
+		want      bool
+	}{
+		{"/a/b/c", "/a/b", true},
+		{"/a/b", "/a/b", true},
+		{"/a/bc", "/a/b", false},
+		{"/a", "/a/b", false},
+		{"/a/..b", "/a", true},
+	}
+	for _, test := range tests {
+		if got := isWithin(test.path, test.dir); got != test.want {
+			t.Errorf("isWithin(%q, %q) = %v; want %v", test.path, test.dir, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Returned when a plugin has verify_signatures set, and the version being
+// checked out is not signed by a trusted key.
+var ErrBadSignature = errors.New("signature verification failed")
+
+// Matches the fingerprints of GPG signatures in the output of
+// "git verify-tag --raw", the key and then its primary key.
+var gpgValidSig = regexp.MustCompile(`(?m)^\[GNUPG:\] VALIDSIG ([0-9A-Fa-f]+) .* ([0-9A-Fa-f]+)$`)
+
+// Matches the fingerprints of SSH signatures, such as
+// `Good "git" signature for a@b.c with ED25519 key SHA256:abc`.
+var sshValidSig = regexp.MustCompile(`(?m)^Good "git" signature .* key (SHA256:\S+)$`)
+
+// Returns the ref version is checked out from: the tag of a semantic
+// version, or the latest commit of a branch.
+func versionRef(version Version) string {
+	if gitVersion, ok := version.(*GitVersion); ok {
+		return "origin/" + gitVersion.branch
+	}
+	return version.GitRef()
+}
+
+// Checks that ref, a tag or a commit, in the repository at pluginDir is
+// signed by one of the plugin's trusted keys, or by any key git trusts if
+// it has none. Does nothing unless the plugin has verify_signatures set.
+func (p *Plugin) verifySignature(ctx context.Context, pluginDir, ref string) error {
+	if !p.VerifySignatures {
+		return nil
+	}
+
+	tag := HasCommit(ctx, pluginDir, "refs/tags/"+ref)
+	output, err := Git.VerifySignature(ctx, pluginDir, ref, tag)
+	if err != nil {
+		return fmt.Errorf("%w: %s of plugin %s is not signed by a key git trusts: %s",
+			ErrBadSignature, ref, p.Name, signatureFailure(output, err))
+	}
+
+	if len(p.TrustedKeys) == 0 {
+		return nil
+	}
+	signers := signingKeys(output)
+	// Trusted keys are full fingerprints, checked when the config file is
+	// read, as short key IDs are easily forged.
+	for _, key := range p.TrustedKeys {
+		if slices.Contains(signers, normalizeKey(key)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s of plugin %s is signed by %s, which is not one of its trusted keys",
+		ErrBadSignature, ref, p.Name, strings.Join(signers, ", "))
+}
+
+// Deletes the plugin if it was just cloned and err is ErrBadSignature, as
+// its default branch is checked out and would otherwise be loaded.
+// Returns err.
+func (p *Plugin) discardUnsigned(err error, cloned bool) error {
+	if cloned && errors.Is(err, ErrBadSignature) {
+		if uninstallErr := p.Uninstall(); uninstallErr != nil {
+			return errors.Join(err, uninstallErr)
+		}
+	}
+	return err
+}
+
+// Returns the fingerprints of the keys that made the valid signatures
+// in the output of git verify-tag or verify-commit.
+func signingKeys(output string) []string {
+	keys := make([]string, 0)
+	for _, match := range gpgValidSig.FindAllStringSubmatch(output, -1) {
+		keys = append(keys, normalizeKey(match[1]), normalizeKey(match[2]))
+	}
+	for _, match := range sshValidSig.FindAllStringSubmatch(output, -1) {
+		keys = append(keys, normalizeKey(match[1]))
+	}
+	return slices.Compact(keys)
+}
+
+// Formats a key fingerprint for comparison, so fingerprints copied with
+// spaces or in lower case still match. SSH fingerprints are left as they
+// are, as they are case sensitive.
+func normalizeKey(key string) string {
+	key = strings.TrimSpace(key)
+	if strings.HasPrefix(key, "SHA256:") {
+		return key
+	}
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimPrefix(key, "0x"), " ", ""))
+}
+
+// Returns an error unless key is the full fingerprint of a GPG key, 40 or
+// 64 hex digits, or of an SSH key, such as "SHA256:xNq1...".
+func checkTrustedKey(key string) error {
+	key = normalizeKey(key)
+	if fingerprint, ok := strings.CutPrefix(key, "SHA256:"); ok {
+		// The base64 of a SHA256 hash, without padding.
+		if len(fingerprint) == 43 {
+			return nil
+		}
+	} else if gpgFingerprint.MatchString(key) {
+		return nil
+	}
+	return fmt.Errorf("trusted key %q is not a full fingerprint, short key IDs are easily forged", key)
+}
+
+// Matches the full fingerprints of v4 and v5 GPG keys.
+var gpgFingerprint = regexp.MustCompile(`^([0-9A-F]{40}|[0-9A-F]{64})$`)
+
+// Returns why verifying failed, from git's output if it explains.
+func signatureFailure(output string, err error) string {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "[GNUPG:]") {
+			return line
+		}
+	}
+	return err.Error()
+}
@@ -0,0 +1,122 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"os/exec"
+	"reflect"
+	"regexp"
+	"slices"
+	"testing"
+
+	"github.com/kjnsn/tim/lib/gittest"
+)
+
+func TestSigningKeys(t *testing.T) {
+	tests := []struct {
+		output string
+		want   []string
+	}{
+		{
+			"[GNUPG:] NEWSIG\n" +
+				"[GNUPG:] GOODSIG BBF6EACCAC7DB4E0 Tester <t@example.com>\n" +
+				"[GNUPG:] VALIDSIG 84F69D1D03E817CDB460C614BBF6EACCAC7DB4E0 2026-10-16 1792163443 0 4 0 22 8 00 84F69D1D03E817CDB460C614BBF6EACCAC7DB4E0\n",
+			[]string{"84F69D1D03E817CDB460C614BBF6EACCAC7DB4E0"},
+		},
+		{
+			`Good "git" signature for t@example.com with ED25519 key SHA256:xNq1tMOb2gKCr6xKr+YyVn5Nk1sQp4Eh6l0q3f6nN0c` + "\n",
+			[]string{"SHA256:xNq1tMOb2gKCr6xKr+YyVn5Nk1sQp4Eh6l0q3f6nN0c"},
+		},
+		{"error: no signature found\n", []string{}},
+	}
+
+	for _, test := range tests {
+		if got := signingKeys(test.output); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("signingKeys(%q) = %v; want %v", test.output, got, test.want)
+		}
+	}
+}
+
+func TestNormalizeKey(t *testing.T) {
+	for key, want := range map[string]string{
+		"84f6 9d1d 03e8":     "84F69D1D03E8",
+		"0xbbf6eaccac7db4e0": "BBF6EACCAC7DB4E0",
+		"SHA256:abcDEF":      "SHA256:abcDEF",
+	} {
+		if got := normalizeKey(key); got != want {
+			t.Errorf("normalizeKey(%q) = %q; want %q", key, got, want)
+		}
+	}
+}
+
+func TestCheckTrustedKey(t *testing.T) {
+	for key, valid := range map[string]bool{
+		"84F69D1D03E817CDB460C614BBF6EACCAC7DB4E0":           true,
+		"84f6 9d1d 03e8 17cd b460 c614 bbf6 eacc ac7d b4e0":  true,
+		"SHA256:xNq1tMOb2gKCr6xKr+YyVn5Nk1sQp4Eh6l0q3f6nN0c": true,
+		"BBF6EACCAC7DB4E0": false,
+		"AC7DB4E0":         false,
+		"":                 false,
+		"SHA256:":          false,
+	} {
+		if err := checkTrustedKey(key); (err == nil) != valid {
+			t.Errorf("checkTrustedKey(%q) = %v; want valid %t", key, err, valid)
+		}
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg is not installed")
+	}
+	defer func() { Git = execGitClient{} }()
+
+	t.Setenv("GNUPGHOME", t.TempDir())
+	t.Cleanup(func() { _ = exec.Command("gpgconf", "--kill", "gpg-agent").Run() })
+	gen := exec.Command("gpg", "--batch", "--passphrase", "", "--quick-gen-key", "Tester <t@example.com>", "ed25519", "sign", "never")
+	if out, err := gen.CombinedOutput(); err != nil {
+		t.Fatalf("gpg --quick-gen-key: %v\n%s", err, out)
+	}
+	out, err := exec.Command("gpg", "--with-colons", "--list-keys").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fingerprint := regexp.MustCompile(`(?m)^fpr:+([0-9A-F]+):`).FindStringSubmatch(string(out))[1]
+
+	repo := gittest.New(t)
+	repo.Git("config", "user.signingkey", fingerprint)
+	unsigned := repo.Commit("unsigned", nil)
+	repo.Git("commit", "-q", "-S", "--allow-empty", "-m", "signed")
+	signed := repo.Head()
+	repo.Git("tag", "-s", "-m", "v1.0.0", "v1.0.0")
+
+	for _, backend := range []GitClient{execGitClient{}, goGitClient{}} {
+		Git = backend
+		for _, test := range []struct {
+			ref string
+			tag bool
+		}{{signed, false}, {"v1.0.0", true}} {
+			output, err := Git.VerifySignature(context.Background(), repo.Dir, test.ref, test.tag)
+			if keys := signingKeys(output); err != nil || !slices.Contains(keys, fingerprint) {
+				t.Errorf("%T: VerifySignature(%s) = %v, %v; want signed by %s", backend, test.ref, keys, err, fingerprint)
+			}
+		}
+		if _, err := Git.VerifySignature(context.Background(), repo.Dir, unsigned, false); err == nil {
+			t.Errorf("%T: VerifySignature() of an unsigned commit = nil; want an error", backend)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Markers around the block of the tmux config that tim writes snippets to.
+const (
+	managedBlockBegin = "# BEGIN tim managed block"
+	managedBlockEnd   = "# END tim managed block"
+)
+
+// Starts the comment above each snippet in the managed block.
+const snippetHeaderPrefix = "# snippet "
+
+// Matches the comment above snippets inserted by older releases of tim,
+// "# <plugin>: <path>", capturing the plugin name.
+var legacySnippetHeaderRegexp = regexp.MustCompile(`^# ([^\s:]+/[^\s:]+): \S+$`)
+
+// Directories searched for example configs when a plugin has no manifest.
+var snippetDirs = []string{".", "example", "examples", "snippets", "conf"}
+
+// Matches `set -g name value` and its variations, capturing the flags,
+// option name and value.
+var setOptionRegexp = regexp.MustCompile(`^\s*(?:set|set-option|setw|set-window-option)((?:\s+-[a-zA-Z]+)*)\s+([^\s'"#]+)\s*(.*?)\s*$`)
+
+// Matches the line loading plugins with tim, which may run tim by its
+// full path.
+var timLoadRegexp = regexp.MustCompile(`^\s*run(?:-shell)?\s+(?:-b\s+)?.*\btim'?\s+load\b`)
+
+// An example tmux config shipped by a plugin.
+type Snippet struct {
+	// Path of the snippet relative to the plugin directory.
+	Path string
+
+	Content string
+}
+
+// An option set by a snippet to a different value than the tmux config.
+type SnippetConflict struct {
+	Option   string
+	Existing string
+	Snippet  string
+}
+
+// Finds the example configs shipped by the plugin. Snippets listed in the
+// plugin's manifest are used if there are any, otherwise well known
+// directories are searched for files like "example.conf" or "tmux.conf.example".
+func (p *Plugin) Snippets() ([]Snippet, error) {
+	pluginDir, err := p.Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := p.Manifest()
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0)
+	if manifest != nil && len(manifest.Snippets) > 0 {
+		for _, snippetPath := range manifest.Snippets {
+			if !filepath.IsLocal(snippetPath) {
+				return nil, fmt.Errorf("snippet %s in %s is outside the plugin directory", snippetPath, ManifestFileName)
+			}
+			paths = append(paths, path.Clean(snippetPath))
+		}
+	} else {
+		for _, dir := range snippetDirs {
+			entries, err := os.ReadDir(path.Join(pluginDir, dir))
+			if err != nil {
+				continue
+			}
+			for _, entry := range entries {
+				if entry.Type().IsRegular() && isSnippetName(entry.Name()) {
+					paths = append(paths, path.Join(dir, entry.Name()))
+				}
+			}
+		}
+	}
+
+	snippets := make([]Snippet, 0, len(paths))
+	for _, snippetPath := range paths {
+		content, err := os.ReadFile(path.Join(pluginDir, snippetPath))
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, Snippet{Path: snippetPath, Content: string(content)})
+	}
+	return snippets, nil
+}
+
+// Checks if a file name looks like a tmux config.
+func isSnippetName(name string) bool {
+	return strings.HasSuffix(name, ".conf") || strings.Contains(name, ".conf.")
+}
+
+// Returns the options set in a tmux config and their values. Appending
+// to an option with "-a" is not included, as it never conflicts.
+func TmuxConfigOptions(tmuxConfig string) map[string]string {
+	options := make(map[string]string)
+	for _, line := range strings.Split(tmuxConfig, "\n") {
+		match := setOptionRegexp.FindStringSubmatch(line)
+		if match == nil || strings.Contains(match[1], "a") {
+			continue
+		}
+		options[match[2]] = strings.Trim(match[3], `'"`)
+	}
+	return options
+}
+
+// Returns the options the snippet sets to a different value than the tmux config.
+func SnippetConflicts(tmuxConfig string, snippet Snippet) []SnippetConflict {
+	existing := TmuxConfigOptions(tmuxConfig)
+	conflicts := make([]SnippetConflict, 0)
+	for _, line := range strings.Split(snippet.Content, "\n") {
+		match := setOptionRegexp.FindStringSubmatch(line)
+		if match == nil || strings.Contains(match[1], "a") {
+			continue
+		}
+		value := strings.Trim(match[3], `'"`)
+		if current, ok := existing[match[2]]; ok && current != value {
+			conflicts = append(conflicts, SnippetConflict{
+				Option:   match[2],
+				Existing: current,
+				Snippet:  value,
+			})
+		}
+	}
+	return conflicts
+}
+
+// Adds the snippet to the tim managed block of the tmux config, creating
+// the block before the line running "tim load" if it does not exist.
+// Returns the new config, and whether any changes were made.
+func InsertSnippet(tmuxConfig, pluginName string, snippet Snippet) (string, bool) {
+	if slices.Contains(strings.Split(tmuxConfig, "\n"), legacySnippetHeader(pluginName, snippet.Path)) {
+		return tmuxConfig, false
+	}
+	return insertManaged(tmuxConfig, snippetHeader(pluginName, snippet.Path), snippet.Content)
+}
+
+// Adds content under the header comment to the tim managed block of the
+// tmux config, unless the header is already there.
+func insertManaged(tmuxConfig, header, content string) (string, bool) {
+	lines := strings.Split(tmuxConfig, "\n")
+	// A header can be the start of a longer one, such as the snippet
+	// "example.conf" of "example.conf.sample".
+	if slices.Contains(lines, header) {
+		return tmuxConfig, false
+	}
+	content = header + "\n" + strings.TrimRight(content, "\n")
+
+	for i, line := range lines {
+		if line == managedBlockEnd {
+			return joinLines(lines[:i], content, lines[i:]), true
+		}
+	}
+
+	block := managedBlockBegin + "\n" + content + "\n" + managedBlockEnd
+	for i, line := range lines {
+		if timLoadRegexp.MatchString(line) {
+			return joinLines(lines[:i], block, lines[i:]), true
+		}
+	}
+	if lines[len(lines)-1] == "" {
+		return joinLines(lines[:len(lines)-1], block, []string{""}), true
+	}
+	return joinLines(lines, block, nil), true
+}
+
+// Removes the plugin's snippets from the tim managed block of the tmux
+// config, and the block itself if nothing is left in it. Returns the new
+// config, and whether any changes were made.
+func RemoveSnippets(tmuxConfig, pluginName string) (string, bool) {
+	prefix := snippetHeader(pluginName, "")
+	return removeManaged(tmuxConfig, func(header string) bool {
+		if match := legacySnippetHeaderRegexp.FindStringSubmatch(header); match != nil {
+			return match[1] == pluginName
+		}
+		return strings.HasPrefix(header, prefix)
+	})
+}
+
+// Removes the entries of the tim managed block whose header comment
+// matches, and the block itself if nothing is left in it.
+func removeManaged(tmuxConfig string, matches func(header string) bool) (string, bool) {
+	lines := strings.Split(tmuxConfig, "\n")
+	kept := make([]string, 0, len(lines))
+	inBlock, removing, changed := false, false, false
+	blockStart := 0
+
+	for _, line := range lines {
+		switch {
+		case line == managedBlockBegin:
+			inBlock = true
+			blockStart = len(kept)
+		case line == managedBlockEnd:
+			inBlock, removing = false, false
+			if changed && blockStart == len(kept)-1 {
+				kept = kept[:blockStart]
+				continue
+			}
+		case inBlock && isManagedHeader(line):
+			removing = matches(line)
+		}
+
+		if removing {
+			changed = true
+			continue
+		}
+		kept = append(kept, line)
+	}
+
+	return strings.Join(kept, "\n"), changed
+}
+
+// Checks if a line of the managed block starts a snippet or hook.
+func isManagedHeader(line string) bool {
+	return strings.HasPrefix(line, snippetHeaderPrefix) || strings.HasPrefix(line, hookHeaderPrefix) ||
+		legacySnippetHeaderRegexp.MatchString(line)
+}
+
+// Returns the comment written above a snippet in the managed block.
+func snippetHeader(pluginName, snippetPath string) string {
+	return fmt.Sprintf("%s%s: %s", snippetHeaderPrefix, pluginName, snippetPath)
+}
+
+// Returns the comment older releases of tim wrote above a snippet.
+func legacySnippetHeader(pluginName, snippetPath string) string {
+	return fmt.Sprintf("# %s: %s", pluginName, snippetPath)
+}
+
+// Joins the lines before, the inserted text and the lines after.
+func joinLines(before []string, inserted string, after []string) string {
+	joined := make([]string, 0, len(before)+len(after)+1)
+	joined = append(joined, before...)
+	joined = append(joined, inserted)
+	joined = append(joined, after...)
+	return strings.Join(joined, "\n")
+}
@@ -0,0 +1,121 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"strings"
+	"testing"
+)
+
+const snippetTmuxConfig = `set -g mouse on
+set -g @theme 'dark'
+set -ga terminal-overrides ',xterm*:Tc'
+run "tim load"
+`
+
+func TestSnippetConflicts(t *testing.T) {
+	snippet := Snippet{Path: "example.conf", Content: `set -g @theme "light"
+set -g mouse on
+set -ga terminal-overrides ',screen*:Tc'
+set -g @other 'value'`}
+
+	conflicts := SnippetConflicts(snippetTmuxConfig, snippet)
+	want := SnippetConflict{Option: "@theme", Existing: "dark", Snippet: "light"}
+	if len(conflicts) != 1 || conflicts[0] != want {
+		t.Errorf("SnippetConflicts() = %v; want [%v]", conflicts, want)
+	}
+}
+
+func TestInsertSnippet(t *testing.T) {
+	first := Snippet{Path: "example.conf", Content: "set -g @theme 'dark'\n"}
+	second := Snippet{Path: "examples/extra.conf", Content: "set -g @extra on\n"}
+
+	got, changed := InsertSnippet(snippetTmuxConfig, "user/plugin", first)
+	if !changed {
+		t.Fatalf("InsertSnippet() made no changes")
+	}
+	got, _ = InsertSnippet(got, "user/plugin", second)
+
+	want := `# BEGIN tim managed block
+# snippet user/plugin: example.conf
+set -g @theme 'dark'
+# snippet user/plugin: examples/extra.conf
+set -g @extra on
+# END tim managed block
+run "tim load"
+`
+	if !strings.HasSuffix(got, want) {
+		t.Errorf("InsertSnippet() = %q; want suffix %q", got, want)
+	}
+
+	if _, changed := InsertSnippet(got, "user/plugin", first); changed {
+		t.Errorf("InsertSnippet() inserted a snippet twice")
+	}
+
+	// The header of example.conf starts that of example.conf.sample.
+	sample := Snippet{Path: "example.conf.sample", Content: "set -g @theme 'light'\n"}
+	got, _ = InsertSnippet(snippetTmuxConfig, "user/plugin", sample)
+	if _, changed := InsertSnippet(got, "user/plugin", first); !changed {
+		t.Errorf("InsertSnippet() of example.conf made no changes after example.conf.sample")
+	}
+}
+
+func TestRemoveSnippets(t *testing.T) {
+	config, _ := InsertSnippet(snippetTmuxConfig, "user/plugin", Snippet{Path: "example.conf", Content: "# Note: a comment\nset -g @theme 'dark'"})
+	config, _ = InsertSnippet(config, "other/plugin", Snippet{Path: "other.conf", Content: "set -g @other on"})
+
+	got, changed := RemoveSnippets(config, "user/plugin")
+	if !changed {
+		t.Fatalf("RemoveSnippets() made no changes")
+	}
+	want, _ := InsertSnippet(snippetTmuxConfig, "other/plugin", Snippet{Path: "other.conf", Content: "set -g @other on"})
+	if got != want {
+		t.Errorf("RemoveSnippets() = %q; want %q", got, want)
+	}
+
+	got, _ = RemoveSnippets(got, "other/plugin")
+	if got != snippetTmuxConfig {
+		t.Errorf("RemoveSnippets() of every snippet = %q; want %q", got, snippetTmuxConfig)
+	}
+}
+
+func TestLegacySnippetHeaders(t *testing.T) {
+	config := `set -g mouse on
+# BEGIN tim managed block
+# user/plugin: example.conf
+set -g @theme 'dark'
+# other/plugin: other.conf
+set -g @other on
+# END tim managed block
+run "tim load"
+`
+
+	if _, changed := InsertSnippet(config, "user/plugin", Snippet{Path: "example.conf", Content: "set -g @theme 'dark'"}); changed {
+		t.Errorf("InsertSnippet() inserted a snippet already under an older header")
+	}
+
+	got, _ := RemoveSnippets(config, "user/plugin")
+	want := `set -g mouse on
+# BEGIN tim managed block
+# other/plugin: other.conf
+set -g @other on
+# END tim managed block
+run "tim load"
+`
+	if got != want {
+		t.Errorf("RemoveSnippets() = %q; want %q", got, want)
+	}
+}
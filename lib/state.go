@@ -0,0 +1,82 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+)
+
+// Gets the tim state directory, creating it if it does not already exist.
+// State is data tim generates that is not worth backing up, such as
+// caches and logs. The directory is $XDG_STATE_HOME/tim, usually
+// "~/.local/state/tim".
+func GetStateDir() (string, error) {
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		stateHome = path.Join(homeDir, ".local/state")
+	}
+
+	stateDir := path.Join(stateHome, "tim")
+	if err := os.MkdirAll(stateDir, 0750); err != nil {
+		return "", err
+	}
+	return stateDir, nil
+}
+
+// Reads the JSON state file with the given name into v. A missing
+// file leaves v unchanged.
+func readStateFile(name string, v any) error {
+	stateDir, err := GetStateDir()
+	if err != nil {
+		return err
+	}
+
+	contents, err := os.ReadFile(path.Join(stateDir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(contents, v)
+}
+
+// Writes v to the JSON state file with the given name.
+func writeStateFile(name string, v any) error {
+	stateDir, err := GetStateDir()
+	if err != nil {
+		return err
+	}
+
+	encoded, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	statePath := path.Join(stateDir, name)
+	if err := os.WriteFile(statePath+".tmp", append(encoded, '\n'), 0600); err != nil {
+		return err
+	}
+	return os.Rename(statePath+".tmp", statePath)
+}
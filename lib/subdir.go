@@ -0,0 +1,150 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/kjnsn/tim/lib/message"
+)
+
+// Plugins can be a subdirectory of a repository holding several, named
+// like "user/repo//path/to/plugin". Each has its own clone of the
+// repository, limited to the subdirectory with sparse checkout, and is
+// loaded from the subdirectory.
+const subdirSeparator = "//"
+
+// Splits a plugin name or argument into its repository and the
+// subdirectory the plugin is in, which is empty for plugins that are a
+// whole repository. The "//" of a URL's scheme is not a separator.
+func splitSubdir(arg string) (string, string) {
+	start := 0
+	if i := strings.Index(arg, "://"); i >= 0 {
+		start = i + len("://")
+	}
+	i := strings.Index(arg[start:], subdirSeparator)
+	if i < 0 {
+		return arg, ""
+	}
+	return arg[:start+i], arg[start+i+len(subdirSeparator):]
+}
+
+// Cleans the subdirectory of a plugin argument, which must be inside
+// the repository.
+func cleanSubdir(arg, subdir string) (string, error) {
+	cleaned := path.Clean(strings.Trim(subdir, "/"))
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", WithErrorCode(CodeInvalidPlugin,
+			fmt.Errorf("invalid plugin %q: the path after // must be a directory in the repository", arg))
+	}
+	return cleaned, nil
+}
+
+// Returns the name of the repository the plugin is in, which is its name
+// unless it is in a subdirectory.
+func (p *Plugin) RepoName() string {
+	repo, _ := splitSubdir(p.Name)
+	return repo
+}
+
+// Returns the subdirectory of its repository the plugin is in, empty if
+// it is the whole repository.
+func (p *Plugin) Subdir() string {
+	_, subdir := splitSubdir(p.Name)
+	return subdir
+}
+
+// Returns the directory the plugin's repository is cloned to, which is
+// Dir unless the plugin is in a subdirectory of it.
+func (p *Plugin) RepoDir() (string, error) {
+	root := p.Root
+	if root == "" {
+		pluginsDir, err := GetPluginsDir()
+		if err != nil {
+			return "", err
+		}
+		root = pluginsDir
+	}
+	return path.Join(root, repoDirName(p.Name)), nil
+}
+
+// Returns the directory of the plugins directory that a plugin's
+// repository is cloned to. Plugins in a subdirectory of a repository
+// get their own clone, such as "user/repo@path%2Fto%2Fplugin".
+func repoDirName(name string) string {
+	repo, subdir := splitSubdir(name)
+	if subdir == "" {
+		return name
+	}
+	return repo + "@" + url.QueryEscape(subdir)
+}
+
+// Returns the name of the plugin cloned to dir, the opposite of
+// repoDirName.
+func pluginNameOfDir(dir string) string {
+	repo, escaped, ok := strings.Cut(dir, "@")
+	if !ok {
+		return dir
+	}
+	subdir, err := url.QueryUnescape(escaped)
+	if err != nil {
+		return dir
+	}
+	return repo + subdirSeparator + subdir
+}
+
+// Limits the clone of a plugin in a subdirectory to it. The whole
+// repository stays checked out if the git backend can't.
+func (p *Plugin) sparseCheckout(ctx context.Context, repoDir string) {
+	subdir := p.Subdir()
+	if subdir == "" {
+		return
+	}
+	if err := Git.SparseCheckout(ctx, repoDir, subdir); err != nil {
+		message.Debug("Unable to check out only %s of plugin %s: %s", subdir, p.Name, err)
+	}
+}
+
+// Returns an error if the plugin is in a subdirectory that the version
+// checked out does not have.
+func (p *Plugin) checkSubdir() error {
+	if p.Subdir() == "" {
+		return nil
+	}
+	pluginDir, err := p.Dir()
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(pluginDir); err != nil || !info.IsDir() {
+		return fmt.Errorf("plugin %s: the repository has no directory %s at %s", p.Name, p.Subdir(), p.Version)
+	}
+	return nil
+}
+
+// Returns the tag pattern of the plugin. Releases of a plugin in a
+// subdirectory are tags prefixed with it, like those of Go modules, such
+// as "path/to/plugin/v1.2.0", unless its tag pattern says otherwise.
+func (p *Plugin) tagPattern() *TagPattern {
+	if p.TagPattern != nil || p.Subdir() == "" {
+		return p.TagPattern
+	}
+	return &TagPattern{pattern: p.Subdir() + "/v*"}
+}
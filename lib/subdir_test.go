@@ -0,0 +1,66 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/kjnsn/tim/lib/gittest"
+)
+
+func TestPluginNameOfDir(t *testing.T) {
+	for _, name := range []string{"user/repo", "gitlab.com/group/repo//tmux/plugin one"} {
+		if got := pluginNameOfDir(repoDirName(name)); got != name {
+			t.Errorf("pluginNameOfDir(repoDirName(%q)) = %q", name, got)
+		}
+	}
+}
+
+func TestInstallSubdir(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	remote := gittest.New(t)
+	remote.Commit("plugins", map[string]string{
+		"plugins/one/one.tmux": "#!/bin/sh\n",
+		"plugins/two/two.tmux": "#!/bin/sh\n",
+	})
+	remote.Tag("v2.0.0")
+	remote.Tag("plugins/one/v1.0.0")
+	remote.Advance()
+
+	plugin := Plugin{Name: "user/repo//plugins/one", Remote: remote.Dir, Root: t.TempDir()}
+	if err := plugin.Install(context.Background(), ""); err != nil {
+		t.Fatal(err)
+	}
+	if plugin.Version.String() != "plugins/one/v1.0.0" {
+		t.Errorf("Install() installed %v; want the tag of the subdirectory, plugins/one/v1.0.0", plugin.Version)
+	}
+	entrypoints, err := plugin.Entrypoints()
+	if err != nil || len(entrypoints) != 1 || path.Base(entrypoints[0]) != "one.tmux" {
+		t.Errorf("Entrypoints() = %v, %v; want one.tmux", entrypoints, err)
+	}
+	repoDir, _ := plugin.RepoDir()
+	if _, err := os.Stat(path.Join(repoDir, "plugins", "two")); !os.IsNotExist(err) {
+		t.Errorf("plugins/two is checked out, want only plugins/one: %v", err)
+	}
+
+	missing := Plugin{Name: "user/repo//plugins/three", Remote: remote.Dir, Root: t.TempDir()}
+	if err := missing.Install(context.Background(), ""); err == nil {
+		t.Errorf("Install() of a missing subdirectory returned no error")
+	}
+}
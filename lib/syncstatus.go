@@ -0,0 +1,96 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"slices"
+	"time"
+)
+
+const syncStatusFile = "sync.json"
+
+// The outcome of installing each plugin the last time the config file
+// was synced, persisted in the state directory so that just the plugins
+// that failed can be retried.
+type SyncStatus struct {
+	// The config file that was synced.
+	Config string `json:"config"`
+
+	// When the sync started.
+	Started time.Time `json:"started"`
+
+	Plugins map[string]*PluginSyncStatus `json:"plugins"`
+}
+
+// The outcome of installing a single plugin.
+type PluginSyncStatus struct {
+	Done  bool      `json:"done"`
+	Error string    `json:"error,omitempty"`
+	Code  ErrorCode `json:"code,omitempty"`
+}
+
+// Starts a new sync of the config file at configPath, forgetting the
+// last one.
+func NewSyncStatus(configPath string) *SyncStatus {
+	return &SyncStatus{
+		Config:  configPath,
+		Started: time.Now().UTC().Truncate(time.Second),
+		Plugins: make(map[string]*PluginSyncStatus),
+	}
+}
+
+// Reads the status of the last sync from the state directory. It is nil
+// if there has not been one.
+func GetSyncStatus() (*SyncStatus, error) {
+	status := &SyncStatus{}
+	if err := readStateFile(syncStatusFile, status); err != nil {
+		return nil, err
+	}
+	if status.Plugins == nil {
+		return nil, nil
+	}
+	return status, nil
+}
+
+// Writes the sync status to the state directory.
+func (s *SyncStatus) Save() error {
+	return writeStateFile(syncStatusFile, s)
+}
+
+// Records that the plugin was installed.
+func (s *SyncStatus) RecordDone(name string) {
+	s.Plugins[name] = &PluginSyncStatus{Done: true}
+}
+
+// Records that the plugin failed to install.
+func (s *SyncStatus) RecordFailure(name string, err error) {
+	s.Plugins[name] = &PluginSyncStatus{
+		Error: err.Error(),
+		Code:  ErrorCodeOf(err),
+	}
+}
+
+// Returns the names of the plugins that failed to install, sorted.
+func (s *SyncStatus) Failed() []string {
+	failed := []string{}
+	for name, status := range s.Plugins {
+		if !status.Done {
+			failed = append(failed, name)
+		}
+	}
+	slices.Sort(failed)
+	return failed
+}
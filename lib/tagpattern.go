@@ -0,0 +1,121 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// Which tags of a plugin are releases, set by the plugin's tag_pattern key
+// in the config file, for plugins tagged like "1.2.3" or "release-2024.05"
+// rather than "v1.2.3".
+//
+// A pattern is a glob, such as "release-*", or a regular expression between
+// slashes, such as "/^stable-(.*)$/". The version of a tag is what the
+// glob's wildcards match, or the regular expression's first group, made
+// comparable by normalizeVersion.
+type TagPattern struct {
+	pattern string
+	regexp  *regexp.Regexp
+}
+
+// Returns the tag pattern, nil if it is empty.
+func ParseTagPattern(pattern string) (*TagPattern, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, err
+		}
+		return &TagPattern{pattern: pattern, regexp: re}, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("%w: %q", err, pattern)
+	}
+	// The version is what the wildcards match, so there must be one.
+	if !strings.ContainsAny(pattern, "*?[") {
+		return nil, fmt.Errorf("tag pattern %q has no wildcard, such as \"release-*\"", pattern)
+	}
+	return &TagPattern{pattern: pattern}, nil
+}
+
+func (tp *TagPattern) String() string {
+	if tp == nil {
+		return ""
+	}
+	return tp.pattern
+}
+
+// Returns the semantic version of tag, and whether it is a release. Only
+// tags that are semantic versions are releases when tp is nil.
+func (tp *TagPattern) version(tag string) (string, bool) {
+	if tp == nil {
+		return tag, semver.IsValid(tag)
+	}
+
+	var version string
+	if tp.regexp != nil {
+		match := tp.regexp.FindStringSubmatch(tag)
+		if match == nil {
+			return "", false
+		}
+		version = match[0]
+		if len(match) > 1 {
+			version = match[1]
+		}
+	} else {
+		if ok, _ := path.Match(tp.pattern, tag); !ok {
+			return "", false
+		}
+		// The literal text around the wildcards is not part of the version.
+		start := strings.IndexAny(tp.pattern, "*?[")
+		end := len(tp.pattern) - strings.LastIndexAny(tp.pattern, "*?]") - 1
+		if start < 0 || start > len(tag)-end {
+			return "", false
+		}
+		version = tag[start : len(tag)-end]
+	}
+
+	version = normalizeVersion(version)
+	return version, semver.IsValid(version)
+}
+
+// Makes version a semantic version where it can, by adding the leading "v"
+// and removing leading zeros, so "2024.05" becomes "v2024.5".
+func normalizeVersion(version string) string {
+	version = strings.TrimPrefix(version, "v")
+	core, rest := version, ""
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		core, rest = version[:i], version[i:]
+	}
+
+	parts := strings.Split(core, ".")
+	for i, part := range parts {
+		if trimmed := strings.TrimLeft(part, "0"); trimmed != "" {
+			parts[i] = trimmed
+		} else if part != "" {
+			parts[i] = "0"
+		}
+	}
+	return "v" + strings.Join(parts, ".") + rest
+}
@@ -0,0 +1,74 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTagPatternVersion(t *testing.T) {
+	tests := []struct {
+		pattern string
+		tag     string
+		want    string
+		wantOk  bool
+	}{
+		{"", "v1.2.3", "v1.2.3", true},
+		{"", "1.2.3", "1.2.3", false},
+		{"*", "1.2.3", "v1.2.3", true},
+		{"*", "v1.2.3-rc.1", "v1.2.3-rc.1", true},
+		{"release-*", "release-2024.05", "v2024.5", true},
+		{"release-*", "2024.05", "", false},
+		{"*-final", "1.0-final", "v1.0", true},
+		{"/^stable-(.*)$/", "stable-3.1.0", "v3.1.0", true},
+		{"/^[0-9.]+$/", "3.1.0", "v3.1.0", true},
+		{"/^[0-9.]+$/", "3.1.0-beta", "", false},
+		{"*", "latest", "vlatest", false},
+	}
+	for _, test := range tests {
+		tagPattern, err := ParseTagPattern(test.pattern)
+		if err != nil {
+			t.Fatalf("ParseTagPattern(%q) error = %v", test.pattern, err)
+		}
+		got, ok := tagPattern.version(test.tag)
+		if ok != test.wantOk || (ok && got != test.want) {
+			t.Errorf("TagPattern{%q}.version(%q) = %q, %t; want %q, %t",
+				test.pattern, test.tag, got, ok, test.want, test.wantOk)
+		}
+	}
+}
+
+func TestParseTagPatternInvalid(t *testing.T) {
+	for _, pattern := range []string{"release-[", "/(/", "stable"} {
+		if _, err := ParseTagPattern(pattern); err == nil {
+			t.Errorf("ParseTagPattern(%q) error = nil; want an error", pattern)
+		}
+	}
+}
+
+func TestSemanticVersionCheckTagPattern(t *testing.T) {
+	defer func(client GitClient) { Git = client }(Git)
+	Git = fakeGitClient{tags: []string{"release-2024.05", "release-2024.11", "release-2023.12", "v9.0.0"}}
+
+	tagPattern, _ := ParseTagPattern("release-*")
+	version := versionFromSpec("release-2024.05", "", tagPattern)
+	result := version.Check(context.Background(), "")
+	if result.Outcome != OutcomeUpgradeAvailable || result.Latest.GitRef() != "release-2024.11" {
+		t.Errorf("Check() = %v, latest %v; want %v, latest release-2024.11",
+			result.Outcome, result.Latest, OutcomeUpgradeAvailable)
+	}
+}
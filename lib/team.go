@@ -0,0 +1,221 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+)
+
+// The version of the team manifest format written by this version of
+// tim. It is separate from CurrentSchemaVersion, as a change to the
+// config file or tim's directories doesn't change the manifest, and a
+// team shouldn't all have to upgrade tim to read it. Manifests shared
+// the config file's version until it reached 4, so theirs starts there.
+const TeamManifestVersion = 4
+
+// A manifest pinning plugins to exact commits, shared by a team so that
+// every machine runs the same plugins. Environment variables are not
+// included, as they often hold machine specific paths or secrets.
+type TeamManifest struct {
+	SchemaVersion int `json:"schema_version"`
+
+	// The oldest tmux version the plugins are known to work with.
+	MinTmux string `json:"min_tmux,omitempty"`
+
+	Plugins map[string]TeamPlugin `json:"plugins"`
+}
+
+// A plugin pinned by a team manifest.
+type TeamPlugin struct {
+	// The git ref the plugin was resolved to, a tag or a branch.
+	Ref string `json:"ref"`
+
+	// The full hash of the commit to check out.
+	Commit string `json:"commit"`
+
+	// URL to clone the plugin from, empty for the default github remote.
+	Remote string `json:"remote,omitempty"`
+
+	// Global tmux options set before loading the plugin.
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// Kinds of difference between a team manifest and the config file.
+const (
+	DriftMissing = "missing"
+	DriftCommit  = "commit"
+	DriftRemote  = "remote"
+	DriftOptions = "options"
+	DriftExtra   = "extra"
+)
+
+// A difference between a team manifest and the config file.
+type TeamDrift struct {
+	Plugin string `json:"plugin"`
+	Kind   string `json:"kind"`
+
+	// What the manifest expects and what is configured, for commits
+	// and remotes.
+	Want string `json:"want,omitempty"`
+	Have string `json:"have,omitempty"`
+}
+
+// Returns a manifest of every plugin in the config file at the commit
+// recorded in tim.lock. Plugins must have been installed with "tim add".
+func (lf *Lockfile) TeamManifest() (*TeamManifest, error) {
+	manifest := &TeamManifest{
+		SchemaVersion: TeamManifestVersion,
+		MinTmux:       MinTmuxVersion,
+		Plugins:       make(map[string]TeamPlugin),
+	}
+
+	for name, spec := range lf.PluginSpecs {
+		// Local plugins only exist on this machine.
+		if spec.Path != "" {
+			continue
+		}
+		locked, ok := lf.Locked[name]
+		if !ok {
+			return nil, WithErrorCode(CodePluginNotFound,
+				fmt.Errorf("plugin %s is not in %s, run \"tim add\" to install it first", name, lf.LockPath()))
+		}
+		manifest.Plugins[name] = TeamPlugin{
+			Ref:     locked.Ref,
+			Commit:  locked.Commit,
+			Remote:  spec.Remote,
+			Options: spec.Options,
+		}
+
+		compat, err := GetPluginCompat(name)
+		if err != nil {
+			return nil, err
+		}
+		if compat == nil {
+			continue
+		}
+		cmp, err := CompareTmuxVersions(compat.MinTmux, manifest.MinTmux)
+		if err != nil {
+			return nil, err
+		}
+		if cmp > 0 {
+			manifest.MinTmux = compat.MinTmux
+		}
+	}
+	return manifest, nil
+}
+
+// Reads the team manifest at manifestPath.
+func ReadTeamManifest(manifestPath string) (*TeamManifest, error) {
+	contents, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &TeamManifest{}
+	if err := json.Unmarshal(contents, manifest); err != nil {
+		return nil, fmt.Errorf("invalid team manifest %s: %w", manifestPath, err)
+	}
+	if manifest.SchemaVersion > TeamManifestVersion {
+		return nil, fmt.Errorf("team manifest %s has schema version %d, but this version of tim only understands up to %d",
+			manifestPath, manifest.SchemaVersion, TeamManifestVersion)
+	}
+	for name, plugin := range manifest.Plugins {
+		if plugin.Commit == "" {
+			return nil, fmt.Errorf("plugin %s in team manifest %s has no commit", name, manifestPath)
+		}
+	}
+	return manifest, nil
+}
+
+// Returns the differences between the manifest and the config file,
+// sorted by plugin name.
+func (m *TeamManifest) Drift(lf *Lockfile) []TeamDrift {
+	drift := make([]TeamDrift, 0)
+	for _, name := range slices.Sorted(maps.Keys(m.Plugins)) {
+		want := m.Plugins[name]
+		spec, ok := lf.PluginSpecs[name]
+		if !ok {
+			drift = append(drift, TeamDrift{Plugin: name, Kind: DriftMissing, Want: want.Commit})
+			continue
+		}
+		// A plugin being developed locally stays local.
+		if spec.Path != "" {
+			continue
+		}
+		if have := lf.Locked[name].Commit; have != want.Commit {
+			drift = append(drift, TeamDrift{Plugin: name, Kind: DriftCommit, Want: want.Commit, Have: have})
+		}
+		if spec.Remote != want.Remote {
+			drift = append(drift, TeamDrift{Plugin: name, Kind: DriftRemote, Want: want.Remote, Have: spec.Remote})
+		}
+		if !maps.Equal(spec.Options, want.Options) {
+			drift = append(drift, TeamDrift{Plugin: name, Kind: DriftOptions})
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(lf.PluginSpecs)) {
+		if _, ok := m.Plugins[name]; !ok && lf.PluginSpecs[name].Path == "" {
+			drift = append(drift, TeamDrift{Plugin: name, Kind: DriftExtra})
+		}
+	}
+	return drift
+}
+
+// Describes the drift in a few words.
+func (d TeamDrift) String() string {
+	switch d.Kind {
+	case DriftMissing:
+		return fmt.Sprintf("%s is not installed", d.Plugin)
+	case DriftCommit:
+		return fmt.Sprintf("%s is at %.10s, the team uses %.10s", d.Plugin, orNone(d.Have), d.Want)
+	case DriftRemote:
+		return fmt.Sprintf("%s is cloned from %s, the team uses %s", d.Plugin, orNone(d.Have), orNone(d.Want))
+	case DriftOptions:
+		return fmt.Sprintf("%s has different tmux options to the team", d.Plugin)
+	case DriftExtra:
+		return fmt.Sprintf("%s is not used by the team", d.Plugin)
+	default:
+		return fmt.Sprintf("%s differs from the team", d.Plugin)
+	}
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
+
+// Returns the plugins in the manifest, pinned to their commits. The
+// manifest has no environment variables, so those in the config file are
+// kept.
+func (m *TeamManifest) Pins() map[string]Pin {
+	pins := make(map[string]Pin, len(m.Plugins))
+	for name, plugin := range m.Plugins {
+		pins[name] = Pin{
+			Ref:     plugin.Ref,
+			Commit:  plugin.Commit,
+			Remote:  plugin.Remote,
+			Options: plugin.Options,
+			KeepEnv: true,
+		}
+	}
+	return pins
+}
@@ -0,0 +1,63 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTeamManifestDrift(t *testing.T) {
+	manifest := &TeamManifest{
+		Plugins: map[string]TeamPlugin{
+			"a/same":    {Ref: "v1.0.0", Commit: "aaa"},
+			"b/commit":  {Ref: "v1.1.0", Commit: "bbb"},
+			"c/missing": {Ref: "main", Commit: "ccc"},
+			"d/options": {Ref: "v1.0.0", Commit: "ddd", Remote: "https://example.com/d.git", Options: map[string]string{"@d": "1"}},
+		},
+	}
+	lockFile := &Lockfile{
+		PluginSpecs: map[string]PluginSpec{
+			"a/same":    {Version: "v1.0.0"},
+			"b/commit":  {Version: "v1.0.0"},
+			"d/options": {Version: "v1.0.0"},
+			"e/extra":   {Version: "v1.0.0"},
+		},
+		Locked: map[string]LockedPlugin{
+			"a/same":    {Ref: "v1.0.0", Commit: "aaa"},
+			"b/commit":  {Ref: "v1.0.0", Commit: "old"},
+			"d/options": {Ref: "v1.0.0", Commit: "ddd"},
+		},
+	}
+
+	want := []TeamDrift{
+		{Plugin: "b/commit", Kind: DriftCommit, Want: "bbb", Have: "old"},
+		{Plugin: "c/missing", Kind: DriftMissing, Want: "ccc"},
+		{Plugin: "d/options", Kind: DriftRemote, Want: "https://example.com/d.git"},
+		{Plugin: "d/options", Kind: DriftOptions},
+		{Plugin: "e/extra", Kind: DriftExtra},
+	}
+	if got := manifest.Drift(lockFile); !reflect.DeepEqual(got, want) {
+		t.Errorf("Drift() = %+v; want %+v", got, want)
+	}
+
+	for name, pin := range manifest.Pins() {
+		lockFile.ApplyPin(name, pin)
+	}
+	if got := manifest.Drift(lockFile); !reflect.DeepEqual(got, []TeamDrift{{Plugin: "e/extra", Kind: DriftExtra}}) {
+		t.Errorf("Drift() after Apply() = %+v; want only e/extra", got)
+	}
+}
@@ -32,6 +32,9 @@ func GetTmuxVersion() (string, error) {
 	var out strings.Builder
 	cmd.Stdout = &out
 	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return "", WithErrorCode(CodeTmuxMissing, err)
+		}
 		return "", err
 	}
 
@@ -45,7 +48,7 @@ func GetTmuxVersion() (string, error) {
 		return "", fmt.Errorf("bad output of first part of command 'tmux -V': %s", out.String())
 	}
 
-	return splits[1], nil
+	return strings.TrimSpace(splits[1]), nil
 }
 
 // Finds the path of the current tmux configuration file.
@@ -71,7 +74,8 @@ func GetTmuxConfigPath() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	_, err = os.Stat(path.Join(configDir, "tmux/tmux.conf"))
+	configPath = path.Join(configDir, "tmux/tmux.conf")
+	_, err = os.Stat(configPath)
 	if err == nil {
 		// Config found! Return the path.
 		return configPath, nil
@@ -82,3 +86,148 @@ func GetTmuxConfigPath() (string, error) {
 	// No more available candidates.
 	return "", ErrNoTmuxConfig
 }
+
+// Sets a global tmux option, such as a user option of the form `@name`.
+func SetTmuxOption(name, value string) error {
+	cmd := exec.Command("tmux", "set-option", "-gq", name, value)
+	cmd.Stderr = Stderr
+	return cmd.Run()
+}
+
+// The oldest tmux version tim supports, the same as TPM.
+const MinTmuxVersion = "1.9"
+
+// Returns the line added to the tmux config to load plugins when tmux
+// starts. The tim in PATH is run if executable is empty, otherwise
+// executable is run by its full path, which may contain any characters.
+func TimLoadLine(executable string) string {
+	if executable == "" {
+		return `run-shell "tim load"`
+	}
+	// run-shell expands formats, so "#" is escaped as "##".
+	command := strings.ReplaceAll(ShellQuote(executable)+" load", "#", "##")
+	return "run-shell " + TmuxQuote(command)
+}
+
+var tmuxQuoteReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// Quotes s as a single argument for the tmux command parser, which
+// otherwise splits on spaces, and expands "$VAR" and "~".
+func TmuxQuote(s string) string {
+	return `"` + tmuxQuoteReplacer.Replace(s) + `"`
+}
+
+// Quotes s as a single word for sh.
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Checks that the given tmux version is supported by tim.
+func CheckTmuxVersion(version string) error {
+	cmp, err := CompareTmuxVersions(version, MinTmuxVersion)
+	if err != nil {
+		return err
+	}
+	if cmp < 0 {
+		return fmt.Errorf("tim requires tmux %s or newer, but tmux %s is installed", MinTmuxVersion, version)
+	}
+	return nil
+}
+
+// Appends loadLine, see TimLoadLine, to the given tmux config unless it
+// already loads plugins with tim. Returns the new config, and whether
+// any changes were made.
+func AddTimLoadLine(tmuxConfig, loadLine string) (string, bool) {
+	for _, line := range strings.Split(tmuxConfig, "\n") {
+		if timLoadRegexp.MatchString(line) {
+			return tmuxConfig, false
+		}
+	}
+
+	if tmuxConfig != "" && !strings.HasSuffix(tmuxConfig, "\n") {
+		tmuxConfig += "\n"
+	}
+	return tmuxConfig + loadLine + "\n", true
+}
+
+// Returns true if a tmux server is running, either because tim is running
+// inside tmux or because `tmux ls` finds a session.
+func TmuxServerRunning() bool {
+	if os.Getenv("TMUX") != "" {
+		return true
+	}
+	return TmuxServerAlive()
+}
+
+// Returns true if tmux finds a running server, even when tim runs inside
+// tmux, in case the server has since exited.
+func TmuxServerAlive() bool {
+	return exec.Command("tmux", "ls").Run() == nil
+}
+
+// Sources the tmux config file in the running tmux server, waiting for
+// any commands it runs, such as "tim load", to finish.
+// Returns the value of a global tmux option, or an empty string if it is
+// not set or no tmux server is running.
+func GetTmuxOption(name string) string {
+	out, err := exec.Command("tmux", "show-option", "-gqv", name).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// Unsets a global tmux option.
+func UnsetTmuxOption(name string) error {
+	cmd := exec.Command("tmux", "set-option", "-gqu", name)
+	cmd.Stderr = Stderr
+	return cmd.Run()
+}
+
+func SourceTmuxConfig(configPath string) error {
+	cmd := exec.Command("tmux", "source-file", configPath)
+	cmd.Stderr = Stderr
+	return cmd.Run()
+}
+
+// Shows a message in the status line of the current tmux client.
+func DisplayTmuxMessage(text string) error {
+	cmd := exec.Command("tmux", "display-message", text)
+	cmd.Stderr = Stderr
+	return cmd.Run()
+}
+
+// Returns the environment and working directory of commands the tmux
+// server runs with run-shell, such as "tim load" in the tmux config.
+func TmuxShellEnviron() ([]string, string, error) {
+	out, err := os.CreateTemp("", "tim-env-*")
+	if err != nil {
+		return nil, "", err
+	}
+	out.Close()
+	defer os.Remove(out.Name())
+
+	// The output of run-shell is not shown outside a client, so it is
+	// written to a file instead.
+	cmd := exec.Command("tmux", "run-shell", "{ pwd; env; } > "+ShellQuote(out.Name()))
+	cmd.Stderr = Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, "", err
+	}
+	contents, err := os.ReadFile(out.Name())
+	if err != nil {
+		return nil, "", err
+	}
+
+	dir, rest, _ := strings.Cut(string(contents), "\n")
+	env := make([]string, 0)
+	for _, line := range strings.Split(strings.TrimSuffix(rest, "\n"), "\n") {
+		// Lines without a "=" continue a value with newlines in it.
+		if !strings.Contains(line, "=") && len(env) > 0 {
+			env[len(env)-1] += "\n" + line
+			continue
+		}
+		env = append(env, line)
+	}
+	return env, dir, nil
+}
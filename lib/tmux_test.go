@@ -0,0 +1,82 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import "testing"
+
+func TestAddTimLoadLine(t *testing.T) {
+	line := TimLoadLine("")
+	tests := []struct {
+		config      string
+		want        string
+		wantChanged bool
+	}{
+		{"", line + "\n", true},
+		{"set -g mouse on", "set -g mouse on\n" + line + "\n", true},
+		{"set -g mouse on\n", "set -g mouse on\n" + line + "\n", true},
+		{"set -g mouse on\nrun \"tim load\"\n", "set -g mouse on\nrun \"tim load\"\n", false},
+		{"run-shell -b 'tim load'\n", "run-shell -b 'tim load'\n", false},
+		{"run-shell \"'/home/José Smith/go/bin/tim' load\"\n", "run-shell \"'/home/José Smith/go/bin/tim' load\"\n", false},
+	}
+
+	for _, test := range tests {
+		got, changed := AddTimLoadLine(test.config, line)
+		if got != test.want || changed != test.wantChanged {
+			t.Errorf("AddTimLoadLine(%q) = %q, %t; want %q, %t", test.config, got, changed, test.want, test.wantChanged)
+		}
+	}
+}
+
+func TestCheckTmuxVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		wantErr bool
+	}{
+		{"3.3a", false},
+		{"1.9", false},
+		{"1.8", true},
+		{"next-3.5", false},
+	}
+
+	for _, test := range tests {
+		err := CheckTmuxVersion(test.version)
+		if (err != nil) != test.wantErr {
+			t.Errorf("CheckTmuxVersion(%q) returned error %v; want error %t", test.version, err, test.wantErr)
+		}
+	}
+}
+
+func TestTimLoadLine(t *testing.T) {
+	tests := []struct {
+		executable string
+		want       string
+	}{
+		{"", `run-shell "tim load"`},
+		{"/home/kaley/go/bin/tim", `run-shell "'/home/kaley/go/bin/tim' load"`},
+		{"/home/José Smith/go/bin/tim", `run-shell "'/home/José Smith/go/bin/tim' load"`},
+		{"/home/o'neil/$bin/#1/tim", `run-shell "'/home/o'\\''neil/\$bin/##1/tim' load"`},
+	}
+
+	for _, test := range tests {
+		got := TimLoadLine(test.executable)
+		if got != test.want {
+			t.Errorf("TimLoadLine(%q) = %s; want %s", test.executable, got, test.want)
+		}
+		if _, changed := AddTimLoadLine(got, got); changed {
+			t.Errorf("AddTimLoadLine() does not recognise %s", got)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/kjnsn/tim/lib/message"
+)
+
+// How long a control mode connection may take before it is abandoned.
+const controlModeTimeout = 10 * time.Second
+
+var errControlModeUnavailable = errors.New("tmux control mode unavailable")
+
+// A batch of tmux commands, run over a single control mode connection
+// (`tmux -C`) rather than spawning a tmux process per command. If control
+// mode is unavailable, for example when no session exists to attach to,
+// each command is run separately instead.
+type TmuxBatch struct {
+	commands [][]string
+}
+
+// Adds a tmux command, such as "set-option", "-g", "status", "on".
+func (b *TmuxBatch) Add(args ...string) {
+	b.commands = append(b.commands, args)
+}
+
+// Adds a command setting a global tmux option.
+func (b *TmuxBatch) SetOption(name, value string) {
+	b.SetOptionIn(TmuxTarget{}, name, value)
+}
+
+// Adds a command setting a tmux option in target.
+func (b *TmuxBatch) SetOptionIn(target TmuxTarget, name, value string) {
+	args := append([]string{"set-option"}, target.optionFlags()...)
+	b.Add(append(args, name, value)...)
+}
+
+// Runs all commands in the batch, returning an error describing every
+// command that failed.
+func (b *TmuxBatch) Run() error {
+	if len(b.commands) == 0 {
+		return nil
+	}
+
+	if len(b.commands) > 1 {
+		err := b.runControlMode()
+		if !errors.Is(err, errControlModeUnavailable) {
+			return err
+		}
+		message.Debug("Falling back to running tmux commands separately: %s", err)
+	}
+
+	errs := make([]error, 0)
+	for _, args := range b.commands {
+		cmd := exec.Command("tmux", args...)
+		cmd.Stderr = Stderr
+		if err := cmd.Run(); err != nil {
+			errs = append(errs, fmt.Errorf("tmux %s: %w", strings.Join(args, " "), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (b *TmuxBatch) runControlMode() error {
+	cmd := exec.Command("tmux", "-C", "attach-session")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("%w: %w", errControlModeUnavailable, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("%w: %w", errControlModeUnavailable, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("%w: %w", errControlModeUnavailable, err)
+	}
+	timer := time.AfterFunc(controlModeTimeout, func() {
+		cmd.Process.Kill()
+	})
+	defer timer.Stop()
+
+	var input strings.Builder
+	for _, args := range b.commands {
+		input.WriteString(quoteTmuxCommand(args) + "\n")
+	}
+	// An empty line detaches the control client.
+	input.WriteString("\n")
+	if _, err := stdin.Write([]byte(input.String())); err != nil {
+		cmd.Wait()
+		return fmt.Errorf("%w: %w", errControlModeUnavailable, err)
+	}
+	stdin.Close()
+
+	// Each command's output is wrapped in %begin and either %end or %error.
+	connected := false
+	block := make([]string, 0)
+	errs := make([]error, 0)
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "%begin"):
+			connected = true
+			block = block[:0]
+		case strings.HasPrefix(line, "%error"):
+			errs = append(errs, errors.New(strings.Join(block, "\n")))
+		case strings.HasPrefix(line, "%"):
+			// %end, %exit and notifications.
+		default:
+			block = append(block, line)
+		}
+	}
+
+	if err := cmd.Wait(); err != nil && !connected {
+		return fmt.Errorf("%w: %w", errControlModeUnavailable, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Quotes the arguments of a tmux command so they are parsed as given.
+// Newlines are escaped, as each line sent in control mode is a command.
+func quoteTmuxCommand(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = TmuxQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,84 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Where plugins are loaded: globally, or into a single tmux session or
+// window. The zero value loads globally.
+type TmuxTarget struct {
+	// A session name or id, such as "work" or "$1".
+	Session string
+
+	// A window, such as "2", "work:2" or "@3". A window without a session
+	// is in Session, or the current session if there is none.
+	Window string
+}
+
+// Returns true if plugins are loaded globally.
+func (t TmuxTarget) IsGlobal() bool {
+	return t.Session == "" && t.Window == ""
+}
+
+// Returns the target as given to tmux with -t.
+func (t TmuxTarget) String() string {
+	if t.Window == "" {
+		return t.Session
+	}
+	if t.Session != "" && !strings.ContainsAny(t.Window, ":@") {
+		return t.Session + ":" + t.Window
+	}
+	return t.Window
+}
+
+// Returns the set-option flags setting an option at the target's scope.
+func (t TmuxTarget) optionFlags() []string {
+	switch {
+	case t.IsGlobal():
+		return []string{"-gq"}
+	case t.Window != "":
+		return []string{"-wq", "-t", t.String()}
+	default:
+		return []string{"-q", "-t", t.String()}
+	}
+}
+
+// Returns the id of the active pane of the target, such as "%3", which
+// scripts are given as TMUX_PANE so that the tmux commands they run
+// apply to the target. Fails if the target does not exist.
+func (t TmuxTarget) Pane() (string, error) {
+	// Unlike display-message, list-panes fails if the target is missing.
+	var errOut strings.Builder
+	cmd := exec.Command("tmux", "list-panes", "-t", t.String(), "-F", "#{pane_active} #{pane_id}")
+	cmd.Stderr = &errOut
+	out, err := cmd.Output()
+	if err != nil {
+		if reason := strings.TrimSpace(errOut.String()); reason != "" {
+			return "", fmt.Errorf("unable to find tmux target %s: %s", t, reason)
+		}
+		return "", fmt.Errorf("unable to find tmux target %s: %w", t, err)
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if pane, ok := strings.CutPrefix(line, "1 "); ok {
+			return pane, nil
+		}
+	}
+	return "", fmt.Errorf("tmux target %s has no active pane", t)
+}
@@ -0,0 +1,44 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTmuxTarget(t *testing.T) {
+	tests := []struct {
+		target    TmuxTarget
+		want      string
+		wantFlags []string
+	}{
+		{TmuxTarget{}, "", []string{"-gq"}},
+		{TmuxTarget{Session: "work"}, "work", []string{"-q", "-t", "work"}},
+		{TmuxTarget{Session: "work", Window: "2"}, "work:2", []string{"-wq", "-t", "work:2"}},
+		{TmuxTarget{Session: "work", Window: "@3"}, "@3", []string{"-wq", "-t", "@3"}},
+		{TmuxTarget{Window: "play:1"}, "play:1", []string{"-wq", "-t", "play:1"}},
+	}
+
+	for _, test := range tests {
+		if got := test.target.String(); got != test.want {
+			t.Errorf("%+v.String() = %q; want %q", test.target, got, test.want)
+		}
+		if got := test.target.optionFlags(); !reflect.DeepEqual(got, test.wantFlags) {
+			t.Errorf("%+v.optionFlags() = %q; want %q", test.target, got, test.wantFlags)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"regexp"
+	"strings"
+)
+
+// The plugin name of TPM itself, which tim replaces.
+const TPMPluginName = "tmux-plugins/tpm"
+
+// Matches `set -g @plugin 'user/repo'` and its variations.
+var tpmPluginRegexp = regexp.MustCompile(`^\s*set(?:-option)?\s+(?:-[a-zA-Z]+\s+)*@plugin\s+['"]?([^'"\s]+)['"]?`)
+
+// Matches the line that runs TPM, usually `run '~/.tmux/plugins/tpm/tpm'`.
+var tpmRunRegexp = regexp.MustCompile(`^\s*run(?:-shell)?\s+(?:-b\s+)?['"]?\S*tpm/tpm['"]?\s*$`)
+
+// A plugin declared in a tmux config file for TPM.
+type TPMPlugin struct {
+	// The plugin as given to TPM, either <username>/<repo> or a git URL.
+	Arg string
+
+	// Branch from the `user/repo#branch` syntax, or empty.
+	Branch string
+}
+
+// Finds all plugins declared for TPM in the given tmux config.
+func ParseTPMPlugins(tmuxConfig string) []TPMPlugin {
+	plugins := make([]TPMPlugin, 0)
+	for _, line := range strings.Split(tmuxConfig, "\n") {
+		match := tpmPluginRegexp.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		arg, branch, _ := strings.Cut(match[1], "#")
+		plugins = append(plugins, TPMPlugin{
+			Arg:    arg,
+			Branch: branch,
+		})
+	}
+	return plugins
+}
+
+// Comments out the line running TPM, adding a line to load plugins with
+// tim after it. Returns the new config, and whether any changes were made.
+func ReplaceTPMRunLine(tmuxConfig string) (string, bool) {
+	lines := strings.Split(tmuxConfig, "\n")
+	replaced := make([]string, 0, len(lines)+1)
+	changed := false
+
+	for _, line := range lines {
+		if !tpmRunRegexp.MatchString(line) {
+			replaced = append(replaced, line)
+			continue
+		}
+
+		changed = true
+		replaced = append(replaced, "# "+line+" # replaced by tim", `run "tim load"`)
+	}
+
+	return strings.Join(replaced, "\n"), changed
+}
@@ -0,0 +1,60 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"slices"
+	"testing"
+)
+
+const tpmConfig = `set -g mouse on
+set -g @plugin 'tmux-plugins/tpm'
+set -g @plugin "tmux-plugins/tmux-sensible"
+set-option -g @plugin 'user/repo#dev'
+  # set -g @plugin 'commented/out'
+set -g @plugin 'git@gitlab.com:user/plugin'
+
+run '~/.tmux/plugins/tpm/tpm'`
+
+func TestParseTPMPlugins(t *testing.T) {
+	want := []TPMPlugin{
+		{Arg: "tmux-plugins/tpm"},
+		{Arg: "tmux-plugins/tmux-sensible"},
+		{Arg: "user/repo", Branch: "dev"},
+		{Arg: "git@gitlab.com:user/plugin"},
+	}
+
+	got := ParseTPMPlugins(tpmConfig)
+	if !slices.Equal(got, want) {
+		t.Errorf("ParseTPMPlugins() = %v; want %v", got, want)
+	}
+}
+
+func TestReplaceTPMRunLine(t *testing.T) {
+	got, changed := ReplaceTPMRunLine(tpmConfig)
+	if !changed {
+		t.Fatalf("ReplaceTPMRunLine() made no changes")
+	}
+
+	want := "# run '~/.tmux/plugins/tpm/tpm' # replaced by tim\nrun \"tim load\""
+	if got[len(got)-len(want):] != want {
+		t.Errorf("ReplaceTPMRunLine() ends with %q; want %q", got[len(got)-len(want):], want)
+	}
+
+	if _, changed := ReplaceTPMRunLine(got); changed {
+		t.Errorf("ReplaceTPMRunLine() changed an already replaced config")
+	}
+}
@@ -0,0 +1,46 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"fmt"
+	"os"
+	"path"
+)
+
+// Gets the directory that plugins being tried out are installed in,
+// creating it if it does not already exist. A RAM backed directory is
+// preferred, as tried plugins are thrown away.
+func GetTryDir() (string, error) {
+	dirName := fmt.Sprintf("tim-try-%d", os.Getuid())
+
+	candidates := []string{"/dev/shm", os.Getenv("XDG_RUNTIME_DIR")}
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+		tryDir := path.Join(candidate, dirName)
+		if err := os.MkdirAll(tryDir, 0700); err == nil {
+			return tryDir, nil
+		}
+	}
+
+	tryDir := path.Join(os.TempDir(), dirName)
+	if err := os.MkdirAll(tryDir, 0700); err != nil {
+		return "", err
+	}
+	return tryDir, nil
+}
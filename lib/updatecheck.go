@@ -0,0 +1,74 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"time"
+)
+
+// The state file the result of the last update check is kept in.
+const updateCheckFile = "update-check.json"
+
+// How often tim checks for a new release of itself, when enabled.
+const UpdateCheckInterval = 7 * 24 * time.Hour
+
+// The result of the last check for a new release of tim.
+type UpdateCheck struct {
+	CheckedAt time.Time `json:"checked_at"`
+
+	// Tag of the latest release, empty if the check failed.
+	Latest string `json:"latest,omitempty"`
+}
+
+// Returns the latest release of tim, checking github at most once every
+// UpdateCheckInterval and otherwise using the result of the last check.
+// Nothing is sent other than the request for the latest release.
+func CachedLatestTimRelease(ctx context.Context) (string, error) {
+	check, err := LastUpdateCheck()
+	if err != nil {
+		return "", err
+	}
+	if time.Since(check.CheckedAt) < UpdateCheckInterval {
+		return check.Latest, nil
+	}
+	check, err = CheckForTimUpdate(ctx)
+	return check.Latest, err
+}
+
+// Returns the result of the last check for a new release of tim, without
+// checking again. CheckedAt is zero if tim has never checked.
+func LastUpdateCheck() (UpdateCheck, error) {
+	check := UpdateCheck{}
+	err := readStateFile(updateCheckFile, &check)
+	return check, err
+}
+
+// Asks github for the latest release of tim, and records the result for
+// CachedLatestTimRelease.
+func CheckForTimUpdate(ctx context.Context) (UpdateCheck, error) {
+	// Failed checks are recorded too, so tim does not retry on every
+	// command while offline.
+	release, err := GetLatestTimRelease(ctx)
+	check := UpdateCheck{CheckedAt: time.Now().UTC()}
+	if err == nil {
+		check.Latest = release.TagName
+	}
+	if err := writeStateFile(updateCheckFile, &check); err != nil {
+		return check, err
+	}
+	return check, err
+}
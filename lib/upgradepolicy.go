@@ -0,0 +1,45 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"fmt"
+)
+
+// How a plugin is upgraded, set by the plugin's upgrade key in the config
+// file. Plugins without one are upgraded by "tim upgrade", but not by
+// "tim watch".
+type UpgradePolicy string
+
+const (
+	// Upgraded by "tim upgrade" and by "tim watch".
+	UpgradeAuto UpgradePolicy = "auto"
+	// New versions are reported, but only upgraded to when the plugin is
+	// named, as in "tim upgrade <plugin>".
+	UpgradeNotify UpgradePolicy = "notify"
+	// Never upgraded. New versions are reported as blocked.
+	UpgradePinned UpgradePolicy = "pinned"
+)
+
+// Returns the upgrade policy named name, which is empty if name is.
+func ParseUpgradePolicy(name string) (UpgradePolicy, error) {
+	switch policy := UpgradePolicy(name); policy {
+	case "", UpgradeAuto, UpgradeNotify, UpgradePinned:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown upgrade policy %q, expected %q, %q or %q",
+		name, UpgradeAuto, UpgradeNotify, UpgradePinned)
+}
@@ -0,0 +1,67 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Returned when an installed plugin is not exactly the commit recorded in
+// tim.lock, for example because its files have been changed by hand.
+var ErrVerifyFailed = errors.New("verification failed")
+
+// Checks that the installed plugin has exactly the commit recorded in
+// tim.lock checked out, with no local changes. Returns an error wrapping
+// ErrVerifyFailed if it does not. Plugins loaded from a local directory
+// are not checked, as they are expected to change.
+func (lf *Lockfile) Verify(ctx context.Context, plugin *Plugin) error {
+	if plugin.IsLocal() {
+		return nil
+	}
+	locked, ok := lf.Locked[plugin.Name]
+	if !ok || locked.Commit == "" {
+		return fmt.Errorf("%w: plugin %s has no commit recorded in tim.lock, run \"tim sync\" to record it",
+			ErrVerifyFailed, plugin.Name)
+	}
+	if err := plugin.CheckInstalled(); err != nil {
+		return err
+	}
+
+	commit, err := plugin.Commit(ctx)
+	if err != nil {
+		return err
+	}
+	if commit != locked.Commit {
+		return fmt.Errorf("%w: plugin %s has commit %.10s checked out, but tim.lock records %.10s",
+			ErrVerifyFailed, plugin.Name, commit, locked.Commit)
+	}
+
+	pluginDir, err := plugin.RepoDir()
+	if err != nil {
+		return err
+	}
+	dirty, err := IsDirty(ctx, pluginDir)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("%w: plugin %s has files that differ from commit %.10s",
+			ErrVerifyFailed, plugin.Name, locked.Commit)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"testing"
+)
+
+type verifyGitClient struct {
+	GitClient
+	head  string
+	dirty bool
+}
+
+func (c verifyGitClient) ResolveCommit(ctx context.Context, dir, ref string) (string, error) {
+	return c.head, nil
+}
+
+func (c verifyGitClient) IsDirty(ctx context.Context, dir string) (bool, error) {
+	return c.dirty, nil
+}
+
+func TestVerify(t *testing.T) {
+	root := t.TempDir()
+	plugin := Plugin{Name: "a/plugin", Root: root}
+	if err := os.MkdirAll(path.Join(root, plugin.Name), 0750); err != nil {
+		t.Fatal(err)
+	}
+	lockFile := &Lockfile{Locked: map[string]LockedPlugin{"a/plugin": {Ref: "v1.0.0", Commit: "aaa"}}}
+
+	defer func(git GitClient) { Git = git }(Git)
+	tests := []struct {
+		client     verifyGitClient
+		wantFailed bool
+	}{
+		{verifyGitClient{head: "aaa"}, false},
+		{verifyGitClient{head: "bbb"}, true},
+		{verifyGitClient{head: "aaa", dirty: true}, true},
+	}
+	for _, test := range tests {
+		Git = test.client
+		err := lockFile.Verify(context.Background(), &plugin)
+		if failed := errors.Is(err, ErrVerifyFailed); failed != test.wantFailed || (err != nil && !failed) {
+			t.Errorf("Verify() with %+v = %v; want failure %v", test.client, err, test.wantFailed)
+		}
+	}
+
+	unlocked := Plugin{Name: "b/unlocked", Root: root}
+	if err := lockFile.Verify(context.Background(), &unlocked); !errors.Is(err, ErrVerifyFailed) {
+		t.Errorf("Verify() of a plugin missing from tim.lock = %v; want %v", err, ErrVerifyFailed)
+	}
+}
@@ -16,8 +16,11 @@ limitations under the License.
 package lib
 
 import (
+	"cmp"
+	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"slices"
 	"strings"
 
@@ -30,18 +33,14 @@ var ErrNoVersions = errors.New("no versions available")
 // can either be a git based branch & commit, or a semver
 // version.
 //
-// HasUpgrade returns true and a new Version when there is
-// an upgrade to a new version available.
-//
-// Check checks if there is an upgrade available.
+// Check fetches from the remote and reports whether there is
+// an upgrade available, and if not, why.
 //
 // Upgrade checks out and switches to this version.
 type Version interface {
-	HasUpgrade() (bool, Version)
-
-	Upgrade(pluginDir string) error
+	Check(ctx context.Context, pluginDir string) CheckResult
 
-	Check(pluginDir string) error
+	Upgrade(ctx context.Context, pluginDir string) error
 
 	String() string
 
@@ -61,36 +60,57 @@ func VersionFromSpec(spec string) Version {
 		}
 	}
 
+	// Older releases of tim recorded the remote default branch, such
+	// as "origin/main", rather than the branch itself.
 	return &GitVersion{
-		branch: spec,
+		branch: strings.TrimPrefix(spec, "origin/"),
 	}
 }
 
+// Returns the version of spec, a semantic version if it is a release
+// according to tagPattern.
+func versionFromSpec(spec string, channel Channel, tagPattern *TagPattern) Version {
+	if _, ok := tagPattern.version(spec); tagPattern != nil && ok {
+		return &SemanticVersion{
+			currentVersion: spec,
+			channel:        channel,
+			tagPattern:     tagPattern,
+		}
+	}
+
+	version := VersionFromSpec(spec)
+	if semanticVersion, ok := version.(*SemanticVersion); ok {
+		semanticVersion.channel = channel
+		semanticVersion.tagPattern = tagPattern
+	}
+	return version
+}
+
 // Finds the best version of the plugin at the given pluginDir,
-// preferencing semver over git.
-func FindBestVersion(pluginDir string) (Version, error) {
-	_, err := RunGitCommand(pluginDir, "fetch", "-t")
-	if err != nil {
+// preferencing releases the channel allows over git.
+func FindBestVersion(ctx context.Context, pluginDir string, channel Channel, tagPattern *TagPattern) (Version, error) {
+	if err := FetchTags(ctx, pluginDir); err != nil {
 		return nil, err
 	}
 
-	versions, err := RunGitCommand(pluginDir, "tag", "--list", "v*")
+	tags, err := Git.Tags(ctx, pluginDir)
 	if err != nil {
 		return nil, err
 	}
 
-	highestSemver := maxVersion(strings.Split(versions, "\n"))
-	if highestSemver != "" {
+	if latest, _, _ := latestTag(tags, "", channel, tagPattern); latest != "" {
 		return &SemanticVersion{
-			currentVersion: highestSemver,
+			currentVersion: latest,
+			channel:        channel,
+			tagPattern:     tagPattern,
 		}, nil
 	}
 
-	branch, err := DefaultBranch(pluginDir)
+	branch, err := DefaultBranch(ctx, pluginDir)
 	if err != nil {
 		return nil, err
 	}
-	currentHash, err := GetRef(pluginDir, "--short", branch)
+	currentHash, err := Git.ResolveCommit(ctx, pluginDir, "origin/"+branch)
 	if err != nil {
 		return nil, err
 	}
@@ -103,49 +123,99 @@ func FindBestVersion(pluginDir string) (Version, error) {
 type SemanticVersion struct {
 	currentVersion string
 	latestVersion  string
-}
 
-func (sv *SemanticVersion) HasUpgrade() (bool, Version) {
-	if sv.latestVersion != "" && semver.Compare(sv.latestVersion, sv.currentVersion) == 1 {
-		return true, &SemanticVersion{
-			currentVersion: sv.latestVersion,
-		}
-	}
-	return false, nil
+	// Which pre-releases may be upgraded to, ChannelStable if empty.
+	channel Channel
+
+	// Which tags are releases, those that are semantic versions if nil.
+	tagPattern *TagPattern
 }
 
-// Checks to see if there is an upgrade, returning ErrNoVersions if no
-// semantic versions are available.
-func (sv *SemanticVersion) Check(pluginDir string) error {
-	_, err := RunGitCommand(pluginDir, "fetch", "-t")
-	if err != nil {
-		return err
+// Checks to see if there is an upgrade. The result has an ErrNoVersions
+// error if no semantic versions are available.
+func (sv *SemanticVersion) Check(ctx context.Context, pluginDir string) CheckResult {
+	if err := FetchTags(ctx, pluginDir); err != nil {
+		return checkFailed(ErrorClassNetwork, err)
 	}
 
-	versions, err := RunGitCommand(pluginDir, "tag", "--list", "v*")
+	tags, err := Git.Tags(ctx, pluginDir)
 	if err != nil {
-		return err
+		return checkFailed(ErrorClassGit, err)
 	}
+	return sv.compareTags(tags)
+}
 
-	latest := maxVersion(strings.Split(versions, "\n"))
+// Compares the current version with the highest release in tags that the
+// channel allows. The current version is always allowed, so a pre-release
+// is not reported as having no versions.
+func (sv *SemanticVersion) compareTags(tags []string) CheckResult {
+	latest, latestSemver, skipped := latestTag(tags, sv.currentVersion, sv.channel, sv.tagPattern)
 	if latest == "" {
-		return ErrNoVersions
+		return checkFailed(ErrorClassNoVersions, ErrNoVersions)
 	}
 	sv.latestVersion = latest
-	if semver.Compare(latest, sv.currentVersion) == 1 {
-		sv.latestVersion = latest
+
+	result := CheckResult{
+		Latest:             &SemanticVersion{currentVersion: latest, channel: sv.channel, tagPattern: sv.tagPattern},
+		SkippedPrereleases: skipped,
 	}
-	return nil
+	currentSemver, _ := sv.tagPattern.version(sv.currentVersion)
+	switch semver.Compare(latestSemver, currentSemver) {
+	case 1:
+		result.Outcome = OutcomeUpgradeAvailable
+		result.Upgrade = result.Latest
+	case -1:
+		result.Outcome = OutcomeAhead
+	default:
+		result.Outcome = OutcomeUpToDate
+	}
+	return result
 }
 
-func (sv *SemanticVersion) Upgrade(pluginDir string) error {
-	_, err := RunGitCommand(pluginDir, "checkout", "-f", sv.GitRef())
-	return err
+func (sv *SemanticVersion) Upgrade(ctx context.Context, pluginDir string) error {
+	// Checks with the github API do not fetch the new tag.
+	if !HasCommit(ctx, pluginDir, sv.GitRef()) {
+		if err := FetchTags(ctx, pluginDir); err != nil {
+			return err
+		}
+	}
+	return Checkout(ctx, pluginDir, sv.GitRef(), true)
+}
+
+// Returns the release in tags with the highest version the channel allows,
+// that version, and how many newer pre-releases the channel does not
+// allow. current is allowed whatever the channel.
+func latestTag(tags []string, current string, channel Channel, tagPattern *TagPattern) (string, string, int) {
+	channel = cmp.Or(channel, ChannelStable)
+	byVersion := make(map[string]string)
+	disallowed := make([]string, 0)
+	for _, tag := range tags {
+		version, ok := tagPattern.version(tag)
+		switch {
+		case !ok:
+		case tag == current || channel.allows(version):
+			byVersion[version] = tag
+		default:
+			disallowed = append(disallowed, version)
+		}
+	}
+	latest := maxVersion(slices.Sorted(maps.Keys(byVersion)))
+
+	skipped := 0
+	for _, version := range disallowed {
+		if semver.Compare(version, latest) > 0 {
+			skipped++
+		}
+	}
+	return byVersion[latest], latest, skipped
 }
 
 // Finds the maximum semver in the given slice of versions.
 // Returns an empty string if no valid versions are present in the slice.
 func maxVersion(versions []string) string {
+	versions = slices.DeleteFunc(slices.Clone(versions), func(v string) bool {
+		return !semver.IsValid(v)
+	})
 	if len(versions) == 0 {
 		return ""
 	}
@@ -171,33 +241,56 @@ type GitVersion struct {
 	latestHash string
 }
 
-func (gv *GitVersion) HasUpgrade() (bool, Version) {
-	if gv.currentHash != "" && gv.latestHash != "" && gv.currentHash != gv.latestHash {
-		return true, &GitVersion{
-			currentHash: gv.latestHash,
-			branch:      gv.branch,
-		}
+// Checks to see if the upstream branch has moved past the current hash.
+func (gv *GitVersion) Check(ctx context.Context, pluginDir string) CheckResult {
+	if err := FetchTags(ctx, pluginDir); err != nil {
+		return checkFailed(ErrorClassNetwork, err)
 	}
-	return false, nil
-}
 
-func (gv *GitVersion) Check(pluginDir string) error {
-	_, err := RunGitCommand(pluginDir, "fetch", "-t")
+	latestHash, err := Git.Upstream(ctx, pluginDir)
 	if err != nil {
-		return err
+		return checkFailed(ErrorClassGit, err)
 	}
+	return gv.compareHash(latestHash)
+}
 
-	gv.latestHash, err = GetRef(pluginDir, "--verify", "@{u}")
-	return err
+// Compares the current commit with latestHash, the latest commit of the branch.
+func (gv *GitVersion) compareHash(latestHash string) CheckResult {
+	gv.latestHash = latestHash
+	latest := &GitVersion{
+		currentHash: gv.latestHash,
+		branch:      gv.branch,
+	}
+	result := CheckResult{
+		Latest:  latest,
+		Outcome: OutcomeUpToDate,
+	}
+	if gv.currentHash == "" {
+		// Without a recorded hash there is nothing to compare against.
+		result.Outcome = OutcomeUnknown
+	} else if gv.currentHash != gv.latestHash {
+		result.Outcome = OutcomeUpgradeAvailable
+		result.Upgrade = latest
+	}
+	return result
 }
 
-func (sv *GitVersion) Upgrade(pluginDir string) error {
-	if _, err := RunGitCommand(pluginDir, "checkout", "-f", sv.GitRef()); err != nil {
+func (sv *GitVersion) Upgrade(ctx context.Context, pluginDir string) error {
+	// Checks with the github API do not fetch the new commit.
+	if sv.currentHash != "" && !HasCommit(ctx, pluginDir, sv.currentHash) {
+		if err := FetchTags(ctx, pluginDir); err != nil {
+			return err
+		}
+	}
+	// The branch can't be moved while it is checked out.
+	upstream := "origin/" + sv.branch
+	if err := Checkout(ctx, pluginDir, upstream, true); err != nil {
 		return err
 	}
-
-	_, err := RunGitCommand(pluginDir, "branch", "-f", sv.branch, "origin/"+sv.branch)
-	return err
+	if err := Git.SetBranch(ctx, pluginDir, sv.branch, upstream); err != nil {
+		return err
+	}
+	return Checkout(ctx, pluginDir, sv.GitRef(), true)
 }
 
 func (gv *GitVersion) String() string {
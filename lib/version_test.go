@@ -16,9 +16,26 @@ limitations under the License.
 package lib
 
 import (
+	"context"
 	"testing"
+
+	"github.com/kjnsn/tim/lib/gittest"
 )
 
+// A GitClient serving a fixed set of tags, without a repository.
+type fakeGitClient struct {
+	GitClient
+	tags []string
+}
+
+func (c fakeGitClient) Fetch(ctx context.Context, dir string) error {
+	return nil
+}
+
+func (c fakeGitClient) Tags(ctx context.Context, dir string) ([]string, error) {
+	return c.tags, nil
+}
+
 func TestMaxVersion(t *testing.T) {
 	got := maxVersion([]string{"", "  ", "not a version"})
 	if got != "" {
@@ -31,3 +48,191 @@ func TestMaxVersion(t *testing.T) {
 			"maxVersion({\"v1\", \"v0.3\", \"v1.2.5\", \"   \"}) = %v; want v1.2.5", got)
 	}
 }
+
+func TestSemanticVersionCheckChannel(t *testing.T) {
+	defer func(client GitClient) { Git = client }(Git)
+	Git = fakeGitClient{tags: []string{"v1.0.0", "v1.1.0-alpha", "v1.1.0-beta.2", "v1.1.0-rc1", "v1.2.0-beta"}}
+
+	tests := []struct {
+		current string
+		channel Channel
+		want    string
+	}{
+		{"v1.0.0", "", "v1.0.0"},
+		{"v1.0.0", ChannelStable, "v1.0.0"},
+		{"v1.0.0", ChannelRC, "v1.1.0-rc1"},
+		{"v1.0.0", ChannelBeta, "v1.2.0-beta"},
+		{"v1.0.0", ChannelAny, "v1.2.0-beta"},
+		// The current version is allowed on any channel.
+		{"v1.1.0-alpha", ChannelStable, "v1.1.0-alpha"},
+	}
+	for _, test := range tests {
+		version := &SemanticVersion{currentVersion: test.current, channel: test.channel}
+		result := version.Check(context.Background(), "")
+		if result.Latest.String() != test.want {
+			t.Errorf("SemanticVersion{%s, %q}.Check() latest = %v; want %v",
+				test.current, test.channel, result.Latest, test.want)
+		}
+	}
+}
+
+func TestParseChannel(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Channel
+		wantErr bool
+	}{
+		{"", ChannelStable, false},
+		{"rc", ChannelRC, false},
+		{"any", ChannelAny, false},
+		{"nightly", "", true},
+	}
+	for _, test := range tests {
+		got, err := ParseChannel(test.name)
+		if got != test.want || (err != nil) != test.wantErr {
+			t.Errorf("ParseChannel(%q) = %q, %v; want %q, error %t", test.name, got, err, test.want, test.wantErr)
+		}
+	}
+}
+
+func TestSemanticVersionCheck(t *testing.T) {
+	defer func(client GitClient) { Git = client }(Git)
+	Git = fakeGitClient{tags: []string{"v1.0.0", "v1.2.0", "latest", "v1.1.0"}}
+
+	tests := []struct {
+		current string
+		want    CheckOutcome
+	}{
+		{"v1.0.0", OutcomeUpgradeAvailable},
+		{"v1.2.0", OutcomeUpToDate},
+		{"v2.0.0", OutcomeAhead},
+	}
+	for _, test := range tests {
+		version := &SemanticVersion{currentVersion: test.current}
+		result := version.Check(context.Background(), "")
+		if result.Outcome != test.want {
+			t.Errorf("SemanticVersion{%s}.Check() outcome = %v; want %v", test.current, result.Outcome, test.want)
+		}
+		if result.Latest.String() != "v1.2.0" {
+			t.Errorf("SemanticVersion{%s}.Check() latest = %v; want v1.2.0", test.current, result.Latest)
+		}
+	}
+}
+
+func TestSemanticVersionCheckSkipsPrereleases(t *testing.T) {
+	defer func(client GitClient) { Git = client }(Git)
+	Git = fakeGitClient{tags: []string{"v1.0.0", "v1.1.0", "v1.1.0-rc.1", "v1.2.0-rc.1", "v1.2.0-rc.2"}}
+
+	// v1.1.0-rc.1 is older than v1.1.0, so is not worth mentioning.
+	result := (&SemanticVersion{currentVersion: "v1.0.0"}).Check(context.Background(), "")
+	if result.Upgrade.String() != "v1.1.0" || result.SkippedPrereleases != 2 {
+		t.Errorf("Check() on the stable channel = %v, %d skipped; want v1.1.0, 2 skipped", result.Upgrade, result.SkippedPrereleases)
+	}
+	if want := "upgrade available: v1.1.0 (2 pre-release versions skipped)"; result.Reason() != want {
+		t.Errorf("Reason() = %q; want %q", result.Reason(), want)
+	}
+
+	result = (&SemanticVersion{currentVersion: "v1.0.0", channel: ChannelRC}).Check(context.Background(), "")
+	if result.Upgrade.String() != "v1.2.0-rc.2" || result.SkippedPrereleases != 0 {
+		t.Errorf("Check() on the rc channel = %v, %d skipped; want v1.2.0-rc.2, none skipped", result.Upgrade, result.SkippedPrereleases)
+	}
+}
+
+// Clones remote into a temporary directory, as installing a plugin does.
+func cloneFixture(t *testing.T, remote *gittest.Repo) string {
+	t.Helper()
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	pluginDir := t.TempDir()
+	if err := Clone(context.Background(), pluginDir, remote.Dir); err != nil {
+		t.Fatal(err)
+	}
+	return pluginDir
+}
+
+func TestFindBestVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    []string
+		channel Channel
+		want    string
+	}{
+		{"highest release", []string{"v1.0.0", "v1.2.0", "v1.1.0"}, "", "v1.2.0"},
+		{"skips pre-releases", []string{"v1.0.0", "v2.0.0-rc.1"}, "", "v1.0.0"},
+		{"rc channel", []string{"v1.0.0", "v2.0.0-rc.1"}, ChannelRC, "v2.0.0-rc.1"},
+		{"no releases", []string{"latest"}, "", "main"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			remote := gittest.New(t)
+			for _, tag := range test.tags {
+				remote.Release(tag)
+			}
+			pluginDir := cloneFixture(t, remote)
+
+			version, err := FindBestVersion(context.Background(), pluginDir, test.channel, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version.GitRef() != test.want {
+				t.Errorf("FindBestVersion() = %v; want %s", version, test.want)
+			}
+		})
+	}
+}
+
+func TestSemanticVersionUpgrade(t *testing.T) {
+	ctx := context.Background()
+	remote := gittest.New(t)
+	remote.Release("v1.0.0")
+	pluginDir := cloneFixture(t, remote)
+	version := &SemanticVersion{currentVersion: "v1.0.0"}
+	if err := Checkout(ctx, pluginDir, version.GitRef(), false); err != nil {
+		t.Fatal(err)
+	}
+
+	if result := version.Check(ctx, pluginDir); result.Outcome != OutcomeUpToDate {
+		t.Errorf("Check() before a release = %v; want %v", result.Outcome, OutcomeUpToDate)
+	}
+
+	released := remote.Release("v1.1.0")
+	result := version.Check(ctx, pluginDir)
+	if result.Outcome != OutcomeUpgradeAvailable || result.Upgrade.GitRef() != "v1.1.0" {
+		t.Fatalf("Check() after a release = %v, %v; want an upgrade to v1.1.0", result.Outcome, result.Upgrade)
+	}
+	if err := result.Upgrade.Upgrade(ctx, pluginDir); err != nil {
+		t.Fatal(err)
+	}
+	if head, _ := Git.ResolveCommit(ctx, pluginDir, "HEAD"); head != released {
+		t.Errorf("HEAD after Upgrade() = %s; want %s", head, released)
+	}
+}
+
+func TestGitVersionUpgrade(t *testing.T) {
+	ctx := context.Background()
+	remote := gittest.New(t)
+	remote.Advance()
+	pluginDir := cloneFixture(t, remote)
+	version, err := FindBestVersion(ctx, pluginDir, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Checkout(ctx, pluginDir, version.GitRef(), false); err != nil {
+		t.Fatal(err)
+	}
+
+	if result := version.Check(ctx, pluginDir); result.Outcome != OutcomeUpToDate {
+		t.Errorf("Check() before upstream moved = %v; want %v", result.Outcome, OutcomeUpToDate)
+	}
+
+	moved := remote.Advance()
+	result := version.Check(ctx, pluginDir)
+	if result.Outcome != OutcomeUpgradeAvailable {
+		t.Fatalf("Check() after upstream moved = %v; want %v", result.Outcome, OutcomeUpgradeAvailable)
+	}
+	if err := result.Upgrade.Upgrade(ctx, pluginDir); err != nil {
+		t.Fatal(err)
+	}
+	if head, _ := Git.ResolveCommit(ctx, pluginDir, "HEAD"); head != moved {
+		t.Errorf("HEAD after Upgrade() = %s; want %s", head, moved)
+	}
+}
@@ -0,0 +1,162 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/kjnsn/tim/lib/message"
+	"golang.org/x/mod/semver"
+)
+
+var ErrUnknownVersion = errors.New("version not found")
+
+// The most versions suggested when one is not found.
+const maxSuggestions = 5
+
+var commitHashRegexp = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// Checks that ref is a tag or branch of remote, without cloning it, so a
+// mistyped version fails quickly. If not, the error suggests the closest
+// versions that are. Commit hashes are not checked, as remotes do not
+// list them.
+func checkRemoteRef(ctx context.Context, remote, ref string) error {
+	if commitHashRegexp.MatchString(ref) {
+		return nil
+	}
+
+	refs, err := Git.RemoteRefs(ctx, remote)
+	if err != nil {
+		// Cloning explains problems with the remote better.
+		message.Debug("Unable to list the versions of %s: %s", remote, err)
+		return nil
+	}
+	if slices.Contains(refs, ref) {
+		return nil
+	}
+
+	if suggestions := suggestRefs(ref, refs); len(suggestions) > 0 {
+		return fmt.Errorf("%w: %s has no tag or branch %s, did you mean %s?",
+			ErrUnknownVersion, remote, ref, strings.Join(suggestions, ", "))
+	}
+	if latest := latestVersions(refs); len(latest) > 0 {
+		return fmt.Errorf("%w: %s has no tag or branch %s, the latest versions are %s",
+			ErrUnknownVersion, remote, ref, strings.Join(latest, ", "))
+	}
+	return fmt.Errorf("%w: %s has no tag or branch %s", ErrUnknownVersion, remote, ref)
+}
+
+// Returns the refs closest to ref: those it starts with or that start
+// with it, then those a few edits away.
+func suggestRefs(ref string, refs []string) []string {
+	type suggestion struct {
+		name     string
+		prefix   bool
+		distance int
+	}
+
+	suggestions := make([]suggestion, 0)
+	for _, name := range refs {
+		prefix := strings.HasPrefix(name, ref) || strings.HasPrefix(ref, name)
+		distance := editDistance(ref, name)
+		if prefix || distance <= max(2, len(ref)/3) {
+			suggestions = append(suggestions, suggestion{name, prefix, distance})
+		}
+	}
+	slices.SortFunc(suggestions, func(a, b suggestion) int {
+		if a.prefix != b.prefix {
+			if a.prefix {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Or(cmp.Compare(a.distance, b.distance), strings.Compare(a.name, b.name))
+	})
+
+	names := make([]string, 0, maxSuggestions)
+	for _, suggestion := range suggestions[:min(len(suggestions), maxSuggestions)] {
+		names = append(names, suggestion.name)
+	}
+	return names
+}
+
+// Returns the highest semantic versions in refs, newest first.
+func latestVersions(refs []string) []string {
+	versions := slices.DeleteFunc(slices.Clone(refs), func(ref string) bool {
+		return !semver.IsValid(ref)
+	})
+	slices.SortFunc(versions, func(a, b string) int {
+		return semver.Compare(b, a)
+	})
+	return versions[:min(len(versions), maxSuggestions)]
+}
+
+// Returns the number of single character insertions, deletions or
+// substitutions needed to turn a into b.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			substitution := previous[j-1]
+			if a[i-1] != b[j-1] {
+				substitution++
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, substitution)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// Checks that spec could be a version of the plugin, so a mistyped
+// version is not written to the config file: it must be a valid git
+// ref name, and a tag or branch of the remote if the remote can be
+// listed.
+func (p *Plugin) CheckVersionSpec(ctx context.Context, spec string) error {
+	if !validRefName(spec) {
+		return fmt.Errorf("%w: %q is not a valid tag or branch name", ErrUnknownVersion, spec)
+	}
+	return checkRemoteRef(ctx, p.RemoteURL(), versionFromSpec(spec, p.Channel, p.tagPattern()).GitRef())
+}
+
+// Reports whether name follows git's rules for ref names, as checked by
+// "git check-ref-format --allow-onelevel".
+func validRefName(name string) bool {
+	if name == "" || name == "@" || strings.HasPrefix(name, "-") ||
+		strings.HasSuffix(name, "/") || strings.HasSuffix(name, ".") ||
+		strings.Contains(name, "..") || strings.Contains(name, "@{") || strings.Contains(name, "//") {
+		return false
+	}
+	for _, component := range strings.Split(name, "/") {
+		if strings.HasPrefix(component, ".") || strings.HasSuffix(component, ".lock") {
+			return false
+		}
+	}
+	return !strings.ContainsFunc(name, func(r rune) bool {
+		return r < 0x20 || r == 0x7f || strings.ContainsRune(" ~^:?*[\\", r)
+	})
+}
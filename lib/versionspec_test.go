@@ -0,0 +1,84 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSuggestRefs(t *testing.T) {
+	refs := []string{"main", "v1.0.0", "v1.1", "v1.2", "v1.2.4", "v2.0.0", "develop"}
+	tests := []struct {
+		ref  string
+		want []string
+	}{
+		{"v1.2.3", []string{"v1.2", "v1.2.4", "v1.0.0"}},
+		{"v1.1.0", []string{"v1.1", "v1.0.0", "v1.2.4", "v2.0.0"}},
+		{"mian", []string{"main"}},
+		{"feature/x", []string{}},
+	}
+
+	for _, test := range tests {
+		if got := suggestRefs(test.ref, refs); !slices.Equal(got, test.want) {
+			t.Errorf("suggestRefs(%q) = %v; want %v", test.ref, got, test.want)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"v1.2.3", "v1.2.3", 0},
+		{"v1.2.3", "v1.2", 2},
+		{"mian", "main", 2},
+		{"", "main", 4},
+	}
+
+	for _, test := range tests {
+		if got := editDistance(test.a, test.b); got != test.want {
+			t.Errorf("editDistance(%q, %q) = %d; want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestValidRefName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"v1.2.3", true},
+		{"main", true},
+		{"feature/x", true},
+		{"", false},
+		{"-v1", false},
+		{"v1..2", false},
+		{"has space", false},
+		{"v1^", false},
+		{"main.lock", false},
+		{"feature/.x", false},
+		{"feature/", false},
+	}
+
+	for _, test := range tests {
+		if got := validRefName(test.name); got != test.want {
+			t.Errorf("validRefName(%q) = %v; want %v", test.name, got, test.want)
+		}
+	}
+}
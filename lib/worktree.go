@@ -0,0 +1,145 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The state of a repository's working tree, see GitClient.Status.
+type WorktreeStatus struct {
+	// The checked out branch, empty if HEAD is detached.
+	Branch string `json:"branch,omitempty"`
+
+	// The commit checked out.
+	Commit string `json:"commit"`
+
+	// Files with uncommitted changes, and files git does not track.
+	Modified  []string `json:"modified,omitempty"`
+	Untracked []string `json:"untracked,omitempty"`
+
+	// Commits on the branch that are not on its upstream, and the other
+	// way around. Both are zero if there is no upstream.
+	Ahead  int `json:"ahead,omitempty"`
+	Behind int `json:"behind,omitempty"`
+}
+
+// Parses the output of "git status --porcelain=v2 --branch -z".
+func parseStatus(out string) (WorktreeStatus, error) {
+	status := WorktreeStatus{}
+	entries := strings.Split(out, "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if header, ok := strings.CutPrefix(entry, "# "); ok {
+			key, value, _ := strings.Cut(header, " ")
+			switch key {
+			case "branch.oid":
+				status.Commit = value
+			case "branch.head":
+				if value != "(detached)" {
+					status.Branch = value
+				}
+			case "branch.ab":
+				if _, err := fmt.Sscanf(value, "+%d -%d", &status.Ahead, &status.Behind); err != nil {
+					return WorktreeStatus{}, fmt.Errorf("unexpected git status %q: %w", entry, err)
+				}
+			}
+			continue
+		}
+
+		kind, _, _ := strings.Cut(entry, " ")
+		switch kind {
+		case "1":
+			status.Modified = append(status.Modified, statusPath(entry, 8))
+		case "2":
+			status.Modified = append(status.Modified, statusPath(entry, 9))
+			// Followed by the path it was renamed from.
+			i++
+		case "u":
+			status.Modified = append(status.Modified, statusPath(entry, 10))
+		case "?":
+			status.Untracked = append(status.Untracked, entry[2:])
+		}
+	}
+	return status, nil
+}
+
+// Returns the path of a git status entry, which follows fields fields.
+func statusPath(entry string, fields int) string {
+	parts := strings.SplitN(entry, " ", fields+1)
+	return parts[len(parts)-1]
+}
+
+// The state of an installed plugin's working tree, and the commit it
+// should have checked out.
+type PluginStatus struct {
+	WorktreeStatus
+
+	// The commit recorded in tim.lock, empty if it has none.
+	LockedCommit string `json:"locked_commit,omitempty"`
+}
+
+// Returns the state of the plugin's working tree, which must be
+// installed, compared with locked.
+func (p *Plugin) Status(ctx context.Context, locked LockedPlugin) (PluginStatus, error) {
+	pluginDir, err := p.RepoDir()
+	if err != nil {
+		return PluginStatus{}, err
+	}
+	worktree, err := Git.Status(ctx, pluginDir)
+	if err != nil {
+		return PluginStatus{}, err
+	}
+	return PluginStatus{WorktreeStatus: worktree, LockedCommit: locked.Commit}, nil
+}
+
+// Describes each change to the plugin that upgrading might overwrite,
+// such as local modifications. Empty if there are none.
+func (s PluginStatus) Problems() []string {
+	problems := make([]string, 0)
+	if len(s.Modified) > 0 {
+		problems = append(problems, countOf(len(s.Modified), "modified file"))
+	}
+	if len(s.Untracked) > 0 {
+		problems = append(problems, countOf(len(s.Untracked), "untracked file"))
+	}
+	if s.LockedCommit != "" && s.Commit != s.LockedCommit {
+		at := "detached at"
+		if s.Branch != "" {
+			at = "on " + s.Branch + " at"
+		}
+		problems = append(problems, fmt.Sprintf("%s %.10s, not %.10s from tim.lock", at, s.Commit, s.LockedCommit))
+	}
+	switch {
+	case s.Ahead > 0 && s.Behind > 0:
+		problems = append(problems, fmt.Sprintf("diverged from upstream, with %s and %d upstream",
+			countOf(s.Ahead, "local commit"), s.Behind))
+	case s.Ahead > 0:
+		problems = append(problems, countOf(s.Ahead, "local commit")+" not upstream")
+	}
+	return problems
+}
+
+// Formats a count of things, such as "1 file" or "2 files".
+func countOf(count int, thing string) string {
+	if count == 1 {
+		return "1 " + thing
+	}
+	return strconv.Itoa(count) + " " + thing + "s"
+}
@@ -0,0 +1,114 @@
+/*
+Copyright © 2024 Kaley Main <kaleymain@google.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package lib
+
+import (
+	"context"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kjnsn/tim/lib/gittest"
+)
+
+func TestParseStatus(t *testing.T) {
+	out := strings.Join([]string{
+		"# branch.oid 1234567890abcdef",
+		"# branch.head main",
+		"# branch.upstream origin/main",
+		"# branch.ab +2 -1",
+		"1 .M N... 100644 100644 100644 aaaa aaaa plugin.tmux",
+		"2 R. N... 100644 100644 100644 aaaa aaaa R100 scripts/new name.sh",
+		"scripts/old.sh",
+		"? notes.txt",
+	}, "\x00")
+	want := WorktreeStatus{
+		Branch:    "main",
+		Commit:    "1234567890abcdef",
+		Modified:  []string{"plugin.tmux", "scripts/new name.sh"},
+		Untracked: []string{"notes.txt"},
+		Ahead:     2,
+		Behind:    1,
+	}
+	got, err := parseStatus(out)
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("parseStatus() = %+v, %v; want %+v", got, err, want)
+	}
+
+	detached, err := parseStatus("# branch.oid abcd\x00# branch.head (detached)")
+	if err != nil || detached.Branch != "" {
+		t.Errorf("parseStatus(detached) = %+v, %v; want no branch", detached, err)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	defer func() { Git = execGitClient{} }()
+
+	remote := gittest.New(t)
+	remote.Commit("first", map[string]string{"plugin.tmux": "#!/bin/sh\n"})
+	clone := remote.Clone(t.TempDir())
+	remote.Commit("upstream", nil)
+	clone.Git("fetch", "-q")
+	clone.Commit("local", nil)
+	if err := os.WriteFile(path.Join(clone.Dir, "plugin.tmux"), []byte("#!/bin/sh\necho hacked\n"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path.Join(clone.Dir, "notes.txt"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, backend := range []GitClient{execGitClient{}, goGitClient{}} {
+		Git = backend
+		got, err := Git.Status(context.Background(), clone.Dir)
+		if err != nil {
+			t.Fatalf("%T: Status() = %v", backend, err)
+		}
+		if got.Branch != "main" || got.Ahead != 1 || got.Behind != 1 || len(got.Commit) != 40 {
+			t.Errorf("%T: Status() = %+v; want main, 1 ahead and 1 behind", backend, got)
+		}
+		if !reflect.DeepEqual(got.Modified, []string{"plugin.tmux"}) || !reflect.DeepEqual(got.Untracked, []string{"notes.txt"}) {
+			t.Errorf("%T: Status() files = %v, %v; want [plugin.tmux], [notes.txt]", backend, got.Modified, got.Untracked)
+		}
+	}
+}
+
+func TestPluginStatusProblems(t *testing.T) {
+	tests := []struct {
+		status PluginStatus
+		want   []string
+	}{
+		{PluginStatus{WorktreeStatus: WorktreeStatus{Commit: "abc"}, LockedCommit: "abc"}, []string{}},
+		{
+			PluginStatus{WorktreeStatus: WorktreeStatus{Commit: "abc", Modified: []string{"a"}, Untracked: []string{"b", "c"}}},
+			[]string{"1 modified file", "2 untracked files"},
+		},
+		{
+			PluginStatus{WorktreeStatus: WorktreeStatus{Commit: "abc"}, LockedCommit: "def"},
+			[]string{"detached at abc, not def from tim.lock"},
+		},
+		{
+			PluginStatus{WorktreeStatus: WorktreeStatus{Branch: "main", Commit: "abc", Ahead: 2, Behind: 1}, LockedCommit: "abc"},
+			[]string{"diverged from upstream, with 2 local commits and 1 upstream"},
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.status.Problems(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Problems(%+v) = %q; want %q", tt.status, got, tt.want)
+		}
+	}
+}
@@ -15,10 +15,19 @@ limitations under the License.
 */
 package main
 
-import "github.com/kjnsn/tim/cmd"
+import (
+	"github.com/kjnsn/tim/cmd"
+	"github.com/kjnsn/tim/lib"
+)
 
-const Version = "v1.0.0"
+// Build metadata, set at build time with
+// -ldflags "-X main.Version=... -X main.commit=... -X main.date=..."
+var (
+	Version = "v1.0.0"
+	commit  = ""
+	date    = ""
+)
 
 func main() {
-	cmd.Execute(Version)
+	cmd.Execute(lib.NewBuildInfo(Version, commit, date))
 }
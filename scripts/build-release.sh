@@ -0,0 +1,17 @@
+#!/bin/sh
+# Builds tim exactly as it is published in a release, so anyone can check
+# a release binary by building it themselves from the tagged commit.
+#
+# Usage: scripts/build-release.sh <version> <goos> <goarch> <output>
+#
+# Use the go version in go.mod, for example with GOTOOLCHAIN=go1.23.1.
+set -eu
+
+version=$1
+commit=$(git rev-parse HEAD)
+# The commit time, rather than the time of the build, keeps it reproducible.
+date=$(git log -1 --format=%cI)
+
+CGO_ENABLED=0 GOOS=$2 GOARCH=$3 go build -trimpath \
+	-ldflags "-s -w -buildid= -X main.Version=$version -X main.commit=$commit -X main.date=$date" \
+	-o "$4" .